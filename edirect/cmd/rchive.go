@@ -64,6 +64,9 @@ func main() {
 	// skip past executable name
 	args := os.Args[1:]
 
+	// replace @@path and --args path references with tokens read from file
+	args = eutils.ExpandArgumentFiles(args)
+
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\nERROR: No command-line arguments supplied to rchive\n")
 		os.Exit(1)
@@ -122,6 +125,9 @@ func main() {
 	indicesPath := ""
 	incrementPath := ""
 
+	// reindex regardless of stored archive hashes
+	frce := false
+
 	// flag for indexed input file
 	turbo := false
 
@@ -131,6 +137,10 @@ func main() {
 	stts := false
 	timr := false
 
+	// periodic status line, seconds between reports, and expected record count for ETA
+	prog := 0
+	totl := 0
+
 	// profiling
 	prfl := false
 
@@ -151,6 +161,14 @@ func main() {
 	ftch := ""
 	strm := ""
 
+	// address and request limit for read-only archive server
+	srvr := ""
+	mxrq := 0
+
+	// report and placeholders for identifiers missing from the archive
+	rprt := ""
+	plch := false
+
 	// path for local extra link data
 	smmn := ""
 
@@ -165,6 +183,17 @@ func main() {
 
 	// destination directory for merging and splitting inverted files
 	merg := ""
+
+	// link field to invert into a sibling merged directory, e.g., "CITES,CITED"
+	rcpr := ""
+
+	// join separately merged inverted index directories
+	e2jn := ""
+
+	// archive integrity manifest and verification
+	mnft := ""
+	vrfy := ""
+	mnfl := ""
 	isLink := false
 
 	// base destination directory for promoting inverted index to retrieval indices
@@ -189,6 +218,15 @@ func main() {
 	plrl := false
 	psns := false
 
+	// maximum number of wildcard expansions for truncated phrases
+	lmit := 0
+
+	// taxonomy archive, taxid field, and limits for [TAXTREE] expansion
+	taxm := ""
+	taxf := ""
+	taxd := 0
+	taxr := ""
+
 	ttls := ""
 	key := ""
 	field := ""
@@ -278,6 +316,13 @@ func main() {
 		case "-input":
 			fileName = eutils.GetStringArg(args, "Input file name")
 			args = args[1:]
+		// file of identifiers for -fetch
+		case "-ids":
+			fileName = eutils.GetStringArg(args, "Identifier file name")
+			args = args[1:]
+		// print placeholder record for identifiers not found by -fetch
+		case "-placeholder":
+			plch = true
 
 		// path to local archive and index folders for incremental updating of cached index components
 		case "-e2incIndex":
@@ -286,6 +331,9 @@ func main() {
 			indicesPath = eutils.GetStringArg(args, "Path to local indices")
 			args = args[1:]
 			// should be followed by -transform meshtree.txt -e2index
+		// reindex all archive folders, ignoring stored hashes, or repromote completed prefixes
+		case "-force":
+			frce = true
 
 		// path to local index folder for incremental updating of cached inverted index components
 		case "-e2incInvert":
@@ -358,6 +406,13 @@ func main() {
 				ftch += "/"
 			}
 			args = args[1:]
+		// serve -fetch archive over HTTP
+		case "-serve":
+			srvr = eutils.GetStringArg(args, "Server address")
+			args = args[1:]
+		case "-max-requests":
+			mxrq = eutils.GetNumericArg(args, "Concurrent request limit", 0, 1, 1000)
+			args = args[1:]
 		// local directory path for retrieval of compressed XML
 		case "-stream":
 			strm = eutils.GetStringArg(args, "Stream path")
@@ -387,6 +442,11 @@ func main() {
 		case "-fuse":
 			fuse = true
 
+		// -mergelink option writes TO->FROM links alongside FROM->TO links
+		case "-reciprocal":
+			rcpr = eutils.GetStringArg(args, "Reciprocal link fields")
+			args = args[1:]
+
 		case "-mergelink":
 			isLink = true
 			fallthrough
@@ -395,6 +455,25 @@ func main() {
 			merg = eutils.GetStringArg(args, "Merge field")
 			args = args[1:]
 
+		// print identifiers and hash values for all records in archive
+		case "-manifest":
+			mnft = eutils.GetStringArg(args, "Manifest archive path")
+			args = args[1:]
+		// compare archive records against manifest
+		case "-verify":
+			if len(args) < 3 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Archive path and manifest file needed\n")
+				os.Exit(1)
+			}
+			vrfy = args[1]
+			mnfl = args[2]
+			args = args[2:]
+
+		// combine merged inverted index directories, e.g., TIAB and CHEM runs
+		case "-e2join":
+			e2jn = eutils.GetStringArg(args, "Joined index path")
+			args = args[1:]
+
 		case "-promotelink":
 			isLink = true
 			fallthrough
@@ -471,6 +550,24 @@ func main() {
 			trms = eutils.GetStringArg(args, "Count argument")
 			args = args[1:]
 
+		case "-limit":
+			lmit = eutils.GetNumericArg(args, "Wildcard expansion limit", 0, 1, 1000000)
+			args = args[1:]
+
+		// [TAXTREE] expansion of taxid or name to all descendant taxids
+		case "-taxonomy":
+			taxm = eutils.GetStringArg(args, "Taxonomy archive path")
+			args = args[1:]
+		case "-taxfield":
+			taxf = eutils.GetStringArg(args, "Taxid postings field")
+			args = args[1:]
+		case "-taxdepth":
+			taxd = eutils.GetNumericArg(args, "Taxonomy expansion depth", 0, 1, 0)
+			args = args[1:]
+		case "-taxrank":
+			taxr = eutils.GetStringArg(args, "Taxonomy expansion rank")
+			args = args[1:]
+
 		case "-totals":
 			if len(args) < 4 {
 				fmt.Fprintf(os.Stderr, "\nERROR: Path, key, or field is missing\n")
@@ -514,9 +611,13 @@ func main() {
 			}
 		case "-padz":
 			padz = true
-		// check for missing records
+		// check for missing records, -fetch can write identifiers to a report file
 		case "-missing":
 			msng = true
+			if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+				rprt = args[1]
+				args = args[1:]
+			}
 
 		// use non-threaded fetch function for windows (undocumented)
 		case "-windows":
@@ -597,6 +698,20 @@ func main() {
 			stts = true
 		case "-timer":
 			timr = true
+		case "-progress":
+			prog = 10
+			// optional number of seconds between reports
+			if len(args) > 1 {
+				if secs, err := strconv.Atoi(args[1]); err == nil && secs > 0 {
+					prog = secs
+					args = args[1:]
+				}
+			}
+			// final summary is the -timer report
+			timr = true
+		case "-total":
+			totl = eutils.GetNumericArg(args, "Expected record count", 0, 1, 0)
+			args = args[1:]
 		case "-profile":
 			prfl = true
 
@@ -690,6 +805,8 @@ func main() {
 		args = append(args, "-dummy")
 	} else if trei || padz || dmgd || cmpr {
 		args = append(args, "-dummy")
+	} else if mnft != "" || vrfy != "" {
+		args = append(args, "-dummy")
 	}
 
 	// expand -archive ~/ to home directory path
@@ -746,6 +863,16 @@ func main() {
 		}
 	}
 
+	// -serve answers fetch requests over HTTP instead of reading identifiers from stdin
+	if srvr != "" {
+		if ftch == "" {
+			fmt.Fprintf(os.Stderr, "\nERROR: -serve requires -fetch archive path\n")
+			os.Exit(1)
+		}
+		eutils.ServeArchive(srvr, ftch, db, zipp, mxrq)
+		return
+	}
+
 	// expand -stream ~/ to home directory path
 	if strm != "" {
 
@@ -809,7 +936,7 @@ func main() {
 
 	// FILE NAME CAN BE SUPPLIED WITH -input COMMAND
 
-	in := os.Stdin
+	var in io.Reader = os.Stdin
 
 	// check for data being piped into stdin
 	isPipe := false
@@ -869,9 +996,21 @@ func main() {
 	recordCount := 0
 	byteCount := 0
 
+	// report bytes consumed and records processed while running
+	stopProgress := func() {}
+	if prog > 0 {
+		var inSize int64
+		if fl, ok := in.(*os.File); ok {
+			inSize = eutils.InputSize(fl)
+		}
+		in = eutils.CreateProgressReader(in)
+		stopProgress = eutils.StartProgress("records", time.Duration(prog)*time.Second, inSize, int64(totl))
+	}
+
 	// print processing rate and program duration
 	printDuration := func(name string) {
 
+		stopProgress()
 		eutils.PrintDuration(name, recordCount, byteCount)
 	}
 
@@ -909,6 +1048,13 @@ func main() {
 		}
 	}
 
+	// ADDITIONAL -e2index FIELD DEFINITIONS
+
+	if len(args) > 2 && args[0] == "-idxfields" {
+		eutils.LoadIndexFields(args[1])
+		args = args[2:]
+	}
+
 	// SPECIFY STRINGS TO GO BEFORE AND AFTER ENTIRE OUTPUT OR EACH RECORD
 
 	head := ""
@@ -1141,7 +1287,7 @@ func main() {
 			return eutils.CreateXMLConsumers(cmds, "", "<IdxDocument>", "</IdxDocument>", transform, false, nil, inp)
 		}
 
-		e2iq := eutils.IncrementalIndex(archivePath, indicesPath, db, pfx, callConsumers)
+		e2iq := eutils.IncrementalIndex(archivePath, indicesPath, db, pfx, frce, callConsumers)
 		if e2iq == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create indexer channel\n")
 			os.Exit(1)
@@ -1505,6 +1651,37 @@ func main() {
 		mfld := eutils.CreateManifold(chns)
 		mrgr := eutils.CreateMergers(mfld)
 		unsq := eutils.CreateXMLUnshuffler(mrgr)
+
+		var sptrs []<-chan string
+
+		if rcpr != "" {
+
+			// -reciprocal CITES,CITED writes inverted links to a sibling CITED directory
+			flds := strings.FieldsFunc(rcpr, func(c rune) bool { return c == ',' || c == ' ' })
+			if !isLink || len(flds) != 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: -reciprocal requires -mergelink and two link field names, e.g., \"CITES,CITED\"\n")
+				os.Exit(1)
+			}
+
+			rdir := filepath.Join(filepath.Dir(filepath.Clean(merg)), flds[1])
+			err := os.MkdirAll(rdir, os.ModePerm)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create reciprocal link directory '%s'\n", rdir)
+				os.Exit(1)
+			}
+
+			var rcpq <-chan eutils.XMLRecord
+			unsq, rcpq = eutils.CreateReciprocator(flds[0], flds[1], unsq)
+
+			// reciprocal records arrive after all forward records have been split
+			rspt := eutils.CreateSplitter(rdir, zipp, true, rcpq)
+			if rspt == nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create reciprocal link merger\n")
+				os.Exit(1)
+			}
+			sptrs = append(sptrs, rspt)
+		}
+
 		sptr := eutils.CreateSplitter(merg, zipp, isLink, unsq)
 
 		if chns == nil || mfld == nil || mrgr == nil || unsq == nil || sptr == nil {
@@ -1512,42 +1689,46 @@ func main() {
 			os.Exit(1)
 		}
 
+		sptrs = append([]<-chan string{sptr}, sptrs...)
+
 		// drain channel, print two-to-four-character index name
 		startTime := time.Now()
 		first := true
 		col := 0
 		spaces := "       "
 
-		for str := range sptr {
+		for _, sptr := range sptrs {
+			for str := range sptr {
 
-			stopTime := time.Now()
-			duration := stopTime.Sub(startTime)
-			seconds := float64(duration.Nanoseconds()) / 1e9
+				stopTime := time.Now()
+				duration := stopTime.Sub(startTime)
+				seconds := float64(duration.Nanoseconds()) / 1e9
 
-			if timr {
-				if first {
-					first = false
+				if timr {
+					if first {
+						first = false
+					} else {
+						fmt.Fprintf(os.Stdout, "%.3f\n", seconds)
+					}
+					fmt.Fprintf(os.Stdout, "%s\t", str)
 				} else {
-					fmt.Fprintf(os.Stdout, "%.3f\n", seconds)
-				}
-				fmt.Fprintf(os.Stdout, "%s\t", str)
-			} else {
-				blank := 7 - len(str)
-				if blank > 0 {
-					fmt.Fprintf(os.Stdout, "%s", spaces[:blank])
-				}
-				fmt.Fprintf(os.Stdout, "%s", str)
-				col++
-				if col >= 10 {
-					col = 0
-					fmt.Fprintf(os.Stdout, "\n")
+					blank := 7 - len(str)
+					if blank > 0 {
+						fmt.Fprintf(os.Stdout, "%s", spaces[:blank])
+					}
+					fmt.Fprintf(os.Stdout, "%s", str)
+					col++
+					if col >= 10 {
+						col = 0
+						fmt.Fprintf(os.Stdout, "\n")
+					}
 				}
-			}
 
-			recordCount++
-			runtime.Gosched()
+				recordCount++
+				runtime.Gosched()
 
-			startTime = time.Now()
+				startTime = time.Now()
+			}
 		}
 
 		stopTime := time.Now()
@@ -1569,11 +1750,153 @@ func main() {
 		return
 	}
 
+	// JOIN SEPARATELY MERGED INVERTED INDEX DIRECTORIES
+
+	// -e2join output_path merged_dir_1 merged_dir_2 ...
+	if e2jn != "" {
+
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Not enough merged index directories to join\n")
+			os.Exit(1)
+		}
+
+		err := os.MkdirAll(e2jn, os.ModePerm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create joined index directory '%s'\n", e2jn)
+			os.Exit(1)
+		}
+
+		// process one prefix at a time, so only matching files are open
+		for _, files := range eutils.MergedFileGroups(args) {
+
+			chns := eutils.CreatePresenters(files)
+			mfld := eutils.CreateManifold(chns)
+			mrgr := eutils.CreateMergers(mfld)
+			unsq := eutils.CreateXMLUnshuffler(mrgr)
+			vrfy := eutils.CreateOrderVerifier(unsq)
+			sptr := eutils.CreateSplitter(e2jn, zipp, isLink, vrfy)
+
+			if chns == nil || mfld == nil || mrgr == nil || unsq == nil || vrfy == nil || sptr == nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create inverted index joiner\n")
+				os.Exit(1)
+			}
+
+			for str := range sptr {
+				fmt.Fprintf(os.Stdout, "%s\n", str)
+				recordCount++
+				runtime.Gosched()
+			}
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("groups")
+		}
+
+		return
+	}
+
+	// VERIFY ARCHIVE INTEGRITY WITH CRC32 HASH VALUES
+
+	// -manifest archive_path, or -verify archive_path manifest_file
+	if mnft != "" || vrfy != "" {
+
+		base := mnft
+
+		var manifest map[string]string
+
+		if vrfy != "" {
+
+			base = vrfy
+
+			fl, err := os.Open(mnfl)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to open manifest file '%s'\n", mnfl)
+				os.Exit(1)
+			}
+
+			manifest = make(map[string]string)
+
+			// manifest lines are UID and hash, as printed by -manifest or -hash
+			scanr := bufio.NewScanner(fl)
+			for scanr.Scan() {
+				id, hsh := eutils.SplitInTwoLeft(scanr.Text(), "\t")
+				if id == "" || hsh == "" {
+					continue
+				}
+				manifest[id] = hsh
+			}
+
+			fl.Close()
+		}
+
+		walk := eutils.CreateArchiveWalker(base)
+		vrfq := eutils.CreateArchiveVerifiers(db, manifest, walk)
+		unsq := eutils.CreateXMLUnshuffler(vrfq)
+
+		if walk == nil || vrfq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create archive verifier\n")
+			os.Exit(1)
+		}
+
+		seen := make(map[string]bool)
+		problems := 0
+
+		for curr := range unsq {
+
+			recordCount++
+
+			if manifest != nil {
+				seen[curr.Ident] = true
+			}
+
+			if curr.Text == "" {
+				continue
+			}
+
+			os.Stdout.WriteString(curr.Text)
+
+			if manifest != nil {
+				problems++
+			}
+		}
+
+		if manifest != nil {
+
+			var absent []string
+			for id := range manifest {
+				if !seen[id] {
+					absent = append(absent, id)
+				}
+			}
+			sort.Strings(absent)
+
+			for _, id := range absent {
+				fmt.Fprintf(os.Stdout, "MISSING\t%s\n", id)
+				problems++
+			}
+
+			if problems > 0 {
+				fmt.Fprintf(os.Stderr, "\nERROR: %d problems found in %d archived records\n", problems, recordCount)
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(os.Stderr, "%d archived records verified\n", recordCount)
+		}
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
 	// PROMOTE MERGED INVERTED INDEX TO TERM LIST AND POSTINGS FILES
 
 	if prom != "" && fild != "" {
 
-		prmq := eutils.CreatePromoters(prom, fild, isLink, args)
+		prmq := eutils.CreatePromoters(prom, fild, isLink, frce, args)
 
 		if prmq == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create new postings file generator\n")
@@ -1611,6 +1934,16 @@ func main() {
 			printDuration("terms")
 		}
 
+		// compare manifests against postings directories
+		problems := eutils.ValidatePromotion(prom, fild)
+		for _, str := range problems {
+			fmt.Fprintf(os.Stderr, "%s\n", str)
+		}
+		if len(problems) > 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: %d problems found in promoted postings files\n", len(problems))
+			os.Exit(1)
+		}
+
 		return
 	}
 
@@ -1633,6 +1966,10 @@ func main() {
 		}
 	}
 
+	if taxm != "" || taxf != "" || taxd > 0 || taxr != "" {
+		eutils.SetTaxonomyExpansion(taxm, taxf, taxd, taxr)
+	}
+
 	if base != "" && btch {
 
 		// read query lines for exact match
@@ -1688,7 +2025,7 @@ func main() {
 	if base != "" && trms != "" {
 
 		// deStop should match value used in building the indices
-		recordCount = eutils.ProcessCount(base, db, trms, plrl, psns, rlxd, deStop)
+		recordCount = eutils.ProcessCount(base, db, trms, plrl, psns, rlxd, deStop, lmit)
 
 		debug.FreeOSMemory()
 
@@ -2056,6 +2393,31 @@ func main() {
 
 		retlength := len("\n")
 
+		var rpt *os.File
+		if rprt != "" {
+			var err error
+			rpt, err = os.Create(rprt)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create missing identifier file '%s'\n", rprt)
+				os.Exit(1)
+			}
+			defer rpt.Close()
+		}
+
+		missing := 0
+
+		// placeholder keeps output aligned with identifier list
+		placeholder := func(id string) string {
+			switch db {
+			case "pmc":
+				return "<PMCExtract status=\"missing\"><PMCID>PMC" + id + "</PMCID></PMCExtract>"
+			case "taxonomy":
+				return "<TaxNode status=\"missing\"><TaxID>" + id + "</TaxID></TaxNode>"
+			default:
+			}
+			return "<PubmedArticle status=\"missing\"><PMID>" + id + "</PMID></PubmedArticle>"
+		}
+
 		if head != "" {
 			os.Stdout.WriteString(head)
 			os.Stdout.WriteString("\n")
@@ -2067,7 +2429,18 @@ func main() {
 			str := curr.Text
 
 			if str == "" {
-				continue
+				if curr.Ident == "" {
+					continue
+				}
+				missing++
+				if rpt != nil {
+					rpt.WriteString(curr.Ident)
+					rpt.WriteString("\n")
+				}
+				if !plch || hshv {
+					continue
+				}
+				str = placeholder(curr.Ident)
 			}
 
 			if hd != "" {
@@ -2121,6 +2494,10 @@ func main() {
 			os.Stdout.WriteString("\n")
 		}
 
+		if missing > 0 || msng {
+			fmt.Fprintf(os.Stderr, "%d identifiers not found in archive\n", missing)
+		}
+
 		debug.FreeOSMemory()
 
 		if timr {
@@ -32,7 +32,6 @@ package main
 
 import (
 	"bufio"
-	"compress/gzip"
 	"encoding/base64"
 	"eutils"
 	"fmt"
@@ -136,6 +135,7 @@ func processFormat(rdr <-chan eutils.XMLBlock, args []string) {
 	doSelf := false
 	doComment := false
 	doCdata := false
+	doCanonical := false
 
 	if len(args) > 0 {
 		// look for [compact|flush|indent|expand] specification
@@ -213,6 +213,10 @@ func processFormat(rdr <-chan eutils.XMLBlock, args []string) {
 		case "-cdata":
 			doCdata = true
 			args = args[1:]
+		// sort attributes and normalize quoting for diff-stable output
+		case "-canonical":
+			doCanonical = true
+			args = args[1:]
 		default:
 			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -format command\n")
 			os.Exit(1)
@@ -224,13 +228,125 @@ func processFormat(rdr <-chan eutils.XMLBlock, args []string) {
 	frgs := eutils.FormatArgs{
 		Format: format, XML: xml, Doctype: doctype,
 		Combine: doCombine, Self: doSelf,
-		Comment: doComment, Cdata: doCdata}
+		Comment: doComment, Cdata: doCdata,
+		Canonical: doCanonical}
 
 	frm := eutils.FormatTokens(tknq, frgs)
 
 	eutils.ChanToStdout(frm)
 }
 
+// processRecordHashes prints identifier and hash of the canonical form of each record
+func processRecordHashes(rdr <-chan eutils.XMLBlock, args []string) {
+
+	if rdr == nil || args == nil {
+		return
+	}
+
+	if len(args) < 2 || !eutils.IsHashAlgorithm(args[1]) {
+		fmt.Fprintf(os.Stderr, "\nERROR: -hash must be followed by crc32, sha1, or sha256\n")
+		os.Exit(1)
+	}
+
+	algo := args[1]
+	args = args[2:]
+
+	pattern := ""
+	key := ""
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-pattern":
+			pattern = eutils.GetStringArg(args, "Pattern")
+			args = args[2:]
+		case "-key":
+			key = eutils.GetStringArg(args, "Identifier element")
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -hash command\n")
+			os.Exit(1)
+		}
+	}
+
+	if pattern == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -hash %s requires -pattern\n", algo)
+		os.Exit(1)
+	}
+
+	xmlq := eutils.CreateXMLProducer(pattern, "", false, rdr)
+	hshq := eutils.CreateRecordHashers(pattern, key, algo, xmlq)
+	unsq := eutils.CreateXMLUnshuffler(hshq)
+
+	if xmlq == nil || hshq == nil || unsq == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create record hasher\n")
+		os.Exit(1)
+	}
+
+	wrtr := bufio.NewWriter(os.Stdout)
+	defer wrtr.Flush()
+
+	for curr := range unsq {
+		wrtr.WriteString(curr.Text)
+	}
+}
+
+// processValidate reports structural problems in XML input, exiting with failure if any are found
+func processValidate(rdr <-chan eutils.XMLBlock, args []string) {
+
+	if rdr == nil {
+		return
+	}
+
+	args = args[1:]
+
+	against := ""
+	pattern := ""
+	limit := 20
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-against":
+			against = eutils.GetStringArg(args, "Reference file")
+			args = args[2:]
+		case "-pattern":
+			pattern = eutils.GetStringArg(args, "Pattern")
+			args = args[2:]
+		case "-limit":
+			limit = eutils.GetNumericArg(args, "Violation limit", 0, 0, 0)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -validate command\n")
+			os.Exit(1)
+		}
+	}
+
+	var allowed map[string]bool
+
+	if against != "" {
+		fl, err := os.Open(against)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open reference file '%s'\n", against)
+			os.Exit(1)
+		}
+		pattern, allowed = eutils.ReferenceElements(eutils.CreateXMLStreamer(fl), pattern)
+		fl.Close()
+
+		if len(allowed) < 1 {
+			fmt.Fprintf(os.Stderr, "\nERROR: No elements found in reference file '%s'\n", against)
+			os.Exit(1)
+		}
+	}
+
+	num := eutils.ValidateStructure(rdr, pattern, allowed, limit)
+
+	if num > 0 {
+		if limit > 0 && num > limit {
+			fmt.Fprintf(os.Stderr, "\n%d violations found, first %d shown\n", num, limit)
+		}
+		os.Exit(1)
+	}
+}
+
 // processTokens shows individual tokens in stream (undocumented)
 func processTokens(rdr <-chan eutils.XMLBlock) {
 
@@ -581,6 +697,64 @@ func processSynopsis(rdr <-chan eutils.XMLBlock, leaf bool, delim string) {
 	}
 }
 
+// processXMLtoJSON converts XML to JSON, streaming one record at a time
+func processXMLtoJSON(rdr <-chan eutils.XMLBlock, args []string) {
+
+	if rdr == nil || args == nil {
+		return
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	set := ""
+	rec := ""
+	nested := false
+
+	for len(args) > 0 {
+		str := args[0]
+		switch str {
+		case "-set":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: No argument after -set\n")
+				os.Exit(1)
+			}
+			set = args[1]
+			args = args[2:]
+		case "-rec":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: No argument after -rec\n")
+				os.Exit(1)
+			}
+			rec = args[1]
+			args = args[2:]
+		case "-attrs", "-attributes":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: No argument after -attrs\n")
+				os.Exit(1)
+			}
+			switch args[1] {
+			case "prefix", "@":
+				nested = false
+			case "nested", "_attributes":
+				nested = true
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized attribute style '%s'\n", args[1])
+				os.Exit(1)
+			}
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -x2j command\n")
+			os.Exit(1)
+		}
+	}
+
+	if err := eutils.XMLToJSON(rdr, set, rec, nested, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
 // processFilter modifies XML content, comments, or CDATA
 func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 
@@ -676,9 +850,28 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 		os.Exit(1)
 	}
 
+	// optional element name restricts action to that object within the pattern
+	elem := ""
+	if max > 2 {
+		elem = args[2]
+	}
+
+	// container removal applies to the selected element, if present
+	cntnr := pttrn
+	if elem != "" {
+		cntnr = elem
+	}
+
 	inPattern := false
 	prevName := ""
 
+	// depth of nested objects matching the selected element
+	depth := 0
+
+	inScope := func() bool {
+		return inPattern && (elem == "" || depth > 0)
+	}
+
 	for tkn := range tknq {
 
 		tag := tkn.Tag
@@ -690,11 +883,14 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 			prevName = name
 			if name == pttrn {
 				inPattern = true
-				if which == eutils.CONTAINERTAG && what == DOREMOVE {
-					continue
-				}
 			}
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if inPattern && name == elem {
+				depth++
+			}
+			if inPattern && name == cntnr && which == eutils.CONTAINERTAG && what == DOREMOVE {
+				continue
+			}
+			if inScope() && which == eutils.OBJECTTAG && what == DOREMOVE {
 				continue
 			}
 			buffer.WriteString("<")
@@ -709,7 +905,10 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 			}
 			buffer.WriteString(">\n")
 		case eutils.SELFTAG:
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if inPattern && (elem == "" || depth > 0 || name == elem) && which == eutils.OBJECTTAG && what == DOREMOVE {
+				continue
+			}
+			if inPattern && name == elem && which == eutils.CONTAINERTAG && what == DOREMOVE {
 				continue
 			}
 			buffer.WriteString("<")
@@ -724,26 +923,31 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 			}
 			buffer.WriteString("/>\n")
 		case eutils.STOPTAG:
+			remove := false
+			if inScope() && which == eutils.OBJECTTAG && what == DOREMOVE {
+				remove = true
+			}
+			if inPattern && name == cntnr && which == eutils.CONTAINERTAG && what == DOREMOVE {
+				remove = true
+			}
+			if inPattern && name == elem && depth > 0 {
+				depth--
+			}
 			if name == pttrn {
 				inPattern = false
-				if which == eutils.OBJECTTAG && what == DOREMOVE {
-					continue
-				}
-				if which == eutils.CONTAINERTAG && what == DOREMOVE {
-					continue
-				}
+				depth = 0
 			}
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if remove {
 				continue
 			}
 			buffer.WriteString("</")
 			buffer.WriteString(name)
 			buffer.WriteString(">\n")
 		case eutils.CONTENTTAG:
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if inScope() && which == eutils.OBJECTTAG && what == DOREMOVE {
 				continue
 			}
-			if inPattern && which == eutils.CONTENTTAG && what == DOEXPAND {
+			if inScope() && which == eutils.CONTENTTAG && what == DOEXPAND {
 				var words []string
 				if strings.Contains(name, "|") {
 					words = strings.FieldsFunc(name, func(c rune) bool {
@@ -783,7 +987,7 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 				}
 				continue
 			}
-			if inPattern && which == tag {
+			if inScope() && which == tag {
 				switch what {
 				case DORETAIN:
 					// default behavior for content - can use -filter X retain content as a no-op
@@ -810,10 +1014,10 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 			buffer.WriteString(name)
 			buffer.WriteString("\n")
 		case eutils.CDATATAG:
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if inScope() && which == eutils.OBJECTTAG && what == DOREMOVE {
 				continue
 			}
-			if inPattern && which == tag {
+			if inScope() && which == tag {
 				switch what {
 				case DORETAIN:
 					// cdata requires explicit retain command
@@ -840,10 +1044,10 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 				buffer.WriteString("\n")
 			}
 		case eutils.COMMENTTAG:
-			if inPattern && which == eutils.OBJECTTAG && what == DOREMOVE {
+			if inScope() && which == eutils.OBJECTTAG && what == DOREMOVE {
 				continue
 			}
-			if inPattern && which == tag {
+			if inScope() && which == tag {
 				switch what {
 				case DORETAIN:
 					// comment requires explicit retain command
@@ -895,6 +1099,138 @@ func processFilter(rdr <-chan eutils.XMLBlock, args []string) {
 	}
 }
 
+// processPrune removes elements that enclose only whitespace, including ancestors left empty
+func processPrune(rdr <-chan eutils.XMLBlock, args []string) {
+
+	if rdr == nil || args == nil {
+		return
+	}
+
+	tknq := eutils.CreateTokenizer(rdr)
+
+	if tknq == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create prune tokenizer\n")
+		os.Exit(1)
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	// by default, elements with attributes are kept even if empty
+	pruneAttrs := false
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-prune-attrs", "-attrs":
+			pruneAttrs = true
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option '%s' supplied to transmute -prune\n", args[0])
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+
+	var buffer strings.Builder
+
+	count := 0
+
+	// pending start tags are only printed once their element is known to be non-empty
+	type Frame struct {
+		Start   string
+		Printed bool
+	}
+
+	var stack []Frame
+
+	// flushPending prints start tags of all ancestors not yet printed
+	flushPending := func() {
+		for i := range stack {
+			if stack[i].Printed {
+				continue
+			}
+			buffer.WriteString(stack[i].Start)
+			stack[i].Printed = true
+		}
+	}
+
+	startTag := func(name, attr, close string) string {
+		str := "<" + name
+		if attr != "" {
+			attr = strings.TrimSpace(attr)
+			attr = eutils.CompressRunsOfSpaces(attr)
+			str += " " + attr
+		}
+		return str + close
+	}
+
+	for tkn := range tknq {
+
+		tag := tkn.Tag
+		name := tkn.Name
+		attr := tkn.Attr
+
+		switch tag {
+		case eutils.STARTTAG:
+			stack = append(stack, Frame{Start: startTag(name, attr, ">\n")})
+			if attr != "" && !pruneAttrs {
+				// element with attributes is retained
+				flushPending()
+			}
+		case eutils.SELFTAG:
+			if attr == "" || pruneAttrs {
+				continue
+			}
+			flushPending()
+			buffer.WriteString(startTag(name, attr, "/>\n"))
+		case eutils.STOPTAG:
+			if len(stack) < 1 {
+				continue
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !top.Printed {
+				// empty element, drop both start and stop tags
+				continue
+			}
+			buffer.WriteString("</")
+			buffer.WriteString(name)
+			buffer.WriteString(">\n")
+		case eutils.CONTENTTAG, eutils.CDATATAG:
+			if eutils.HasFlankingSpace(name) {
+				name = strings.TrimSpace(name)
+			}
+			if name == "" {
+				continue
+			}
+			if tag == eutils.CDATATAG {
+				name = html.EscapeString(name)
+			}
+			flushPending()
+			buffer.WriteString(name)
+			buffer.WriteString("\n")
+		case eutils.ISCLOSED:
+			txt := buffer.String()
+			if txt != "" {
+				// print final buffer
+				fmt.Fprintf(os.Stdout, "%s", txt)
+			}
+			return
+		default:
+		}
+
+		count++
+		if count > 1000 {
+			count = 0
+			txt := buffer.String()
+			if txt != "" {
+				// print current buffered output
+				fmt.Fprintf(os.Stdout, "%s", txt)
+			}
+			buffer.Reset()
+		}
+	}
+}
+
 // STRING CONVERTERS
 
 func encodeURL(inp io.Reader) {
@@ -1021,6 +1357,57 @@ func decodeHGVS(inp io.Reader) {
 	os.Stdout.WriteString("</HGVS>\n")
 }
 
+// TAB-DELIMITED TABLE TO CSV
+
+// tableToCSV converts tab-delimited lines to RFC 4180 comma-separated values, quoting fields as needed
+func tableToCSV(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	args = args[1:]
+
+	delim := ','
+	bom := false
+	crlf := false
+	pad := false
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-delim":
+			str := eutils.GetStringArg(args, "Delimiter")
+			if str == "tab" || str == "\\t" {
+				str = "\t"
+			}
+			if len(str) != 1 || str == "\"" || str == "\n" || str == "\r" {
+				fmt.Fprintf(os.Stderr, "\nERROR: -delim must be a single character other than quote\n")
+				os.Exit(1)
+			}
+			delim = rune(str[0])
+			args = args[2:]
+		case "-bom":
+			bom = true
+			args = args[1:]
+		case "-crlf":
+			crlf = true
+			args = args[1:]
+		case "-pad":
+			pad = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -toCSV command\n")
+			os.Exit(1)
+		}
+	}
+
+	err := eutils.TableToCSV(inp, delim, bom, crlf, pad, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
 // COLUMN ALIGNMENT FORMATTER
 
 // processAlign aligns a tab-delimited table by individual column widths
@@ -1037,6 +1424,7 @@ func processAlign(inp io.Reader, args []string) {
 	pdg := 0
 	mnw := 0
 	aln := ""
+	tbl := ""
 
 	// skip past command name
 	args = args[1:]
@@ -1056,22 +1444,125 @@ func processAlign(inp io.Reader, args []string) {
 		case "-a":
 			aln = eutils.GetStringArg(args, "-a column alignment code string")
 			args = args[2:]
+		case "-md", "-markdown":
+			tbl = "md"
+			args = args[1:]
+		case "-html":
+			tbl = "html"
+			args = args[1:]
 		default:
 			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -align command\n")
 			os.Exit(1)
 		}
-	}
+	}
+
+	if tbl != "" {
+		// Markdown or HTML table instead of padded columns
+		frmt := eutils.FormatTable(inp, tbl, aln)
+		if frmt == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create table format function\n")
+			os.Exit(1)
+		}
+		eutils.ChanToStdout(frmt)
+		return
+	}
+
+	algn := eutils.AlignColumns(inp, mrg, pdg, mnw, aln)
+
+	if algn == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create alignment function\n")
+		os.Exit(1)
+	}
+
+	eutils.ChanToStdout(algn)
+
+	return
+}
+
+// TABLE JOIN
+
+// joinTables merges two tab-delimited files on a shared key column, loading the
+// smaller file into a map and streaming the larger one in a single pass
+func joinTables(args []string) {
+
+	keyA := 1
+	keyB := 1
+	outer := false
+	header := false
+	cartesian := false
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+
+		switch args[0] {
+		case "-key":
+			keyA = eutils.GetNumericArg(args, "Key column", 1, 1, 0)
+			keyB = keyA
+			args = args[2:]
+		case "-key1":
+			keyA = eutils.GetNumericArg(args, "Key column of first file", 1, 1, 0)
+			args = args[2:]
+		case "-key2":
+			keyB = eutils.GetNumericArg(args, "Key column of second file", 1, 1, 0)
+			args = args[2:]
+		case "-outer":
+			outer = true
+			args = args[1:]
+		case "-header":
+			header = true
+			args = args[1:]
+		case "-cartesian":
+			cartesian = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -jointables command\n")
+			os.Exit(1)
+		}
+	}
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -jointables command\n")
+		os.Exit(1)
+	}
+
+	fileA := args[0]
+	fileB := args[1]
+
+	openTable := func(fname string) *os.File {
+
+		f, err := os.Open(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open file %s - %s\n", fname, err.Error())
+			os.Exit(1)
+		}
 
-	algn := eutils.AlignColumns(inp, mrg, pdg, mnw, aln)
+		return f
+	}
 
-	if algn == nil {
-		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create alignment function\n")
-		os.Exit(1)
+	// smaller file is the lookup table, output columns stay in command-line order
+	lookupFile, streamFile := fileA, fileB
+	lookupKey, streamKey := keyA, keyB
+	lookupFirst := true
+	infoA, errA := os.Stat(fileA)
+	infoB, errB := os.Stat(fileB)
+	if errA == nil && errB == nil && infoB.Size() < infoA.Size() {
+		lookupFile, streamFile = fileB, fileA
+		lookupKey, streamKey = keyB, keyA
+		lookupFirst = false
 	}
 
-	eutils.ChanToStdout(algn)
+	lf := openTable(lookupFile)
+	defer lf.Close()
+	sf := openTable(streamFile)
+	defer sf.Close()
 
-	return
+	err := eutils.JoinTables(lf, sf, lookupKey, streamKey, lookupFirst, outer, header, cartesian, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
 }
 
 // SEQUENCE EDITING
@@ -1325,7 +1816,14 @@ func sequenceSearch(inp io.Reader, args []string) {
 
 	srch.Search(str[:],
 		func(str, pat string, pos int) bool {
-			txt = fmt.Sprintf("%d\t%s\n", pos, pat)
+			// reverse complement matches have the top strand pattern in parentheses
+			strand := "+"
+			if strings.HasPrefix(pat, "(") && strings.HasSuffix(pat, ")") {
+				strand = "-"
+				pat = strings.TrimPrefix(pat, "(")
+				pat = strings.TrimSuffix(pat, ")")
+			}
+			txt = fmt.Sprintf("%d\t%s\t%s\n", pos, strand, pat)
 			os.Stdout.WriteString(txt)
 			return true
 		})
@@ -1452,6 +1950,30 @@ func lowerString(inp io.Reader) {
 	}
 }
 
+// aminoAcidConvert rewrites residue codes line by line, leaving unrecognized tokens untouched
+func aminoAcidConvert(inp io.Reader, toOne bool) {
+
+	if inp == nil {
+		return
+	}
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := scanr.Text()
+
+		if toOne {
+			line = eutils.AminoAcid3to1(line)
+		} else {
+			line = eutils.AminoAcid1to3(line)
+		}
+
+		os.Stdout.WriteString(line)
+		os.Stdout.WriteString("\n")
+	}
+}
+
 // FASTA BASE COUNT
 
 // baseCount prints a summary of base or residue counts
@@ -1490,6 +2012,51 @@ func baseCount(inp io.Reader) {
 	}
 }
 
+// GC CONTENT
+
+// gcContent prints GC percentage for each FASTA record, optionally in sliding windows
+func gcContent(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	window := 0
+	step := 0
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-window":
+			window = eutils.GetNumericArg(args, "window size", 0, 1, 0)
+			args = args[2:]
+		case "-step":
+			step = eutils.GetNumericArg(args, "step size", 0, 1, 0)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -gc command\n")
+			os.Exit(1)
+		}
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		if window < 1 {
+			fmt.Fprintf(os.Stdout, "%s\t%s\n", fsa.SeqID, eutils.GCContent(fsa.Sequence))
+			continue
+		}
+
+		for _, item := range eutils.GCWindows(fsa.Sequence, window, step) {
+			fmt.Fprintf(os.Stdout, "%s\t%s\n", fsa.SeqID, item)
+		}
+	}
+}
+
 // REVERSE SEQUENCE
 
 // seqFlip reverses without complementing - e.g., minus strand proteins translated in reverse order
@@ -1616,6 +2183,9 @@ func printFastaPairs(frst, scnd string) {
 		mx++
 	}
 
+	// count residues of first sequence, skipping alignment gaps
+	pos := 0
+
 	// pad output to multiple of 50
 	j := mx % 50
 	if j > 0 {
@@ -1638,7 +2208,8 @@ func printFastaPairs(frst, scnd string) {
 		fs = fs[dl:]
 		sc = sc[dl:]
 		tm := strings.TrimRight(string(lf), " ")
-		fmt.Fprintf(os.Stdout, "%s %6d\n%s\n", string(lf), i+len(tm), string(rt))
+		pos += len(tm) - strings.Count(tm, "-")
+		fmt.Fprintf(os.Stdout, "%s %6d\n%s\n", string(lf), pos, string(rt))
 	}
 }
 
@@ -1648,9 +2219,27 @@ func fastaDiff(inp io.Reader, args []string) {
 		return
 	}
 
+	band := 50
+	maxLen := 100000
+
 	// skip past command name
 	args = args[1:]
 
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+
+		switch args[0] {
+		case "-band":
+			band = eutils.GetNumericArg(args, "alignment band width", 0, 1, 0)
+			args = args[2:]
+		case "-maxlen":
+			maxLen = eutils.GetNumericArg(args, "maximum sequence length", 0, 1, 0)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -diff command\n")
+			os.Exit(1)
+		}
+	}
+
 	if len(args) != 2 {
 		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -diff command\n")
 		os.Exit(1)
@@ -1681,8 +2270,121 @@ func fastaDiff(inp io.Reader, args []string) {
 		return
 	}
 
-	// sequences are assumed to be aligned, this code highlight mismatches
-	printFastaPairs(frstFasta, scndFasta)
+	if maxLen > 0 && (len(frstFasta) > maxLen || len(scndFasta) > maxLen) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Sequence lengths %d and %d exceed -maxlen %d for -diff alignment\n", len(frstFasta), len(scndFasta), maxLen)
+		os.Exit(1)
+	}
+
+	fa, sa := eutils.GlobalAlign(frstFasta, scndFasta, band)
+
+	idn, mis, ins, del := eutils.AlignmentCounts(fa, sa)
+
+	// mismatches and gaps are shown in upper case
+	printFastaPairs(fa, sa)
+
+	fmt.Fprintf(os.Stdout, "%d identities, %d mismatches, %d insertions, %d deletions\n", idn, mis, ins, del)
+}
+
+// K-MER PROFILE DISTANCE
+
+// kmerDistance compares FASTA files by cosine distance and Jaccard similarity of canonical k-mer profiles
+func kmerDistance(args []string) {
+
+	k := 8
+	byRecord := false
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+
+		switch args[0] {
+		case "-k":
+			k = eutils.GetNumericArg(args, "k-mer length", 8, 1, 31)
+			args = args[2:]
+		case "-records":
+			byRecord = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -kmerdist command\n")
+			os.Exit(1)
+		}
+	}
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -kmerdist command\n")
+		os.Exit(1)
+	}
+
+	type Profile struct {
+		ID     string
+		Counts map[uint64]int
+	}
+
+	// each record is a separate profile with -records, otherwise records in a file are pooled
+	readProfiles := func(fname string) []Profile {
+
+		f, err := os.Open(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open file %s - %s\n", fname, err.Error())
+			os.Exit(1)
+		}
+
+		defer f.Close()
+
+		var profs []Profile
+
+		pooled := Profile{ID: fname, Counts: make(map[uint64]int)}
+
+		fsta := eutils.FASTAConverter(f, false)
+
+		for fsa := range fsta {
+			if byRecord {
+				prof := Profile{ID: fsa.SeqID, Counts: make(map[uint64]int)}
+				eutils.KmerProfile(fsa.Sequence, k, prof.Counts)
+				profs = append(profs, prof)
+			} else {
+				eutils.KmerProfile(fsa.Sequence, k, pooled.Counts)
+			}
+		}
+
+		if !byRecord {
+			profs = append(profs, pooled)
+		}
+
+		return profs
+	}
+
+	frst := readProfiles(args[0])
+	scnd := readProfiles(args[1])
+
+	var buffer strings.Builder
+
+	if byRecord {
+		// matrix of cosine distances, rows from first file and columns from second
+		buffer.WriteString("-")
+		for _, sc := range scnd {
+			buffer.WriteString("\t" + sc.ID)
+		}
+		buffer.WriteString("\n")
+		for _, fs := range frst {
+			buffer.WriteString(fs.ID)
+			for _, sc := range scnd {
+				dist, _, _, _, _ := eutils.CompareKmerProfiles(fs.Counts, sc.Counts)
+				buffer.WriteString(fmt.Sprintf("\t%.4f", dist))
+			}
+			buffer.WriteString("\n")
+		}
+	} else if len(frst) > 0 && len(scnd) > 0 {
+		dist, jacc, shared, uniqF, uniqS := eutils.CompareKmerProfiles(frst[0].Counts, scnd[0].Counts)
+		buffer.WriteString(fmt.Sprintf("cosine_distance\t%.4f\n", dist))
+		buffer.WriteString(fmt.Sprintf("jaccard_similarity\t%.4f\n", jacc))
+		buffer.WriteString(fmt.Sprintf("shared\t%d\n", shared))
+		buffer.WriteString(fmt.Sprintf("unique_first\t%d\n", uniqF))
+		buffer.WriteString(fmt.Sprintf("unique_second\t%d\n", uniqS))
+	}
+
+	os.Stdout.WriteString(buffer.String())
 }
 
 // PROTEIN WEIGHT
@@ -1694,6 +2396,7 @@ func protWeight(inp io.Reader, args []string) {
 	}
 
 	trimLeadingMet := true
+	var opts eutils.MassOptions
 
 	// skip past command name
 	args = args[1:]
@@ -1704,6 +2407,23 @@ func protWeight(inp io.Reader, args []string) {
 		case "-met":
 			trimLeadingMet = false
 			args = args[1:]
+		case "-mono":
+			opts.Mono = true
+			args = args[1:]
+		case "-average":
+			opts.Average = true
+			args = args[1:]
+		case "-mod":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Residue modification is missing\n")
+				os.Exit(1)
+			}
+			ch, dlt := eutils.ParseMassModification(args[1])
+			if opts.Mods == nil {
+				opts.Mods = make(map[rune]float64)
+			}
+			opts.Mods[ch] += dlt
+			args = args[2:]
 		default:
 			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -molwt command\n")
 			os.Exit(1)
@@ -1712,7 +2432,7 @@ func protWeight(inp io.Reader, args []string) {
 
 	str := readOneFastaSequence(inp)
 
-	str = eutils.ProteinWeight(str, trimLeadingMet)
+	str = eutils.ProteinMass(str, trimLeadingMet, opts)
 
 	os.Stdout.WriteString(str)
 	if !strings.HasSuffix(str, "\n") {
@@ -1735,6 +2455,7 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 	is5primeComplete := true
 	is3primeComplete := true
 	between := ""
+	location := ""
 
 	repeat := 1
 
@@ -1768,6 +2489,9 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 		case "-between":
 			between = eutils.GetStringArg(args, "separator between residues")
 			args = args[2:]
+		case "-location", "-loc":
+			location = eutils.GetStringArg(args, "coding region location")
+			args = args[2:]
 		case "-repeat":
 			repeat = eutils.GetNumericArg(args, "number of repetitions for testing", 1, 1, 100)
 			args = args[2:]
@@ -1784,6 +2508,18 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 
 	txt := readOneFastaSequence(inp)
 
+	if location != "" {
+		// extract and splice coding region from genomic sequence, partial markers override flags
+		featLoc, complete5, complete3 := eutils.ParseGenBankLocation(location)
+		if !complete5 {
+			is5primeComplete = false
+		}
+		if !complete3 {
+			is3primeComplete = false
+		}
+		txt = eutils.SequenceExtract(txt, featLoc, true)
+	}
+
 	for i := 0; i < repeat; i++ {
 
 		// repeat multiple times for performance testing (undocumented)
@@ -1836,6 +2572,159 @@ func nucProtCodonReport(args []string) {
 	}
 }
 
+// CODON USAGE
+
+// codonUsage tallies codons in one or more coding sequences and prints a 64-row usage table
+func codonUsage(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	genCode := 1
+	frame := 0
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-code", "-gencode":
+			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 33)
+			args = args[2:]
+		case "-frame":
+			frame = eutils.GetNumericArg(args, "offset into coding sequence", 0, 0, 2)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -codonuse command\n")
+			os.Exit(1)
+		}
+	}
+
+	counts := make(map[string]int)
+	skipped := 0
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		_, skp, partial := eutils.CountCodons(fsa.Sequence, frame, counts)
+		if partial {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Length of sequence %s is not divisible by three, ignoring trailing bases\n", fsa.SeqID)
+		}
+		skipped += skp
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d codons with ambiguous bases skipped\n", skipped)
+	}
+
+	os.Stdout.WriteString(eutils.CodonUsageTable(counts, genCode))
+}
+
+// RESTRICTION DIGEST
+
+// restrictionDigest reports enzyme sites and the fragment sizes of a complete digest
+func restrictionDigest(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	circular := false
+	uniqueCutters := false
+	minFragments := 0
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+		if args[0] == "-circular" {
+			circular = true
+			args = args[1:]
+		} else if args[0] == "-enzfile" {
+			eutils.LoadRestrictionEnzymes(eutils.GetStringArg(args, "Restriction enzyme file name"))
+			args = args[2:]
+		} else if args[0] == "-unique-cutters" || args[0] == "-unique" {
+			uniqueCutters = true
+			args = args[1:]
+		} else if args[0] == "-min-fragments" {
+			minFragments = eutils.GetNumericArg(args, "minimum number of fragments", 0, 1, 0)
+			args = args[2:]
+		} else if strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -digest command\n")
+			os.Exit(1)
+		} else {
+			// remaining arguments are enzyme names
+			break
+		}
+	}
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Missing enzyme names after -digest command\n")
+		os.Exit(1)
+	}
+
+	var enzymes []eutils.RestrictionEnzyme
+
+	for _, arg := range args {
+		// names can also be separated by commas or spaces within one argument, e.g., "EcoRI BamHI"
+		names := strings.FieldsFunc(arg, func(c rune) bool {
+			return c == ',' || unicode.IsSpace(c)
+		})
+		for _, name := range names {
+			enz, ok := eutils.LookupRestrictionEnzyme(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized restriction enzyme '%s'\n", name)
+				os.Exit(1)
+			}
+			enzymes = append(enzymes, enz)
+		}
+	}
+
+	str := readOneFastaSequence(inp)
+	ln := len(str)
+
+	sites := eutils.RestrictionDigest(str, enzymes, circular)
+
+	// filter by the number of fragments each enzyme produces on its own
+	cuts := make(map[string][]int)
+	for _, site := range sites {
+		cuts[site.Enzyme] = append(cuts[site.Enzyme], site.Cut)
+	}
+
+	keep := make(map[string]bool)
+	for name, pts := range cuts {
+		frags := eutils.DigestFragments(pts, ln, circular)
+		if uniqueCutters && len(pts) != 1 {
+			continue
+		}
+		if minFragments > 0 && len(frags) < minFragments {
+			continue
+		}
+		keep[name] = true
+	}
+
+	var buffer strings.Builder
+
+	var all []int
+	for _, site := range sites {
+		if !keep[site.Enzyme] {
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("%s\t%d\t%s\n", site.Enzyme, site.Pos+1, site.Strand))
+		all = append(all, site.Cut)
+	}
+
+	// fragments of the full digest with all selected enzymes
+	for _, frag := range eutils.DigestFragments(all, ln, circular) {
+		buffer.WriteString(fmt.Sprintf("fragment\t%d\n", frag))
+	}
+
+	os.Stdout.WriteString(buffer.String())
+}
+
 // MAIN FUNCTION
 
 func main() {
@@ -1843,6 +2732,9 @@ func main() {
 	// skip past executable name
 	args := os.Args[1:]
 
+	// replace @@path and --args path references with tokens read from file
+	args = eutils.ExpandArgumentFiles(args)
+
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\nERROR: No command-line arguments supplied to transmute\n")
 		os.Exit(1)
@@ -1911,6 +2803,7 @@ func main() {
 
 	// use pgzip decompression on release files
 	zipp := false
+	noZip := false
 
 	inSwitch := true
 
@@ -1987,6 +2880,10 @@ func main() {
 
 		case "-gzip":
 			zipp = true
+			noZip = false
+		case "-nogzip":
+			noZip = true
+			zipp = false
 
 		// allow setting of unicode, script, and mathml flags (undocumented)
 		case "-unicode":
@@ -2139,7 +3036,7 @@ func main() {
 
 	// FILE NAME CAN BE SUPPLIED WITH -input COMMAND
 
-	in := os.Stdin
+	var in io.Reader = os.Stdin
 
 	// check for data being piped into stdin
 	isPipe := false
@@ -2171,6 +3068,9 @@ func main() {
 		}
 	}
 
+	// gzip-compressed input is detected on first read, -gzip forces decompression
+	in = eutils.DecompressIfGzipped(in, zipp, !noZip)
+
 	// check for -input command after extraction arguments
 	for _, str := range args {
 		if str == "-input" {
@@ -2333,6 +3233,7 @@ func main() {
 
 		set := ""
 		rec := ""
+		b64 := false
 
 		// look for optional arguments
 		for {
@@ -2354,10 +3255,13 @@ func main() {
 				if ok && rec == "-" {
 					rec = ""
 				}
+			case "-base64":
+				// decode hex strings, print bytes in base64
+				b64 = true
 			}
 		}
 
-		acnv := eutils.ASN1Converter(in, set, rec)
+		acnv := eutils.ASN1Converter(in, set, rec, b64)
 
 		if acnv == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create ASN.1 to XML converter\n")
@@ -2394,7 +3298,7 @@ func main() {
 
 	// READ TAB-DELIMITED FILE AND WRAP IN XML FIELDS
 
-	doTable := func(delim string) {
+	doTable := func(delim, quote string) {
 
 		// skip past command name
 		args = args[1:]
@@ -2444,6 +3348,21 @@ func main() {
 				}
 				skip = val
 				args = args[1:]
+			case "-quote":
+				args = args[1:]
+				if len(args) < 1 {
+					fmt.Fprintf(os.Stderr, "\nERROR: No argument after -quote\n")
+					os.Exit(1)
+				}
+				quote = args[0]
+				if quote == "-" {
+					// plain split, without quoted fields
+					quote = ""
+				} else if quote == "" || quote == delim {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unsuitable -quote argument '%s'\n", quote)
+					os.Exit(1)
+				}
+				args = args[1:]
 			case "-header", "-headers", "-heading":
 				header = true
 				args = args[1:]
@@ -2474,7 +3393,7 @@ func main() {
 			os.Exit(1)
 		}
 
-		tble := eutils.TableConverter(in, delim, set, rec, skip, header, lower, upper, indent, fields)
+		tble := eutils.TableConverter(in, delim, quote, set, rec, skip, header, lower, upper, indent, fields)
 
 		if tble == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create table to XML converter\n")
@@ -2509,19 +3428,19 @@ func main() {
 
 	if len(args) > 1 && args[0] == "-t2x" {
 
-		doTable("\t")
+		doTable("\t", "")
 		return
 	}
 
 	if len(args) > 1 && args[0] == "-c2x" {
 
-		doTable(",")
+		doTable(",", "\"")
 		return
 	}
 
 	if len(args) > 1 && args[0] == "-s2x" {
 
-		doTable(";")
+		doTable(";", "")
 		return
 	}
 
@@ -2712,24 +3631,8 @@ func main() {
 		// GenBank and GenPept flatfiles start with LOCUS line
 		recordStartPattern := "LOCUS       "
 
-		inp := io.Reader(in)
-
-		if zipp {
-
-			zpr, err := gzip.NewReader(in)
-
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to create gzip reader - %s\n", err.Error())
-				os.Exit(1)
-			}
-
-			defer zpr.Close()
-
-			// replace input io.Reader
-			inp = zpr
-		}
-
-		lbsq := eutils.CreateTextStreamer(inp)
+		// -gzip input was already wrapped in a decompressor
+		lbsq := eutils.CreateTextStreamer(in)
 		psrq := eutils.CreateTextProducer(recordStartPattern, lbsq)
 
 		if lbsq == nil || psrq == nil {
@@ -2828,6 +3731,12 @@ func main() {
 		makePlain(in)
 	case "-hgvs":
 		decodeHGVS(in)
+	case "-aa3to1":
+		aminoAcidConvert(in, true)
+	case "-aa1to3":
+		aminoAcidConvert(in, false)
+	case "-toCSV", "-tocsv":
+		tableToCSV(in, args)
 	case "-align":
 		processAlign(in, args)
 	case "-remove":
@@ -2860,8 +3769,18 @@ func main() {
 		cdRegionToProtein(in, args)
 	case "-codons":
 		nucProtCodonReport(args)
+	case "-codonuse":
+		codonUsage(in, args)
+	case "-gc":
+		gcContent(in, args)
+	case "-digest":
+		restrictionDigest(in, args)
 	case "-diff":
 		fastaDiff(in, args)
+	case "-kmerdist":
+		kmerDistance(args)
+	case "-jointables", "-merge-tables":
+		joinTables(args)
 	default:
 		// if not any of the conversion commands, keep going
 		inSwitch = false
@@ -2914,6 +3833,8 @@ func main() {
 		processFormat(rdr, args)
 	case "-filter":
 		processFilter(rdr, args)
+	case "-prune":
+		processPrune(rdr, args)
 	case "-normalize", "-normal":
 		if len(args) < 2 {
 			fmt.Fprintf(os.Stderr, "\nERROR: No database supplied to -normalize\n")
@@ -2922,6 +3843,8 @@ func main() {
 		db := args[1]
 		nrm := eutils.NormalizeXML(rdr, db)
 		eutils.ChanToStdout(nrm)
+	case "-x2j", "-xml2json":
+		processXMLtoJSON(rdr, args)
 	case "-outline":
 		processOutline(rdr)
 	case "-contour":
@@ -2939,6 +3862,10 @@ func main() {
 		processSynopsis(rdr, leaf, delim)
 	case "-tokens":
 		processTokens(rdr)
+	case "-validate":
+		processValidate(rdr, args)
+	case "-hash":
+		processRecordHashes(rdr, args)
 	default:
 		// if not any of the formatting commands, keep going
 		inSwitch = false
@@ -96,6 +96,12 @@ func main() {
 	// skip past executable name
 	args := os.Args[1:]
 
+	// replace @@path and --args path references with tokens read from file
+	args = eutils.ExpandArgumentFiles(args)
+
+	// kept to find the original positions of extraction arguments
+	cmdline := args
+
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\nERROR: No command-line arguments supplied to xtract\n")
 		os.Exit(1)
@@ -158,12 +164,79 @@ func main() {
 	// flag for indexed input file
 	turbo := false
 
+	// comma-separated output
+	doCSV := false
+
+	// array of JSON objects output
+	doJSON := false
+
+	// column names before first record
+	doHeader := false
+
+	// force or disable gzip decompression of input
+	doGzip := false
+	noGzip := false
+
+	// concurrent -input files, and whether an unreadable file is fatal
+	parallelFiles := 0
+	strictFiles := false
+
+	// write records as soon as they are in order
+	flushOutput := false
+
+	// skip a truncated final record instead of failing
+	lenientInput := false
+
+	// discard records with an empty -element column
+	requireAll := false
+
+	// window of -pattern records to process
+	skipRecords := 0
+	takeRecords := 0
+
+	// omit -head and -tail if no record produced output
+	suppressEmpty := false
+
+	// -sort-records keys, order, and memory limit
+	sortRecords := false
+	var sortBy []string
+	sortNumeric := false
+	sortReverse := false
+	sortMem := 0
+
+	// oversized record limits, skipped unless -fail-on-limit
+	maxRecordBytes := 0
+	maxRecordNodes := 0
+	failOnLimit := false
+
+	// identifier for removing repeated records, keeping first or last
+	dedupBy := ""
+	dedupLast := false
+
+	// -join-records identifier and separator
+	joinOn := ""
+	joinSep := "|"
+	joinRecords := false
+
+	// feature keys paired by -insd -joined
+	joinFeatures := ""
+
+	// write each record to a separate file
+	doSplit := false
+
+	// -molwt customizations
+	var massOpts eutils.MassOptions
+
 	// debugging
 	mpty := false
 	idnt := false
 	stts := false
 	timr := false
 
+	// periodic status line, seconds between reports, and expected record count for ETA
+	prog := 0
+	totl := 0
+
 	// profiling
 	prfl := false
 
@@ -213,10 +286,157 @@ func main() {
 			fileName = eutils.GetStringArg(args, "Input file name")
 			args = args[1:]
 
+		// remove records with repeated identifiers, keeping first or last occurrence
+		case "-dedup-by":
+			dedupBy = eutils.GetStringArg(args, "Deduplication identifier")
+			dedupLast = false
+			args = args[1:]
+		case "-dedup-last":
+			dedupBy = eutils.GetStringArg(args, "Deduplication identifier")
+			dedupLast = true
+			args = args[1:]
+
+		// merge output rows of records with the same identifier
+		case "-join-records":
+			joinRecords = true
+		case "-on":
+			joinOn = eutils.GetStringArg(args, "Join identifier")
+			args = args[1:]
+		case "-join-sep":
+			joinSep = eutils.GetStringArg(args, "Join separator")
+			args = args[1:]
+
+		// additional elements for sequence coordinate conversion
+		case "-seqcoords":
+			eutils.LoadSequenceTypes(eutils.GetStringArg(args, "Sequence coordinate file name"))
+			args = args[1:]
+
+		// monoisotopic mass, ambiguous residue averaging, and fixed modifications for -molwt
+		case "-molwt-mono":
+			massOpts.Mono = true
+		case "-molwt-average":
+			massOpts.Average = true
+		case "-molwt-mod":
+			ch, dlt := eutils.ParseMassModification(eutils.GetStringArg(args, "Residue modification"))
+			if massOpts.Mods == nil {
+				massOpts.Mods = make(map[rune]float64)
+			}
+			massOpts.Mods[ch] += dlt
+			args = args[1:]
+
+		// force or disable gzip decompression, which is otherwise detected automatically
+		case "-gzip":
+			doGzip = true
+			noGzip = false
+		case "-nogzip":
+			noGzip = true
+			doGzip = false
+
+		// directory or glob pattern in -input reads multiple files
+		case "-parallel-files":
+			parallelFiles = eutils.GetNumericArg(args, "Number of files to read concurrently", 1, 1, 64)
+			args = args[1:]
+		case "-strict-files":
+			strictFiles = true
+
+		// match prefixed names by namespace URI
+		case "-namespace":
+			pair := eutils.GetStringArg(args, "Namespace prefix=URI")
+			pfx, uri := eutils.SplitInTwoLeft(pair, "=")
+			if pfx == "" || uri == "" {
+				fmt.Fprintf(os.Stderr, "\nERROR: -namespace argument '%s' must be in prefix=URI form\n", pair)
+				os.Exit(1)
+			}
+			eutils.AddNamespace(pfx, uri)
+			args = args[1:]
+
+		// treat deprecated argument constructs as errors
+		case "-strict-args":
+			eutils.SetStrictArgs(true)
+
+		// only print records in which every -element clause has a value
+		case "-require-all":
+			eutils.SetRequireAll(true)
+			requireAll = true
+
+		// skip a record truncated by premature end of input instead of failing
+		case "-lenient":
+			lenientInput = true
+
+		// write each record as soon as it is in order, limiting records held for reordering
+		case "-flush":
+			flushOutput = true
+
+		// process only a window of -pattern records
+		case "-skip":
+			skipRecords = eutils.GetNumericArg(args, "Number of records to skip", 0, 0, 0)
+			args = args[1:]
+		case "-take":
+			takeRecords = eutils.GetNumericArg(args, "Number of records to process", 0, 1, 0)
+			args = args[1:]
+
+		// omit -head and -tail and exit with status 2 if no record produced output
+		case "-suppress-empty":
+			suppressEmpty = true
+
+		// order output by extracted keys at end of input
+		case "-sort-records":
+			sortRecords = true
+		case "-by":
+			sortBy = append(sortBy, eutils.GetStringArg(args, "Sort key element"))
+			args = args[1:]
+		case "-numeric":
+			sortNumeric = true
+		case "-reverse":
+			sortReverse = true
+		case "-sort-mem":
+			sortMem = eutils.GetNumericArg(args, "Sort memory in megabytes", 0, 1, 0)
+			args = args[1:]
+
+		// fixed reference date for -age instead of the current date
+		case "-today":
+			today := eutils.GetStringArg(args, "Reference date")
+			if !eutils.SetAgeReference(today) {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -today date '%s'\n", today)
+				os.Exit(1)
+			}
+			args = args[1:]
+
+		// skip pathologically large records
+		case "-max-record-bytes":
+			maxRecordBytes = eutils.GetNumericArg(args, "Maximum record size in bytes", 0, 0, 0)
+			args = args[1:]
+		case "-max-record-nodes":
+			maxRecordNodes = eutils.GetNumericArg(args, "Maximum number of nodes in record", 0, 0, 0)
+			args = args[1:]
+		case "-fail-on-limit":
+			failOnLimit = true
+
+		// pair rows generated by -insd -joined
+		case "-join-features":
+			joinFeatures = eutils.GetStringArg(args, "Joined feature keys")
+			args = args[1:]
+
+		// one output file per record
+		case "-split":
+			doSplit = true
+
 		// input is indexed with <NEXT_RECORD_SIZE> objects
 		case "-turbo":
 			turbo = true
 
+		// RFC 4180 comma-separated output
+		case "-csv":
+			doCSV = true
+
+		// JSON object for each record, within a top-level array
+		case "-jsonpkg":
+			doJSON = true
+
+		// header row derived from extraction commands
+		case "-header":
+			doHeader = true
+
 		// data cleanup flags
 		case "-compress", "-compressed":
 			doCompress = true
@@ -267,6 +487,20 @@ func main() {
 			stts = true
 		case "-timer":
 			timr = true
+		case "-progress":
+			prog = 10
+			// optional number of seconds between reports
+			if len(args) > 1 {
+				if secs, err := strconv.Atoi(args[1]); err == nil && secs > 0 {
+					prog = secs
+					args = args[1:]
+				}
+			}
+			// final summary is the -timer report
+			timr = true
+		case "-total":
+			totl = eutils.GetNumericArg(args, "Expected record count", 0, 1, 0)
+			args = args[1:]
 		case "-profile":
 			prfl = true
 		case "-trial", "-trials":
@@ -343,6 +577,8 @@ func main() {
 
 	eutils.SetOptions(doStrict, doMixed, doSelf, deAccent, deSymbol, doASCII, doCompress, doCleanup, doStem, deStop)
 
+	eutils.SetMassOptions(massOpts)
+
 	// -stats prints number of CPUs and performance tuning values if no other arguments (undocumented)
 	if stts && len(args) < 1 {
 
@@ -386,7 +622,7 @@ func main() {
 
 	// FILE NAME CAN BE SUPPLIED WITH -input COMMAND
 
-	in := os.Stdin
+	var in io.Reader = os.Stdin
 
 	// check for data being piped into stdin
 	isPipe := false
@@ -399,16 +635,29 @@ func main() {
 
 	if fileName != "" {
 
-		inFile, err := os.Open(fileName)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open input file '%s'\n", fileName)
-			os.Exit(1)
-		}
+		files := eutils.ExpandInputFiles(fileName)
 
-		defer inFile.Close()
+		if len(files) == 1 && files[0] == fileName {
+
+			inFile, err := os.Open(fileName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to open input file '%s'\n", fileName)
+				os.Exit(1)
+			}
+
+			defer inFile.Close()
+
+			// use indicated file instead of stdin
+			in = inFile
+
+		} else {
+
+			// concatenate directory contents or glob matches, each decompressed as needed
+			in = eutils.CreateMultiFileReader(files, doGzip, !noGzip, strictFiles, parallelFiles)
+			doGzip = false
+			noGzip = true
+		}
 
-		// use indicated file instead of stdin
-		in = inFile
 		usingFile = true
 
 		if isPipe && runtime.GOOS != "windows" {
@@ -446,9 +695,21 @@ func main() {
 	recordCount := 0
 	byteCount := 0
 
+	// report bytes consumed and records processed while running
+	stopProgress := func() {}
+	if prog > 0 {
+		var inSize int64
+		if fl, ok := in.(*os.File); ok {
+			inSize = eutils.InputSize(fl)
+		}
+		in = eutils.CreateProgressReader(in)
+		stopProgress = eutils.StartProgress("records", time.Duration(prog)*time.Second, inSize, int64(totl))
+	}
+
 	// print processing rate and program duration
 	printDuration := func(name string) {
 
+		stopProgress()
 		eutils.PrintDuration(name, recordCount, byteCount)
 	}
 
@@ -457,7 +718,8 @@ func main() {
 	tform := ""
 	transform := make(map[string]string)
 
-	populateTx := func(tf string, special bool) {
+	// key and value columns are 1-based, zero for both splits each line at its first tab
+	populateTx := func(tf string, special bool, keyCol, valCol int, skipHeader bool) {
 
 		inFile, err := os.Open(tf)
 		if err != nil {
@@ -468,39 +730,91 @@ func main() {
 
 		scanr := bufio.NewScanner(inFile)
 
+		// report each conflicting key only once
+		warned := make(map[string]bool)
+
 		// populate transformation map for -translate, -keywords, and -matrix output
 		for scanr.Scan() {
 
 			line := scanr.Text()
 
+			if skipHeader {
+				skipHeader = false
+				continue
+			}
+
 			if special && strings.HasPrefix(line, "#") {
 				continue
 			}
 
 			frst, scnd := eutils.SplitInTwoLeft(line, "\t")
 
+			if keyCol > 0 && valCol > 0 {
+				cols := strings.Split(line, "\t")
+				if len(cols) < keyCol || len(cols) < valCol {
+					continue
+				}
+				frst = cols[keyCol-1]
+				scnd = cols[valCol-1]
+			}
+
 			if special && scnd == "-" {
 				delete(transform, frst)
-			} else {
-				transform[frst] = scnd
+				continue
 			}
+
+			if prev, ok := transform[frst]; ok && prev != scnd && !special && !warned[frst] {
+				warned[frst] = true
+				fmt.Fprintf(os.Stderr, "\nWARNING: Transformation key '%s' has conflicting values '%s' and '%s'\n", frst, prev, scnd)
+			}
+
+			transform[frst] = scnd
 		}
 	}
 
 	forClassify := false
 
 	if len(args) > 2 {
-		if args[0] == "-transform" || args[0] == "-aliases" || args[0] == "-transfigure" {
+		if args[0] == "-transform" || args[0] == "-aliases" || args[0] == "-classify-terms" || args[0] == "-transfigure" {
 			special := false
-			if args[0] == "-aliases" {
+			if args[0] == "-aliases" || args[0] == "-classify-terms" {
 				forClassify = true
 			} else if args[0] == "-transfigure" {
 				special = true
 			}
 			tform = args[1]
 			args = args[2:]
+			// optional column selection for multi-column files
+			keyCol := 1
+			valCol := 2
+			skipHeader := false
+			multiCol := false
+			for len(args) > 0 {
+				if args[0] == "-key" {
+					keyCol = eutils.GetNumericArg(args, "Transformation key column", 1, 1, 0)
+					multiCol = true
+					args = args[2:]
+				} else if args[0] == "-value" {
+					valCol = eutils.GetNumericArg(args, "Transformation value column", 2, 1, 0)
+					multiCol = true
+					args = args[2:]
+				} else if args[0] == "-header" {
+					skipHeader = true
+					args = args[1:]
+				} else if args[0] == "-noheader" {
+					skipHeader = false
+					args = args[1:]
+				} else {
+					break
+				}
+			}
+			if !multiCol {
+				// default keeps tabs within the value after the first column
+				keyCol = 0
+				valCol = 0
+			}
 			if tform != "" {
-				populateTx(tform, special)
+				populateTx(tform, special, keyCol, valCol, skipHeader)
 			}
 		}
 	}
@@ -538,6 +852,80 @@ func main() {
 
 		// data in pipe, so replace arguments, execute dynamically
 		args = insd
+
+		if len(args) > 1 && args[0] == "-join-features" {
+			joinFeatures = args[1]
+			args = args[2:]
+		}
+	}
+
+	// BIOC EXTRACTION COMMAND GENERATOR
+
+	// -bioc simplifies extraction of PMC passages in BioC format
+	if args[0] == "-bioc" {
+
+		args = args[1:]
+
+		bioc := eutils.ProcessBioC(args, isPipe || usingFile)
+
+		if !isPipe && !usingFile {
+			// no piped input, so write output instructions
+			fmt.Printf("xtract")
+			for _, str := range bioc {
+				fmt.Printf(" %s", str)
+			}
+			fmt.Printf("\n")
+			return
+		}
+
+		// data in pipe, so replace arguments, execute dynamically
+		args = bioc
+	}
+
+	// AUTHOR EXTRACTION COMMAND GENERATOR
+
+	// -authors simplifies extraction of per-author rows from PubMed records
+	if args[0] == "-authors" {
+
+		args = args[1:]
+
+		auth := eutils.ProcessAuthors(args, isPipe || usingFile)
+
+		if !isPipe && !usingFile {
+			// no piped input, so write output instructions
+			fmt.Printf("xtract")
+			for _, str := range auth {
+				fmt.Printf(" %s", str)
+			}
+			fmt.Printf("\n")
+			return
+		}
+
+		// data in pipe, so replace arguments, execute dynamically
+		args = auth
+	}
+
+	// TAXONOMY EXTRACTION COMMAND GENERATOR
+
+	// -tax simplifies extraction of lineage ranks from TaxaSet records
+	if args[0] == "-tax" {
+
+		args = args[1:]
+
+		tax := eutils.ProcessTaxonomy(args, isPipe || usingFile)
+
+		if !isPipe && !usingFile {
+			// no piped input, so write output instructions
+			fmt.Printf("xtract")
+			for _, str := range tax {
+				fmt.Printf(" %s", str)
+			}
+			fmt.Printf("\n")
+			return
+		}
+
+		// data in pipe, so replace arguments, execute dynamically
+		args = tax
 	}
 
 	// CITATION MATCHER EXTRACTION COMMAND GENERATOR
@@ -658,6 +1046,14 @@ func main() {
 				fmt.Fprintf(os.Stderr, "\nERROR: Pattern missing after -wrp command\n")
 				os.Exit(1)
 			}
+			if doCSV {
+				fmt.Fprintf(os.Stderr, "\nERROR: Cannot combine -wrp with -csv\n")
+				os.Exit(1)
+			}
+			if doJSON {
+				fmt.Fprintf(os.Stderr, "\nERROR: Cannot combine -wrp with -jsonpkg\n")
+				os.Exit(1)
+			}
 			tmp := eutils.ConvertSlash(args[1])
 			lft, rgt := eutils.SplitInTwoLeft(tmp, ",")
 			if lft != "" {
@@ -708,12 +1104,15 @@ func main() {
 
 	// CREATE XML BLOCK READER FROM STDIN OR FILE
 
+	// gzip-compressed input is detected on first read
+	src := eutils.DecompressIfGzipped(in, doGzip, !noGzip)
+
 	const FirstBuffSize = 4096
 
 	getFirstBlock := func() string {
 
 		buffer := make([]byte, FirstBuffSize)
-		n, err := in.Read(buffer)
+		n, err := src.Read(buffer)
 		if err != nil && err != io.EOF {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to read first block: %s\n", err.Error())
 			// os.Exit(1)
@@ -724,7 +1123,7 @@ func main() {
 
 	first := getFirstBlock()
 
-	mlt := io.MultiReader(strings.NewReader(first), in)
+	mlt := io.MultiReader(strings.NewReader(first), src)
 
 	isJsn := false
 	isAsn := false
@@ -790,7 +1189,7 @@ func main() {
 		jrdr := eutils.JSONConverter(mlt, "root", "", "element")
 		mlt = eutils.ChanToReader(jrdr)
 	} else if isAsn {
-		ardr := eutils.ASN1Converter(mlt, "", "")
+		ardr := eutils.ASN1Converter(mlt, "", "", false)
 		mlt = eutils.ChanToReader(ardr)
 	} else if isGbf {
 		grdr := eutils.GenBankConverter(mlt)
@@ -1043,6 +1442,79 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nERROR: -pattern Parent/Child construct is not supported\n")
 		os.Exit(1)
 	}
+	if star != "" && strings.Contains(topPattern, ",") {
+		fmt.Fprintf(os.Stderr, "\nERROR: -pattern alternatives cannot be combined with Parent/* construct\n")
+		os.Exit(1)
+	}
+
+	// SPLIT RECORDS INTO SEPARATE FILES
+
+	// -split -pattern record_name -key parent/element@attribute^version -dir path [-suffix .xml] [-trie] [-overwrite]
+	if doSplit {
+
+		key := ""
+		dir := ""
+		sfx := ".xml"
+		trie := false
+		clobber := false
+
+		splt := args[2:]
+		for len(splt) > 0 {
+			switch splt[0] {
+			case "-key":
+				key = eutils.GetStringArg(splt, "Split key")
+				splt = splt[1:]
+			case "-dir":
+				dir = eutils.GetStringArg(splt, "Split directory")
+				splt = splt[1:]
+			case "-suffix":
+				sfx = eutils.GetStringArg(splt, "Split file suffix")
+				splt = splt[1:]
+			case "-trie":
+				trie = true
+			case "-overwrite":
+				clobber = true
+			case "-noclobber":
+				clobber = false
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -split argument '%s'\n", splt[0])
+				os.Exit(1)
+			}
+			splt = splt[1:]
+		}
+
+		if key == "" {
+			fmt.Fprintf(os.Stderr, "\nERROR: -split requires -key argument\n")
+			os.Exit(1)
+		}
+		if dir == "" {
+			dir = "."
+		}
+
+		xmlq := eutils.CreateXMLProducer(topPattern, star, false, rdr)
+		splq := eutils.CreateSplitters(dir, topPattern, key, hd, tl, sfx, trie, clobber, xmlq)
+
+		if xmlq == nil || splq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create splitter\n")
+			os.Exit(1)
+		}
+
+		// drain output channel
+		for range splq {
+			recordCount++
+			runtime.Gosched()
+		}
+
+		fmt.Fprintf(os.Stderr, "%d records written to %s\n", recordCount, dir)
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
 
 	// SAVE ONLY RECORDS WITH NON-ASCII CHARACTERS
 
@@ -1550,17 +2022,118 @@ func main() {
 
 	// PARSE AND VALIDATE EXTRACTION ARGUMENTS
 
+	// leading options have been removed, so remaining arguments that end the command line can cite their positions
+	var argPosn []int
+	if ofs := len(cmdline) - len(args); ofs >= 0 {
+		same := true
+		for i, str := range args {
+			if cmdline[ofs+i] != str {
+				same = false
+				break
+			}
+		}
+		if same {
+			for i := range args {
+				argPosn = append(argPosn, ofs+i+1)
+			}
+		}
+	}
+
+	// -csv and -jsonpkg are passed along to ParseArguments, which applies them to every block
+	if doCSV {
+		args = append(args, "-csv")
+	}
+	for _, txt := range args {
+		// -jsonpkg can also follow -pattern
+		if txt == "-jsonpkg" {
+			doJSON = true
+		}
+	}
+	if doJSON {
+		args = append(args, "-jsonpkg")
+		// each record is an object, and the set is an array
+		head = "["
+		tail = "]"
+		hd = "{"
+		tl = "}"
+	}
+
+	// -topn and -hist-xml control -histogram and -group-by output, and are removed before parsing
+	topN := 0
+	histXML := false
+	// -tee copies the raw XML of each record that produces output to a file
+	teeFile := ""
+	teeHead := ""
+	teeTail := ""
+	var remaining []string
+	var remainingPosn []int
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-topn":
+			topN = eutils.GetNumericArg(args[i:], "Number of most frequent values", 0, 1, 0)
+			i++
+		case "-hist-xml":
+			histXML = true
+		case "-tee":
+			teeFile = eutils.GetStringArg(args[i:], "Tee file name")
+			i++
+		case "-tee-head":
+			teeHead = eutils.GetStringArg(args[i:], "Tee file header")
+			i++
+		case "-tee-tail":
+			teeTail = eutils.GetStringArg(args[i:], "Tee file trailer")
+			i++
+		default:
+			remaining = append(remaining, args[i])
+			if argPosn != nil {
+				// appended -csv and -jsonpkg have no original position
+				pos := 0
+				if i < len(argPosn) {
+					pos = argPosn[i]
+				}
+				remainingPosn = append(remainingPosn, pos)
+			}
+		}
+	}
+	args = remaining
+
+	eutils.SetArgumentPositions(remainingPosn)
+
 	// parse nested exploration instruction from command-line arguments
 	cmds := eutils.ParseArguments(args, topPattern)
+
+	eutils.SetArgumentPositions(nil)
+
+	// count of deprecation warnings is repeated after output
+	defer eutils.PrintDeprecationSummary()
+
 	if cmds == nil {
 		fmt.Fprintf(os.Stderr, "\nERROR: Problem parsing command-line arguments\n")
 		os.Exit(1)
 	}
 
+	// header row is printed after any -head text, and only if there is extraction output
+	if doHeader && !doJSON {
+		hdr := eutils.ColumnHeader(cmds)
+		if head != "" {
+			head += "\n" + hdr
+		} else {
+			head = hdr
+		}
+	}
+
 	// GLOBAL MAP FOR SORT-UNIQ-COUNT HISTOGRAM ARGUMENT
 
 	histogram := make(map[string]int)
 
+	// -group-by shares the histogram map, but prints counts in decreasing order
+	groupBy := false
+	for _, txt := range args {
+		if txt == "-group-by" {
+			groupBy = true
+		}
+	}
+
 	// PERFORMANCE TIMING COMMAND
 
 	// -stats with an extraction command prints XML size and processing time for each record
@@ -1697,6 +2270,9 @@ func main() {
 		idx := 0
 		rec := 0
 
+		// multiple records selected by a range
+		var extra []eutils.XMLRecord
+
 		if cmds.Position == "first" {
 
 			eutils.PartitionXML(topPattern, star, turbo, rdr,
@@ -1718,51 +2294,198 @@ func main() {
 
 		} else {
 
-			// use numeric position
-			number, err := strconv.Atoi(cmds.Position)
-			if err != nil {
+			// use numeric position or range
+			beg, end, isRange, ok := eutils.ParsePosition(cmds.Position)
+			if !ok {
 				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized position '%s'\n", cmds.Position)
 				os.Exit(1)
 			}
 
+			// negative index counts from end, so collect all records first
+			fromEnd := beg < 0 || end < 0
+			var all []string
+
 			eutils.PartitionXML(topPattern, star, turbo, rdr,
 				func(str string) {
 					rec++
-					if rec == number {
+					if fromEnd {
+						all = append(all, str)
+					} else if isRange {
+						if (beg == 0 || rec >= beg) && (end == 0 || rec <= end) {
+							extra = append(extra, eutils.XMLRecord{Index: rec, Text: str})
+						}
+					} else if rec == beg {
 						qry = str
 						idx = rec
 					}
 				})
+
+			if fromEnd {
+				lo, hi := eutils.PositionBounds(len(all), beg, end, isRange)
+				for i := lo; i <= hi; i++ {
+					extra = append(extra, eutils.XMLRecord{Index: i, Text: all[i-1]})
+				}
+			}
+		}
+
+		if qry != "" {
+			extra = append(extra, eutils.XMLRecord{Index: idx, Text: qry})
 		}
 
-		if qry == "" {
+		if len(extra) < 1 {
 			return
 		}
 
 		// clear position on top node to prevent condition test failure
 		cmds.Position = ""
 
-		// process single selected record
-		res := eutils.ProcessExtract(qry[:], parent, idx, hd, tl, transform, nil, histogram, cmds)
+		// process selected records in order
+		var results []string
+		for _, sel := range extra {
+			res := eutils.ProcessExtract(sel.Text[:], parent, sel.Index, hd, tl, transform, nil, histogram, cmds)
+			if res != "" {
+				results = append(results, res)
+			}
+		}
 
-		if res != "" {
+		if doJSON {
+			for i, res := range results {
+				if i < len(results)-1 {
+					results[i] = strings.TrimSuffix(res, "\n") + ",\n"
+				}
+			}
+			fmt.Printf("%s\n%s%s\n", head, strings.Join(results, ""), tail)
+			return
+		}
+
+		for _, res := range results {
 			fmt.Printf("%s", res)
 		}
 
 		return
 	}
 
+	// -on and -join-sep only modify -join-records
+	if joinRecords && joinOn == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -join-records requires an -on identifier\n")
+		os.Exit(1)
+	}
+	if !joinRecords && joinOn != "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -on requires -join-records\n")
+		os.Exit(1)
+	}
+	if joinRecords && sortRecords {
+		fmt.Fprintf(os.Stderr, "\nERROR: -join-records cannot be combined with -sort-records\n")
+		os.Exit(1)
+	}
+
+	// -by, -numeric, and -reverse only modify -sort-records
+	if sortRecords && len(sortBy) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: -sort-records requires at least one -by element\n")
+		os.Exit(1)
+	}
+	if !sortRecords && (len(sortBy) > 0 || sortNumeric || sortReverse || sortMem > 0) {
+		fmt.Fprintf(os.Stderr, "\nERROR: -by, -numeric, -reverse, and -sort-mem require -sort-records\n")
+		os.Exit(1)
+	}
+
+	// consumers extract the first value of each key
+	if sortRecords {
+		var keys []*eutils.Block
+		for _, by := range sortBy {
+			keys = append(keys, eutils.ParseArguments([]string{"-pattern", topPat, "-first", by}, topPattern))
+		}
+		eutils.SetRecordSort(keys, sortNumeric, sortReverse, sortMem<<20)
+	}
+
 	// LAUNCH PRODUCER, CONSUMER, AND UNSHUFFLER GOROUTINES
 
+	// apply backpressure from slow output consumers before launching producer
+	if flushOutput {
+		eutils.SetFlushOutput(true)
+	}
+
+	// restrict processing to a window of records, keeping absolute record indices
+	if skipRecords > 0 || takeRecords > 0 {
+		eutils.SetRecordWindow(skipRecords, takeRecords)
+	}
+
+	// defer -head until there is output
+	if suppressEmpty {
+		eutils.SetSuppressEmpty(true)
+	}
+
+	// skip or fail on records over the size limits
+	if maxRecordBytes > 0 || maxRecordNodes > 0 {
+		eutils.SetRecordLimits(maxRecordBytes, maxRecordNodes, failOnLimit)
+	}
+
+	// consumers keep raw text of records with output for -tee
+	if teeFile != "" {
+		eutils.SetRecordTee(true)
+	}
+
 	// launch producer goroutine to partition XML by pattern
 	xmlq := eutils.CreateXMLProducer(topPattern, star, turbo, rdr)
 
+	// drop records whose identifier has already been seen
+	if dedupBy != "" {
+		xmlq = eutils.CreateDeduplicator(topPattern, dedupBy, dedupLast, xmlq)
+	}
+
+	// save identifier of each record for merging rows
+	if joinRecords {
+		xmlq = eutils.CreateRecordKeyer(topPattern, joinOn, xmlq)
+	}
+
 	// launch consumer goroutines to parse and explore partitioned XML objects
 	tblq := eutils.CreateXMLConsumers(cmds, parent, hd, tl, transform, forClassify, histogram, xmlq)
 
 	// launch unshuffler goroutine to restore order of results
 	unsq := eutils.CreateXMLUnshuffler(tblq)
 
+	// set aside the producer's report of truncated input until all complete records are written
+	var truncated *eutils.XMLTruncation
+	if unsq != nil {
+		trnq := make(chan eutils.XMLRecord, eutils.ChanDepth())
+		go func(inp <-chan eutils.XMLRecord) {
+			defer close(trnq)
+			for curr := range inp {
+				if curr.Truncated != nil {
+					truncated = curr.Truncated
+					continue
+				}
+				trnq <- curr
+			}
+		}(unsq)
+		unsq = trnq
+	}
+
+	// write raw records to -tee file in output order
+	if teeFile != "" {
+		unsq = eutils.CreateRecordTee(teeFile, teeHead, teeTail, unsq)
+	}
+
+	// combine -insd -joined rows after restoring their order
+	if joinFeatures != "" {
+		unsq = eutils.CreateFeatureJoiner(joinFeatures, unsq)
+	}
+
+	// merge rows with the same identifier
+	if joinRecords {
+		unsq = eutils.CreateRecordJoiner(joinSep, unsq)
+	}
+
+	// hold all results and send them in key order
+	if sortRecords {
+		unsq = eutils.CreateRecordSorter(unsq)
+	}
+
+	// separate -jsonpkg records with commas after restoring their order
+	if doJSON {
+		unsq = eutils.CreateJSONSeparator(unsq)
+	}
+
 	if xmlq == nil || tblq == nil || unsq == nil {
 		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create servers\n")
 		os.Exit(1)
@@ -1805,9 +2528,43 @@ func main() {
 
 	// DRAIN OUTPUT CHANNEL TO EXECUTE EXTRACTION COMMANDS, RESTORE OUTPUT ORDER WITH HEAP
 
-	recordCount, byteCount = eutils.DrainExtractions(head, tail, posn, mpty, idnt, histogram, unsq)
+	recordCount, byteCount = eutils.DrainExtractions(head, tail, posn, mpty, idnt, nil, unsq)
+
+	// -jsonpkg with no matching records is still a valid, empty, array
+	if doJSON && !suppressEmpty && eutils.EmptyOutput() {
+		os.Stdout.WriteString("[]\n")
+	}
+
+	// truncated input is an error unless -lenient was requested
+	if truncated != nil {
+		if !lenientInput {
+			fmt.Fprintf(os.Stderr, "\nERROR: %s\n", truncated.Error())
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "\nWARNING: %s\n", truncated.Error())
+		skipped := 0
+		if truncated.Record {
+			skipped = 1
+		}
+		fmt.Fprintf(os.Stderr, "\nWARNING: Skipped %d truncated record(s)\n", skipped)
+	}
+
+	if requireAll {
+		fmt.Fprintf(os.Stderr, "discarded: %d\n", eutils.DiscardedRecords())
+	}
+
+	if groupBy {
+		eutils.PrintGroupCounts(histogram, topN)
+	} else {
+		eutils.PrintHistogram(histogram, topN, histXML)
+	}
 
 	if timr {
 		printDuration("records")
 	}
+
+	// distinct status lets scripts tell an empty result from success
+	if suppressEmpty && eutils.EmptyOutput() {
+		os.Exit(2)
+	}
 }
@@ -35,15 +35,40 @@ import (
 	"fmt"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
+	"golang.org/x/text/width"
+	"html"
 	"io"
 	"os"
 	"strconv"
 	"strings"
-	"unicode/utf8"
+	"unicode"
 )
 
 // Inspired by Steve Kinzler's align script - see http://kinzler.com/me/align/
 
+// displayWidth returns the number of monospace terminal cells needed to show a string,
+// counting East Asian wide characters as two cells and combining marks as none
+func displayWidth(str string) int {
+
+	if IsNotASCII(str) {
+		wd := 0
+		for _, ch := range str {
+			if unicode.In(ch, unicode.Mn, unicode.Me, unicode.Cf) {
+				continue
+			}
+			switch width.LookupRune(ch).Kind() {
+			case width.EastAsianWide, width.EastAsianFullwidth:
+				wd += 2
+			default:
+				wd++
+			}
+		}
+		return wd
+	}
+
+	return len(str)
+}
+
 // AlignColumns aligns a tab-delimited table to the computed widths of individual columns.
 func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-chan string {
 
@@ -193,7 +218,7 @@ func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-c
 				flds = append(flds, str)
 
 				// determine maximum length of current column
-				ln := utf8.RuneCountInString(str)
+				ln := displayWidth(str)
 				if ln > width[i] {
 					width[i] = ln
 				}
@@ -207,11 +232,11 @@ func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-c
 							fr = "." + fr
 						}
 
-						lf := utf8.RuneCountInString(wh)
+						lf := displayWidth(wh)
 						if lf > whole[i] {
 							whole[i] = lf
 						}
-						rt := utf8.RuneCountInString(fr)
+						rt := displayWidth(fr)
 						if rt > fract[i] {
 							fract[i] = rt
 						}
@@ -281,8 +306,8 @@ func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-c
 					code = lst
 				}
 
-				// accommodate multi-byte characters like Greek letter beta
-				ln := utf8.RuneCountInString(str)
+				// accommodate multi-byte characters like Greek letter beta, and wide or combining characters
+				ln := displayWidth(str)
 
 				mx := width[i]
 				diff := mx - ln
@@ -320,14 +345,16 @@ func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-c
 							rc := fract[i]
 							wh, fr := SplitInTwoLeft(str, ".")
 							if fract[i] > 0 {
-								if fr == "" {
-									fr = "."
-								} else {
+								if fr != "" || strings.HasSuffix(str, ".") {
 									fr = "." + fr
+								} else if rgtPad == "0" {
+									// zero-padded decimals need a decimal point
+									fr = "."
 								}
-								lf := utf8.RuneCountInString(wh)
-								lft = sn - lf
-								rt := utf8.RuneCountInString(fr)
+								lf := displayWidth(wh)
+								rt := displayWidth(fr)
+								// aligned numbers are right-justified as a block when a header is wider
+								lft = mx - sn - rc + sn - lf
 								rgt = rc - rt
 								str = wh + fr
 							}
@@ -366,3 +393,182 @@ func AlignColumns(inp io.Reader, margin, padding, minimum int, align string) <-c
 
 	return out
 }
+
+// FormatTable renders a tab-delimited table as a GitHub-flavored Markdown table ("md") or as
+// a minimal HTML table ("html"), using the first row as the header. Alignment codes follow
+// AlignColumns, with the last letter repeated as needed.
+func FormatTable(inp io.Reader, format, align string) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	if format != "md" && format != "html" {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized table format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	out := make(chan string, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "Unable to create table format channel\n")
+		os.Exit(1)
+	}
+
+	// alignment of a column, empty if no code string
+	justify := func(i int) string {
+
+		if align == "" {
+			return ""
+		}
+
+		code := rune(align[len(align)-1])
+		if i < len(align) {
+			code = rune(align[i])
+		}
+
+		switch code {
+		case 'l':
+			return "left"
+		case 'c':
+			return "center"
+		case 'r', 'n', 'N', 'z', 'Z', 'm', 'M':
+			return "right"
+		}
+
+		return ""
+	}
+
+	formatTable := func(inp io.Reader, out chan<- string) {
+
+		// close channel when all rows have been sent
+		defer close(out)
+
+		var rows [][]string
+		numCols := 0
+
+		scanr := bufio.NewScanner(inp)
+
+		for scanr.Scan() {
+
+			line := scanr.Text()
+			if line == "" {
+				continue
+			}
+
+			cols := strings.Split(line, "\t")
+			for i, str := range cols {
+				cols[i] = strings.TrimSpace(CompressRunsOfSpaces(str))
+			}
+			// empty trailing fields do not add columns beyond the header
+			if len(rows) > 0 {
+				for len(cols) > numCols && cols[len(cols)-1] == "" {
+					cols = cols[:len(cols)-1]
+				}
+			}
+			if len(cols) > numCols {
+				numCols = len(cols)
+			}
+			rows = append(rows, cols)
+		}
+
+		if len(rows) < 1 {
+			return
+		}
+
+		var buffer strings.Builder
+
+		if format == "md" {
+
+			mdCell := func(str string) string {
+				if str == "" {
+					return " "
+				}
+				// escape pipes, and keep angle brackets from being read as inline HTML
+				str = strings.ReplaceAll(str, "|", "\\|")
+				return strings.ReplaceAll(str, "<", "&lt;")
+			}
+
+			for r, cols := range rows {
+				buffer.Reset()
+				buffer.WriteString("|")
+				for i := 0; i < numCols; i++ {
+					str := ""
+					if i < len(cols) {
+						str = cols[i]
+					}
+					buffer.WriteString(" ")
+					buffer.WriteString(mdCell(str))
+					buffer.WriteString(" |")
+				}
+				buffer.WriteString("\n")
+
+				if r == 0 {
+					// separator line after header carries column alignment
+					buffer.WriteString("|")
+					for i := 0; i < numCols; i++ {
+						switch justify(i) {
+						case "left":
+							buffer.WriteString(" :--- |")
+						case "center":
+							buffer.WriteString(" :---: |")
+						case "right":
+							buffer.WriteString(" ---: |")
+						default:
+							buffer.WriteString(" --- |")
+						}
+					}
+					buffer.WriteString("\n")
+				}
+
+				out <- buffer.String()
+			}
+
+			return
+		}
+
+		out <- "<table>\n"
+
+		for r, cols := range rows {
+			tag := "td"
+			if r == 0 {
+				tag = "th"
+				out <- "<thead>\n"
+			} else if r == 1 {
+				out <- "<tbody>\n"
+			}
+
+			buffer.Reset()
+			buffer.WriteString("<tr>")
+			for i := 0; i < numCols; i++ {
+				str := ""
+				if i < len(cols) {
+					str = cols[i]
+				}
+				buffer.WriteString("<" + tag)
+				if just := justify(i); just != "" {
+					buffer.WriteString(" style=\"text-align:" + just + "\"")
+				}
+				buffer.WriteString(">")
+				buffer.WriteString(html.EscapeString(str))
+				buffer.WriteString("</" + tag + ">")
+			}
+			buffer.WriteString("</tr>\n")
+
+			out <- buffer.String()
+
+			if r == 0 {
+				out <- "</thead>\n"
+			}
+		}
+
+		if len(rows) > 1 {
+			out <- "</tbody>\n"
+		}
+		out <- "</table>\n"
+	}
+
+	// launch single table format goroutine
+	go formatTable(inp, out)
+
+	return out
+}
@@ -0,0 +1,81 @@
+package eutils
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// alignFile runs AlignColumns on a test file and returns the result
+func alignFile(t *testing.T, fname, align string) string {
+
+	t.Helper()
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf strings.Builder
+	for str := range AlignColumns(f, 0, 2, 0, align) {
+		buf.WriteString(str)
+	}
+
+	return buf.String()
+}
+
+func TestAlignColumnsGolden(t *testing.T) {
+
+	// mixes integers, decimals, accented text, and East Asian wide characters
+	input := filepath.Join("testdata", "align_mixed.txt")
+
+	for _, align := range []string{"lrnnl", "c", "lrNzr", "lrmM"} {
+
+		got := alignFile(t, input, align)
+
+		golden := filepath.Join("testdata", "align_mixed_"+align+".golden")
+		if *updateGolden {
+			if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("-a %s: got\n%s\nwant\n%s", align, got, want)
+		}
+	}
+
+	// M code groups the Count column by 3 digits
+	if got := alignFile(t, input, "lrmM"); !strings.Contains(got, " 1,024 ") {
+		t.Errorf("-a lrmM: missing grouped count 1,024 in\n%s", got)
+	}
+}
+
+func TestAlignColumnsDecimalPoints(t *testing.T) {
+
+	got := alignFile(t, filepath.Join("testdata", "align_mixed.txt"), "lrnnl")
+
+	// decimal points, or the ends of integers, fall in the same terminal cell on every row
+	col := -1
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n")[1:] {
+		fields := strings.Fields(line)
+		score := fields[len(fields)-3]
+		pos := strings.Index(line, " "+score+" ") + 1
+		wh, _ := SplitInTwoLeft(score, ".")
+		cell := displayWidth(line[:pos]) + len(wh)
+		if col < 0 {
+			col = cell
+		} else if cell != col {
+			t.Errorf("decimal point of %s is in cell %d, want %d", score, cell, col)
+		}
+	}
+}
@@ -32,6 +32,8 @@ package eutils
 
 import (
 	"bufio"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"html"
 	"io"
@@ -40,14 +42,47 @@ import (
 	"strings"
 )
 
-// ASN1Converter parses text ASN.1 records into XML objects
-func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
+// asnToken records where each token starts, for reporting parse errors
+type asnToken struct {
+	text string
+	row  int
+	offs int
+}
+
+// asnSnippet returns text surrounding a column, with a caret line marking the position
+func asnSnippet(line string, col int) string {
+
+	if col > len(line) {
+		col = len(line)
+	}
+
+	beg := col - 30
+	pfx := "..."
+	if beg <= 0 {
+		beg = 0
+		pfx = ""
+	}
+	end := col + 30
+	sfx := "..."
+	if end >= len(line) {
+		end = len(line)
+		sfx = ""
+	}
+
+	txt := pfx + line[beg:end] + sfx
+	caret := strings.Repeat(" ", len(pfx)+col-beg) + "^"
+
+	return "  " + txt + "\n  " + caret
+}
+
+// ASN1Converter parses text ASN.1 records into XML objects, optionally converting hex strings to base64
+func ASN1Converter(inp io.Reader, set, rec string, b64 bool) <-chan string {
 
 	if inp == nil {
 		return nil
 	}
 
-	tks := make(chan string, chanDepth)
+	tks := make(chan asnToken, chanDepth)
 	out := make(chan string, chanDepth)
 	if tks == nil || out == nil {
 		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create ASN1 converter channels\n")
@@ -55,7 +90,7 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 	}
 
 	// tokenizeASN1 sends ASN1 tokens down a channel
-	tokenizeASN1 := func(inp io.Reader, tks chan<- string) {
+	tokenizeASN1 := func(inp io.Reader, tks chan<- asnToken) {
 
 		// close channel when all tokens have been sent
 		defer close(tks)
@@ -63,11 +98,18 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 		var buf strings.Builder
 
 		scanr := bufio.NewScanner(inp)
+		// allow long lines of sequence data
+		scanr.Buffer(make([]byte, 0, 65536), 64*1024*1024)
 
 		row := 0
 		idx := 0
 		line := ""
 
+		// current line and byte offset of its start, for error reporting
+		orig := ""
+		start := 0
+		next := 0
+
 		sentinel := string(rune(0))
 
 		nextLine := func() string {
@@ -76,19 +118,47 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 				// read line
 				line := scanr.Text()
 				row++
+				start = next
+				next += len(line) + 1
 				if line == "" {
 					// ignore blank lines
 					continue
 				}
 				// add sentinel
 				line += sentinel
+				orig = line
 				return line
 			}
 
+			if err := scanr.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to read ASN.1 after line %d, byte offset %d: %s\n", row, next, err.Error())
+				os.Exit(1)
+			}
+
 			// end of data
 			return sentinel
 		}
 
+		// column of unprocessed text within current line
+		column := func() int {
+			return len(orig) - len(line)
+		}
+
+		send := func(tkn string, col int) {
+			tks <- asnToken{text: tkn, row: row, offs: start + col}
+		}
+
+		failAt := func(msg string, row, offs int, text string, col int) {
+			fmt.Fprintf(os.Stderr, "\nERROR: %s at line %d, byte offset %d\n", msg, row, offs)
+			fmt.Fprintf(os.Stderr, "%s\n", asnSnippet(strings.TrimSuffix(text, sentinel), col))
+			os.Exit(1)
+		}
+
+		fail := func(msg string) {
+			col := column()
+			failAt(msg, row, start+col, orig, col)
+		}
+
 		readRestOfAsnString := func() string {
 
 			// continue reading additional lines of string
@@ -189,22 +259,24 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 				line = line[idx:]
 				idx = 0
 
+				col := column()
+
 				if ch == ',' {
-					tks <- string(ch)
+					send(string(ch), col)
 					line = line[1:]
 					continue
 				}
 
 				if ch == '{' {
 					// start structure
-					tks <- string(ch)
+					send(string(ch), col)
 					line = line[1:]
 					continue
 				}
 
 				if ch == '}' {
 					// end structure
-					tks <- string(ch)
+					send(string(ch), col)
 					line = line[1:]
 					continue
 				}
@@ -213,6 +285,7 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 					// "
 					// start of string
 					buf.Reset()
+					srow, sofs := row, start+col
 
 					// skip past opening quote
 					line = line[1:]
@@ -249,7 +322,7 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 						tmp = "\"\""
 					}
 
-					tks <- tmp
+					tks <- asnToken{text: tmp, row: srow, offs: sofs}
 					buf.Reset()
 
 					continue
@@ -258,6 +331,7 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 				if ch == '\'' {
 					// start of bit string
 					buf.Reset()
+					srow, sofs, sorig := row, start+col, orig
 
 					// skip past opening apostrophe
 					line = line[1:]
@@ -292,52 +366,95 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 						line = nextLine()
 					}
 
-					// then skip past trailing hex or binary indicator
-					if strings.HasPrefix(line, "H") || strings.HasPrefix(line, "B") {
+					// blanks are tolerated before the hex or binary indicator
+					line = strings.TrimLeft(line, " \t")
+
+					// remove line breaks and indentation from value
+					tmp := strings.Map(func(r rune) rune {
+						if r == ' ' || r == '\t' {
+							return -1
+						}
+						return r
+					}, buf.String())
+					buf.Reset()
+
+					if strings.HasPrefix(line, "H") {
 						line = line[1:]
-						idx = 0
+						for i, r := range tmp {
+							if !strings.ContainsRune("0123456789ABCDEFabcdef", r) {
+								// report from start of string
+								failAt(fmt.Sprintf("Invalid character '%c' at position %d of ASN.1 hex string", r, i+1), srow, sofs, sorig, col)
+							}
+						}
+						if b64 && tmp != "" {
+							if len(tmp)%2 != 0 {
+								// odd number of hex digits implies trailing zero
+								tmp += "0"
+							}
+							bts, _ := hex.DecodeString(tmp)
+							tmp = base64.StdEncoding.EncodeToString(bts)
+						}
+					} else if strings.HasPrefix(line, "B") {
+						line = line[1:]
+					} else {
+						fail("Expected H or B after closing apostrophe of ASN.1 string")
 					}
 
-					tks <- buf.String()
-					buf.Reset()
+					if tmp == "" {
+						// encode empty string
+						tmp = "\"\""
+					}
+
+					tks <- asnToken{text: tmp, row: srow, offs: sofs}
 
 					continue
 				}
 
 				if ch == ':' && strings.HasPrefix(line, "::=") {
 					// start of record contents
-					tks <- "::="
+					send("::=", col)
 					line = line[3:]
 					idx = 0
 					continue
 				}
 
 				if ch == '-' && strings.HasPrefix(line, "--") {
-					// skip comments
-					break
+					// comment ends at next double hyphen or at end of line
+					pos := strings.Index(line[2:], "--")
+					if pos < 0 {
+						break
+					}
+					line = line[pos+4:]
+					continue
 				}
 				if ch == ';' {
 					// skip comments
 					break
 				}
 
-				// read token or unquoted numeric value
+				// read token or unquoted numeric value, stopping at comment
 				idx = 0
 				for inAsnTag[ch] {
+					if ch == '-' && idx > 0 && line[idx+1] == '-' {
+						break
+					}
 					idx++
 					ch = line[idx]
 				}
+				if idx == 0 {
+					fail(fmt.Sprintf("Unexpected character '%c' in ASN.1", ch))
+				}
 				tkn := line[:idx]
 				line = line[idx:]
 				idx = 0
 
-				tks <- tkn
+				send(tkn, col)
 			}
 		}
 	}
 
 	// convertASN1 sends XML records down a channel
-	convertASN1 := func(inp <-chan string, out chan<- string) {
+	convertASN1 := func(inp <-chan asnToken, out chan<- string) {
 
 		// close channel when all tokens have been processed
 		defer close(out)
@@ -373,6 +490,10 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 			return temp.String()
 		}
 
+		// recent tokens and position of latest one, for error reporting
+		var recent []string
+		last := asnToken{}
+
 		nextToken := func() string {
 
 			for {
@@ -380,18 +501,30 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 				if !ok {
 					break
 				}
-				if tkn == "" {
+				if tkn.text == "" {
 					// ignore blank tokens
 					continue
 				}
 
-				return tkn
+				last = tkn
+				recent = append(recent, tkn.text)
+				if len(recent) > 8 {
+					recent = recent[1:]
+				}
+
+				return tkn.text
 			}
 
 			// end of data
 			return ""
 		}
 
+		fail := func(msg string) {
+			fmt.Fprintf(os.Stderr, "\nERROR: %s at line %d, byte offset %d\n", msg, last.row, last.offs)
+			fmt.Fprintf(os.Stderr, "  near: %s\n", strings.Join(recent, " "))
+			os.Exit(1)
+		}
+
 		// collects tags until next brace or comma
 		var arry []string
 
@@ -493,6 +626,9 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 			for {
 				tkn := nextToken()
 				if tkn == "" {
+					if lvl > 0 {
+						fail("Unexpected end of data inside ASN.1 structure")
+					}
 					return
 				}
 
@@ -554,8 +690,7 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 					}
 					return
 				case "::=":
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected ::= token found\n")
-					os.Exit(1)
+					fail("Unexpected ::= token found")
 				default:
 					arry = append(arry, tkn)
 				}
@@ -594,12 +729,10 @@ func ASN1Converter(inp io.Reader, set, rec string) <-chan string {
 
 			tkn := nextToken()
 			if tkn == "" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Incomplete ASN1 starting with '%s'\n", top)
-				os.Exit(1)
+				fail(fmt.Sprintf("Incomplete ASN1 starting with '%s'", top))
 			}
 			if tkn != "::=" {
-				fmt.Fprintf(os.Stderr, "\nERROR: ASN1 message missing expected ::= token, found '%s'\n", tkn)
-				os.Exit(1)
+				fail(fmt.Sprintf("ASN1 message missing expected ::= token, found '%s'", tkn))
 			}
 
 			parseAsnObject(top, 0)
@@ -636,7 +769,7 @@ func ASNtoXML(asn, set, rec string) string {
 		return ""
 	}
 
-	acnv := ASN1Converter(strings.NewReader(asn), set, rec)
+	acnv := ASN1Converter(strings.NewReader(asn), set, rec, false)
 	if acnv == nil {
 		fmt.Fprintf(os.Stderr, "Unable to create ASN.1 converter\n")
 		return ""
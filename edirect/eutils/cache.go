@@ -773,6 +773,203 @@ func CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString string, hash, zi
 	return out
 }
 
+// SanitizeFileName replaces characters that are unsafe in file names
+func SanitizeFileName(str string) string {
+
+	var buffer strings.Builder
+
+	for _, ch := range str {
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '.' || ch == '-' || ch == '_' {
+			buffer.WriteRune(ch)
+		} else {
+			buffer.WriteRune('_')
+		}
+	}
+
+	res := buffer.String()
+
+	// prevent hidden files and relative path components
+	res = strings.TrimLeft(res, ".")
+
+	return res
+}
+
+// CreateSplitters writes each record to a separate file named by an extracted identifier,
+// optionally placed in trie subdirectories, and sends the file path down the output channel
+func CreateSplitters(dir, parent, indx, hd, tl, sfx string, trie, clobber bool, inp <-chan XMLRecord) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan string, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create splitter channel\n")
+		os.Exit(1)
+	}
+
+	find := ParseIndex(indx)
+
+	type SplitterType int
+
+	const (
+		OKAY SplitterType = iota
+		WAIT
+		BAIL
+	)
+
+	// mutex to protect access to inUse map
+	var wlock sync.Mutex
+
+	// map to track files currently being written
+	inUse := make(map[string]int)
+
+	// lockFile function prevents colliding writes, later record with same key wins
+	lockFile := func(id string, index int) SplitterType {
+
+		wlock.Lock()
+		defer wlock.Unlock()
+
+		idx, ok := inUse[id]
+
+		if ok {
+			if index < idx {
+				return BAIL
+			}
+			return WAIT
+		}
+
+		inUse[id] = index
+		return OKAY
+	}
+
+	freeFile := func(id string) {
+
+		wlock.Lock()
+		delete(inUse, id)
+		wlock.Unlock()
+	}
+
+	// splitRecord saves individual XML record to its own file
+	splitRecord := func(str, id string, index int) string {
+
+		key := SanitizeFileName(id)
+		if key == "" {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Skipping record %d with unusable key '%s'\n", index, id)
+			return ""
+		}
+
+		dpath := dir
+		if trie {
+			sub, _ := ArchiveTrie(key)
+			if sub != "" {
+				dpath = filepath.Join(dir, sub)
+			}
+		}
+
+		fpath := filepath.Join(dpath, key+sfx)
+
+		for keepChecking := true; keepChecking; {
+			switch lockFile(fpath, index) {
+			case OKAY:
+				keepChecking = false
+			case WAIT:
+				time.Sleep(time.Millisecond)
+			case BAIL:
+				// later record with same key is being saved, skip this one
+				if !clobber {
+					fmt.Fprintf(os.Stderr, "\nWARNING: Skipping record %d, file '%s' already written\n", index, fpath)
+				}
+				return ""
+			default:
+			}
+		}
+
+		defer freeFile(fpath)
+
+		err := os.MkdirAll(dpath, os.ModePerm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return ""
+		}
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if !clobber {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		}
+
+		fl, err := os.OpenFile(fpath, flags, 0644)
+		if err != nil {
+			if os.IsExist(err) {
+				// report duplicate or pre-existing file, and continue with remaining records
+				fmt.Fprintf(os.Stderr, "\nWARNING: Skipping record %d, file '%s' already exists, use -overwrite to replace\n", index, fpath)
+				return ""
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return ""
+		}
+
+		if hd != "" {
+			fl.WriteString(hd)
+			fl.WriteString("\n")
+		}
+
+		fl.WriteString(str)
+		if !strings.HasSuffix(str, "\n") {
+			fl.WriteString("\n")
+		}
+
+		if tl != "" {
+			fl.WriteString(tl)
+			fl.WriteString("\n")
+		}
+
+		err = fl.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return ""
+		}
+
+		return fpath
+	}
+
+	// xmlSplitter reads from channel and calls splitRecord
+	xmlSplitter := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- string) {
+
+		defer wg.Done()
+
+		for ext := range inp {
+
+			id := FindIdentifier(ext.Text, parent, find)
+
+			res := splitRecord(ext.Text, id, ext.Index)
+
+			runtime.Gosched()
+
+			if res != "" {
+				out <- res
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple splitter goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlSplitter(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all splitters are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
 // CreateDeleter reads PMIDs, deletes them in the archive, and sends them
 // down a channel to have the affected inverted index cache files removed.
 func CreateDeleter(stsh string, in io.Reader) <-chan string {
@@ -925,6 +1122,30 @@ func CreateClearer(indexBase, invertBase string, inp <-chan string) <-chan strin
 	return out
 }
 
+// trimArchiveHeader removes xml and DOCTYPE lines now included in archive XML files
+func trimArchiveHeader(str, db string) string {
+
+	if str == "" {
+		return ""
+	}
+
+	rec := "<PubmedArticle>"
+	if db == "pmc" {
+		rec = "<PMCExtract>"
+	} else if db == "taxonomy" {
+		rec = "<TaxNode>"
+	} else if db != "" && db != "pubmed" {
+		return str
+	}
+
+	pos := strings.Index(str, rec)
+	if pos > 0 {
+		str = str[pos:]
+	}
+
+	return str
+}
+
 // CreateFetchers returns uncompressed records from archive, multithreaded for speed
 func CreateFetchers(stsh, db, pfx, sfx string, zipp bool, inp <-chan XMLRecord) <-chan XMLRecord {
 
@@ -952,36 +1173,17 @@ func CreateFetchers(stsh, db, pfx, sfx string, zipp bool, inp <-chan XMLRecord)
 
 			str := fetchOneXMLRecord(ext.Text, stsh, pfx, sfx, zipp, buf)
 
-			// trim header now included in archive XML files
-			if db == "" || db == "pubmed" {
-				if str != "" {
-					pos := strings.Index(str, "<PubmedArticle>")
-					if pos > 0 {
-						// remove leading xml and DOCTYPE lines
-						str = str[pos:]
-					}
-				}
-			} else if db == "pmc" {
-				if str != "" {
-					pos := strings.Index(str, "<PMCExtract>")
-					if pos > 0 {
-						// remove any leading xml and DOCTYPE lines
-						str = str[pos:]
-					}
-				}
-			} else if db == "taxonomy" {
-				if str != "" {
-					pos := strings.Index(str, "<TaxNode>")
-					if pos > 0 {
-						// remove any leading xml and DOCTYPE lines
-						str = str[pos:]
-					}
-				}
-			}
+			str = trimArchiveHeader(str, db)
 
 			runtime.Gosched()
 
-			out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: str}
+			// identifier is kept to report missing records
+			id := ext.Ident
+			if id == "" {
+				id = strings.TrimPrefix(ext.Text, "PMC")
+			}
+
+			out <- XMLRecord{Index: ext.Index, Ident: id, Text: str}
 		}
 	}
 
@@ -1002,6 +1204,148 @@ func CreateFetchers(stsh, db, pfx, sfx string, zipp bool, inp <-chan XMLRecord)
 	return out
 }
 
+// CreateArchiveWalker sends the path of each record file in a trie-based archive down a channel
+func CreateArchiveWalker(base string) <-chan XMLRecord {
+
+	if base == "" {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create archive walker channel\n")
+		os.Exit(1)
+	}
+
+	archiveWalker := func(base string, out chan<- XMLRecord) {
+
+		defer close(out)
+
+		idx := 0
+
+		filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			// identifier is file name without suffixes
+			id := strings.TrimSuffix(info.Name(), ".gz")
+			ext := filepath.Ext(id)
+			if ext != ".xml" && ext != ".asn" && ext != ".e2x" {
+				return nil
+			}
+			id = strings.TrimSuffix(id, ext)
+
+			idx++
+			out <- XMLRecord{Index: idx, Ident: id, Text: path}
+
+			return nil
+		})
+	}
+
+	go archiveWalker(base, out)
+
+	return out
+}
+
+// CreateArchiveVerifiers recomputes the crc32 hash of each archived record. Without a manifest it sends
+// identifier and hash lines. With a manifest, it sends a line for each unreadable or mismatched record.
+func CreateArchiveVerifiers(db string, manifest map[string]string, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create verifier channel\n")
+		os.Exit(1)
+	}
+
+	readRecord := func(fpath string) (string, error) {
+
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.HasSuffix(fpath, ".gz") {
+			return string(data), nil
+		}
+
+		zpr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		defer zpr.Close()
+
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(zpr)
+		if err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	}
+
+	xmlVerifier := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		defer wg.Done()
+
+		for ext := range inp {
+
+			id := ext.Ident
+			fpath := ext.Text
+
+			res := ""
+
+			str, err := readRecord(fpath)
+			if err != nil || str == "" {
+				res = "UNREADABLE\t" + id + "\t" + fpath + "\n"
+			} else {
+				str = trimArchiveHeader(str, db)
+
+				// calculate hash code as in -hash
+				hsh := crc32.NewIEEE()
+				hsh.Write([]byte(str))
+				val := strconv.FormatUint(uint64(hsh.Sum32()), 10)
+
+				if manifest == nil {
+					res = id + "\t" + val + "\n"
+				} else if exp, ok := manifest[id]; !ok {
+					res = "UNLISTED\t" + id + "\t" + fpath + "\n"
+				} else if exp != val {
+					res = "MISMATCH\t" + id + "\t" + fpath + "\n"
+				}
+			}
+
+			runtime.Gosched()
+
+			out <- XMLRecord{Index: ext.Index, Ident: id, Text: res}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple verifier goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlVerifier(&wg, inp, out)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
 // CreateCacheStreamers returns compressed records from archive, multithreaded for speed,
 // could be used for sending records over network to be decompressed later by client
 func CreateCacheStreamers(stsh, pfx, sfx string, inp <-chan XMLRecord) <-chan XMLRecord {
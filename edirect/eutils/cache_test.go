@@ -0,0 +1,49 @@
+package eutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitterSkipsExistingFiles(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// pre-existing file is left alone without -overwrite
+	if err := os.WriteFile(filepath.Join(dir, "2.xml"), []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{
+		"<PubmedArticle><PMID>1</PMID></PubmedArticle>",
+		"<PubmedArticle><PMID>2</PMID></PubmedArticle>",
+		"<PubmedArticle><PMID>3</PMID></PubmedArticle>",
+	}
+
+	inp := make(chan XMLRecord, len(texts))
+	for i, txt := range texts {
+		inp <- XMLRecord{Index: i + 1, Text: txt}
+	}
+	close(inp)
+
+	out := CreateSplitters(dir, "PubmedArticle", "PMID", "", "", ".xml", false, false, inp)
+
+	count := 0
+	for range out {
+		count++
+	}
+
+	// remaining records are still written after the collision
+	if count != 2 {
+		t.Errorf("wrote %d files, want 2", count)
+	}
+	for _, name := range []string{"1.xml", "3.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "2.xml")); string(data) != "old\n" {
+		t.Errorf("existing file was replaced: %q", data)
+	}
+}
@@ -63,6 +63,9 @@ func cit2json(query string) string {
 	base := "https://pubmed.ncbi.nlm.nih.gov/api/citmatch"
 	path := fmt.Sprintf("%s?%s", base, params)
 
+	// share request rate with other goroutines
+	WaitForRequest()
+
 	// persistent HTTP connection by default
 	resp, err := http.Get(path)
 	if err != nil {
@@ -0,0 +1,264 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  digest.go
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RestrictionEnzyme has a recognition sequence and the top strand cut offset from the start of the site
+type RestrictionEnzyme struct {
+	Name string
+	Site string
+	Cut  int
+}
+
+// RestrictionSite records a recognition site, with its start and cut positions in 0-based top strand coordinates
+type RestrictionSite struct {
+	Enzyme string
+	Pos    int
+	Strand string
+	Cut    int
+}
+
+// common enzymes, keyed by lower-case name, extensible with LoadRestrictionEnzymes
+var restrictionEnzymes = map[string]RestrictionEnzyme{
+	"aatii":   {"AatII", "GACGTC", 5},
+	"agei":    {"AgeI", "ACCGGT", 1},
+	"alui":    {"AluI", "AGCT", 2},
+	"apai":    {"ApaI", "GGGCCC", 5},
+	"asci":    {"AscI", "GGCGCGCC", 2},
+	"avai":    {"AvaI", "CYCGRG", 1},
+	"bamhi":   {"BamHI", "GGATCC", 1},
+	"bglii":   {"BglII", "AGATCT", 1},
+	"bsai":    {"BsaI", "GGTCTC", 7},
+	"clai":    {"ClaI", "ATCGAT", 2},
+	"dpnii":   {"DpnII", "GATC", 0},
+	"drai":    {"DraI", "TTTAAA", 3},
+	"ecori":   {"EcoRI", "GAATTC", 1},
+	"ecorv":   {"EcoRV", "GATATC", 3},
+	"haeiii":  {"HaeIII", "GGCC", 2},
+	"hincii":  {"HincII", "GTYRAC", 3},
+	"hindiii": {"HindIII", "AAGCTT", 1},
+	"hpaii":   {"HpaII", "CCGG", 1},
+	"kpni":    {"KpnI", "GGTACC", 5},
+	"mlui":    {"MluI", "ACGCGT", 1},
+	"mspi":    {"MspI", "CCGG", 1},
+	"ncoi":    {"NcoI", "CCATGG", 1},
+	"ndei":    {"NdeI", "CATATG", 2},
+	"nhei":    {"NheI", "GCTAGC", 1},
+	"noti":    {"NotI", "GCGGCCGC", 2},
+	"psti":    {"PstI", "CTGCAG", 5},
+	"saci":    {"SacI", "GAGCTC", 5},
+	"sali":    {"SalI", "GTCGAC", 1},
+	"sau3ai":  {"Sau3AI", "GATC", 0},
+	"smai":    {"SmaI", "CCCGGG", 3},
+	"spei":    {"SpeI", "ACTAGT", 1},
+	"sphi":    {"SphI", "GCATGC", 5},
+	"taqi":    {"TaqI", "TCGA", 1},
+	"xbai":    {"XbaI", "TCTAGA", 1},
+	"xhoi":    {"XhoI", "CTCGAG", 1},
+}
+
+// LoadRestrictionEnzymes reads additional enzymes from a file of name, recognition site, and cut offset
+func LoadRestrictionEnzymes(fname string) {
+
+	inFile, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open restriction enzyme file '%s'\n", fname)
+		os.Exit(1)
+	}
+	defer inFile.Close()
+
+	scanr := bufio.NewScanner(inFile)
+
+	row := 0
+	for scanr.Scan() {
+
+		line := scanr.Text()
+		row++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Expected 3 columns in restriction enzyme file line %d\n", row)
+			os.Exit(1)
+		}
+
+		name := strings.TrimSpace(cols[0])
+		site := strings.ToUpper(strings.TrimSpace(cols[1]))
+		cut, err := strconv.Atoi(strings.TrimSpace(cols[2]))
+		if name == "" || site == "" || err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized restriction enzyme on line %d\n", row)
+			os.Exit(1)
+		}
+
+		restrictionEnzymes[strings.ToLower(name)] = RestrictionEnzyme{Name: name, Site: site, Cut: cut}
+	}
+}
+
+// LookupRestrictionEnzyme finds an enzyme by case-insensitive name
+func LookupRestrictionEnzyme(name string) (RestrictionEnzyme, bool) {
+
+	enz, ok := restrictionEnzymes[strings.ToLower(name)]
+
+	return enz, ok
+}
+
+// RestrictionDigest finds recognition sites for all enzymes on both strands in a single scan
+func RestrictionDigest(seq string, enzymes []RestrictionEnzyme, isCircular bool) []RestrictionSite {
+
+	ln := len(seq)
+	if ln == 0 || len(enzymes) == 0 {
+		return nil
+	}
+
+	lookup := make(map[string]RestrictionEnzyme)
+
+	// each instantiated site lists every enzyme and strand it represents,
+	// so isoschizomers and overlapping ambiguous sites are all reported
+	owners := make(map[string][]string)
+	var order []string
+
+	addOwner := func(site, owner string) {
+		if owners[site] == nil {
+			order = append(order, site)
+		}
+		owners[site] = append(owners[site], owner)
+	}
+
+	for _, enz := range enzymes {
+
+		lookup[enz.Name] = enz
+
+		expanded, overflowed := ExpandNucleotidePattern(strings.ToUpper(enz.Site))
+		if overflowed {
+			fmt.Fprintf(os.Stderr, "\nERROR: Ignoring %s due to pattern expansion overflow\n", enz.Name)
+			continue
+		}
+
+		inSet := make(map[string]bool)
+		for _, str := range expanded {
+			inSet[str] = true
+		}
+
+		for _, str := range expanded {
+			addOwner(str, enz.Name+"+")
+			rev := ReverseComplement(str)
+			// palindromic sites, including degenerate ones like GGNCC, are only reported on the top strand
+			if !inSet[rev] {
+				addOwner(rev, enz.Name+"-")
+			}
+		}
+	}
+
+	var arry []string
+	for _, str := range order {
+		arry = append(arry, str+":"+strings.Join(owners[str], ","))
+	}
+
+	// reverse complements are already entered, so search top strand only
+	srch := fsmSearcher(arry, false, false, false, true, isCircular, true, false)
+
+	var sites []RestrictionSite
+
+	srch.Search(seq,
+		func(str, alias string, pos int) bool {
+			for _, owner := range strings.Split(alias, ",") {
+				name := owner[:len(owner)-1]
+				strand := owner[len(owner)-1:]
+				enz := lookup[name]
+				cut := pos + enz.Cut
+				if strand == "-" {
+					cut = pos + len(enz.Site) - enz.Cut
+				}
+				if isCircular {
+					cut = ((cut % ln) + ln) % ln
+				}
+				sites = append(sites, RestrictionSite{Enzyme: name, Pos: pos, Strand: strand, Cut: cut})
+			}
+			return true
+		})
+
+	sort.SliceStable(sites, func(i, j int) bool { return sites[i].Pos < sites[j].Pos })
+
+	return sites
+}
+
+// DigestFragments returns fragment lengths in positional order for a set of cut positions
+func DigestFragments(cuts []int, ln int, isCircular bool) []int {
+
+	// remove duplicate cuts and cuts at or past the ends of a linear molecule
+	seen := make(map[int]bool)
+	var pts []int
+	for _, cut := range cuts {
+		if !isCircular && (cut <= 0 || cut >= ln) {
+			continue
+		}
+		if seen[cut] {
+			continue
+		}
+		seen[cut] = true
+		pts = append(pts, cut)
+	}
+	sort.Ints(pts)
+
+	var frags []int
+
+	if len(pts) == 0 {
+		return append(frags, ln)
+	}
+
+	if isCircular {
+		for i := 1; i < len(pts); i++ {
+			frags = append(frags, pts[i]-pts[i-1])
+		}
+		// fragment that spans the origin
+		frags = append(frags, ln-pts[len(pts)-1]+pts[0])
+		return frags
+	}
+
+	prev := 0
+	for _, cut := range pts {
+		frags = append(frags, cut-prev)
+		prev = cut
+	}
+	frags = append(frags, ln-prev)
+
+	return frags
+}
@@ -33,6 +33,7 @@ package eutils
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
 )
@@ -252,3 +253,246 @@ func FASTAConverter(inp io.Reader, caseSensitive bool) <-chan FASTARecord {
 
 	return out
 }
+
+// KmerProfile counts canonical k-mers, packed two bits per base, skipping windows with ambiguous bases
+func KmerProfile(seq string, k int, counts map[uint64]int) {
+
+	mask := uint64(1)<<(2*uint(k)) - 1
+	shift := 2 * uint(k-1)
+
+	var fwd, rev uint64
+	valid := 0
+
+	for i := 0; i < len(seq); i++ {
+		var code uint64
+		switch seq[i] {
+		case 'A', 'a':
+			code = 0
+		case 'C', 'c':
+			code = 1
+		case 'G', 'g':
+			code = 2
+		case 'T', 't', 'U', 'u':
+			code = 3
+		default:
+			// restart after ambiguous base
+			valid = 0
+			fwd = 0
+			rev = 0
+			continue
+		}
+		fwd = ((fwd << 2) | code) & mask
+		rev = (rev >> 2) | ((3 - code) << shift)
+		valid++
+		if valid < k {
+			continue
+		}
+		// canonical k-mer is the lesser of the two strands
+		if rev < fwd {
+			counts[rev]++
+		} else {
+			counts[fwd]++
+		}
+	}
+}
+
+// CompareKmerProfiles returns cosine distance, Jaccard similarity of k-mer presence, and the numbers of
+// shared k-mers and of k-mers unique to each profile
+func CompareKmerProfiles(frst, scnd map[uint64]int) (float64, float64, int, int, int) {
+
+	dot := 0.0
+	sumF := 0.0
+	sumS := 0.0
+	shared := 0
+
+	for km, num := range frst {
+		sumF += float64(num) * float64(num)
+		if val, ok := scnd[km]; ok {
+			dot += float64(num) * float64(val)
+			shared++
+		}
+	}
+	for _, num := range scnd {
+		sumS += float64(num) * float64(num)
+	}
+
+	dist := 1.0
+	if sumF > 0 && sumS > 0 {
+		dist = 1.0 - dot/(math.Sqrt(sumF)*math.Sqrt(sumS))
+		if dist < 0 {
+			dist = 0
+		}
+	}
+
+	uniqF := len(frst) - shared
+	uniqS := len(scnd) - shared
+
+	jacc := 0.0
+	if shared+uniqF+uniqS > 0 {
+		jacc = float64(shared) / float64(shared+uniqF+uniqS)
+	}
+
+	return dist, jacc, shared, uniqF, uniqS
+}
+
+// GlobalAlign performs banded global alignment with affine gap penalties, returning lower-case gapped sequences
+func GlobalAlign(frst, scnd string, band int) (string, string) {
+
+	const (
+		match    = 2
+		mismatch = -3
+		gapOpen  = -5
+		gapExt   = -2
+		negInf   = math.MinInt32 / 2
+	)
+
+	frst = strings.ToLower(frst)
+	scnd = strings.ToLower(scnd)
+
+	n := len(frst)
+	m := len(scnd)
+
+	// diagonal range d = j - i must contain both corners of the matrix
+	dmin := -n
+	dmax := m
+	if band > 0 && n*m > 1000000 {
+		dmin = -band
+		if m-n < 0 {
+			dmin += m - n
+		}
+		dmax = band
+		if m-n > 0 {
+			dmax += m - n
+		}
+	}
+	wd := dmax - dmin + 1
+
+	// traceback packs the source state of M, X, and Y into two bits each
+	const (
+		fromM = 0
+		fromX = 1
+		fromY = 2
+	)
+	tb := make([]byte, (n+1)*wd)
+
+	prvM := make([]int, wd+2)
+	prvX := make([]int, wd+2)
+	prvY := make([]int, wd+2)
+	curM := make([]int, wd+2)
+	curX := make([]int, wd+2)
+	curY := make([]int, wd+2)
+
+	best := func(a, b, c int) (int, byte) {
+		if a >= b && a >= c {
+			return a, fromM
+		}
+		if b >= c {
+			return b, fromX
+		}
+		return c, fromY
+	}
+
+	// arrays are offset by one so that k-1 and k+1 are always valid
+	for i := 0; i <= n; i++ {
+
+		for k := 0; k < wd+2; k++ {
+			curM[k] = negInf
+			curX[k] = negInf
+			curY[k] = negInf
+		}
+
+		for k := 1; k <= wd; k++ {
+			j := i + dmin + k - 1
+			if j < 0 || j > m {
+				continue
+			}
+
+			var tm, tx, ty byte
+
+			if i == 0 && j == 0 {
+				curM[k] = 0
+			} else if i == 0 {
+				// leading gap in first sequence
+				curY[k], ty = best(curM[k-1]+gapOpen, curX[k-1]+gapOpen, curY[k-1]+gapExt)
+			} else if j == 0 {
+				// leading gap in second sequence
+				curX[k], tx = best(prvM[k+1]+gapOpen, prvX[k+1]+gapExt, prvY[k+1]+gapOpen)
+			} else {
+				sc := mismatch
+				if frst[i-1] == scnd[j-1] {
+					sc = match
+				}
+				curM[k], tm = best(prvM[k], prvX[k], prvY[k])
+				curM[k] += sc
+				curX[k], tx = best(prvM[k+1]+gapOpen, prvX[k+1]+gapExt, prvY[k+1]+gapOpen)
+				curY[k], ty = best(curM[k-1]+gapOpen, curX[k-1]+gapOpen, curY[k-1]+gapExt)
+			}
+
+			tb[i*wd+k-1] = tm | tx<<2 | ty<<4
+		}
+
+		prvM, curM = curM, prvM
+		prvX, curX = curX, prvX
+		prvY, curY = curY, prvY
+	}
+
+	// trace back from the lower right corner
+	k := m - n - dmin + 1
+	_, state := best(prvM[k], prvX[k], prvY[k])
+
+	var fa, sa []byte
+
+	i := n
+	j := m
+	for i > 0 || j > 0 {
+		ptr := tb[i*wd+j-i-dmin]
+		switch state {
+		case fromM:
+			fa = append(fa, frst[i-1])
+			sa = append(sa, scnd[j-1])
+			state = ptr & 3
+			i--
+			j--
+		case fromX:
+			fa = append(fa, frst[i-1])
+			sa = append(sa, '-')
+			state = (ptr >> 2) & 3
+			i--
+		default:
+			fa = append(fa, '-')
+			sa = append(sa, scnd[j-1])
+			state = (ptr >> 4) & 3
+			j--
+		}
+	}
+
+	// reverse traceback into alignment order
+	for l, r := 0, len(fa)-1; l < r; l, r = l+1, r-1 {
+		fa[l], fa[r] = fa[r], fa[l]
+		sa[l], sa[r] = sa[r], sa[l]
+	}
+
+	return string(fa), string(sa)
+}
+
+// AlignmentCounts tallies aligned columns, where a gap in the first sequence is an insertion,
+// and a gap in the second sequence is a deletion
+func AlignmentCounts(fa, sa string) (int, int, int, int) {
+
+	idn, mis, ins, del := 0, 0, 0, 0
+
+	for i := 0; i < len(fa) && i < len(sa); i++ {
+		switch {
+		case fa[i] == '-':
+			ins++
+		case sa[i] == '-':
+			del++
+		case fa[i] == sa[i]:
+			idn++
+		default:
+			mis++
+		}
+	}
+
+	return idn, mis, ins, del
+}
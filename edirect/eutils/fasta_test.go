@@ -0,0 +1,98 @@
+package eutils
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKmerProfiles(t *testing.T) {
+
+	seq := "ATGGCGTACGTTAGCCGATCGATCGGCTAGCTAGGCTTACGATCGNNNACGTAGCTAGCATCGACTAGCATCGA"
+
+	fwd := make(map[uint64]int)
+	KmerProfile(seq, 8, fwd)
+
+	// identical input gives distance 0
+	same := make(map[uint64]int)
+	KmerProfile(seq, 8, same)
+	dist, jacc, shared, uniqF, uniqS := CompareKmerProfiles(fwd, same)
+	if dist > 1e-12 || jacc != 1 || shared != len(fwd) || uniqF != 0 || uniqS != 0 {
+		t.Errorf("identical: got %g %g %d %d %d", dist, jacc, shared, uniqF, uniqS)
+	}
+
+	// reverse complement gives the same canonical profile
+	rev := make(map[uint64]int)
+	KmerProfile(ReverseComplement(seq), 8, rev)
+	if !reflect.DeepEqual(fwd, rev) {
+		t.Error("reverse complement profile differs")
+	}
+
+	// windows containing ambiguous bases are skipped
+	total := 0
+	for _, num := range fwd {
+		total += num
+	}
+	if want := (45 - 8 + 1) + (26 - 8 + 1); total != want {
+		t.Errorf("counted %d k-mers, want %d", total, want)
+	}
+
+	// unrelated sequences share nothing
+	other := make(map[uint64]int)
+	KmerProfile("AAAAAAAAAAAAAAAA", 8, other)
+	dist, jacc, shared, _, uniqS = CompareKmerProfiles(fwd, other)
+	if math.Abs(dist-1) > 1e-12 || jacc != 0 || shared != 0 || uniqS != 1 {
+		t.Errorf("unrelated: got %g %g %d %d", dist, jacc, shared, uniqS)
+	}
+
+	// k up to 12 packs into the map key
+	big := make(map[uint64]int)
+	KmerProfile(seq, 12, big)
+	for km := range big {
+		if km >= 1<<24 {
+			t.Errorf("k-mer key %d exceeds 24 bits", km)
+		}
+	}
+}
+
+func TestGlobalAlign(t *testing.T) {
+
+	// deterministic 1 kb sequence from a linear congruential generator
+	var buffer strings.Builder
+	seed := uint32(12345)
+	for i := 0; i < 1000; i++ {
+		seed = seed*1103515245 + 12345
+		buffer.WriteByte("ACGT"[(seed>>16)&3])
+	}
+	frst := buffer.String()
+	scnd := frst[:10] + "TTT" + frst[10:]
+
+	for _, band := range []int{50, 0} {
+		fa, sa := GlobalAlign(frst, scnd, band)
+		if len(fa) != len(sa) {
+			t.Fatalf("band %d: aligned lengths differ, %d vs %d", band, len(fa), len(sa))
+		}
+		if !strings.EqualFold(strings.ReplaceAll(fa, "-", ""), frst) ||
+			!strings.EqualFold(strings.ReplaceAll(sa, "-", ""), scnd) {
+			t.Fatalf("band %d: alignment does not preserve input sequences", band)
+		}
+
+		idn, mis, ins, del := AlignmentCounts(fa, sa)
+		if idn != 1000 || mis != 0 || ins != 3 || del != 0 {
+			t.Errorf("band %d: got %d identities, %d mismatches, %d insertions, %d deletions",
+				band, idn, mis, ins, del)
+		}
+
+		// the three inserted bases form a single gap
+		if strings.Count(fa, "-") != 3 || !strings.Contains(fa, "---") || strings.Contains(sa, "-") {
+			t.Errorf("band %d: expected one gap of length 3, got %q", band, fa[:20])
+		}
+	}
+
+	// deletion is the mirror image
+	fa, sa := GlobalAlign("ACGTACGTACGT", "ACGTACGT", 50)
+	if _, _, ins, del := AlignmentCounts(fa, sa); ins != 0 || del != 4 {
+		t.Errorf("expected 4 deletions, got %d insertions, %d deletions (%s / %s)", ins, del, fa, sa)
+	}
+}
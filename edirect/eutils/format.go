@@ -31,23 +31,135 @@
 package eutils
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"html"
 	"os"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // FormatArgs contains XML format customization arguments
 type FormatArgs struct {
-	Format  string
-	XML     string
-	Doctype string
-	Unicode string
-	Script  string
-	Mathml  string
-	Combine bool
-	Self    bool
-	Comment bool
-	Cdata   bool
+	Format    string
+	XML       string
+	Doctype   string
+	Unicode   string
+	Script    string
+	Mathml    string
+	Combine   bool
+	Self      bool
+	Comment   bool
+	Cdata     bool
+	Canonical bool
+}
+
+// canonicalText unescapes entities, collapses white space if requested, and escapes only what XML requires
+func canonicalText(str string, collapse, inAttr bool) string {
+
+	if strings.Contains(str, "&") {
+		str = html.UnescapeString(str)
+	}
+
+	if collapse {
+		str = strings.Join(strings.Fields(str), " ")
+	}
+
+	str = strings.ReplaceAll(str, "&", "&amp;")
+	str = strings.ReplaceAll(str, "<", "&lt;")
+	if inAttr {
+		str = strings.ReplaceAll(str, "\"", "&quot;")
+	} else {
+		str = strings.ReplaceAll(str, ">", "&gt;")
+	}
+
+	return str
+}
+
+// canonicalAttributes sorts attributes by name and writes each value in double quotes with minimal escaping
+func canonicalAttributes(attr string) string {
+
+	type attrPair struct {
+		name  string
+		value string
+	}
+
+	var pairs []attrPair
+
+	idx := 0
+	attlen := len(attr)
+
+	skipBlanks := func() {
+		for idx < attlen && inBlank[attr[idx]] {
+			idx++
+		}
+	}
+
+	for {
+		skipBlanks()
+		if idx >= attlen {
+			break
+		}
+
+		start := idx
+		for idx < attlen && attr[idx] != '=' && !inBlank[attr[idx]] {
+			idx++
+		}
+		name := attr[start:idx]
+
+		skipBlanks()
+		if idx >= attlen || attr[idx] != '=' {
+			// attribute without value
+			pairs = append(pairs, attrPair{name, ""})
+			continue
+		}
+		idx++
+		skipBlanks()
+
+		value := ""
+		if idx < attlen && (attr[idx] == '"' || attr[idx] == '\'') {
+			quote := attr[idx]
+			idx++
+			start = idx
+			for idx < attlen && attr[idx] != quote {
+				idx++
+			}
+			value = attr[start:idx]
+			// skip past closing quote
+			idx++
+		} else {
+			// unquoted value ends at next blank
+			start = idx
+			for idx < attlen && !inBlank[attr[idx]] {
+				idx++
+			}
+			value = attr[start:idx]
+		}
+
+		pairs = append(pairs, attrPair{name, canonicalText(value, true, true)})
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].name < pairs[j].name })
+
+	var buffer strings.Builder
+
+	for i, pr := range pairs {
+		if i > 0 {
+			buffer.WriteString(" ")
+		}
+		buffer.WriteString(pr.name)
+		buffer.WriteString("=\"")
+		buffer.WriteString(pr.value)
+		buffer.WriteString("\"")
+	}
+
+	return buffer.String()
 }
 
 // xmlFormatter reformats a record string or a stream of XML tokens
@@ -92,6 +204,9 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 	doComment := args.Comment
 	doCdata := args.Cdata
 
+	// sorted attributes and consistent empty elements for byte-stable comparison
+	canonical := args.Canonical
+
 	// formatXML goroutine processes one record
 	formatXML := func(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool, out chan<- string) {
 
@@ -190,7 +305,11 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 				return
 			}
 			attr = strings.TrimSpace(attr)
-			attr = CompressRunsOfSpaces(attr)
+			if canonical {
+				attr = canonicalAttributes(attr)
+			} else {
+				attr = CompressRunsOfSpaces(attr)
+			}
 
 			if deAccent {
 				if IsNotASCII(attr) {
@@ -408,6 +527,21 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 				}
 				// convert start-stop to self-closing tag if attributes are present, otherwise skip
 				if nxtTag == STOPTAG && nxtName == name {
+					if canonical && !keepSelfClosing {
+						// canonical form expands every empty element
+						buffer.WriteString(pfx)
+						doIndent(indent)
+						buffer.WriteString("<")
+						buffer.WriteString(name)
+						printAttributes(tkn.Attr)
+						buffer.WriteString("></")
+						buffer.WriteString(name)
+						buffer.WriteString(">")
+						pfx = ret
+						okIndent = true
+						skip++
+						return
+					}
 					if tkn.Attr != "" || keepSelfClosing {
 						buffer.WriteString(pfx)
 						doIndent(indent)
@@ -440,7 +574,18 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 					buffer.WriteString("\n")
 				}
 			case SELFTAG:
-				if tkn.Attr != "" || keepSelfClosing {
+				if canonical && !keepSelfClosing {
+					buffer.WriteString(pfx)
+					doIndent(indent)
+					buffer.WriteString("<")
+					buffer.WriteString(name)
+					printAttributes(tkn.Attr)
+					buffer.WriteString("></")
+					buffer.WriteString(name)
+					buffer.WriteString(">")
+					pfx = ret
+					okIndent = true
+				} else if tkn.Attr != "" || keepSelfClosing {
 					buffer.WriteString(pfx)
 					doIndent(indent)
 					buffer.WriteString("<")
@@ -492,6 +637,9 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 					if doMixed {
 						name = cleanupMixed(name)
 					}
+					if canonical {
+						name = canonicalText(name, false, false)
+					}
 					buffer.WriteString(name)
 				}
 				if (doStrict || doMixed) && !deAccent && nxtTag == STARTTAG {
@@ -583,7 +731,7 @@ func xmlFormatter(rcrd, prnt string, inp <-chan XMLToken, offset int, doXML bool
 
 		} else {
 
-			parseXML(rcrd, prnt, nil, doPair, nil, nil)
+			parseXML(rcrd, prnt, nil, doPair, nil, nil, nil)
 		}
 
 		// isclosed tag
@@ -614,3 +762,104 @@ func FormatTokens(inp <-chan XMLToken, args FormatArgs) <-chan string {
 
 	return xmlFormatter("", "", inp, 0, true, args)
 }
+
+// CANONICAL RECORD HASHING
+
+// IsHashAlgorithm reports whether a name is supported by CreateRecordHashers
+func IsHashAlgorithm(name string) bool {
+
+	switch name {
+	case "crc32", "sha1", "sha256":
+		return true
+	}
+
+	return false
+}
+
+// CanonicalRecord returns a record in compact -canonical form, so records that
+// differ only in attribute order, quoting, entities, or layout are identical
+func CanonicalRecord(rcrd, prnt string) string {
+
+	var buffer strings.Builder
+
+	frm := FormatRecord(rcrd, prnt, FormatArgs{Format: "compact", Canonical: true})
+	for str := range frm {
+		buffer.WriteString(str)
+	}
+
+	return buffer.String()
+}
+
+// hashRecord computes a checksum of canonical text, in decimal for crc32 as in rchive -hash, otherwise in hex
+func hashRecord(str, algo string) string {
+
+	switch algo {
+	case "crc32":
+		return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(str))), 10)
+	case "sha1":
+		sum := sha1.Sum([]byte(str))
+		return hex.EncodeToString(sum[:])
+	case "sha256":
+		sum := sha256.Sum256([]byte(str))
+		return hex.EncodeToString(sum[:])
+	}
+
+	return ""
+}
+
+// CreateRecordHashers canonicalizes and hashes each record, sending identifier and hash lines
+func CreateRecordHashers(prnt, key, algo string, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil || !IsHashAlgorithm(algo) {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create hasher channel\n")
+		os.Exit(1)
+	}
+
+	find := ParseIndex(key)
+
+	xmlHasher := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		defer wg.Done()
+
+		for ext := range inp {
+
+			if ext.Text == "" {
+				// forward truncation report
+				out <- ext
+				continue
+			}
+
+			id := ""
+			if key != "" {
+				id = FindIdentifier(ext.Text[:], prnt, find)
+			}
+
+			val := hashRecord(CanonicalRecord(ext.Text, prnt), algo)
+
+			runtime.Gosched()
+
+			out <- XMLRecord{Index: ext.Index, Ident: id, Text: id + "\t" + val + "\n"}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple hasher goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlHasher(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all hashers are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,143 @@
+package eutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// formatFile runs FormatTokens on a test file and returns the result
+func formatFile(t *testing.T, fname string, args FormatArgs) string {
+
+	t.Helper()
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf strings.Builder
+	for str := range FormatTokens(CreateTokenizer(CreateXMLStreamer(f)), args) {
+		buf.WriteString(str)
+	}
+
+	return buf.String()
+}
+
+func TestFormatCanonicalGolden(t *testing.T) {
+
+	// the same PubmedArticle with different attribute order, quoting, white space, and empty elements
+	inputs := []string{
+		filepath.Join("testdata", "canonical_a.xml"),
+		filepath.Join("testdata", "canonical_b.xml"),
+	}
+
+	tests := []struct {
+		name string
+		args FormatArgs
+	}{
+		{"indent", FormatArgs{Format: "indent", Canonical: true}},
+		{"indent_self", FormatArgs{Format: "indent", Canonical: true, Self: true}},
+		{"compact", FormatArgs{Format: "compact", Canonical: true}},
+	}
+
+	for _, tt := range tests {
+
+		golden := filepath.Join("testdata", "canonical_"+tt.name+".golden")
+		if *updateGolden {
+			if err := os.WriteFile(golden, []byte(formatFile(t, inputs[0], tt.args)), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, input := range inputs {
+			if got := formatFile(t, input, tt.args); got != string(want) {
+				t.Errorf("%s %s: got\n%s\nwant\n%s", tt.name, input, got, want)
+			}
+		}
+	}
+}
+
+func TestFormatWithoutCanonical(t *testing.T) {
+
+	// plain formatting keeps the differences that -canonical removes
+	frst := formatFile(t, filepath.Join("testdata", "canonical_a.xml"), FormatArgs{Format: "indent"})
+	scnd := formatFile(t, filepath.Join("testdata", "canonical_b.xml"), FormatArgs{Format: "indent"})
+
+	if frst == scnd {
+		t.Error("differently formatted inputs are identical without -canonical")
+	}
+}
+
+// hashRecords runs CreateRecordHashers on a set of records and returns the output lines
+func hashRecords(t *testing.T, text, algo string) []string {
+
+	t.Helper()
+
+	xmlq := CreateXMLProducer("Rec", "", false, CreateXMLStreamer(strings.NewReader(text)))
+	unsq := CreateXMLUnshuffler(CreateRecordHashers("Rec", "Id", algo, xmlq))
+	if unsq == nil {
+		t.Fatal("unable to create record hasher")
+	}
+
+	var lines []string
+	for curr := range unsq {
+		if curr.Text != "" {
+			lines = append(lines, strings.TrimSuffix(curr.Text, "\n"))
+		}
+	}
+
+	return lines
+}
+
+func TestRecordHashes(t *testing.T) {
+
+	xml := "<Set>\n" +
+		"<Rec><Id>1</Id><Name first=\"a\" last=\"b\">x</Name><Empty/></Rec>\n" +
+		"<Rec>\n  <Id>1</Id>\n  <Name last='b'  first='a'>x</Name>\n  <Empty></Empty>\n</Rec>\n" +
+		"<Rec><Id>2</Id><Name first=\"a\" last=\"b\">y</Name><Empty/></Rec>\n" +
+		"<Rec><Id>3</Id><Name first=\"a\" last=\"c\">x</Name><Empty/></Rec>\n" +
+		"</Set>\n"
+
+	lengths := map[string]int{"sha256": 64, "sha1": 40}
+
+	for _, algo := range []string{"crc32", "sha1", "sha256"} {
+
+		lines := hashRecords(t, xml, algo)
+		if len(lines) != 4 {
+			t.Fatalf("%s: got %d lines, want 4: %q", algo, len(lines), lines)
+		}
+
+		var hashes []string
+		for i, line := range lines {
+			id, hash, ok := strings.Cut(line, "\t")
+			if !ok || id != []string{"1", "1", "2", "3"}[i] {
+				t.Errorf("%s: line %d is %q", algo, i+1, line)
+			}
+			if n, ok := lengths[algo]; ok && len(hash) != n {
+				t.Errorf("%s: hash %q has length %d", algo, hash, len(hash))
+			}
+			hashes = append(hashes, hash)
+		}
+
+		// attribute order, quoting, layout, and empty element style do not matter
+		if hashes[0] != hashes[1] {
+			t.Errorf("%s: equivalent records hash differently, %s and %s", algo, hashes[0], hashes[1])
+		}
+		// changed content or attribute value does
+		if hashes[2] == hashes[0] || hashes[3] == hashes[0] || hashes[2] == hashes[3] {
+			t.Errorf("%s: changed records share a hash: %q", algo, hashes)
+		}
+	}
+
+	if IsHashAlgorithm("md5") || CreateRecordHashers("Rec", "Id", "md5", make(chan XMLRecord)) != nil {
+		t.Error("unsupported algorithm accepted")
+	}
+}
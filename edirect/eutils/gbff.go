@@ -741,17 +741,29 @@ func GenBankConverter(inp io.Reader) <-chan string {
 								qual = qual[:idx]
 							}
 
+							// quoted value remains open while it has an odd number of quote characters,
+							// since internal quotes are doubled
+							quoted := strings.HasPrefix(val, "\"")
+							inQuote := quoted && strings.Count(val, "\"")%2 == 1
+
 							for {
 								line = nextLine()
 								row++
+								if inQuote && line != "" && strings.TrimSpace(line) == "" {
+									// skip blank continuation line within quoted value
+									continue
+								}
 								if !strings.HasPrefix(line, twentyonespaces) {
 									break
 								}
 								txt := strings.TrimPrefix(line, twentyonespaces)
-								if strings.HasPrefix(txt, "/") {
+								if strings.HasPrefix(txt, "/") && !inQuote {
 									// if not continuation of qualifier, break out of loop
 									break
 								}
+								if strings.Count(txt, "\"")%2 == 1 {
+									inQuote = !inQuote
+								}
 								// append subsequent line to value and continue with loop
 								if qual == "transcription" || qual == "translation" || qual == "peptide" || qual == "anticodon" {
 									val += strings.TrimSpace(txt)
@@ -766,6 +778,9 @@ func GenBankConverter(inp io.Reader) <-chan string {
 
 							val = strings.TrimPrefix(val, "\"")
 							val = strings.TrimSuffix(val, "\"")
+							if quoted {
+								val = strings.Replace(val, "\"\"", "\"", -1)
+							}
 							val = strings.TrimSpace(val)
 							if val != "" {
 
@@ -0,0 +1,52 @@
+package eutils
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+const quotedNoteRecord = `LOCUS       AB000001                  60 bp    DNA     linear   PRI 01-JAN-2000
+DEFINITION  Test sequence.
+ACCESSION   AB000001
+VERSION     AB000001.1
+KEYWORDS    .
+SOURCE      Homo sapiens (human)
+  ORGANISM  Homo sapiens
+            Eukaryota; Metazoa.
+FEATURES             Location/Qualifiers
+     source          1..60
+                     /organism="Homo sapiens"
+     misc_feature    1..20
+                     /note="5' UTR; see
+                     ""figure
+                     2"""
+                     /gene="ABC1"
+ORIGIN      
+        1 acgtacgtac gtacgtacgt acgtacgtac gtacgtacgt acgtacgtac gtacgtacgt
+//
+`
+
+func TestGenBankQuotedNote(t *testing.T) {
+
+	var buf strings.Builder
+	for str := range GenBankConverter(strings.NewReader(quotedNoteRecord)) {
+		buf.WriteString(str)
+	}
+	out := buf.String()
+
+	// doubled quotes are unescaped, and wrapped lines are joined by single spaces
+	note := ""
+	if _, after, found := strings.Cut(out, "<INSDQualifier_name>note</INSDQualifier_name>"); found {
+		val, _, _ := strings.Cut(after, "</INSDQualifier_value>")
+		_, note, _ = strings.Cut(val, "<INSDQualifier_value>")
+	}
+	if got := html.UnescapeString(note); got != `5' UTR; see "figure 2"` {
+		t.Errorf("got note %q", got)
+	}
+
+	// qualifier following the quoted value is still recognized
+	if !strings.Contains(out, "<INSDQualifier_value>ABC1</INSDQualifier_value>") {
+		t.Errorf("gene qualifier missing:\n%s", out)
+	}
+}
@@ -518,6 +518,167 @@ func TranslateCdRegion(seq string, genCode, frame int, includeStop, doEveryCodon
 	return txt
 }
 
+// FindOpenReadingFrames scans both strands in all three frames for start-to-stop reading frames of at least
+// minLen nucleotides, reporting 1-based start..stop (start > stop on minus strand), strand, frame, and length
+func FindOpenReadingFrames(seq string, genCode, minLen int, atgOnly, doProtein, asXML bool) []string {
+
+	genCode = correctGenCode(genCode)
+
+	seq = strings.ToUpper(seq)
+	ln := len(seq)
+
+	var res []string
+
+	record := func(str, strand string, frame, fr, to int) {
+
+		// fr and to are 0-based half-open offsets in the scanned strand
+		size := to - fr
+		if size < minLen {
+			return
+		}
+
+		start := fr + 1
+		stop := to
+		if strand == "-" {
+			start = ln - fr
+			stop = ln - to + 1
+		}
+
+		if doProtein {
+			prt := TranslateCdRegion(str[fr:to], genCode, 0, false, false, false, true, true, "")
+			if asXML {
+				prt = "<Protein>" + prt + "</Protein>"
+			}
+			res = append(res, prt)
+			return
+		}
+
+		if asXML {
+			res = append(res, fmt.Sprintf("<Start>%d</Start><Stop>%d</Stop><Strand>%s</Strand><Frame>%d</Frame><Length>%d</Length>", start, stop, strand, frame, size))
+		} else {
+			res = append(res, fmt.Sprintf("%d..%d %s %d %d", start, stop, strand, frame, size))
+		}
+	}
+
+	scan := func(str, strand string) {
+
+		for frame := 0; frame < 3; frame++ {
+
+			fr := -1
+			i := frame
+
+			// final partial codon is ignored
+			for ; i+3 <= ln; i += 3 {
+				state := SetCodonState(int(str[i]), int(str[i+1]), int(str[i+2]))
+				if fr < 0 {
+					if (atgOnly && IsATGStart(genCode, state)) || (!atgOnly && IsOrfStart(genCode, state)) {
+						fr = i
+					}
+				}
+				if fr >= 0 && GetCodonResidue(genCode, state) == '*' {
+					record(str, strand, frame+1, fr, i+3)
+					fr = -1
+				}
+			}
+
+			// open frame running off the end of the sequence is reported up to the last complete codon
+			if fr >= 0 {
+				record(str, strand, frame+1, fr, i)
+			}
+		}
+	}
+
+	scan(seq, "+")
+	scan(ReverseComplement(seq), "-")
+
+	return res
+}
+
+// CountCodons tallies unambiguous codons in a coding sequence starting at the given frame offset,
+// returning the number counted, the number skipped for ambiguous bases, and whether bases were left over
+func CountCodons(seq string, frame int, counts map[string]int) (int, int, bool) {
+
+	seq = strings.ToUpper(seq)
+	seq = strings.Replace(seq, "U", "T", -1)
+
+	if frame < len(seq) {
+		seq = seq[frame:]
+	} else {
+		seq = ""
+	}
+
+	total := 0
+	skipped := 0
+
+	for i := 0; i+3 <= len(seq); i += 3 {
+		codon := seq[i : i+3]
+		if strings.Trim(codon, "ACGT") != "" {
+			skipped++
+			continue
+		}
+		counts[codon]++
+		total++
+	}
+
+	return total, skipped, len(seq)%3 != 0
+}
+
+// CodonUsageTable prints codon, amino acid, count, frequency per thousand, and fraction within the
+// synonymous family for all 64 codons, sorted by amino acid and then by codon
+func CodonUsageTable(counts map[string]int, genCode int) string {
+
+	type CodonUse struct {
+		Codon   string
+		Residue byte
+		Count   int
+	}
+
+	var codons []CodonUse
+
+	family := make(map[byte]int)
+	total := 0
+
+	bases := "TCAG"
+	for _, ch1 := range bases {
+		for _, ch2 := range bases {
+			for _, ch3 := range bases {
+				state := SetCodonState(int(ch1), int(ch2), int(ch3))
+				aa := byte(GetCodonResidue(genCode, state))
+				codon := string(ch1) + string(ch2) + string(ch3)
+				num := counts[codon]
+				codons = append(codons, CodonUse{Codon: codon, Residue: aa, Count: num})
+				family[aa] += num
+				total += num
+			}
+		}
+	}
+
+	sort.Slice(codons, func(i, j int) bool {
+		if codons[i].Residue != codons[j].Residue {
+			return codons[i].Residue < codons[j].Residue
+		}
+		return codons[i].Codon < codons[j].Codon
+	})
+
+	var buffer strings.Builder
+
+	for _, cdn := range codons {
+
+		perThousand := 0.0
+		if total > 0 {
+			perThousand = float64(cdn.Count) * 1000.0 / float64(total)
+		}
+		fraction := 0.0
+		if family[cdn.Residue] > 0 {
+			fraction = float64(cdn.Count) / float64(family[cdn.Residue])
+		}
+
+		buffer.WriteString(fmt.Sprintf("%s\t%c\t%d\t%.2f\t%.3f\n", cdn.Codon, cdn.Residue, cdn.Count, perThousand, fraction))
+	}
+
+	return buffer.String()
+}
+
 // NucProtCodonReport displays triplet codons above the translated amino acid
 func NucProtCodonReport(nuc, prt string, frame int, threeLetter bool) string {
 
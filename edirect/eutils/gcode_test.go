@@ -0,0 +1,102 @@
+package eutils
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCodonUsageTable(t *testing.T) {
+
+	counts := make(map[string]int)
+
+	total, skipped, partial := CountCodons("atgGCTgccGCAgcgTTATTGctgNNNtaaTGAuaa", 0, counts)
+	if total != 11 || skipped != 1 || partial {
+		t.Errorf("got total %d, skipped %d, partial %v", total, skipped, partial)
+	}
+
+	// frame offset leaves trailing bases
+	if _, _, partial := CountCodons("AATGGC", 1, counts); !partial {
+		t.Error("expected leftover bases to be reported")
+	}
+
+	table := CodonUsageTable(counts, 1)
+	lines := strings.Split(strings.TrimSuffix(table, "\n"), "\n")
+	if len(lines) != 64 {
+		t.Fatalf("got %d rows, want 64", len(lines))
+	}
+
+	// fractions within each synonymous family sum to one
+	sums := make(map[string]float64)
+	used := make(map[string]bool)
+	prev := ""
+	for _, line := range lines {
+		cols := strings.Split(line, "\t")
+		if len(cols) != 5 {
+			t.Fatalf("bad row %q", line)
+		}
+		// sorted by amino acid, then codon
+		key := cols[1] + cols[0]
+		if key < prev {
+			t.Errorf("row %q out of order", line)
+		}
+		prev = key
+		frac, err := strconv.ParseFloat(cols[4], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums[cols[1]] += frac
+		if cols[2] != "0" {
+			used[cols[1]] = true
+		}
+	}
+	for aa := range used {
+		if math.Abs(sums[aa]-1.0) > 0.002 {
+			t.Errorf("fractions for %s sum to %.3f", aa, sums[aa])
+		}
+	}
+
+	// alanine family has four codons, each used once
+	if !strings.Contains(table, "GCA\tA\t1\t") || !strings.Contains(table, "\t0.250\n") {
+		t.Errorf("unexpected alanine rows\n%s", table)
+	}
+
+	// mitochondrial code translates TGA as tryptophan
+	if !strings.Contains(CodonUsageTable(counts, 2), "TGA\tW\t") {
+		t.Error("genetic code was not applied")
+	}
+}
+
+func TestFindOpenReadingFrames(t *testing.T) {
+
+	plus := "ATG" + strings.Repeat("GCT", 10) + "TAA"
+	minus := "ATG" + strings.Repeat("AAA", 10) + "TGA"
+
+	// plus strand ORF in frame 3, minus strand ORF, and a minus strand ORF that runs off the end
+	seq := "CC" + plus + "CCCCC" + ReverseComplement(minus) + "C"
+
+	got := FindOpenReadingFrames(seq, 1, 30, true, false, false)
+	want := []string{"3..38 + 3 36", "47..3 - 1 45", "79..44 - 2 36"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coordinates: got %q, want %q", got, want)
+	}
+
+	got = FindOpenReadingFrames(seq, 1, 30, true, true, false)
+	want = []string{"MAAAAAAAAAA", "MRGLSSSSSSSSSSH", "MKKKKKKKKKK"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("proteins: got %q, want %q", got, want)
+	}
+
+	// minimum length filters short frames
+	if got := FindOpenReadingFrames(seq, 1, 40, true, false, false); !reflect.DeepEqual(got, []string{"47..3 - 1 45"}) {
+		t.Errorf("minimum length: got %q", got)
+	}
+
+	// alternative start codons find an additional frame
+	if got := FindOpenReadingFrames(seq, 1, 30, false, false, true); len(got) != 4 ||
+		got[0] != "<Start>7</Start><Stop>78</Stop><Strand>+</Strand><Frame>1</Frame><Length>72</Length>" {
+		t.Errorf("alternative starts: got %q", got)
+	}
+}
@@ -41,9 +41,12 @@ import (
 )
 
 var (
-	accnRegEx *regexp.Regexp
-	genRegEx  *regexp.Regexp
-	protRegEx *regexp.Regexp
+	accnRegEx    *regexp.Regexp
+	nucVarRegEx  *regexp.Regexp
+	nucPosRegEx  *regexp.Regexp
+	protVarRegEx *regexp.Regexp
+	repeatRegEx  *regexp.Regexp
+	shiftRegEx   *regexp.Regexp
 )
 
 // integer table for HGVS class used to define sort order
@@ -114,8 +117,13 @@ type SPDI struct {
 	Number    int
 	Version   int
 	Position  int
+	Relative  bool
+	Start     string
+	Stop      string
 	Deleted   string
 	Inserted  string
+	CopyCount string
+	Shift     string
 	Hgvs      string
 }
 
@@ -197,11 +205,20 @@ func ParseHGVS(str string) string {
 	if accnRegEx == nil {
 		accnRegEx = regexp.MustCompile("\\*|\\d+|\\.|\\D+")
 	}
-	if genRegEx == nil {
-		genRegEx = regexp.MustCompile("\\d+|\\D|>|\\D")
+	if nucVarRegEx == nil {
+		nucVarRegEx = regexp.MustCompile(`^([-*]?\d+(?:[-+]\d+)?)(?:_([-*]?\d+(?:[-+]\d+)?))?(.+)$`)
 	}
-	if protRegEx == nil {
-		protRegEx = regexp.MustCompile("\\*|\\d+|\\D+")
+	if nucPosRegEx == nil {
+		nucPosRegEx = regexp.MustCompile(`^([-*]?)(\d+)`)
+	}
+	if protVarRegEx == nil {
+		protVarRegEx = regexp.MustCompile(`^(\*|ter|[a-z]{3}|[a-z])(\d+)(?:_(\*|ter|[a-z]{3}|[a-z])(\d+))?(.+)$`)
+	}
+	if repeatRegEx == nil {
+		repeatRegEx = regexp.MustCompile(`^([a-z]*)\[(\d+)\]$`)
+	}
+	if shiftRegEx == nil {
+		shiftRegEx = regexp.MustCompile(`^([a-z]{3}|[a-z])?(fs|ext)(\*|ter|-)?(\d+|\?)?$`)
 	}
 
 	// track highest version per accession
@@ -210,192 +227,329 @@ func ParseHGVS(str string) string {
 	hasNM := false
 	hasNP := false
 
-	// parse substitution
-	parseSubs := func(cls, acc, vrn string) *SPDI {
+	// convert residue string of three-letter or one-letter amino acids
+	aaResidues := func(str string) (string, bool) {
 
-		pos := ""
-		ins := ""
-		del := ""
-		class := 0
-		htype := 0
-		ok := false
+		if str == "" {
+			return "", true
+		}
 
-		// parse variation string
-		switch cls {
-		case "g":
-			class = GENOMIC
+		var acc []string
 
-			arry := genRegEx.FindAllString(vrn, -1)
-			if len(arry) != 4 {
-				// fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variant '%s', array '%v'\n", vrn, arry)
-				return nil
+		if len(str)%3 == 0 {
+			good := true
+			for i := 0; i < len(str); i += 3 {
+				aa, ok := aaConvert[str[i:i+3]]
+				if !ok {
+					good = false
+					break
+				}
+				acc = append(acc, aa)
 			}
+			if good {
+				return strings.Join(acc, ""), true
+			}
+			acc = nil
+		}
 
-			pos = arry[0]
-
-			lf := strings.ToLower(arry[1])
-			rt := strings.ToLower(arry[3])
-
-			del, ok = naConvert[lf]
+		for _, ch := range str {
+			aa, ok := aaConvert[string(ch)]
 			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized nucleotide '%s'\n", lf)
-				return nil
+				return "", false
 			}
-			ins, ok = naConvert[rt]
+			acc = append(acc, aa)
+		}
+
+		return strings.Join(acc, ""), true
+	}
+
+	// convert nucleotide string, allowing uracil and ambiguity codes
+	naResidues := func(str string) (string, bool) {
+
+		var acc []string
+
+		for _, ch := range str {
+			na, ok := naConvert[string(ch)]
 			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized nucleotide '%s'\n", rt)
-				return nil
+				if strings.IndexRune("acgtunrykmswbdhv", ch) < 0 {
+					return "", false
+				}
+				na = strings.ToUpper(string(ch))
 			}
+			acc = append(acc, na)
+		}
 
-			htype = SUBS
-		case "c":
-			class = CODING
+		return strings.Join(acc, ""), true
+	}
 
-			arry := genRegEx.FindAllString(vrn, -1)
-			if len(arry) != 4 {
-				// fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variant '%s', array '%v'\n", vrn, arry)
-				return nil
-			}
+	// 0-based position of a location such as 123 or -14, where c.1 is 0 and c.-1 is -1, returning
+	// false for intronic (88+2) or 3' UTR (*23) locations, which are not offsets into the sequence
+	basePosition := func(pos string) (int, bool) {
 
-			// pos starts at the first nucleotide of the translation initiation codon
-			pos = arry[0]
+		arry := nucPosRegEx.FindStringSubmatch(pos)
+		if arry == nil {
+			return 0, false
+		}
 
-			lf := strings.ToLower(arry[1])
-			rt := strings.ToLower(arry[3])
+		num, err := strconv.Atoi(arry[2])
+		if err != nil {
+			return 0, false
+		}
 
-			del, ok = naConvert[lf]
-			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized nucleotide '%s'\n", lf)
+		relative := arry[1] == "*" || len(arry[0]) < len(pos)
+
+		if arry[1] == "-" {
+			return -num, !relative
+		}
+
+		return num - 1, !relative
+	}
+
+	// parse one nucleotide variant, including intronic and UTR positions
+	parseNuc := func(class int, acc, vrn string) *SPDI {
+
+		arry := nucVarRegEx.FindStringSubmatch(vrn)
+		if arry == nil {
+			return nil
+		}
+
+		start := arry[1]
+		stop := arry[2]
+		rest := arry[3]
+		if stop == "" {
+			stop = start
+		}
+
+		pos, exact := basePosition(start)
+
+		spdi := &SPDI{Class: class, Accession: acc, Position: pos, Relative: !exact, Start: start, Stop: stop}
+
+		var ok bool
+
+		switch {
+		case rest == "=":
+			spdi.Type = SYN
+			return spdi
+		case strings.HasPrefix(rest, "delins"):
+			spdi.Type = INDEL
+			spdi.Inserted, ok = naResidues(rest[6:])
+			if !ok || spdi.Inserted == "" {
 				return nil
 			}
-			ins, ok = naConvert[rt]
+			return spdi
+		case strings.HasPrefix(rest, "del"):
+			spdi.Type = DEL
+			rest = rest[3:]
+			if IsAllDigits(rest) {
+				// deleted length, e.g., del3
+				return spdi
+			}
+			spdi.Deleted, ok = naResidues(rest)
 			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized nucleotide '%s'\n", rt)
 				return nil
 			}
-
-			htype = SUBS
-		case "p":
-			class = PROTEIN
-
-			arry := protRegEx.FindAllString(vrn, -1)
-			if len(arry) != 3 {
-				// fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variant '%s', array '%v'\n", vrn, arry)
+			return spdi
+		case strings.HasPrefix(rest, "dup"):
+			spdi.Type = DUP
+			spdi.Inserted, ok = naResidues(rest[3:])
+			if !ok {
 				return nil
 			}
-
-			pos = arry[1]
-
-			lf := strings.ToLower(arry[0])
-			rt := strings.ToLower(arry[2])
-
-			del, ok = aaConvert[lf]
+			return spdi
+		case strings.HasPrefix(rest, "inv"):
+			spdi.Type = INV
+			rest = rest[3:]
+			if IsAllDigits(rest) {
+				return spdi
+			}
+			spdi.Deleted, ok = naResidues(rest)
 			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized amino acid '%s'\n", lf)
 				return nil
 			}
-			ins, ok = aaConvert[rt]
-			if !ok {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized amino acid '%s'\n", rt)
+			return spdi
+		case strings.HasPrefix(rest, "ins"):
+			spdi.Type = INS
+			spdi.Inserted, ok = naResidues(rest[3:])
+			if !ok || spdi.Inserted == "" {
 				return nil
 			}
+			return spdi
+		case strings.HasPrefix(rest, "con"):
+			// gene conversion records the donor location as the inserted sequence
+			spdi.Type = CONV
+			if rest[3:] == "" {
+				return nil
+			}
+			spdi.Inserted = strings.ToUpper(rest[3:])
+			return spdi
+		}
 
-			htype = MIS
-			if ins == "*" {
-				htype = TRM
-			} else if del == "*" {
-				htype = EXT
-			} else if del == ins {
-				htype = SYN
+		// repeated sequence, e.g., cag[12]
+		if rpt := repeatRegEx.FindStringSubmatch(rest); rpt != nil {
+			spdi.Type = REP
+			spdi.Inserted, ok = naResidues(rpt[1])
+			if !ok {
+				return nil
 			}
-		default:
-			// fmt.Fprintf(os.Stderr, "\nWARNING: Parsing of HGVS class '%s' not yet implemented\n", cls)
-			return nil
+			spdi.CopyCount = rpt[2]
+			return spdi
 		}
 
-		// position should be unsigned integer
-		if !IsAllDigits(pos) {
-			fmt.Fprintf(os.Stderr, "\nERROR: Non-integer position '%s'\n", pos)
+		// substitution, e.g., t>g
+		lf, rt := SplitInTwoLeft(rest, ">")
+		if len(lf) != 1 || len(rt) != 1 || start != stop {
 			return nil
 		}
-
-		num, err := strconv.Atoi(pos)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "\nERROR: Integer conversion error '%s'\n", err)
+		spdi.Type = SUBS
+		spdi.Deleted, ok = naResidues(lf)
+		if !ok {
+			return nil
+		}
+		spdi.Inserted, ok = naResidues(rt)
+		if !ok {
 			return nil
 		}
-		// adjust position to 0-based
-		num--
-
-		spdi := &SPDI{Class: class, Type: htype, Accession: acc, Position: num, Deleted: del, Inserted: ins}
 
 		return spdi
 	}
 
-	// reality checks on variant type, will eventually call the different type-specific parsers
-	parseOneType := func(cls, acc, vrn string) *SPDI {
+	// parse one protein variant, including frameshifts and extensions
+	parseProt := func(acc, vrn string) *SPDI {
 
-		if strings.Index(vrn, "delins") >= 0 || strings.Index(vrn, "indel") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: deletion-insertion not yet implemented\n")
-			return nil
-		}
-		if strings.Index(vrn, "del") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: deletion not yet implemented\n")
+		arry := protVarRegEx.FindStringSubmatch(vrn)
+		if arry == nil {
 			return nil
 		}
-		if strings.Index(vrn, "inv") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: inversion not yet implemented\n")
+
+		frst, ok := aaResidues(arry[1])
+		if !ok {
 			return nil
 		}
-		if strings.Index(vrn, "dup") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: duplication not yet implemented\n")
-			return nil
+		start := arry[2]
+		stop := arry[4]
+		rest := arry[5]
+		if stop == "" {
+			stop = start
 		}
-		if strings.Index(vrn, "con") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: conversion not yet implemented\n")
+
+		num, err := strconv.Atoi(start)
+		if err != nil {
 			return nil
 		}
-		if strings.Index(vrn, "ins") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: insertion not yet implemented\n")
-			return nil
+
+		spdi := &SPDI{Class: PROTEIN, Accession: acc, Position: num - 1, Start: start, Stop: stop}
+
+		switch {
+		case rest == "=":
+			spdi.Type = SYN
+			spdi.Deleted = frst
+			spdi.Inserted = frst
+			return spdi
+		case strings.HasPrefix(rest, "delins"):
+			spdi.Type = INDEL
+			spdi.Inserted, ok = aaResidues(rest[6:])
+			if !ok || spdi.Inserted == "" {
+				return nil
+			}
+			return spdi
+		case rest == "del":
+			spdi.Type = DEL
+			if start == stop {
+				spdi.Deleted = frst
+			}
+			return spdi
+		case rest == "dup":
+			spdi.Type = DUP
+			if start == stop {
+				spdi.Inserted = frst
+			}
+			return spdi
+		case strings.HasPrefix(rest, "ins"):
+			spdi.Type = INS
+			spdi.Inserted, ok = aaResidues(rest[3:])
+			if !ok || spdi.Inserted == "" {
+				return nil
+			}
+			return spdi
 		}
-		if strings.Index(vrn, "fs") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: frameshift not yet implemented\n")
-			return nil
+
+		if rpt := repeatRegEx.FindStringSubmatch(rest); rpt != nil && rpt[1] == "" {
+			spdi.Type = REP
+			spdi.Inserted = frst
+			spdi.CopyCount = rpt[2]
+			return spdi
 		}
-		if strings.Index(vrn, "*") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: UTR variant not yet implemented\n")
-			return nil
+
+		// frameshift, e.g., argfs*10, or extension, e.g., glnext*17 or ext-5
+		if sft := shiftRegEx.FindStringSubmatch(rest); sft != nil {
+			spdi.Inserted, ok = aaResidues(sft[1])
+			if !ok {
+				return nil
+			}
+			spdi.Deleted = frst
+			spdi.Type = FS
+			if sft[2] == "ext" {
+				spdi.Type = EXT
+			}
+			spdi.Shift = strings.Replace(sft[3], "ter", "*", 1) + sft[4]
+			return spdi
 		}
-		if strings.Index(vrn, "?") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: predictions not yet implemented\n")
+
+		// substitution, e.g., lys23arg or trp24*
+		if start != stop {
 			return nil
 		}
-		if strings.Index(vrn, ";") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: variation pairs not yet implemented\n")
+		ins, ok := aaResidues(rest)
+		if !ok || len(ins) != 1 {
 			return nil
 		}
-		if strings.Index(vrn, "/") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: variation pairs not yet implemented\n")
-			return nil
+
+		spdi.Deleted = frst
+		spdi.Inserted = ins
+		spdi.Type = MIS
+		if ins == "*" {
+			spdi.Type = TRM
+		} else if frst == "*" {
+			spdi.Type = EXT
+		} else if frst == ins {
+			spdi.Type = SYN
 		}
-		if strings.Index(vrn, "(") >= 0 || strings.Index(vrn, ")") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: intron variation not yet implemented\n")
+
+		return spdi
+	}
+
+	// dispatch on HGVS class, unsupported forms such as alleles and uncertain positions return nil
+	parseOneType := func(cls, acc, vrn string) *SPDI {
+
+		if strings.ContainsAny(vrn, ";/()") {
 			return nil
 		}
-		if strings.Index(vrn, "[") >= 0 || strings.Index(vrn, "]") >= 0 || strings.Index(vrn, ";") >= 0 {
-			// fmt.Fprintf(os.Stderr, "\nWARNING: repetitive stretch not yet implemented\n")
-			return nil
+
+		switch cls {
+		case "g":
+			return parseNuc(GENOMIC, acc, vrn)
+		case "c":
+			return parseNuc(CODING, acc, vrn)
+		case "n":
+			return parseNuc(NONCODING, acc, vrn)
+		case "m":
+			return parseNuc(MITOCONDRIAL, acc, vrn)
+		case "r":
+			return parseNuc(RNA, acc, vrn)
+		case "p":
+			return parseProt(acc, vrn)
 		}
 
-		// otherwise use substitution
-		return parseSubs(cls, acc, vrn)
+		return nil
 	}
 
 	var buffer strings.Builder
 
 	var spdis []*SPDI
 
+	// strings that cannot be parsed are reported instead of dropped
+	var unparsed []string
+
 	ok := false
 
 	// trim prefix and suffix
@@ -411,6 +565,7 @@ func ParseHGVS(str string) string {
 	for _, hgv := range hgvs {
 
 		// skip empty item
+		hgv = strings.TrimSpace(hgv)
 		if hgv == "" {
 			continue
 		}
@@ -418,13 +573,13 @@ func ParseHGVS(str string) string {
 		// extract accession
 		acc, rgt := SplitInTwoLeft(hgv, ":")
 		if acc == "" || rgt == "" {
-			// fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse HGVS '%s'\n", hgv)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 		// split into type and variation
 		cls, vrn := SplitInTwoLeft(rgt, ".")
 		if cls == "" || vrn == "" {
-			// fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse HGVS '%s'\n", rgt)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
@@ -438,6 +593,7 @@ func ParseHGVS(str string) string {
 
 		spdi := parseOneType(cls, acc, vrn)
 		if spdi == nil {
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
@@ -457,33 +613,39 @@ func ParseHGVS(str string) string {
 			ver = strings.ToLower(arry[3])
 			if arry[2] != "." {
 				fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse version '%s', arry '%v'\n", acc, arry)
+				unparsed = append(unparsed, hgv)
 				continue
 			}
 		} else {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse accession '%s', arry '%v'\n", acc, arry)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
 		if pfx == "" || num == "" {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse accession '%s'\n", acc)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
 		// RefSeq accession body should be unsigned integer
 		if !IsAllDigits(num) {
 			fmt.Fprintf(os.Stderr, "\nERROR: Non-integer accession body '%s'\n", num)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
 		val, err := strconv.Atoi(num)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Integer conversion error '%s'\n", err)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
 		vsn, err := strconv.Atoi(ver)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Version error '%s'\n", err)
+			unparsed = append(unparsed, hgv)
 			continue
 		}
 
@@ -513,7 +675,7 @@ func ParseHGVS(str string) string {
 		ok = true
 	}
 
-	if !ok {
+	if !ok && len(unparsed) == 0 {
 		return ""
 	}
 
@@ -569,21 +731,179 @@ func ParseHGVS(str string) string {
 		clss := hgvsClass[itm.Class]
 		htyp := hgvsType[itm.Type]
 
-		pos := strconv.Itoa(itm.Position)
-		lbl := "Position"
-		if itm.Class == CODING {
-			lbl = "Offset"
-		}
 		buffer.WriteString("<Variant>" +
 			"<Class>" + clss + "</Class>" +
 			"<Type>" + htyp + "</Type>" +
-			"<Accession>" + itm.Accession + "</Accession>" +
-			"<" + lbl + ">" + pos + "</" + lbl + ">" +
-			"<Deleted>" + itm.Deleted + "</Deleted>" +
-			"<Inserted>" + itm.Inserted + "</Inserted>" +
-			"<Hgvs>" + itm.Hgvs + "</Hgvs>" +
+			"<Accession>" + itm.Accession + "</Accession>")
+		if !itm.Relative {
+			pos := strconv.Itoa(itm.Position)
+			lbl := "Position"
+			if itm.Class == CODING {
+				lbl = "Offset"
+			}
+			buffer.WriteString("<" + lbl + ">" + pos + "</" + lbl + ">")
+		}
+		buffer.WriteString("<Start>" + itm.Start + "</Start>" +
+			"<Stop>" + itm.Stop + "</Stop>")
+		if itm.Deleted != "" {
+			buffer.WriteString("<Deleted>" + itm.Deleted + "</Deleted>")
+		}
+		if itm.Inserted != "" {
+			buffer.WriteString("<Inserted>" + itm.Inserted + "</Inserted>")
+		}
+		if itm.CopyCount != "" {
+			buffer.WriteString("<CopyCount>" + itm.CopyCount + "</CopyCount>")
+		}
+		if itm.Shift != "" {
+			// distance to new stop codon in frameshift or extension
+			buffer.WriteString("<Shift>" + itm.Shift + "</Shift>")
+		}
+		buffer.WriteString("<Hgvs>" + itm.Hgvs + "</Hgvs>" +
 			"</Variant>\n")
 	}
 
+	for _, hgv := range unparsed {
+		buffer.WriteString("<Unparsed>" + html.EscapeString(hgv) + "</Unparsed>\n")
+	}
+
+	return buffer.String()
+}
+
+// AMINO ACID CODE CONVERSION
+
+// lower-case HGVS operators that may follow residues within a token, longest first
+var hgvsKeywords = []string{"delins", "del", "ins", "dup", "ext", "inv", "con", "fs"}
+
+// convertResidueRun rewrites one run of letters, returning false if any part is not a residue or HGVS keyword
+func convertResidueRun(run string, toOne bool) (string, bool) {
+
+	var buffer strings.Builder
+
+	for run != "" {
+
+		found := false
+		for _, kw := range hgvsKeywords {
+			if strings.HasPrefix(run, kw) {
+				buffer.WriteString(kw)
+				run = run[len(kw):]
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		if toOne {
+			if len(run) >= 4 && strings.ToLower(run[:4]) == "stop" {
+				buffer.WriteString("*")
+				run = run[4:]
+				continue
+			}
+			if len(run) < 3 {
+				return "", false
+			}
+			res, ok := aaTo1[strings.ToLower(run[:3])]
+			if !ok || res == "-" {
+				return "", false
+			}
+			buffer.WriteString(res)
+			run = run[3:]
+			continue
+		}
+
+		res, ok := aaTo3[strings.ToUpper(run[:1])]
+		if !ok || res == "Gap" {
+			return "", false
+		}
+		if res == "Xxx" {
+			// HGVS uses Xaa for an unknown residue
+			res = "Xaa"
+		}
+		buffer.WriteString(res)
+		run = run[1:]
+	}
+
+	return buffer.String(), true
+}
+
+// convertResidues applies convertResidueRun to each run of letters, stop asterisks are left in place
+func convertResidues(str string, toOne bool) string {
+
+	isRunChar := func(ch byte) bool {
+		return ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z'
+	}
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(str); {
+
+		if !isRunChar(str[i]) {
+			buffer.WriteByte(str[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(str) && isRunChar(str[j]) {
+			j++
+		}
+		run := str[i:j]
+
+		if txt, ok := convertResidueRun(run, toOne); ok {
+			buffer.WriteString(txt)
+		} else {
+			buffer.WriteString(run)
+		}
+		i = j
+	}
+
+	return buffer.String()
+}
+
+// convertProteinExpressions converts text following a "p." prefix, up to whitespace, comma, or semicolon,
+// leaving all other text unchanged
+func convertProteinExpressions(str string, toOne bool) string {
+
+	var buffer strings.Builder
+
+	for {
+		idx := strings.Index(str, "p.")
+		if idx < 0 {
+			buffer.WriteString(str)
+			break
+		}
+		buffer.WriteString(str[:idx+2])
+
+		// prefix must begin a token, so "Group." is not treated as a protein expression
+		if idx > 0 {
+			ch := str[idx-1]
+			if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '_' || ch == '.' {
+				str = str[idx+2:]
+				continue
+			}
+		}
+		str = str[idx+2:]
+
+		end := strings.IndexAny(str, " \t\n,;")
+		if end < 0 {
+			end = len(str)
+		}
+		buffer.WriteString(convertResidues(str[:end], toOne))
+		str = str[end:]
+	}
+
 	return buffer.String()
 }
+
+// AminoAcid3to1 replaces three-letter residue codes, e.g., p.Arg175His becomes p.R175H
+func AminoAcid3to1(str string) string {
+
+	return convertProteinExpressions(str, true)
+}
+
+// AminoAcid1to3 replaces one-letter residue codes, e.g., p.R175H becomes p.Arg175His
+func AminoAcid1to3(str string) string {
+
+	return convertProteinExpressions(str, false)
+}
@@ -0,0 +1,189 @@
+package eutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAminoAcidConversion(t *testing.T) {
+
+	// three-letter expressions survive a round trip through one-letter codes
+	three := []string{
+		"p.Arg175His",
+		"NP_000537.3:p.Arg175His",
+		"p.Trp24*",
+		"p.Arg97Profs*23",
+		"p.Met1ext-5",
+		"p.Lys23_Val25del",
+		"p.Cys28delinsTrpVal",
+		"p.(Gln18*)",
+		"p.Asx12Glx",
+		"p.Xaa5Gly",
+		"p.Arg175His, p.Trp24*; p.Gly12Val",
+	}
+	for _, str := range three {
+		one := AminoAcid3to1(str)
+		if one == str {
+			t.Errorf("%q was not converted", str)
+		}
+		if back := AminoAcid1to3(one); back != str {
+			t.Errorf("%q became %q and then %q", str, one, back)
+		}
+	}
+
+	tests := []struct {
+		in, out string
+		toOne   bool
+	}{
+		{"p.Arg175His", "p.R175H", true},
+		{"p.arg175HIS", "p.R175H", true},
+		{"p.Trp24Ter", "p.W24*", true},
+		{"p.Trp24*", "p.W24*", true},
+		{"p.W24*", "p.Trp24*", false},
+		{"p.R97Pfs*23", "p.Arg97Profs*23", false},
+		{"p.B12Z", "p.Asx12Glx", false},
+		{"p.X5G", "p.Xaa5Gly", false},
+		// text outside of p. expressions is left alone
+		{"hello world", "hello world", true},
+		{"hello world", "hello world", false},
+		{"TP53 R175H", "TP53 R175H", false},
+		{"NM_000546.6:c.524G>A", "NM_000546.6:c.524G>A", true},
+		{"NM_000546.6:c.524G>A", "NM_000546.6:c.524G>A", false},
+		{"Group.A", "Group.A", false},
+		// unknown tokens within an expression are untouched
+		{"p.Arg175Foo", "p.R175Foo", true},
+	}
+	for _, tst := range tests {
+		got := ""
+		if tst.toOne {
+			got = AminoAcid3to1(tst.in)
+		} else {
+			got = AminoAcid1to3(tst.in)
+		}
+		if got != tst.out {
+			t.Errorf("%q: expected %q, got %q", tst.in, tst.out, got)
+		}
+	}
+}
+
+// representative ClinVar expressions covering each supported variant class
+var clinvarHGVS = []string{
+	"NM_000546.6:c.524G>A",
+	"NM_000546.6:c.215C>G",
+	"NM_000546.6:c.743G>A",
+	"NM_000546.6:c.818G>A",
+	"NM_007294.4:c.68_69del",
+	"NM_007294.4:c.5266dup",
+	"NM_007294.4:c.181T>G",
+	"NM_007294.4:c.4035del",
+	"NM_000059.4:c.5946del",
+	"NM_000059.4:c.9097dup",
+	"NM_000059.4:c.771_775del",
+	"NM_000059.4:c.8487+1G>A",
+	"NM_000492.4:c.1521_1523del",
+	"NM_000492.4:c.1624G>T",
+	"NM_000492.4:c.1585-1G>A",
+	"NM_000492.4:c.3718-2477C>T",
+	"NM_000492.4:c.-8G>C",
+	"NM_000518.5:c.20A>T",
+	"NM_000518.5:c.-78A>G",
+	"NM_000518.5:c.*110T>C",
+	"NM_000518.5:c.92+1G>A",
+	"NM_000518.5:c.27_28insG",
+	"NM_004006.3:c.2T>C",
+	"NM_004006.3:c.123_127delinsAG",
+	"NM_004006.3:c.88+2T>G",
+	"NM_004006.3:c.-14+1G>A",
+	"NM_004006.3:c.1000_1010inv",
+	"NM_002111.8:c.52CAG[42]",
+	"NM_000044.6:c.172CAG[25]",
+	"NM_000138.5:c.4916_4917delinsTT",
+	"NM_000138.5:c.5788+5G>A",
+	"NM_000249.4:c.1852_1854del",
+	"NM_000249.4:c.350C>T",
+	"NM_000251.3:c.942+3A>T",
+	"NC_000017.11:g.43045712T>C",
+	"NC_000013.11:g.32340301_32340302del",
+	"NC_000007.14:g.117559590_117559592del",
+	"NC_012920.1:m.3243A>G",
+	"NC_012920.1:m.8344A>G",
+	"NR_003051.3:n.100A>G",
+	"NP_000537.3:p.Arg175His",
+	"NP_000537.3:p.Arg273Cys",
+	"NP_000537.3:p.(Arg248Gln)",
+	"NP_009225.1:p.Lys23Argfs*10",
+	"NP_009225.1:p.Gln1756Profs*74",
+	"NP_000050.3:p.Trp24Ter",
+	"NP_000483.3:p.Phe508del",
+	"NP_000483.3:p.Gly542*",
+	"NP_000509.1:p.Glu7Val",
+	"NP_000509.1:p.Met1ext-5",
+}
+
+func TestParseHGVS(t *testing.T) {
+
+	if len(clinvarHGVS) != 50 {
+		t.Fatalf("expected 50 test expressions, found %d", len(clinvarHGVS))
+	}
+
+	for _, hgv := range clinvarHGVS {
+		xml := ParseHGVS(hgv)
+		if strings.Contains(xml, "<Unparsed>") {
+			t.Errorf("%s: unparsed", hgv)
+			continue
+		}
+		if strings.Count(xml, "<Variant>") != 1 {
+			t.Errorf("%s: expected one variant, got %q", hgv, xml)
+			continue
+		}
+		for _, tag := range []string{"Class", "Type", "Accession", "Start", "Stop"} {
+			if !strings.Contains(xml, "<"+tag+">") {
+				t.Errorf("%s: missing %s in %q", hgv, tag, xml)
+			}
+		}
+		for _, tag := range []string{"Class", "Type", "Accession", "Offset", "Position", "Start", "Stop", "Deleted", "Inserted", "CopyCount", "Shift"} {
+			if strings.Contains(xml, "<"+tag+"></"+tag+">") {
+				t.Errorf("%s: empty %s in %q", hgv, tag, xml)
+			}
+		}
+	}
+
+	tests := []struct {
+		hgvs string
+		want []string
+		omit []string
+	}{
+		{"NM_004006.3:c.123_127delinsAG", []string{"<Type>Indel</Type>", "<Offset>122</Offset>", "<Start>123</Start>", "<Stop>127</Stop>", "<Inserted>AG</Inserted>"}, []string{"<Deleted>"}},
+		{"NM_004006.3:c.88+2T>G", []string{"<Start>88+2</Start>", "<Deleted>T</Deleted>", "<Inserted>G</Inserted>"}, []string{"<Offset>"}},
+		{"NM_004006.3:c.-14+1G>A", []string{"<Start>-14+1</Start>"}, []string{"<Offset>"}},
+		{"NM_000518.5:c.*110T>C", []string{"<Start>*110</Start>"}, []string{"<Offset>"}},
+		{"NM_000518.5:c.-78A>G", []string{"<Offset>-78</Offset>"}, nil},
+		{"NM_000546.6:c.524G>A", []string{"<Offset>523</Offset>"}, nil},
+		{"NC_000017.11:g.43045712T>C", []string{"<Position>43045711</Position>"}, nil},
+		{"NM_002111.8:c.52CAG[42]", []string{"<Type>Repetitive</Type>", "<Inserted>CAG</Inserted>", "<CopyCount>42</CopyCount>"}, []string{"<Deleted>"}},
+		{"NM_007294.4:c.5266dup", []string{"<Type>Duplication</Type>"}, []string{"<Deleted>", "<Inserted>"}},
+		{"NM_004006.3:c.1000_1010inv", []string{"<Type>Inversion</Type>", "<Stop>1010</Stop>"}, nil},
+		{"NP_009225.1:p.Lys23Argfs*10", []string{"<Type>Frameshift</Type>", "<Deleted>K</Deleted>", "<Inserted>R</Inserted>", "<Shift>*10</Shift>"}, nil},
+		{"NP_000050.3:p.Trp24Ter", []string{"<Type>Termination</Type>", "<Inserted>*</Inserted>"}, nil},
+		{"NP_000483.3:p.Phe508del", []string{"<Type>Deletion</Type>", "<Deleted>F</Deleted>"}, []string{"<Inserted>"}},
+	}
+	for _, tst := range tests {
+		xml := ParseHGVS(tst.hgvs)
+		for _, str := range tst.want {
+			if !strings.Contains(xml, str) {
+				t.Errorf("%s: expected %s in %q", tst.hgvs, str, xml)
+			}
+		}
+		for _, str := range tst.omit {
+			if strings.Contains(xml, str) {
+				t.Errorf("%s: unexpected %s in %q", tst.hgvs, str, xml)
+			}
+		}
+	}
+
+	// unparseable input is reported rather than dropped
+	xml := ParseHGVS("NM_000546.6:c.524G>A,NM_000546.6:c.?,garbage")
+	if strings.Count(xml, "<Unparsed>") != 2 || strings.Count(xml, "<Variant>") != 1 {
+		t.Errorf("expected one variant and two unparsed, got %q", xml)
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"bufio"
 	"compress/gzip"
 	"fmt"
+	"hash/crc32"
 	"html"
 	"io"
 	"os"
@@ -137,6 +138,103 @@ import (
 
 // ENTREZ2INDEX COMMAND GENERATOR
 
+// indexField is a custom -idxfields definition, indexed alongside the built-in fields
+type indexField struct {
+	Name string
+	Path string
+	Mode string
+}
+
+var customFields []indexField
+
+// LoadIndexFields reads FIELD<TAB>xtract-path<TAB>mode lines, with mode terms, pairs, indices, or year
+func LoadIndexFields(fname string) {
+
+	inFile, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open index field file '%s'\n", fname)
+		os.Exit(1)
+	}
+	defer inFile.Close()
+
+	scanr := bufio.NewScanner(inFile)
+
+	row := 0
+	for scanr.Scan() {
+
+		row++
+		line := strings.TrimSpace(scanr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Line %d of '%s' must have FIELD, path, and mode separated by tabs\n", row, fname)
+			os.Exit(1)
+		}
+
+		name := strings.TrimSpace(cols[0])
+		path := strings.Trim(strings.TrimSpace(cols[1]), "/")
+		mode := strings.ToLower(strings.TrimSpace(cols[2]))
+
+		// field names become postings directories and query qualifiers
+		if name == "" || !IsAllCapsOrDigits(name) || name[0] < 'A' || name[0] > 'Z' {
+			fmt.Fprintf(os.Stderr, "\nERROR: Field name '%s' on line %d must be upper-case letters and digits\n", name, row)
+			os.Exit(1)
+		}
+		if path == "" {
+			fmt.Fprintf(os.Stderr, "\nERROR: Missing xtract path for field '%s' on line %d\n", name, row)
+			os.Exit(1)
+		}
+
+		switch mode {
+		case "terms", "pairs", "indices", "year":
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized mode '%s' for field '%s', use terms, pairs, indices, or year\n", mode, name)
+			os.Exit(1)
+		}
+
+		customFields = append(customFields, indexField{Name: name, Path: path, Mode: mode})
+	}
+}
+
+// makeCustomFieldCommands explores the parent of each -idxfields path and wraps values in the field name
+func makeCustomFieldCommands(recname string) []string {
+
+	var acc []string
+
+	for _, fld := range customFields {
+
+		// GrantList/Grant/GrantID explores GrantList/Grant and extracts GrantID
+		block := recname
+		leaf := fld.Path
+		if pos := strings.LastIndex(leaf, "/"); pos >= 0 {
+			block = leaf[:pos]
+			leaf = leaf[pos+1:]
+			// -block accepts at most Parent/Child
+			if idx := strings.LastIndex(block, "/"); idx >= 0 {
+				if prv := strings.LastIndex(block[:idx], "/"); prv >= 0 {
+					block = block[prv+1:]
+				}
+			}
+		}
+
+		switch fld.Mode {
+		case "terms":
+			acc = append(acc, "-block", block, "-wrp", fld.Name, "-element", leaf)
+		case "pairs":
+			acc = append(acc, "-block", block, "-wrp", fld.Name, "-pairx", leaf)
+		case "indices":
+			acc = append(acc, "-block", block, "-indices:"+fld.Name, leaf)
+		case "year":
+			acc = append(acc, "-block", block, "-wrp", fld.Name, "-year", leaf)
+		}
+	}
+
+	return acc
+}
+
 // MakeE2Commands generates extraction commands to create input for Entrez2Index
 func MakeE2Commands(tform, db string, isPipe bool) []string {
 
@@ -305,6 +403,17 @@ func MakeE2Commands(tform, db string, isPipe bool) []string {
 		acc = append(acc, "-block", "TaxNode", "-wrp", "HGC", "-element", "Hydrogenosome")
 	}
 
+	// custom fields from -idxfields follow the built-in fields inside IdxSearchFields
+	if len(acc) > 0 && len(customFields) > 0 {
+		recname := "PubmedArticle"
+		if db == "pmc" {
+			recname = "PMCExtract"
+		} else if db == "taxonomy" {
+			recname = "TaxNode"
+		}
+		acc = append(acc, makeCustomFieldCommands(recname)...)
+	}
+
 	return acc
 }
 
@@ -467,9 +576,71 @@ func stringToGzFile(base, path, file, str string) {
 // e2IndexConsumer callbacks have access to application-specific data as closures
 type e2IndexConsumer func(inp <-chan XMLRecord) <-chan XMLRecord
 
+// archiveFolderHashes returns UID and crc32 lines for the records in one archive folder,
+// using whichever compressed or uncompressed record file exists, as the fetcher does
+func archiveFolderHashes(archiveBase, path, pfx string, uids []string) string {
+
+	var buffer strings.Builder
+
+	for _, uid := range uids {
+
+		sum := "-"
+
+		for _, name := range []string{uid + ".xml.gz", uid + ".xml", pfx + uid + ".xml.gz", pfx + uid + ".xml"} {
+			data, err := os.ReadFile(filepath.Join(archiveBase, path, name))
+			if err == nil {
+				sum = strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10)
+				break
+			}
+		}
+
+		buffer.WriteString(uid)
+		buffer.WriteString("\t")
+		buffer.WriteString(sum)
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// indexFileSignature summarizes the .e2x.gz files that make up one inverted index file, using the
+// archive hashes saved with each indexed file, or the checksum of the indexed file if there are none
+func indexFileSignature(filenames []string) string {
+
+	var buffer strings.Builder
+
+	for _, fpath := range filenames {
+
+		data, err := os.ReadFile(strings.TrimSuffix(fpath, ".e2x.gz") + ".crc")
+		if err != nil {
+			data, err = os.ReadFile(fpath)
+		}
+		if err != nil {
+			continue
+		}
+
+		buffer.WriteString(filepath.Base(fpath))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10))
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// writeHashFile saves archive folder hashes next to the corresponding .e2x.gz file
+func writeHashFile(fpath, str string) {
+
+	err := os.WriteFile(fpath, []byte(str), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+	}
+}
+
 // IncrementalIndex creates or updates missing cached .e2x.gz indexed files,
-// e.g., /Index/02/53/025393.e2x.gz for /Archive/02/53/93/*.xml.gz
-func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsumer) <-chan string {
+// e.g., /Index/02/53/025393.e2x.gz for /Archive/02/53/93/*.xml.gz, also
+// reindexing folders whose archived records no longer match the stored .crc hashes
+func IncrementalIndex(archiveBase, indexBase, db, pfx string, force bool, csmr e2IndexConsumer) <-chan string {
 
 	if csmr == nil {
 		return nil
@@ -615,6 +786,11 @@ func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsum
 		return out
 	}
 
+	// mutex protects hashes of folders waiting to be indexed
+	var hlock sync.Mutex
+
+	pendingHashes := make(map[string]string)
+
 	// filterIndexFolders checks for presence of an Index file for an archive folder,
 	// only passing those files that need to be (re)indexed
 	filterIndexFolders := func(indexBase string, inp <-chan []string) <-chan XMLRecord {
@@ -648,12 +824,28 @@ func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsum
 
 				target := filepath.Join(indBase, indPath, indFile+".e2x.gz")
 
+				hashes := archiveFolderHashes(archiveBase, path, pfx, pmids)
+				crcPath := filepath.Join(indBase, indPath, indFile+".crc")
+
 				_, err := os.Stat(target)
-				if err == nil {
-					// skip if first-level incremental Entrez index file exists for current set of 100 archive records
-					continue
+				if err == nil && !force {
+					prev, err := os.ReadFile(crcPath)
+					if err != nil {
+						// record current hashes for index files created before hashes were saved
+						writeHashFile(crcPath, hashes)
+						continue
+					}
+					if string(prev) == hashes {
+						// skip if first-level incremental Entrez index file exists and archived records are unchanged
+						continue
+					}
 				}
 
+				// hashes are saved after the index file is written
+				hlock.Lock()
+				pendingHashes[indFile] = hashes
+				hlock.Unlock()
+
 				for _, pmid := range pmids {
 					// increment index so unshuffler can restore order of resuls
 					idx++
@@ -754,6 +946,19 @@ func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsum
 			vlock.Unlock()
 		}
 
+		// saveHashes writes archive hashes for a newly indexed folder
+		saveHashes := func(indBase, indPath, ident string) {
+
+			hlock.Lock()
+			hashes, ok := pendingHashes[ident]
+			delete(pendingHashes, ident)
+			hlock.Unlock()
+
+			if ok {
+				writeHashFile(filepath.Join(indBase, indPath, ident+".crc"), hashes)
+			}
+		}
+
 		indexCombiner := func(indBase string, inp <-chan XMLRecord, out chan<- string) {
 
 			defer close(out)
@@ -783,6 +988,7 @@ func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsum
 					txt := buffer.String()
 					indPath, _ := IndexTrie(currentIdent + "00")
 					stringToGzFile(indBase, indPath, currentIdent+".e2x.gz", txt)
+					saveHashes(indBase, indPath, currentIdent)
 					buffer.Reset()
 
 					if verbose {
@@ -805,6 +1011,7 @@ func IncrementalIndex(archiveBase, indexBase, db, pfx string, csmr e2IndexConsum
 				txt := buffer.String()
 				indPath, _ := IndexTrie(currentIdent + "00")
 				stringToGzFile(indBase, indPath, currentIdent+".e2x.gz", txt)
+				saveHashes(indBase, indPath, currentIdent)
 				buffer.Reset()
 
 				if verbose {
@@ -1343,11 +1550,7 @@ func IncrementalInvert(indexBase, invertBase, db string) <-chan string {
 			target := filepath.Join(invertBase, fname)
 
 			// incremental inverted index file is removed when records in relevant range are archived or deleted
-			_, err := os.Stat(target)
-			if err == nil {
-				// if inverted index file exists, no need to recreate
-				return
-			}
+			_, terr := os.Stat(target)
 
 			mids, _, _ := examineFolder(indexBase, path)
 
@@ -1379,7 +1582,25 @@ func IncrementalInvert(indexBase, invertBase, db string) <-chan string {
 				return
 			}
 
+			// inverted index file is recreated when the indexed files it was built from have changed
+			signature := indexFileSignature(filenames)
+			sigPath := filepath.Join(invertBase, fileBase+sfx+".crc")
+
+			if terr == nil {
+				prev, err := os.ReadFile(sigPath)
+				if err != nil {
+					// record current signature for inverted files created before signatures were saved
+					writeHashFile(sigPath, signature)
+					return
+				}
+				if string(prev) == signature {
+					// if inverted index file exists and indexed files are unchanged, no need to recreate
+					return
+				}
+			}
+
 			invertIndexFiles(fname, filenames)
+			writeHashFile(sigPath, signature)
 
 			out <- fname
 		}
@@ -0,0 +1,80 @@
+package eutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiveFolderHashes(t *testing.T) {
+
+	base := t.TempDir()
+	path := filepath.Join("02", "53", "93")
+	dir := filepath.Join(base, path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, str string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(str), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("2539300.xml.gz", "compressed")
+	write("2539301.xml", "uncompressed")
+	write("PMC2539302.xml.gz", "prefixed")
+
+	uids := []string{"2539300", "2539301", "2539302", "2539303"}
+
+	first := archiveFolderHashes(base, path, "PMC", uids)
+	lines := strings.Split(strings.TrimSpace(first), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4\n%s", len(lines), first)
+	}
+	for i, line := range lines[:3] {
+		if strings.HasSuffix(line, "\t-") {
+			t.Errorf("record %s was not hashed", uids[i])
+		}
+	}
+	if !strings.HasSuffix(lines[3], "\t-") {
+		t.Errorf("missing record has hash: %s", lines[3])
+	}
+
+	// changing an uncompressed record changes the folder hash
+	write("2539301.xml", "revised")
+	if archiveFolderHashes(base, path, "PMC", uids) == first {
+		t.Error("hash did not change after record was revised")
+	}
+}
+
+func TestIndexFileSignature(t *testing.T) {
+
+	dir := t.TempDir()
+
+	e2x := filepath.Join(dir, "025393.e2x.gz")
+	crc := filepath.Join(dir, "025393.crc")
+
+	if err := os.WriteFile(e2x, []byte("index"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without saved archive hashes, the indexed file itself is used
+	plain := indexFileSignature([]string{e2x})
+	if plain == "" {
+		t.Fatal("empty signature")
+	}
+
+	if err := os.WriteFile(crc, []byte("2539300\t1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := indexFileSignature([]string{e2x})
+
+	if err := os.WriteFile(crc, []byte("2539300\t2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if indexFileSignature([]string{e2x}) == saved {
+		t.Error("signature did not change after archive hashes changed")
+	}
+}
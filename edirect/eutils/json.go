@@ -31,6 +31,7 @@
 package eutils
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"github.com/gedex/inflector"
@@ -481,3 +482,295 @@ func JSONtoXML(jsn, set, rec, nest string) string {
 
 	return res
 }
+
+// XMLToJSON is the inverse of JSONConverter, writing each record as soon as it closes.
+// Repeated elements become arrays, attributes are "@name" fields or a nested "_attributes"
+// object, text of mixed content is "#text", and -set or -rec remove wrappers
+func XMLToJSON(rdr <-chan XMLBlock, set, rec string, nested bool, out io.Writer) error {
+
+	if rdr == nil || out == nil {
+		return fmt.Errorf("Missing XML to JSON input or output")
+	}
+
+	tknq := CreateTokenizer(rdr)
+
+	if tknq == nil {
+		return fmt.Errorf("Unable to create XML to JSON tokenizer")
+	}
+
+	type xnode struct {
+		name  string
+		attrs []string
+		text  string
+		kids  []*xnode
+	}
+
+	var printValue func(buffer *strings.Builder, node *xnode, depth int)
+
+	indentTo := func(buffer *strings.Builder, depth int) {
+		for i := 0; i < depth; i++ {
+			buffer.WriteString("  ")
+		}
+	}
+
+	// printArray writes several values, each on its own line
+	printArray := func(buffer *strings.Builder, nodes []*xnode, depth int) {
+
+		buffer.WriteString("[\n")
+		for i, kid := range nodes {
+			if i > 0 {
+				buffer.WriteString(",\n")
+			}
+			indentTo(buffer, depth+1)
+			printValue(buffer, kid, depth+1)
+		}
+		buffer.WriteString("\n")
+		indentTo(buffer, depth)
+		buffer.WriteString("]")
+	}
+
+	printValue = func(buffer *strings.Builder, node *xnode, depth int) {
+
+		if len(node.attrs) == 0 && len(node.kids) == 0 {
+			buffer.WriteString(JSONScalar(node.text))
+			return
+		}
+
+		// children named with -j2x _E suffix came from a nested array
+		if len(node.attrs) == 0 && node.text == "" {
+			isArray := true
+			for _, kid := range node.kids {
+				if kid.name != node.name+"_E" {
+					isArray = false
+					break
+				}
+			}
+			if isArray {
+				printArray(buffer, node.kids, depth)
+				return
+			}
+		}
+
+		// group repeated children by name, in order of first appearance
+		var order []string
+		groups := make(map[string][]*xnode)
+		for _, kid := range node.kids {
+			if _, ok := groups[kid.name]; !ok {
+				order = append(order, kid.name)
+			}
+			groups[kid.name] = append(groups[kid.name], kid)
+		}
+
+		first := true
+		startField := func(key string) {
+			if first {
+				buffer.WriteString("{\n")
+				first = false
+			} else {
+				buffer.WriteString(",\n")
+			}
+			indentTo(buffer, depth+1)
+			// XML names never need escaping, but must be quoted even if numeric
+			buffer.WriteString("\"" + key + "\"")
+			buffer.WriteString(": ")
+		}
+
+		if len(node.attrs) > 1 {
+			if nested {
+				startField("_attributes")
+				buffer.WriteString("{\n")
+				for i := 0; i+1 < len(node.attrs); i += 2 {
+					if i > 0 {
+						buffer.WriteString(",\n")
+					}
+					indentTo(buffer, depth+2)
+					buffer.WriteString("\"" + node.attrs[i] + "\"")
+					buffer.WriteString(": ")
+					buffer.WriteString(JSONScalar(node.attrs[i+1]))
+				}
+				buffer.WriteString("\n")
+				indentTo(buffer, depth+1)
+				buffer.WriteString("}")
+			} else {
+				for i := 0; i+1 < len(node.attrs); i += 2 {
+					startField("@" + node.attrs[i])
+					buffer.WriteString(JSONScalar(node.attrs[i+1]))
+				}
+			}
+		}
+
+		if node.text != "" {
+			startField("#text")
+			buffer.WriteString(JSONScalar(node.text))
+		}
+
+		for _, name := range order {
+			startField(name)
+			kids := groups[name]
+			if len(kids) > 1 {
+				printArray(buffer, kids, depth+1)
+			} else {
+				printValue(buffer, kids[0], depth+1)
+			}
+		}
+
+		if first {
+			// attribute string without any name-value pairs
+			buffer.WriteString("{}")
+			return
+		}
+
+		buffer.WriteString("\n")
+		indentTo(buffer, depth)
+		buffer.WriteString("}")
+	}
+
+	wrtr := bufio.NewWriter(out)
+	defer wrtr.Flush()
+
+	var err error
+
+	count := 0
+	held := ""
+
+	// printRecord holds back the first record, then opens an array if a second one follows
+	printRecord := func(node *xnode, wrapped bool) {
+
+		var buffer strings.Builder
+
+		if wrapped {
+			buffer.WriteString("{\n  ")
+			buffer.WriteString("\"" + node.name + "\"")
+			buffer.WriteString(": ")
+			printValue(&buffer, node, 1)
+			buffer.WriteString("\n}")
+		} else {
+			printValue(&buffer, node, 0)
+		}
+
+		count++
+
+		switch count {
+		case 1:
+			held = buffer.String()
+			return
+		case 2:
+			wrtr.WriteString("[\n")
+			wrtr.WriteString(held)
+			held = ""
+		}
+
+		wrtr.WriteString(",\n")
+		wrtr.WriteString(buffer.String())
+	}
+
+	var stack []*xnode
+
+	// depth of record in stack, records are emitted as soon as they close
+	recDepth := 1
+	if set != "" {
+		recDepth = 2
+	}
+
+	addNode := func(name, attr string) *xnode {
+
+		if len(stack) == 0 && set != "" && name != set && err == nil {
+			err = fmt.Errorf("Set wrapper <%s> does not match -set %s", name, set)
+		}
+
+		node := &xnode{name: name}
+		if attr != "" {
+			attrs := ParseAttributes(strings.TrimSpace(attr))
+			for i := 0; i+1 < len(attrs); i += 2 {
+				node.attrs = append(node.attrs, attrs[i], html.UnescapeString(attrs[i+1]))
+			}
+		}
+
+		if len(stack) > 0 {
+			prnt := stack[len(stack)-1]
+			if prnt != nil {
+				prnt.kids = append(prnt.kids, node)
+			}
+		}
+
+		// outside of a record, only keep track of depth
+		if rec != "" && name != rec && (len(stack) == 0 || stack[len(stack)-1] == nil) {
+			node = nil
+		}
+
+		stack = append(stack, node)
+
+		return node
+	}
+
+	closeNode := func() {
+
+		if len(stack) == 0 {
+			return
+		}
+
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if node == nil {
+			return
+		}
+
+		if rec != "" {
+			if node.name == rec && (len(stack) == 0 || stack[len(stack)-1] == nil) {
+				printRecord(node, false)
+			}
+		} else if len(stack)+1 == recDepth {
+			printRecord(node, true)
+			if len(stack) > 0 && stack[len(stack)-1] != nil {
+				// release record from set wrapper
+				prnt := stack[len(stack)-1]
+				prnt.kids = nil
+			}
+		}
+	}
+
+	for tkn := range tknq {
+
+		// drain remaining tokens after an error so the tokenizer is not blocked
+		if err != nil {
+			continue
+		}
+
+		switch tkn.Tag {
+		case STARTTAG:
+			addNode(tkn.Name, tkn.Attr)
+		case SELFTAG:
+			addNode(tkn.Name, tkn.Attr)
+			closeNode()
+		case STOPTAG:
+			closeNode()
+		case CONTENTTAG, CDATATAG:
+			if len(stack) > 0 && stack[len(stack)-1] != nil {
+				node := stack[len(stack)-1]
+				str := strings.TrimSpace(tkn.Name)
+				if tkn.Tag == CONTENTTAG {
+					str = html.UnescapeString(str)
+				}
+				if node.text != "" && str != "" {
+					node.text += " "
+				}
+				node.text += str
+			}
+		default:
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if count == 1 {
+		wrtr.WriteString(held)
+		wrtr.WriteString("\n")
+	} else if count > 1 {
+		wrtr.WriteString("\n]\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package eutils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// x2j runs XMLToJSON on an XML string
+func x2j(t *testing.T, xml, set, rec string) string {
+
+	t.Helper()
+
+	var buf strings.Builder
+	if err := XMLToJSON(CreateXMLStreamer(strings.NewReader(xml)), set, rec, false, &buf); err != nil {
+		t.Fatalf("XMLToJSON: %v", err)
+	}
+
+	return buf.String()
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name string
+		jsn  string
+		set  string
+		nest string
+	}{
+		{"scalars", `{"id": 7, "ok": true, "bad": false, "none": null, "score": -1.5e3, "name": "x y"}`, "", ""},
+		{"arrays", `{"tags": ["a", "b"], "flags": [true, false, null], "nums": [1, 2.5, -3]}`, "", ""},
+		{"nested arrays", `{"grid": [[1, 2], [3, null]], "sub": {"k": [true, 2.5], "deep": {"v": null}}}`, "", "element"},
+		{"records", `[{"a": 1, "b": null}, {"a": 2, "b": [false, true]}]`, "Set", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			xml := JSONtoXML(tt.jsn, tt.set, "Rec", tt.nest)
+			out := x2j(t, xml, tt.set, "Rec")
+
+			var want, got interface{}
+			if err := json.Unmarshal([]byte(tt.jsn), &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v\nXML:\n%s", got, want, xml)
+			}
+		})
+	}
+}
+
+func TestXMLToJSONScalars(t *testing.T) {
+
+	out := x2j(t, "<Rec><a>null</a><b>true</b><c>007</c><d>1.5e-3</d><e>\"null\"</e></Rec>\n", "", "Rec")
+
+	// null is bare like true and numbers, leading zeros are not a JSON number
+	for _, str := range []string{`"a": null`, `"b": true`, `"c": "007"`, `"d": 1.5e-3`, `"e": "\"null\""`} {
+		if !strings.Contains(out, str) {
+			t.Errorf("missing %s in\n%s", str, out)
+		}
+	}
+}
+
+func TestXMLToJSONSetMismatch(t *testing.T) {
+
+	var buf strings.Builder
+	err := XMLToJSON(CreateXMLStreamer(strings.NewReader("<Other><Rec/></Other>\n")), "Set", "", false, &buf)
+
+	if err == nil || err.Error() != "Set wrapper <Other> does not match -set Set" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -41,6 +41,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -366,6 +367,56 @@ func CreateFusers(inp <-chan XMLRecord) <-chan Plex {
 	return out
 }
 
+// mergePositions combines pos="..." attributes for the same UID found in separate inverted indices
+func mergePositions(prev, curr string) string {
+
+	if prev == "" || prev == curr {
+		return curr
+	}
+	if curr == "" {
+		return prev
+	}
+
+	if !strings.HasPrefix(prev, "pos=\"") || !strings.HasPrefix(curr, "pos=\"") {
+		return curr
+	}
+
+	var arry []int
+
+	seen := make(map[int]bool)
+
+	for _, atr := range []string{prev, curr} {
+		atr = strings.TrimPrefix(atr, "pos=\"")
+		atr = strings.TrimSuffix(atr, "\"")
+		for _, item := range strings.Split(atr, ",") {
+			num, err := strconv.Atoi(item)
+			if err != nil {
+				return curr
+			}
+			if seen[num] {
+				continue
+			}
+			seen[num] = true
+			arry = append(arry, num)
+		}
+	}
+
+	sort.Ints(arry)
+
+	var buffer strings.Builder
+
+	buffer.WriteString("pos=\"")
+	for i, num := range arry {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(strconv.Itoa(num))
+	}
+	buffer.WriteString("\"")
+
+	return buffer.String()
+}
+
 // CreateMergers combines collected indices for the same term
 func CreateMergers(inp <-chan Plex) <-chan XMLRecord {
 
@@ -397,7 +448,8 @@ func CreateMergers(inp <-chan Plex) <-chan XMLRecord {
 					fields[fld] = positions
 				}
 
-				positions[uid] = pos
+				// same term and UID in separate indices, e.g., from -e2join
+				positions[uid] = mergePositions(positions[uid], pos)
 			}
 
 			addUID := func(tag, attr, content string) {
@@ -509,6 +561,360 @@ func CreateMergers(inp <-chan Plex) <-chan XMLRecord {
 	return out
 }
 
+// reciprocal link pairs held in memory before spilling a sorted run to a temporary file
+const reciprocalSpill = 1 << 22
+
+// linkPair is one inverted link, sorted by zero-padded key and then numerically by UID
+type linkPair struct {
+	Key string
+	UID string
+}
+
+func linkPairLess(a, b linkPair) bool {
+
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	if len(a.UID) != len(b.UID) {
+		return len(a.UID) < len(b.UID)
+	}
+	return a.UID < b.UID
+}
+
+// linkRun reads one sorted temporary run during the final merge
+type linkRun struct {
+	scanr *bufio.Scanner
+	curr  linkPair
+}
+
+func (r *linkRun) next() bool {
+
+	if !r.scanr.Scan() {
+		return false
+	}
+	key, uid := SplitInTwoLeft(r.scanr.Text(), "\t")
+	r.curr = linkPair{key, uid}
+	return true
+}
+
+// linkRunHeap orders runs by their current pair
+type linkRunHeap []*linkRun
+
+func (h linkRunHeap) Len() int {
+	return len(h)
+}
+func (h linkRunHeap) Less(i, j int) bool {
+	return linkPairLess(h[i].curr, h[j].curr)
+}
+func (h linkRunHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// Push works on pointer to linkRunHeap
+func (h *linkRunHeap) Push(x interface{}) {
+	*h = append(*h, x.(*linkRun))
+}
+
+// Pop works on pointer to linkRunHeap
+func (h *linkRunHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// CreateReciprocator passes merged link records through unchanged while collecting each
+// FROM->TO pair in the field as a TO->FROM pair in the recip field, then sends the inverted
+// records in key order on the second channel after the first has closed
+func CreateReciprocator(field, recip string, inp <-chan XMLRecord) (<-chan XMLRecord, <-chan XMLRecord) {
+
+	if inp == nil || field == "" || recip == "" {
+		return nil, nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	rcp := make(chan XMLRecord, ChanDepth())
+	if out == nil || rcp == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create reciprocator channel\n")
+		os.Exit(1)
+	}
+
+	xmlReciprocator := func(inp <-chan XMLRecord, out, rcp chan<- XMLRecord) {
+
+		defer close(rcp)
+
+		var pairs []linkPair
+		var runs []string
+
+		tmpDir := ""
+
+		// spill writes the current pairs as a sorted, deduplicated run to keep memory bounded
+		spill := func() {
+
+			if len(pairs) < 1 {
+				return
+			}
+
+			if tmpDir == "" {
+				dir, err := os.MkdirTemp("", "rchive-reciprocal-")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unable to create temporary directory for reciprocal links\n")
+					os.Exit(1)
+				}
+				tmpDir = dir
+			}
+
+			sort.Slice(pairs, func(i, j int) bool { return linkPairLess(pairs[i], pairs[j]) })
+
+			fpath := filepath.Join(tmpDir, "run"+strconv.Itoa(len(runs)))
+			fl, err := os.Create(fpath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create reciprocal link run '%s'\n", fpath)
+				os.Exit(1)
+			}
+
+			wrtr := bufio.NewWriter(fl)
+			last := linkPair{}
+			for _, pr := range pairs {
+				if pr == last {
+					continue
+				}
+				wrtr.WriteString(pr.Key)
+				wrtr.WriteString("\t")
+				wrtr.WriteString(pr.UID)
+				wrtr.WriteString("\n")
+				last = pr
+			}
+			wrtr.Flush()
+			fl.Close()
+
+			runs = append(runs, fpath)
+			pairs = nil
+
+			debug.FreeOSMemory()
+		}
+
+		rec := 0
+		key := ""
+		var uids []string
+
+		// flush sends one inverted record for the current key
+		flush := func() {
+
+			if key == "" || len(uids) < 1 {
+				return
+			}
+
+			var buffer strings.Builder
+
+			buffer.WriteString("<InvDocument>\n<InvKey>")
+			buffer.WriteString(key)
+			buffer.WriteString("</InvKey>\n<InvIDs>\n")
+			for _, uid := range uids {
+				buffer.WriteString("<" + recip + ">" + uid + "</" + recip + ">\n")
+			}
+			buffer.WriteString("</InvIDs>\n</InvDocument>\n")
+
+			rec++
+			rcp <- XMLRecord{Index: rec, Ident: key, Text: buffer.String()}
+
+			uids = nil
+		}
+
+		// emit groups sorted pairs by key, skipping duplicate UIDs
+		emit := func(pr linkPair) {
+
+			if pr.Key != key {
+				flush()
+				key = pr.Key
+			}
+			if len(uids) > 0 && uids[len(uids)-1] == pr.UID {
+				return
+			}
+			uids = append(uids, pr.UID)
+		}
+
+		for curr := range inp {
+
+			// FROM key is zero-padded, TO identifiers are not
+			from := strings.TrimLeft(curr.Ident, "0")
+			if from == "" {
+				from = "0"
+			}
+
+			StreamValues(curr.Text[:], "InvDocument", func(tag, attr, content string) {
+				if tag == field && content != "" {
+					pairs = append(pairs, linkPair{PadNumericID(content), from})
+				}
+			})
+
+			out <- curr
+
+			if len(pairs) >= reciprocalSpill {
+				spill()
+			}
+		}
+
+		close(out)
+
+		if len(runs) == 0 {
+
+			// everything fit in memory
+			sort.Slice(pairs, func(i, j int) bool { return linkPairLess(pairs[i], pairs[j]) })
+			for _, pr := range pairs {
+				emit(pr)
+			}
+			flush()
+			return
+		}
+
+		spill()
+
+		defer os.RemoveAll(tmpDir)
+
+		// merge sorted runs
+		hp := &linkRunHeap{}
+		heap.Init(hp)
+
+		for _, fpath := range runs {
+			fl, err := os.Open(fpath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to open reciprocal link run '%s'\n", fpath)
+				os.Exit(1)
+			}
+			defer fl.Close()
+
+			run := &linkRun{scanr: bufio.NewScanner(fl)}
+			if run.next() {
+				heap.Push(hp, run)
+			}
+		}
+
+		for hp.Len() > 0 {
+			run := heap.Pop(hp).(*linkRun)
+			emit(run.curr)
+			if run.next() {
+				heap.Push(hp, run)
+			}
+		}
+		flush()
+	}
+
+	// launch single reciprocator goroutine
+	go xmlReciprocator(inp, out, rcp)
+
+	return out, rcp
+}
+
+// MergedFileGroups collects .mrg files with the same name from several merged inverted index directories
+func MergedFileGroups(dirs []string) [][]string {
+
+	groups := make(map[string][]string)
+
+	for _, dir := range dirs {
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to read merged index directory '%s'\n", dir)
+			os.Exit(1)
+		}
+
+		for _, ent := range entries {
+			name := ent.Name()
+			if ent.IsDir() {
+				continue
+			}
+			key := strings.TrimSuffix(name, ".gz")
+			if !strings.HasSuffix(key, ".mrg") {
+				continue
+			}
+			key = strings.TrimSuffix(key, ".mrg")
+			groups[key] = append(groups[key], filepath.Join(dir, name))
+		}
+	}
+
+	var keys []string
+	for ky := range groups {
+		keys = append(keys, ky)
+	}
+	sort.Strings(keys)
+
+	var res [][]string
+
+	for _, ky := range keys {
+		res = append(res, groups[ky])
+	}
+
+	return res
+}
+
+// CreateOrderVerifier passes merged records through, exiting if terms are not in sorted order,
+// or if the UID list for any field is not sorted and free of duplicates
+func CreateOrderVerifier(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create verifier channel\n")
+		os.Exit(1)
+	}
+
+	xmlVerifier := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		defer close(out)
+
+		prev := ""
+
+		for curr := range inp {
+
+			if prev != "" && curr.Ident <= prev {
+				fmt.Fprintf(os.Stderr, "\nERROR: Merged term '%s' is out of order after '%s'\n", curr.Ident, prev)
+				os.Exit(1)
+			}
+			prev = curr.Ident
+
+			// UIDs within each field must be strictly increasing, using numeric order
+			last := make(map[string]string)
+
+			StreamValues(curr.Text[:], "InvDocument", func(tag, attr, content string) {
+
+				if tag == "InvKey" {
+					return
+				}
+
+				lst, ok := last[tag]
+				last[tag] = content
+
+				if !ok {
+					return
+				}
+
+				// shorter string is numerically less, assuming no leading zeros
+				if len(content) > len(lst) || (len(content) == len(lst) && content > lst) {
+					return
+				}
+
+				if content == lst {
+					fmt.Fprintf(os.Stderr, "\nERROR: Merged term '%s' has duplicate %s UID '%s'\n", curr.Ident, tag, content)
+				} else {
+					fmt.Fprintf(os.Stderr, "\nERROR: Merged term '%s' has %s UID '%s' out of order after '%s'\n", curr.Ident, tag, content, lst)
+				}
+				os.Exit(1)
+			})
+
+			out <- curr
+		}
+	}
+
+	go xmlVerifier(inp, out)
+
+	return out
+}
+
 // CreateSplitter distributes adjacent records with the same identifier prefix
 func CreateSplitter(mergePath string, zipp, isLink bool, inp <-chan XMLRecord) <-chan string {
 
@@ -0,0 +1,188 @@
+package eutils
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	idxDocumentRE = regexp.MustCompile(`<IdxUid>([^<]*)</IdxUid><IdxSearchFields>(.*?)</IdxSearchFields>`)
+	idxFieldRE    = regexp.MustCompile(`<(\w+)(?: pos="([0-9,]+)")?>([^<]*)</\w+>`)
+)
+
+// splitIndexed divides each IdxDocument between two index sets, with first choosing the set
+// for each field and position, and UID kept in both
+func splitIndexed(t *testing.T, idx string, first func(tag string, pos int) bool) (string, string) {
+
+	t.Helper()
+
+	var one, two strings.Builder
+
+	one.WriteString("<IdxDocumentSet>\n")
+	two.WriteString("<IdxDocumentSet>\n")
+
+	for _, doc := range idxDocumentRE.FindAllStringSubmatch(idx, -1) {
+
+		var lft, rgt strings.Builder
+
+		for _, fld := range idxFieldRE.FindAllStringSubmatch(doc[2], -1) {
+
+			tag, pos, term := fld[1], fld[2], fld[3]
+
+			if tag == "UID" {
+				lft.WriteString(fld[0])
+				rgt.WriteString(fld[0])
+				continue
+			}
+
+			if pos == "" {
+				if first(tag, 0) {
+					lft.WriteString(fld[0])
+				} else {
+					rgt.WriteString(fld[0])
+				}
+				continue
+			}
+
+			var a, b []string
+			for _, str := range strings.Split(pos, ",") {
+				num, err := strconv.Atoi(str)
+				if err != nil {
+					t.Fatalf("bad position in %s", fld[0])
+				}
+				if first(tag, num) {
+					a = append(a, str)
+				} else {
+					b = append(b, str)
+				}
+			}
+			if len(a) > 0 {
+				lft.WriteString("<" + tag + " pos=\"" + strings.Join(a, ",") + "\">" + term + "</" + tag + ">")
+			}
+			if len(b) > 0 {
+				rgt.WriteString("<" + tag + " pos=\"" + strings.Join(b, ",") + "\">" + term + "</" + tag + ">")
+			}
+		}
+
+		head := "<IdxDocument><IdxUid>" + doc[1] + "</IdxUid><IdxSearchFields>"
+		tail := "</IdxSearchFields></IdxDocument>\n"
+
+		one.WriteString(head + lft.String() + tail)
+		two.WriteString(head + rgt.String() + tail)
+	}
+
+	one.WriteString("</IdxDocumentSet>\n")
+	two.WriteString("</IdxDocumentSet>\n")
+
+	return one.String(), two.String()
+}
+
+// postingsFiles reads every promoted file except the manifest, keyed by relative path
+func postingsFiles(t *testing.T, base string) map[string][]byte {
+
+	t.Helper()
+
+	res := make(map[string][]byte)
+
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "promote.manifest" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(base, path)
+		res[rel] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestJoinMergedIndices(t *testing.T) {
+
+	xml := `<PubmedArticleSet>
+<PubmedArticle><MedlineCitation><PMID>7</PMID><Article>
+<ArticleTitle>Tamoxifen and breast cancer recurrence.</ArticleTitle>
+<Abstract>
+<AbstractText>Adjuvant tamoxifen lowered recurrence of breast cancer.</AbstractText>
+<AbstractText>Cancer mortality also declined.</AbstractText>
+</Abstract>
+</Article></MedlineCitation></PubmedArticle>
+<PubmedArticle><MedlineCitation><PMID>12</PMID><Article>
+<ArticleTitle>Lung cancer screening with computed tomography.</ArticleTitle>
+</Article></MedlineCitation></PubmedArticle>
+<PubmedArticle><MedlineCitation><PMID>100</PMID><Article>
+<ArticleTitle>Aspirin use in older adults.</ArticleTitle>
+<Abstract>
+<AbstractText>Aspirin did not prolong disability-free survival, and cancer deaths rose.</AbstractText>
+</Abstract>
+</Article></MedlineCitation></PubmedArticle>
+</PubmedArticleSet>
+`
+
+	const fields = "TIAB TITL PAIR PROP"
+
+	dir := t.TempDir()
+	idx := indexPubmedRecords(t, xml)
+
+	want := postingsFiles(t, promoteMerged(t, mergeIndexed(t, filepath.Join(dir, "Combined"), idx), filepath.Join(dir, "Expected"), fields))
+	if len(want) == 0 {
+		t.Fatal("no postings from combined inversion")
+	}
+
+	tests := []struct {
+		name  string
+		first func(tag string, pos int) bool
+	}{
+		// separate field sets, as from different indexing runs
+		{"fields", func(tag string, pos int) bool { return tag == "TIAB" || tag == "TITL" }},
+		// title and abstract positions of the same term and UID in different indices
+		{"positions", func(tag string, pos int) bool { return pos > 0 && pos < 100 }},
+	}
+
+	for _, tt := range tests {
+
+		sub := filepath.Join(dir, tt.name)
+		one, two := splitIndexed(t, idx, tt.first)
+
+		dirs := []string{
+			mergeIndexed(t, filepath.Join(sub, "One"), one),
+			mergeIndexed(t, filepath.Join(sub, "Two"), two),
+		}
+
+		joined := filepath.Join(sub, "Joined")
+		if err := os.MkdirAll(joined, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		// same pipeline as rchive -e2join
+		for _, files := range MergedFileGroups(dirs) {
+			for range CreateSplitter(joined, false, false, CreateOrderVerifier(CreateXMLUnshuffler(CreateMergers(CreateManifold(CreatePresenters(files)))))) {
+			}
+		}
+
+		got := postingsFiles(t, promoteMerged(t, joined, filepath.Join(sub, "Postings"), fields))
+
+		for path, data := range want {
+			if !bytes.Equal(got[path], data) {
+				t.Errorf("%s: joined %s differs from combined inversion", tt.name, path)
+			}
+		}
+		for path := range got {
+			if _, ok := want[path]; !ok {
+				t.Errorf("%s: joined index has extra file %s", tt.name, path)
+			}
+		}
+	}
+}
@@ -31,7 +31,9 @@
 package eutils
 
 import (
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"html"
 	"sort"
 	"strconv"
@@ -742,6 +744,7 @@ var aaTo1 = map[string]string{
 	"Trp": "W",
 	"Tyr": "Y",
 	"Val": "V",
+	"Xaa": "X",
 	"Xle": "J",
 	"Xxx": "X",
 	"ala": "A",
@@ -770,6 +773,7 @@ var aaTo1 = map[string]string{
 	"trp": "W",
 	"tyr": "Y",
 	"val": "V",
+	"xaa": "X",
 	"xle": "J",
 	"xxx": "X",
 }
@@ -1823,6 +1827,218 @@ func DoTrimFlankingHTML(str string) string {
 	return str
 }
 
+// IsArithmeticExpression recognizes the inside of a parenthesized variable assignment that should be
+// evaluated, either because it refers to another variable, or because it applies an operator to integers
+// without leading zeros. Other parenthesized text, such as "(, )", "(-)", or "(2024-01-01)", is a literal.
+func IsArithmeticExpression(str string) bool {
+
+	hasVar := false
+	hasOp := false
+	allArith := true
+	leadZero := false
+	// an operand precedes a binary operator
+	operand := false
+
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch {
+		case ch == '&' && i+1 < len(str) && ((str[i+1] >= 'A' && str[i+1] <= 'Z') || (str[i+1] >= '0' && str[i+1] <= '9')):
+			// variable reference must start a token, so "R&D" is text
+			if i == 0 || strings.IndexByte(" \t(+-*/%", str[i-1]) >= 0 {
+				hasVar = true
+			} else {
+				allArith = false
+			}
+			for i+1 < len(str) && ((str[i+1] >= 'A' && str[i+1] <= 'Z') || (str[i+1] >= '0' && str[i+1] <= '9')) {
+				i++
+			}
+			operand = true
+		case ch >= '0' && ch <= '9':
+			if ch == '0' && i+1 < len(str) && str[i+1] >= '0' && str[i+1] <= '9' && (i == 0 || str[i-1] < '0' || str[i-1] > '9') {
+				leadZero = true
+			}
+			operand = true
+		case ch == ')':
+			operand = true
+		case ch == '+', ch == '-', ch == '*', ch == '/', ch == '%':
+			if operand {
+				hasOp = true
+			}
+			operand = false
+		case ch == '(':
+			operand = false
+		case ch == ' ', ch == '\t':
+		default:
+			allArith = false
+		}
+	}
+
+	return hasVar || (allArith && hasOp && !leadZero)
+}
+
+// EvaluateArithmetic computes an integer expression with + - * /, parentheses, unary minus,
+// and &VARIABLE references, returning false if a variable is missing or not an integer
+func EvaluateArithmetic(str string, variables map[string]string) (int, bool, error) {
+
+	pos := 0
+	missing := false
+
+	skipSpaces := func() {
+		for pos < len(str) && (str[pos] == ' ' || str[pos] == '\t') {
+			pos++
+		}
+	}
+
+	var parseSum func() (int, error)
+	var parseFactor func() (int, error)
+
+	// parseFactor handles integers, variables, unary minus, and parenthesized subexpressions
+	parseFactor = func() (int, error) {
+
+		skipSpaces()
+		if pos >= len(str) {
+			return 0, errors.New("unexpected end of expression")
+		}
+
+		ch := str[pos]
+
+		switch {
+		case ch == '-':
+			pos++
+			val, err := parseFactor()
+			return -val, err
+		case ch == '+':
+			pos++
+			return parseFactor()
+		case ch == '(':
+			pos++
+			val, err := parseSum()
+			if err != nil {
+				return 0, err
+			}
+			skipSpaces()
+			if pos >= len(str) || str[pos] != ')' {
+				return 0, errors.New("missing closing parenthesis")
+			}
+			pos++
+			return val, nil
+		case ch == '&':
+			pos++
+			start := pos
+			for pos < len(str) && ((str[pos] >= 'A' && str[pos] <= 'Z') || (str[pos] >= '0' && str[pos] <= '9')) {
+				pos++
+			}
+			name := str[start:pos]
+			if name == "" {
+				return 0, errors.New("missing variable name after '&'")
+			}
+			num, err := strconv.Atoi(strings.TrimSpace(variables[name]))
+			if err != nil {
+				// substitute zero so the rest of the expression is still checked
+				missing = true
+				return 0, nil
+			}
+			return num, nil
+		case ch >= '0' && ch <= '9':
+			start := pos
+			for pos < len(str) && str[pos] >= '0' && str[pos] <= '9' {
+				pos++
+			}
+			num, err := strconv.Atoi(str[start:pos])
+			if err != nil {
+				return 0, errors.New("integer '" + str[start:pos] + "' is out of range")
+			}
+			return num, nil
+		}
+
+		return 0, errors.New("unexpected '" + string(ch) + "'")
+	}
+
+	// unsupportedOperator reports an operator that is not + - * /
+	unsupportedOperator := func() error {
+
+		skipSpaces()
+		if pos < len(str) && strings.IndexByte("%^&|<>=!", str[pos]) >= 0 {
+			return errors.New("has unsupported operator '" + string(str[pos]) + "'")
+		}
+
+		return nil
+	}
+
+	// parseProduct applies * and / before + and -
+	parseProduct := func() (int, error) {
+
+		val, err := parseFactor()
+		if err != nil {
+			return 0, err
+		}
+
+		for {
+			if err := unsupportedOperator(); err != nil {
+				return 0, err
+			}
+			if pos >= len(str) || (str[pos] != '*' && str[pos] != '/') {
+				return val, nil
+			}
+			op := str[pos]
+			pos++
+			rgt, err := parseFactor()
+			if err != nil {
+				return 0, err
+			}
+			if op == '*' {
+				val *= rgt
+			} else if rgt != 0 {
+				val /= rgt
+			} else if !missing {
+				return 0, errors.New("division by zero")
+			}
+		}
+	}
+
+	parseSum = func() (int, error) {
+
+		val, err := parseProduct()
+		if err != nil {
+			return 0, err
+		}
+
+		for {
+			skipSpaces()
+			if pos >= len(str) || (str[pos] != '+' && str[pos] != '-') {
+				return val, nil
+			}
+			op := str[pos]
+			pos++
+			rgt, err := parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			if op == '+' {
+				val += rgt
+			} else {
+				val -= rgt
+			}
+		}
+	}
+
+	val, err := parseSum()
+	if err != nil {
+		return 0, false, err
+	}
+
+	skipSpaces()
+	if pos < len(str) {
+		return 0, false, errors.New("unexpected '" + str[pos:] + "'")
+	}
+
+	if missing {
+		return 0, false, nil
+	}
+
+	return val, true, nil
+}
+
 // FixSpecialCases fixes hyphenated or primed prefixes and suffixes for indexing
 func FixSpecialCases(str string) string {
 
@@ -1948,6 +2164,107 @@ func GenBankToMedlineAuthors(name string) string {
 	return name
 }
 
+// surnameParticles are lowercase prefixes kept with the family name in AuthorKey
+var surnameParticles = map[string]bool{
+	"da":    true,
+	"das":   true,
+	"de":    true,
+	"del":   true,
+	"della": true,
+	"der":   true,
+	"des":   true,
+	"di":    true,
+	"do":    true,
+	"dos":   true,
+	"du":    true,
+	"la":    true,
+	"le":    true,
+	"ten":   true,
+	"ter":   true,
+	"van":   true,
+	"von":   true,
+}
+
+// nameSuffixes are generational suffixes dropped by AuthorKey
+var nameSuffixes = map[string]bool{
+	"jr":  true,
+	"sr":  true,
+	"2nd": true,
+	"3rd": true,
+	"ii":  true,
+	"iii": true,
+	"iv":  true,
+}
+
+// AuthorKey converts "Smith JA", "Smith,J.A.", "Smith, John A", or "J. A. Smith" to "smith j"
+func AuthorKey(name string) string {
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+
+	name = TransformAccents(name, false, false)
+	name = strings.Replace(name, "&#39;", "'", -1)
+
+	surname := ""
+	given := ""
+
+	if pos := strings.Index(name, ","); pos >= 0 {
+		rgt := strings.TrimSpace(name[pos+1:])
+		if strings.Contains(rgt, " ") {
+			// "Smith, John A"
+			surname = name[:pos]
+			given = rgt
+		} else {
+			// GenBank "Smith-Jones,J.-P." becomes Medline "Smith-Jones JP"
+			name = GenBankToMedlineAuthors(name)
+		}
+	}
+
+	if surname == "" {
+
+		words := strings.Fields(strings.Replace(name, ".", ". ", -1))
+
+		// drop trailing generational suffix
+		for len(words) > 1 && nameSuffixes[strings.ToLower(strings.TrimSuffix(words[len(words)-1], "."))] {
+			words = words[:len(words)-1]
+		}
+
+		if len(words) < 2 {
+			surname = strings.Join(words, " ")
+		} else if last := words[len(words)-1]; len(last) <= 3 && strings.ToUpper(last) == last && strings.ToLower(last) != last {
+			// Medline "de la Cruz M" has surname first, then initials
+			surname = strings.Join(words[:len(words)-1], " ")
+			given = last
+		} else {
+			// "J. A. van der Berg" has given names first, particles start the surname
+			pos := len(words) - 1
+			for pos > 1 && surnameParticles[strings.ToLower(words[pos-1])] {
+				pos--
+			}
+			surname = strings.Join(words[pos:], " ")
+			given = strings.Join(words[:pos], " ")
+		}
+	}
+
+	surname = strings.ToLower(strings.TrimSpace(surname))
+	given = strings.TrimSpace(given)
+
+	if given == "" {
+		return surname
+	}
+
+	// keep only the first initial
+	for _, ch := range given {
+		if unicode.IsLetter(ch) {
+			return surname + " " + strings.ToLower(string(ch))
+		}
+	}
+
+	return surname
+}
+
 // HasAdjacentSpaces reports if CompressRunsOfSpaces is needed
 func HasAdjacentSpaces(str string) bool {
 
@@ -2001,6 +2318,16 @@ func HasAmpOrNotASCII(str string) bool {
 	return false
 }
 
+// EscapeIfNeeded calls html.EscapeString only if the string has a character it would encode
+func EscapeIfNeeded(str string) string {
+
+	if strings.IndexAny(str, "&<>\"'") < 0 {
+		return str
+	}
+
+	return html.EscapeString(str)
+}
+
 // HasAngleBracket reports angle brackets or ampersand encodings
 func HasAngleBracket(str string) bool {
 
@@ -2344,24 +2671,72 @@ func IsUnicodeSuper(ch rune) bool {
 	return ch == 0x00B2 || ch == 0x00B3 || ch == 0x00B9 || (ch >= 0x2070 && ch <= 0x207F)
 }
 
-// Ncbi2naToIupac converts a hex-encoded ncbi2na binary nucleotide sequence to IUPAC
-func Ncbi2naToIupac(str string) string {
+// decodePackedSequence returns the bytes of a packed sequence written in hex or, unless
+// forceHex is set, in base64, with hex taking precedence for strings valid in both
+func decodePackedSequence(str string, forceHex bool) ([]byte, bool) {
 
-	if str == "" {
-		return ""
+	str = strings.Join(strings.Fields(str), "")
+
+	isHex := str != ""
+	for _, ch := range str {
+		if !strings.ContainsRune("0123456789ABCDEFabcdef", ch) {
+			isHex = false
+			break
+		}
 	}
 
-	var buffer strings.Builder
+	if isHex {
+		if len(str)%2 != 0 {
+			// odd number of hex digits implies trailing zero
+			str += "0"
+		}
+		dst, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, false
+		}
+		return dst, true
+	}
 
-	src := []byte(str)
-	dst := make([]byte, hex.DecodedLen(len(src)))
+	if forceHex {
+		return nil, false
+	}
 
-	n, err := hex.Decode(dst, src)
+	dst, err := base64.StdEncoding.DecodeString(str)
 	if err != nil {
+		dst, err = base64.RawStdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return dst, true
+}
+
+// Ncbi2naToIupac converts a hex- or base64-encoded ncbi2na binary nucleotide sequence to IUPAC
+func Ncbi2naToIupac(str string) string {
+
+	return unpackNcbi2na(str, false)
+}
+
+// Hex2naToIupac converts a hex-encoded ncbi2na sequence, without trying base64
+func Hex2naToIupac(str string) string {
+
+	return unpackNcbi2na(str, true)
+}
+
+func unpackNcbi2na(str string, forceHex bool) string {
+
+	if str == "" {
 		return ""
 	}
 
-	dst = dst[:n]
+	dst, ok := decodePackedSequence(str, forceHex)
+	if !ok {
+		return ""
+	}
+
+	var buffer strings.Builder
+
 	for _, byt := range dst {
 		tmp := ncbi2naToIupac[int(byt)]
 		buffer.WriteString(tmp)
@@ -2370,24 +2745,20 @@ func Ncbi2naToIupac(str string) string {
 	return buffer.String()
 }
 
-// Ncbi4naToIupac converts a hex-encoded ncbi4na binary nucleotide sequence to IUPAC
+// Ncbi4naToIupac converts a hex- or base64-encoded ncbi4na binary nucleotide sequence to IUPAC
 func Ncbi4naToIupac(str string) string {
 
 	if str == "" {
 		return ""
 	}
 
-	var buffer strings.Builder
-
-	src := []byte(str)
-	dst := make([]byte, hex.DecodedLen(len(src)))
-
-	n, err := hex.Decode(dst, src)
-	if err != nil {
+	dst, ok := decodePackedSequence(str, false)
+	if !ok {
 		return ""
 	}
 
-	dst = dst[:n]
+	var buffer strings.Builder
+
 	for _, byt := range dst {
 		tmp := ncbi4naToIupac[int(byt)]
 		buffer.WriteString(tmp)
@@ -2396,6 +2767,22 @@ func Ncbi4naToIupac(str string) string {
 	return buffer.String()
 }
 
+// TrimPackedSequence removes padding from an unpacked sequence, given the length recorded
+// in the record and the number of residues per byte, reporting whether the lengths agree
+func TrimPackedSequence(seq string, length, perByte int) (string, bool) {
+
+	if length < 1 || perByte < 1 {
+		return seq, true
+	}
+
+	// last byte may be partially filled
+	if len(seq) < length || len(seq) >= length+perByte {
+		return seq, false
+	}
+
+	return seq[:length], true
+}
+
 // NeedsTightening determines whether TightenParentheses needs to be called
 func NeedsTightening(str string) bool {
 
@@ -0,0 +1,277 @@
+package eutils
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+// typical PubMed titles and abstract sentences, with the two that need escaping at the end
+var pubmedTexts = []string{
+	"Mutations in the TP53 tumor suppressor gene in human cancers.",
+	"Genome-wide association study identifies new susceptibility loci for type 2 diabetes.",
+	"The structure of the potassium channel: molecular basis of K+ conduction and selectivity.",
+	"We analyzed 1,204 patients with confirmed diagnosis over a period of 10 years.",
+	"CRISPR-Cas9 mediated knockout of BRCA1 in human embryonic stem cells.",
+	"Results are discussed in the context of prior reports.",
+	"Expression was reduced (p < 0.05) in treated cells compared with controls.",
+	"Effects of diet & exercise on \"metabolic\" markers in older adults.",
+}
+
+func TestEscapeIfNeeded(t *testing.T) {
+
+	extra := []string{"", "&", "<b>", "it's", "a > b", "&amp;", "plain"}
+
+	for _, str := range append(pubmedTexts, extra...) {
+		if got, want := EscapeIfNeeded(str), html.EscapeString(str); got != want {
+			t.Errorf("%q: got %q, want %q", str, got, want)
+		}
+	}
+}
+
+// go test -bench Escape -benchmem compares unconditional and guarded escaping,
+// on plain text alone and on the full set that includes strings needing escapes
+func BenchmarkEscape(b *testing.B) {
+
+	sets := []struct {
+		name  string
+		texts []string
+	}{
+		{"plain", pubmedTexts[:6]},
+		{"mixed", pubmedTexts},
+	}
+
+	for _, set := range sets {
+		texts := set.texts
+
+		b.Run(set.name+"/EscapeString", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, str := range texts {
+					html.EscapeString(str)
+				}
+			}
+		})
+
+		b.Run(set.name+"/EscapeIfNeeded", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, str := range texts {
+					EscapeIfNeeded(str)
+				}
+			}
+		})
+	}
+}
+
+func TestPackedSequenceEncodings(t *testing.T) {
+
+	// ACGTACGTAC packed four bases per byte, with the last byte padded, in hex and base64
+	tests := []struct {
+		name string
+		fn   func(string) string
+		hex  string
+		b64  string
+		want string
+	}{
+		{"ncbi2na", Ncbi2naToIupac, "1B1B10", "GxsQ", "ACGTACGTACAA"},
+		{"ncbi2na lower case", Ncbi2naToIupac, "1b1b10", "GxsQ", "ACGTACGTACAA"},
+		{"ncbi4na", Ncbi4naToIupac, "12481248", "EkgSSA==", "ACGTACGT"},
+		{"ncbi4na unpadded base64", Ncbi4naToIupac, "12481248", "EkgSSA", "ACGTACGT"},
+	}
+
+	for _, tt := range tests {
+		frst := tt.fn(tt.hex)
+		scnd := tt.fn(tt.b64)
+		if frst != tt.want || scnd != tt.want {
+			t.Errorf("%s: hex gives %q, base64 gives %q, want %q", tt.name, frst, scnd, tt.want)
+		}
+	}
+
+	// -hex2na does not try base64
+	if seq := Hex2naToIupac("1B1B10"); seq != "ACGTACGTACAA" {
+		t.Errorf("Hex2naToIupac gives %q", seq)
+	}
+	if seq := Hex2naToIupac("GxsQ"); seq != "" {
+		t.Errorf("Hex2naToIupac decoded base64 as %q", seq)
+	}
+	if seq := Ncbi2naToIupac("not packed!"); seq != "" {
+		t.Errorf("undecodable value gives %q", seq)
+	}
+}
+
+func TestTrimPackedSequence(t *testing.T) {
+
+	tests := []struct {
+		seq     string
+		length  int
+		perByte int
+		want    string
+		match   bool
+	}{
+		{"ACGTACGTACAA", 10, 4, "ACGTACGTAC", true},
+		{"ACGTACGTACAA", 12, 4, "ACGTACGTACAA", true},
+		{"ACGTACGTACAA", 0, 4, "ACGTACGTACAA", true},
+		{"ACGTACGTACAA", 20, 4, "ACGTACGTACAA", false},
+		{"ACGTACGTACAA", 8, 4, "ACGTACGTACAA", false},
+		{"ACGTACGT", 7, 2, "ACGTACG", true},
+	}
+
+	for _, tt := range tests {
+		seq, match := TrimPackedSequence(tt.seq, tt.length, tt.perByte)
+		if seq != tt.want || match != tt.match {
+			t.Errorf("TrimPackedSequence(%q, %d, %d) = %q, %v, want %q, %v", tt.seq, tt.length, tt.perByte, seq, match, tt.want, tt.match)
+		}
+	}
+}
+
+func TestPackedSequenceExtraction(t *testing.T) {
+
+	record := func(length, data string) string {
+		return "<Bioseq><Seq-inst><Seq-inst_length>" + length + "</Seq-inst_length><Seq-inst_seq-data><Seq-data>" +
+			"<Seq-data_ncbi2na><NCBI2na>" + data + "</NCBI2na></Seq-data_ncbi2na></Seq-data></Seq-inst_seq-data></Seq-inst></Bioseq>"
+	}
+
+	// both encodings give the same sequence, trimmed to the recorded length
+	xml := "<Bioseq-set>" + record("10", "1B1B10") + record("10", "GxsQ") + "</Bioseq-set>\n"
+
+	if out := extractText(t, xml, "-pattern", "Bioseq", "-ncbi2na", "NCBI2na"); out != "ACGTACGTAC\nACGTACGTAC\n" {
+		t.Errorf("-ncbi2na gives %q", out)
+	}
+
+	// a length that cannot match is reported
+	xml = "<Bioseq-set>" + record("20", "1B1B10") + "</Bioseq-set>\n"
+
+	out := ""
+	log := captureStderr(t, func() {
+		out = extractText(t, xml, "-pattern", "Bioseq", "-ncbi2na", "NCBI2na")
+	})
+	if out != "ACGTACGTACAA\n" || !strings.Contains(log, "Decoded 12 residues, but sequence length is 20") {
+		t.Errorf("mismatched length gives %q with warning %q", out, log)
+	}
+
+	// -hex2na warns instead of guessing base64
+	xml = "<Bioseq-set>" + record("10", "GxsQ") + "</Bioseq-set>\n"
+
+	log = captureStderr(t, func() {
+		out = extractText(t, xml, "-pattern", "Bioseq", "-hex2na", "NCBI2na")
+	})
+	if out != "" || !strings.Contains(log, "Unable to decode packed sequence 'GxsQ'") {
+		t.Errorf("-hex2na on base64 gives %q with warning %q", out, log)
+	}
+}
+
+func TestEvaluateArithmetic(t *testing.T) {
+
+	variables := map[string]string{"FR": "101", "TO": "250", "NEG": "-4", "TXT": "abc"}
+
+	tests := []struct {
+		expr string
+		want int
+		ok   bool
+		err  string
+	}{
+		{"&TO - &FR + 1", 150, true, ""},
+		// * and / before + and -, left to right within each level
+		{"2 + 3 * 4", 14, true, ""},
+		{"(2 + 3) * 4", 20, true, ""},
+		{"20 - 6 - 4", 10, true, ""},
+		{"100 / 10 / 5", 2, true, ""},
+		{"7 / 2", 3, true, ""},
+		{"-7 / 2", -3, true, ""},
+		{"-(&TO - &FR) * -2", 298, true, ""},
+		{"((1 + 2) * (3 + 4)) - --1", 20, true, ""},
+		{"&NEG * &NEG", 16, true, ""},
+		// missing or non-integer variables leave the result unset
+		{"&TO - &MISSING + 1", 0, false, ""},
+		{"&TXT + 1", 0, false, ""},
+		{"&MISSING / 0", 0, false, ""},
+		{"&TO / (&FR - 101)", 0, false, "division by zero"},
+		{"&TO % 2", 0, false, "has unsupported operator '%'"},
+		{"&TO ^ 2", 0, false, "has unsupported operator '^'"},
+		{"(&TO - 1", 0, false, "missing closing parenthesis"},
+		{"&TO -", 0, false, "unexpected end of expression"},
+		{"&TO 5", 0, false, "unexpected '5'"},
+	}
+
+	for _, tt := range tests {
+		num, ok, err := EvaluateArithmetic(tt.expr, variables)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if num != tt.want || ok != tt.ok || msg != tt.err {
+			t.Errorf("%q: got %d, %v, %q, want %d, %v, %q", tt.expr, num, ok, msg, tt.want, tt.ok, tt.err)
+		}
+	}
+}
+
+func TestIsArithmeticExpression(t *testing.T) {
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"&TO - &FR + 1", true},
+		{"7 / 2", true},
+		{"3-2", true},
+		{"&T % 2", true},
+		{"7 % 2", true},
+		{"&A, &B", true},
+		// literals
+		{", ", false},
+		{"-", false},
+		{"1", false},
+		{"-1", false},
+		{"2024-01-01", false},
+		{"R&D", false},
+		{"a - b", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsArithmeticExpression(tt.expr); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorKey(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		// the same author in Medline, GenBank, full name, and given-name-first forms
+		{"Smith J", "smith j"},
+		{"Smith JA", "smith j"},
+		{"J. A. Smith", "smith j"},
+		{"Smith,J.A.", "smith j"},
+		{"Smith, John A", "smith j"},
+		// apostrophes, including the encoded form, are kept
+		{"O'Brien K", "o'brien k"},
+		{"Kevin O'Brien", "o'brien k"},
+		{"O&#39;Brien K", "o'brien k"},
+		// particles stay with the surname
+		{"de la Cruz M", "de la cruz m"},
+		{"Maria de la Cruz", "de la cruz m"},
+		{"Ludwig van Beethoven", "van beethoven l"},
+		{"van Beethoven L", "van beethoven l"},
+		// accents are stripped
+		{"Müller H", "muller h"},
+		{"Hans Müller", "muller h"},
+		// hyphenated surnames are preserved
+		{"Smith-Jones JP", "smith-jones j"},
+		{"Smith-Jones,J.-P.", "smith-jones j"},
+		// generational suffixes are dropped
+		{"Smith J Jr", "smith j"},
+		{"Smith JA 3rd", "smith j"},
+		{"Madonna", "madonna"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := AuthorKey(tt.name); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -31,6 +31,8 @@
 package eutils
 
 import (
+	"fmt"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -249,3 +251,133 @@ func ProteinWeight(str string, trimLeadingMet bool) string {
 
 	return str
 }
+
+// MassOptions selects monoisotopic masses, fixed residue modifications, and ambiguous residue handling
+type MassOptions struct {
+	Mono    bool
+	Average bool
+	Mods    map[rune]float64
+}
+
+// massOpts is used by the xtract -molwt extraction command
+var massOpts MassOptions
+
+// SetMassOptions records -molwt customizations for subsequent extraction
+func SetMassOptions(opts MassOptions) {
+
+	massOpts = opts
+}
+
+// ParseMassModification splits a residue:delta argument, e.g. C:+57.02146
+func ParseMassModification(str string) (rune, float64) {
+
+	res, dlt := SplitInTwoLeft(str, ":")
+	res = strings.ToUpper(res)
+
+	if len(res) != 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Modification '%s' must start with a single residue letter\n", str)
+		os.Exit(1)
+	}
+	ch := rune(res[0])
+	if _, ok := numC[ch]; !ok || ch == 'B' || ch == 'Z' || ch == 'X' {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized residue '%s' in modification '%s'\n", res, str)
+		os.Exit(1)
+	}
+
+	val, err := strconv.ParseFloat(dlt, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized mass offset '%s' in modification '%s'\n", dlt, str)
+		os.Exit(1)
+	}
+
+	return ch, val
+}
+
+// residueMass returns the mass of an amino acid residue within a peptide chain
+func residueMass(ch rune, mono bool) float64 {
+
+	if mono {
+		return 12.0*float64(numC[ch]) +
+			1.00782503207*float64(numH[ch]) +
+			14.0030740048*float64(numN[ch]) +
+			15.99491461956*float64(numO[ch]) +
+			31.97207100*float64(numS[ch]) +
+			79.9165213*float64(numSe[ch])
+	}
+
+	return 12.01115*float64(numC[ch]) +
+		1.0079*float64(numH[ch]) +
+		14.0067*float64(numN[ch]) +
+		15.9994*float64(numO[ch]) +
+		32.064*float64(numS[ch]) +
+		78.96*float64(numSe[ch])
+}
+
+// ambiguousMass averages the possible residues for B (D or N), Z (E or Q), and X (any standard residue)
+func ambiguousMass(ch rune, mono bool) float64 {
+
+	choices := ""
+
+	switch ch {
+	case 'B':
+		choices = "DN"
+	case 'Z':
+		choices = "EQ"
+	case 'X':
+		choices = "ACDEFGHIKLMNPQRSTVWY"
+	default:
+		return residueMass(ch, mono)
+	}
+
+	sum := 0.0
+	for _, aa := range choices {
+		sum += residueMass(aa, mono)
+	}
+
+	return sum / float64(len(choices))
+}
+
+// ProteinMass calculates the average or monoisotopic mass of a peptide, with optional fixed modifications
+func ProteinMass(str string, trimLeadingMet bool, opts MassOptions) string {
+
+	// without customization, keep the traditional integer molecular weight
+	if !opts.Mono && !opts.Average && len(opts.Mods) == 0 {
+		return ProteinWeight(str, trimLeadingMet)
+	}
+
+	str = strings.ToUpper(str)
+
+	if trimLeadingMet {
+		str = strings.TrimPrefix(str, "M")
+	}
+
+	// start with water (H2O)
+	wt := 2*1.0079 + 15.9994
+	if opts.Mono {
+		wt = 2*1.00782503207 + 15.99491461956
+	}
+
+	skipped := 0
+
+	for _, ch := range str {
+		if _, ok := numC[ch]; !ok {
+			continue
+		}
+		if ch == 'B' || ch == 'Z' || ch == 'X' {
+			if !opts.Average {
+				skipped++
+				continue
+			}
+			wt += ambiguousMass(ch, opts.Mono)
+			continue
+		}
+		wt += residueMass(ch, opts.Mono)
+		wt += opts.Mods[ch]
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "\nWARNING: Skipped %d ambiguous residues (B, Z, X) in mass calculation\n", skipped)
+	}
+
+	return strconv.FormatFloat(wt, 'f', 3, 64)
+}
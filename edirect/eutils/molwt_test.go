@@ -0,0 +1,60 @@
+package eutils
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestProteinMass(t *testing.T) {
+
+	mono := MassOptions{Mono: true}
+	carb := MassOptions{Mono: true, Mods: map[rune]float64{'C': 57.02146}}
+
+	tests := []struct {
+		seq  string
+		opts MassOptions
+		want float64
+		tol  float64
+	}{
+		// monoisotopic reference masses
+		{"PEPTIDE", mono, 799.35997, 0.001},
+		{"DRVYIHPF", mono, 1045.53451, 0.001},
+		{"RPPGFSPFR", mono, 1059.56140, 0.001},
+		// carbamidomethylation on every cysteine
+		{"CAC", mono, 295.06605, 0.001},
+		{"CAC", carb, 409.10897, 0.001},
+		// average mass
+		{"PEPTIDE", MassOptions{Average: true}, 799.83, 0.01},
+	}
+
+	for _, tt := range tests {
+		str := ProteinMass(tt.seq, false, tt.opts)
+		got, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			t.Errorf("%s: unexpected result %q", tt.seq, str)
+			continue
+		}
+		if math.Abs(got-tt.want) > tt.tol {
+			t.Errorf("%s: got %s, want %.5f", tt.seq, str, tt.want)
+		}
+	}
+
+	// three decimal places
+	if str := ProteinMass("PEPTIDE", false, mono); str != "799.360" {
+		t.Errorf("got %q, want 799.360", str)
+	}
+
+	// ambiguous residues are skipped unless averaging is requested
+	if ProteinMass("PEPTIDEX", false, mono) != ProteinMass("PEPTIDE", false, mono) {
+		t.Error("ambiguous residue was not skipped")
+	}
+	if ProteinMass("PEPTIDEX", false, MassOptions{Mono: true, Average: true}) == ProteinMass("PEPTIDE", false, mono) {
+		t.Error("ambiguous residue was not averaged")
+	}
+
+	// trimming leading methionine
+	if ProteinMass("MPEPTIDE", true, mono) != "799.360" {
+		t.Error("leading methionine was not trimmed")
+	}
+}
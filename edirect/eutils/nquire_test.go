@@ -0,0 +1,133 @@
+package eutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// failingServer answers the first failures requests with the given status and Retry-After value, then succeeds
+func failingServer(status, failures int, after string) (*httptest.Server, *int64) {
+
+	var count int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1) <= int64(failures) {
+			if after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("<Result>ok</Result>\n"))
+	}))
+
+	return srv, &count
+}
+
+// runNquire runs the nquire script with curl, returning standard output and standard error, and whether
+// the request succeeded, since nquire reports a failed request without a failing exit status
+func runNquire(t *testing.T, args ...string) (string, string, bool) {
+
+	t.Helper()
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not available")
+	}
+
+	cmd := exec.Command("bash", append([]string{"../nquire"}, args...)...)
+	cmd.Env = append(cmd.Environ(), "EDIRECT_MAX_RETRIES=", "NQUIRE_HELPER=curl")
+
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	return stdout.String(), stderr.String(), err == nil && !strings.Contains(stderr.String(), "command failed")
+}
+
+func TestNquireRetry(t *testing.T) {
+
+	srv, count := failingServer(http.StatusTooManyRequests, 2, "0")
+	defer srv.Close()
+
+	out, log, ok := runNquire(t, "-get", srv.URL+"/esummary.fcgi", "-db", "pubmed", "-api_key", "SECRET")
+
+	if !ok || out != "<Result>ok</Result>\n" {
+		t.Fatalf("got %q, success %v, log:\n%s", out, ok, log)
+	}
+	if *count != 3 {
+		t.Errorf("server saw %d requests, want 3", *count)
+	}
+	if n := strings.Count(log, "nquire HTTP 429, retry"); n != 2 {
+		t.Errorf("log shows %d retries, want 2:\n%s", n, log)
+	}
+	if strings.Contains(log, "SECRET") || !strings.Contains(log, "api_key=REDACTED") {
+		t.Errorf("api_key is not redacted:\n%s", log)
+	}
+}
+
+func TestNquireRetryLimit(t *testing.T) {
+
+	srv, count := failingServer(http.StatusServiceUnavailable, 5, "0")
+	defer srv.Close()
+
+	_, log, ok := runNquire(t, "-max-retries", "1", "-get", srv.URL+"/efetch.fcgi", "-id", "1")
+
+	if ok {
+		t.Errorf("succeeded after exhausting retries, log:\n%s", log)
+	}
+	if *count != 2 {
+		t.Errorf("server saw %d requests, want 2", *count)
+	}
+}
+
+func TestNquireHistoryPost(t *testing.T) {
+
+	// a server error may have created the history entry, so the POST is not repeated
+	srv, count := failingServer(http.StatusInternalServerError, 1, "0")
+	defer srv.Close()
+
+	_, log, ok := runNquire(t, "-url", srv.URL, "esearch.fcgi", "-db", "pubmed", "-term", "cancer", "-usehistory", "y")
+
+	if ok || *count != 1 {
+		t.Errorf("history POST after HTTP 500: success %v after %d requests, log:\n%s", ok, *count, log)
+	}
+
+	// too many requests means it was never processed, so it is safe to send again
+	srv429, count429 := failingServer(http.StatusTooManyRequests, 1, "0")
+	defer srv429.Close()
+
+	out, log, ok := runNquire(t, "-url", srv429.URL, "esearch.fcgi", "-db", "pubmed", "-term", "cancer", "-usehistory", "y")
+
+	if !ok || out != "<Result>ok</Result>\n" || *count429 != 2 {
+		t.Errorf("history POST after HTTP 429: got %q, success %v after %d requests, log:\n%s", out, ok, *count429, log)
+	}
+}
+
+func TestNquireBackoff(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("backoff waits at least one second")
+	}
+
+	// without Retry-After, the first wait is one second plus jitter
+	srv, count := failingServer(http.StatusBadGateway, 1, "")
+	defer srv.Close()
+
+	out, log, ok := runNquire(t, "-get", srv.URL+"/einfo.fcgi")
+
+	if !ok || out != "<Result>ok</Result>\n" || *count != 2 {
+		t.Fatalf("got %q, success %v after %d requests, log:\n%s", out, ok, *count, log)
+	}
+	if !strings.Contains(log, "nquire HTTP 502, retry 1 of 3 after 1.") {
+		t.Errorf("unexpected backoff:\n%s", log)
+	}
+}
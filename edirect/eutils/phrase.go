@@ -136,6 +136,195 @@ var (
 	meshTree alias
 )
 
+type taxonomy struct {
+	children map[string][]string
+	ranks    map[string]string
+	names    map[string][]string
+	lock     sync.Mutex
+	fpath    string
+	isLoaded bool
+}
+
+// taxTree holds parent-child relationships from the local taxonomy archive for [TAXTREE] queries
+var taxTree taxonomy
+
+// no built-in indexer records taxids, so the postings field is a custom -idxfields field named by -taxfield
+var (
+	taxField = ""
+	taxDepth = 0
+	taxRank  = ""
+)
+
+// SetTaxonomyExpansion sets the taxonomy archive, the postings field for taxids, and optional
+// depth or rank limits used when a [TAXTREE] query expands to descendant taxids
+func SetTaxonomyExpansion(master, field string, depth int, rank string) {
+
+	if master != "" {
+		taxTree.lock.Lock()
+		taxTree.fpath = filepath.Join(master, "Data", "taxnodes.xml")
+		taxTree.isLoaded = false
+		taxTree.lock.Unlock()
+	}
+	if field != "" {
+		taxField = strings.ToUpper(field)
+	}
+	taxDepth = depth
+	taxRank = strings.ToLower(rank)
+}
+
+// cleanTaxonName matches scientific names to query terms, which are lower case and broken at punctuation
+func cleanTaxonName(str string) string {
+
+	str = strings.ToLower(html.UnescapeString(str))
+	str = strings.Replace(str, "'", "", -1)
+	parts := strings.FieldsFunc(str, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+
+	return strings.Join(parts, " ")
+}
+
+// loadTaxonomyTree should be called within a lock on the taxonomy.lock mutex
+func (t *taxonomy) loadTaxonomyTree() {
+
+	if t == nil || t.fpath == "" {
+		return
+	}
+
+	if t.isLoaded {
+		return
+	}
+
+	// set even if loading failed to prevent multiple attempts
+	t.isLoaded = true
+
+	file, ferr := os.Open(t.fpath)
+	if ferr != nil {
+		return
+	}
+	defer file.Close()
+
+	rdr := CreateXMLStreamer(file)
+	if rdr == nil {
+		return
+	}
+
+	t.children = make(map[string][]string)
+	t.ranks = make(map[string]string)
+	t.names = make(map[string][]string)
+
+	PartitionXML("TaxNode", "", false, rdr,
+		func(str string) {
+
+			taxID := ""
+			parent := ""
+			rank := ""
+			name := ""
+
+			StreamValues(str, "TaxNode", func(tag, attr, content string) {
+				switch tag {
+				case "TaxID":
+					taxID = content
+				case "ParentID":
+					parent = content
+				case "Rank":
+					rank = content
+				case "Scientific":
+					name = content
+				}
+			})
+
+			if taxID == "" {
+				return
+			}
+
+			// root node is its own parent
+			if parent != "" && parent != taxID {
+				t.children[parent] = append(t.children[parent], taxID)
+			}
+			if rank != "" {
+				t.ranks[taxID] = strings.ToLower(rank)
+			}
+			if name != "" {
+				// homonyms, e.g., Morus the plant and Morus the bird, keep every taxid
+				key := cleanTaxonName(name)
+				t.names[key] = append(t.names[key], taxID)
+			}
+		})
+}
+
+// expandTaxon returns a taxid, given as a number or a scientific name, plus all of its descendants
+func expandTaxon(str string) []string {
+
+	taxTree.lock.Lock()
+	if !taxTree.isLoaded {
+		taxTree.loadTaxonomyTree()
+	}
+	taxTree.lock.Unlock()
+
+	if taxTree.children == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Taxonomy archive is needed for [TAXTREE], set EDIRECT_TAXONOMY_MASTER or use -taxonomy\n")
+		os.Exit(1)
+	}
+
+	roots := []string{str}
+	if !IsAllDigits(str) {
+		taxIDs, ok := taxTree.names[cleanTaxonName(str)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized taxonomy name '%s'\n", str)
+			os.Exit(1)
+		}
+		if len(taxIDs) > 1 {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Taxonomy name '%s' matches taxids %s, expanding all, query by taxid to select one\n", str, strings.Join(taxIDs, ", "))
+		}
+		roots = taxIDs
+	}
+
+	var res []string
+	seen := make(map[string]bool)
+
+	// breadth-first expansion stops at depth limit or below nodes of limiting rank
+	level := roots
+	for depth := 0; len(level) > 0 && (taxDepth < 1 || depth <= taxDepth); depth++ {
+		var next []string
+		for _, taxID := range level {
+			if seen[taxID] {
+				continue
+			}
+			seen[taxID] = true
+			res = append(res, taxID)
+			if taxRank != "" && taxTree.ranks[taxID] == taxRank {
+				continue
+			}
+			next = append(next, taxTree.children[taxID]...)
+		}
+		level = next
+	}
+
+	return res
+}
+
+// checkTaxonomyField reports a [TAXTREE] query against postings that lack the taxid field,
+// which would otherwise silently match nothing
+func checkTaxonomyField(base string, clauses []string) {
+
+	if taxField == "" {
+		return
+	}
+
+	fld := " [" + taxField + "]"
+	for _, str := range clauses {
+		if !strings.HasSuffix(str, fld) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(base, taxField)); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "\nERROR: Taxid field '%s' for [TAXTREE] not found in postings directory '%s'\n", taxField, base)
+			os.Exit(1)
+		}
+		return
+	}
+}
+
 func printTermCount(base, term, field string) int {
 
 	data, _ := getPostingIDs(base, term, field, true, false)
@@ -145,7 +334,8 @@ func printTermCount(base, term, field string) int {
 	return size
 }
 
-func printTermCounts(base, term, field string) int {
+// matchingTerms calls proc with each indexed term matching a trailing wildcard, and its document count
+func matchingTerms(base, term, field string, proc func(str string, size int)) int {
 
 	pdlen := len(PostingDir(term))
 
@@ -223,7 +413,7 @@ func printTermCounts(base, term, field string) int {
 		if re.MatchString(str) {
 			offset := indx[R].PostOffset
 			size := indx[R+1].PostOffset - offset
-			fmt.Fprintf(os.Stdout, "%d\t%s\n", size/4, str)
+			proc(str, int(size/4))
 			count++
 		}
 	}
@@ -231,6 +421,74 @@ func printTermCounts(base, term, field string) int {
 	return count
 }
 
+func printTermCounts(base, term, field string) int {
+
+	return matchingTerms(base, term, field,
+		func(str string, size int) {
+			fmt.Fprintf(os.Stdout, "%d\t%s\n", size, str)
+		})
+}
+
+// printPhraseCounts expands the wildcard in the final word of a phrase, evaluating one
+// expanded phrase at a time, and prints counts sorted by decreasing frequency
+func printPhraseCounts(base string, words []string, field string, limit int) int {
+
+	if len(words) < 2 {
+		return 0
+	}
+
+	last := len(words) - 1
+	fixed := strings.Join(words[:last], " ")
+
+	type TermCount struct {
+		Term  string
+		Count int
+	}
+
+	var expansions []TermCount
+
+	matchingTerms(base, words[last], field,
+		func(str string, size int) {
+			expansions = append(expansions, TermCount{str, size})
+		})
+
+	if limit > 0 && len(expansions) > limit {
+		// keep most frequent individual terms
+		sort.SliceStable(expansions, func(i, j int) bool { return expansions[i].Count > expansions[j].Count })
+		expansions = expansions[:limit]
+	}
+
+	var results []TermCount
+
+	for _, exp := range expansions {
+
+		phrase := fixed + " " + exp.Term
+		clauses := []string{phrase + " [" + field + "]"}
+
+		_, data := evaluateQuery(base, "", phrase, clauses, true, false)
+		if len(data) < 1 {
+			continue
+		}
+
+		results = append(results, TermCount{phrase, len(data)})
+
+		runtime.Gosched()
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Term < results[j].Term
+	})
+
+	for _, res := range results {
+		fmt.Fprintf(os.Stdout, "%d\t%s\n", res.Count, res.Term)
+	}
+
+	return len(results)
+}
+
 func printTermPositions(base, term, field string) int {
 
 	data, ofst := getPostingIDs(base, term, field, false, false)
@@ -259,6 +517,8 @@ func evaluateQuery(base, dbase, phrase string, clauses []string, noStdout, isLin
 		return 0, nil
 	}
 
+	checkTaxonomyField(base, clauses)
+
 	count := 0
 
 	// flag set if no tildes, indicates no proximity tests in query
@@ -669,6 +929,54 @@ func evaluateQuery(base, dbase, phrase string, clauses []string, noStdout, isLin
 
 // QUERY PARSING FUNCTIONS
 
+// proximity window must be less than the minimum padding between indexed paragraphs
+const maxProximityWindow = 19
+
+var proximityPhrase = regexp.MustCompile(`"([^"]*)"\s*~\s*(\d+)`)
+
+// expandProximity converts "breast cancer"~5 into tilde operators, with a two-word
+// phrase matching in either order, and ~0 becoming an exact adjacent phrase
+func expandProximity(str string) string {
+
+	if !strings.Contains(str, "\"") || !strings.Contains(str, "~") {
+		return str
+	}
+
+	return proximityPhrase.ReplaceAllStringFunc(str, func(match string) string {
+
+		parts := proximityPhrase.FindStringSubmatch(match)
+		if len(parts) < 3 {
+			return match
+		}
+
+		words := strings.Fields(parts[1])
+		if len(words) < 1 {
+			return ""
+		}
+
+		dist, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return match
+		}
+		if dist > maxProximityWindow {
+			fmt.Fprintf(os.Stderr, "\nERROR: Proximity window %d exceeds maximum of %d\n", dist, maxProximityWindow)
+			os.Exit(1)
+		}
+
+		if dist == 0 || len(words) == 1 {
+			return strings.Join(words, " ")
+		}
+
+		tilde := " " + strings.Repeat("~", dist) + " "
+
+		if len(words) == 2 {
+			return "( " + words[0] + tilde + words[1] + " | " + words[1] + tilde + words[0] + " )"
+		}
+
+		return strings.Join(words, tilde)
+	})
+}
+
 func prepareQuery(str string) string {
 
 	if str == "" {
@@ -681,6 +989,8 @@ func prepareQuery(str string) string {
 
 	str = html.UnescapeString(str)
 
+	str = expandProximity(str)
+
 	str = CleanupQuery(str, false, true)
 
 	str = strings.Replace(str, "~ ~", "~~", -1)
@@ -691,12 +1001,14 @@ func prepareQuery(str string) string {
 	// temporarily flank with spaces to detect misplaced operators at ends
 	str = " " + str + " "
 
+	// separate parentheses first so AND(mouse OR murine)NOT review is recognized
+	str = strings.Replace(str, "(", " ( ", -1)
+	str = strings.Replace(str, ")", " ) ", -1)
+
 	str = strings.Replace(str, " AND ", " & ", -1)
 	str = strings.Replace(str, " OR ", " | ", -1)
 	str = strings.Replace(str, " NOT ", " ! ", -1)
 
-	str = strings.Replace(str, "(", " ( ", -1)
-	str = strings.Replace(str, ")", " ) ", -1)
 	str = strings.Replace(str, "&", " & ", -1)
 	str = strings.Replace(str, "|", " | ", -1)
 	str = strings.Replace(str, "!", " ! ", -1)
@@ -1328,6 +1640,33 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 
 			// skip if MeSH term not yet indexed in tree
 			continue
+
+		} else if strings.HasSuffix(str, " [TAXTREE]") {
+
+			slen := len(str)
+			str = str[:slen-10]
+
+			if taxField == "" {
+				fmt.Fprintf(os.Stderr, "\nERROR: [TAXTREE] needs -taxfield to name the taxid postings field, e.g., one built with -idxfields\n")
+				os.Exit(1)
+			}
+
+			// expand taxid or name to itself and all descendants in OR group
+			fld := " [" + taxField + "]"
+			taxa := expandTaxon(str)
+			if len(taxa) == 1 {
+				res = append(res, taxa[0]+fld)
+				continue
+			}
+			pfx := "("
+			sfx := ")"
+			for _, tx := range taxa {
+				res = append(res, pfx)
+				pfx = "|"
+				res = append(res, tx+fld)
+			}
+			res = append(res, sfx)
+			continue
 		}
 
 		// remove leading and trailing plus signs and spaces
@@ -1480,7 +1819,7 @@ func ProcessMock(base, dbase, phrase string, xact, titl, rlxd, deStop bool) int
 }
 
 // ProcessCount prints document count for each term, also supports terminal wildcards
-func ProcessCount(base, dbase, phrase string, plrl, psns, rlxd, deStop bool) int {
+func ProcessCount(base, dbase, phrase string, plrl, psns, rlxd, deStop bool, limit int) int {
 
 	if phrase == "" {
 		return 0
@@ -1583,6 +1922,15 @@ func ProcessCount(base, dbase, phrase string, plrl, psns, rlxd, deStop bool) int
 			return
 		}
 
+		// multi-word phrase with truncated final word, e.g., "long noncoding*"
+		if plrl && !psns && len(words) > 1 && !strings.Contains(str, "+") {
+			last := len(words) - 1
+			if strings.HasSuffix(words[last], "*") && !strings.Contains(strings.Join(words[:last], " "), "*") {
+				count += printPhraseCounts(base, words, field, limit)
+				return
+			}
+		}
+
 		for _, term := range words {
 
 			term = strings.Replace(term, "_", " ", -1)
@@ -1952,7 +2300,7 @@ func ProcessLinks(base, fld string) {
 	runtime.Gosched()
 }
 
-// initialize empty journal and MeSH maps before non-init functions are called
+// initialize empty journal and MeSH maps, and taxonomy path, before non-init functions are called
 func init() {
 
 	meshName.table = make(map[string]string)
@@ -1966,4 +2314,9 @@ func init() {
 		meshName.fpath = filepath.Join(nv, "Data", "meshname.txt")
 		meshTree.fpath = filepath.Join(nv, "Data", "meshtree.txt")
 	}
+
+	tx := os.Getenv("EDIRECT_TAXONOMY_MASTER")
+	if tx != "" {
+		taxTree.fpath = filepath.Join(tx, "Data", "taxnodes.xml")
+	}
 }
@@ -0,0 +1,309 @@
+package eutils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// indexPubmed runs PubmedArticle records through -e2index, -e2invert, -merge, and -promote,
+// and returns the postings directory
+func indexPubmed(t *testing.T, dir, xml string) string {
+
+	t.Helper()
+
+	merged := mergeIndexed(t, dir, indexPubmedRecords(t, xml))
+
+	return promoteMerged(t, merged, filepath.Join(dir, "Postings"), "TIAB TITL")
+}
+
+// indexPubmedRecords returns the -e2index IdxDocumentSet for PubmedArticle records
+func indexPubmedRecords(t *testing.T, xml string) string {
+
+	t.Helper()
+
+	if NumServe() < 1 {
+		SetTunings(0, 0, 0, 0, 0, 0, 0, false)
+	}
+
+	// -set and -rec are handled by rchive, not by the extraction commands
+	args := MakeE2Commands("", "pubmed", true)
+	if len(args) < 4 || args[0] != "-set" || args[2] != "-rec" {
+		t.Fatalf("unexpected -e2index arguments %v", args)
+	}
+	cmds := ParseArguments(args[4:], "PubmedArticle")
+
+	xmlq := CreateXMLProducer("PubmedArticle", "", false, CreateXMLStreamer(strings.NewReader(xml)))
+	tblq := CreateXMLConsumers(cmds, "PubmedArticle", "<IdxDocument>", "</IdxDocument>", nil, false, nil, xmlq)
+
+	var idx strings.Builder
+	idx.WriteString("<IdxDocumentSet>\n")
+	for ext := range CreateXMLUnshuffler(tblq) {
+		idx.WriteString(ext.Text)
+	}
+	idx.WriteString("</IdxDocumentSet>\n")
+
+	return idx.String()
+}
+
+// mergeIndexed inverts an IdxDocumentSet, merges it into dir/Merged, and returns that directory
+func mergeIndexed(t *testing.T, dir, idx string) string {
+
+	t.Helper()
+
+	var inv strings.Builder
+	inv.WriteString("<InvDocumentSet>\n")
+	for str := range InvertIndexedFile(StringToChan(idx)) {
+		inv.WriteString(str)
+	}
+	inv.WriteString("</InvDocumentSet>\n")
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(dir, "index.inv")
+	if err := os.WriteFile(fname, []byte(inv.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(dir, "Merged")
+	if err := os.MkdirAll(merged, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for range CreateSplitter(merged, false, false, CreateXMLUnshuffler(CreateMergers(CreateManifold(CreatePresenters([]string{fname}))))) {
+	}
+
+	return merged
+}
+
+// promoteMerged runs -promote on every .mrg file in merged, and returns the postings directory
+func promoteMerged(t *testing.T, merged, base, fields string) string {
+
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(merged, "*.mrg"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no merged files in %s", merged)
+	}
+
+	for range CreatePromoters(base, fields, false, true, files) {
+	}
+
+	return base
+}
+
+// taxonomyFixture writes a small taxonomy archive and a TXID postings index, returning their paths
+func taxonomyFixture(t *testing.T) (string, string) {
+
+	t.Helper()
+
+	dir := t.TempDir()
+
+	// Hominidae with two genera, and the plant and bird genera that share the name Morus
+	nodes := [][]string{
+		{"9604", "314295", "family", "Hominidae"},
+		{"9605", "9604", "genus", "Homo"},
+		{"9606", "9605", "species", "Homo sapiens"},
+		{"63221", "9606", "subspecies", "Homo sapiens neanderthalensis"},
+		{"741158", "9606", "subspecies", "Homo sapiens subsp. 'Denisova'"},
+		{"9596", "9604", "genus", "Pan"},
+		{"9598", "9596", "species", "Pan troglodytes"},
+		{"10090", "10088", "species", "Mus musculus"},
+		{"3497", "3487", "genus", "Morus"},
+		{"3498", "3497", "species", "Morus alba"},
+		{"37577", "30446", "genus", "Morus"},
+		{"37578", "37577", "species", "Morus bassanus"},
+	}
+
+	var buf strings.Builder
+	buf.WriteString("<TaxNodeSet>\n")
+	for _, nd := range nodes {
+		buf.WriteString("<TaxNode>\n  <TaxID>" + nd[0] + "</TaxID>\n  <Rank>" + nd[2] + "</Rank>\n")
+		buf.WriteString("  <Scientific>" + nd[3] + "</Scientific>\n  <ParentID>" + nd[1] + "</ParentID>\n</TaxNode>\n")
+	}
+	buf.WriteString("</TaxNodeSet>\n")
+
+	master := filepath.Join(dir, "Taxonomy")
+	if err := os.MkdirAll(filepath.Join(master, "Data"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(master, "Data", "taxnodes.xml"), []byte(buf.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// merged inverted index of records tagged with one taxid each, in term order
+	postings := [][]string{
+		{"10090", "5"},
+		{"3498", "7"},
+		{"37578", "8"},
+		{"63221", "2"},
+		{"741158", "3"},
+		{"9598", "4"},
+		{"9605", "6"},
+		{"9606", "1"},
+	}
+
+	buf.Reset()
+	buf.WriteString("<InvDocumentSet>\n")
+	for _, pst := range postings {
+		buf.WriteString("<InvDocument>\n  <InvKey>" + pst[0] + "</InvKey>\n  <InvIDs>\n    <TXID>" + pst[1] + "</TXID>\n  </InvIDs>\n</InvDocument>\n")
+	}
+	buf.WriteString("</InvDocumentSet>\n")
+
+	mrg := filepath.Join(dir, "txid.mrg")
+	if err := os.WriteFile(mrg, []byte(buf.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Join(dir, "Postings")
+	for range CreatePromoters(base, "TXID", false, true, []string{mrg}) {
+	}
+
+	return master, base
+}
+
+// resetTaxonomy clears [TAXTREE] settings and the loaded tree
+func resetTaxonomy() {
+
+	taxTree.lock.Lock()
+	taxTree.children = nil
+	taxTree.ranks = nil
+	taxTree.names = nil
+	taxTree.fpath = ""
+	taxTree.isLoaded = false
+	taxTree.lock.Unlock()
+
+	taxField = ""
+	taxDepth = 0
+	taxRank = ""
+}
+
+func TestTaxonomyExpansion(t *testing.T) {
+
+	master, base := taxonomyFixture(t)
+
+	defer resetTaxonomy()
+
+	tests := []struct {
+		query string
+		depth int
+		rank  string
+		want  []int32
+	}{
+		{"9606 [TAXTREE]", 0, "", []int32{1, 2, 3}},
+		{"Homo sapiens [TAXTREE]", 0, "", []int32{1, 2, 3}},
+		{"HOMO [TAXTREE]", 0, "", []int32{1, 2, 3, 6}},
+		{"9606 [TXID]", 0, "", []int32{1}},
+		{"Hominidae [TAXTREE]", 1, "", []int32{6}},
+		{"Hominidae [TAXTREE]", 0, "species", []int32{1, 4, 6}},
+		{"9606 [TAXTREE] NOT 63221 [TXID]", 0, "", []int32{1, 3}},
+	}
+
+	for _, tt := range tests {
+		SetTaxonomyExpansion(master, "txid", tt.depth, tt.rank)
+		got := ProcessQuery(base, "pubmed", tt.query, false, false, false, false, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q depth %d rank %q: got %v, want %v", tt.query, tt.depth, tt.rank, got, tt.want)
+		}
+	}
+}
+
+func TestTaxonomyHomonyms(t *testing.T) {
+
+	master, base := taxonomyFixture(t)
+
+	defer resetTaxonomy()
+
+	SetTaxonomyExpansion(master, "TXID", 0, "")
+
+	var got []int32
+	errs := captureStderr(t, func() {
+		got = ProcessQuery(base, "pubmed", "Morus [TAXTREE]", false, false, false, false, false)
+	})
+
+	// both genera are expanded, rather than whichever name was read last
+	if !reflect.DeepEqual(got, []int32{7, 8}) {
+		t.Errorf("got %v, want [7 8]", got)
+	}
+	if !strings.Contains(errs, "matches taxids 3497, 37577") {
+		t.Errorf("missing homonym warning, got %q", errs)
+	}
+
+	// a taxid selects one of them
+	if got := ProcessQuery(base, "pubmed", "37577 [TAXTREE]", false, false, false, false, false); !reflect.DeepEqual(got, []int32{8}) {
+		t.Errorf("got %v, want [8]", got)
+	}
+}
+
+func TestProximitySearch(t *testing.T) {
+
+	// title words are numbered from 1, and each abstract paragraph starts 100 positions after the last
+	xml := `<PubmedArticleSet>
+<PubmedArticle><MedlineCitation><PMID>1</PMID><Article>
+<ArticleTitle>Breast cancer screening outcomes.</ArticleTitle>
+</Article></MedlineCitation></PubmedArticle>
+<PubmedArticle><MedlineCitation><PMID>2</PMID><Article>
+<ArticleTitle>Breast tissue density predicts later cancer.</ArticleTitle>
+</Article></MedlineCitation></PubmedArticle>
+<PubmedArticle><MedlineCitation><PMID>3</PMID><Article>
+<ArticleTitle>Imaging trial results.</ArticleTitle>
+<Abstract>
+<AbstractText>Screening was offered to every woman with dense breast.</AbstractText>
+<AbstractText>Cancer incidence was recorded for ten years.</AbstractText>
+</Abstract>
+</Article></MedlineCitation></PubmedArticle>
+<PubmedArticle><MedlineCitation><PMID>4</PMID><Article>
+<ArticleTitle>Cancer, breast and lung.</ArticleTitle>
+</Article></MedlineCitation></PubmedArticle>
+</PubmedArticleSet>
+`
+
+	base := indexPubmed(t, t.TempDir(), xml)
+
+	tests := []struct {
+		query string
+		want  []int32
+	}{
+		// both words anywhere in the record
+		{"breast AND cancer", []int32{1, 2, 3, 4}},
+		// exact adjacent phrase, with or without ~0
+		{"breast cancer", []int32{1}},
+		{`"breast cancer"~0`, []int32{1}},
+		// two-word window matches in either order
+		{`"breast cancer"~1`, []int32{1, 4}},
+		// ~N allows up to N intervening words
+		{`"breast cancer"~3`, []int32{1, 4}},
+		{`"breast cancer"~4`, []int32{1, 2, 4}},
+		// largest window still cannot span the padding between abstract paragraphs
+		{`"breast cancer"~19`, []int32{1, 2, 4}},
+		{`"dense breast"~0 AND cancer`, []int32{3}},
+	}
+
+	for _, tt := range tests {
+		got := ProcessQuery(base, "pubmed", tt.query, false, false, false, false, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeIDs(t *testing.T) {
+
+	tests := []struct {
+		n, m, want []int32
+	}{
+		{[]int32{1, 3}, []int32{2}, []int32{1, 3}},
+		{[]int32{1, 2, 3, 4}, []int32{2}, []int32{1, 3, 4}},
+		{[]int32{1, 2, 3}, []int32{1, 2, 3}, []int32{}},
+		{[]int32{5, 6}, []int32{1, 2}, []int32{5, 6}},
+		{[]int32{1, 2}, nil, []int32{1, 2}},
+	}
+
+	for _, tt := range tests {
+		if got := excludeIDs(tt.n, tt.m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v NOT %v: got %v, want %v", tt.n, tt.m, got, tt.want)
+		}
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"fmt"
 	"github.com/klauspost/pgzip"
 	"github.com/surgebase/porter2"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -74,7 +75,11 @@ import (
 // for calculating TF-IDF term weights, which can support ranked retrieval,
 // is the total number of live PubMed documents, which could easily be saved
 // during indexing.
-func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan string {
+//
+// Completed term prefixes are recorded, with file sizes and crc32 checksums,
+// in a promote.manifest file in each field directory. Prefixes already listed
+// there are skipped on a later run unless force is set.
+func CreatePromoters(prom, fields string, isLink, force bool, files []string) <-chan string {
 
 	if files == nil {
 		return nil
@@ -88,6 +93,66 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 
 	flds := strings.Split(fields, " ")
 
+	// completed prefixes from earlier runs, and open manifest for each field
+	done := make(map[string]map[string]bool)
+	mnfs := make(map[string]*os.File)
+	var mlock sync.Mutex
+
+	for _, fld := range flds {
+
+		dpath := filepath.Join(prom, fld)
+		err := os.MkdirAll(dpath, os.ModePerm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create directory '%s'\n", dpath)
+			os.Exit(1)
+		}
+
+		mpath := filepath.Join(dpath, "promote.manifest")
+
+		if force {
+			os.Remove(mpath)
+		} else if pm := readPromoteManifest(mpath); pm != nil {
+			done[fld] = completedPrefixes(dpath, pm)
+			// drop anything after the last completed prefix before appending
+			os.Truncate(mpath, pm.valid)
+		}
+
+		fl, err := os.OpenFile(mpath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open manifest file '%s'\n", mpath)
+			os.Exit(1)
+		}
+		mnfs[fld] = fl
+	}
+
+	// recordPrefix appends file entries and a completion line for one prefix
+	recordPrefix := func(field, key string, entries []string) {
+
+		var buffer strings.Builder
+
+		for _, ent := range entries {
+			buffer.WriteString(key)
+			buffer.WriteString("\t")
+			buffer.WriteString(ent)
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(key)
+		buffer.WriteString("\tCOMPLETE\t")
+		buffer.WriteString(strconv.Itoa(len(entries)))
+		buffer.WriteString("\n")
+
+		mlock.Lock()
+		defer mlock.Unlock()
+
+		fl := mnfs[field]
+		_, err := fl.WriteString(buffer.String())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return
+		}
+		fl.Sync()
+	}
+
 	// xmlPromoter saves records in a single set of term/posting files
 	xmlPromoter := func(wg *sync.WaitGroup, fileName string, out chan<- string) {
 
@@ -265,18 +330,21 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 			binary.Write(&uqidList, binary.LittleEndian, ofstPos)
 		}
 
-		writeFile := func(dpath, fname string, bfr bytes.Buffer) {
+		// writeFile returns a manifest entry with relative path, size, and checksum
+		writeFile := func(field, dpath, fname string, bfr bytes.Buffer) (string, bool) {
 
 			fpath := filepath.Join(dpath, fname)
 			if fpath == "" {
-				return
+				return "", false
 			}
 
-			// overwrites and truncates existing file
-			fl, err := os.Create(fpath)
+			// write to temporary file, then rename, so partial files never appear complete
+			tpath := fpath + ".tmp"
+
+			fl, err := os.Create(tpath)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-				return
+				return "", false
 			}
 
 			data := bfr.Bytes()
@@ -284,48 +352,83 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 			wrtr := bufio.NewWriter(fl)
 
 			_, err = wrtr.Write(data)
+			if err == nil {
+				err = wrtr.Flush()
+			}
+			if err == nil {
+				err = fl.Sync()
+			}
+			fl.Close()
+
+			if err == nil {
+				err = os.Rename(tpath, fpath)
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				os.Remove(tpath)
+				return "", false
 			}
 
-			wrtr.Flush()
+			rel, err := filepath.Rel(filepath.Join(prom, field), fpath)
+			if err != nil {
+				rel = fpath
+			}
 
-			// fl.Sync()
+			ent := rel + "\t" + strconv.Itoa(len(data)) + "\t" + strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10)
 
-			fl.Close()
+			return ent, true
 		}
 
-		writeFiveFiles := func(field, key string) {
+		writeFiveFiles := func(field, key string) ([]string, bool) {
 
 			dpath, ky := PostingPath(prom, field, key, isLink)
 			if dpath == "" {
-				return
+				return nil, false
 			}
 
 			// make subdirectories, if necessary
 			err := os.MkdirAll(dpath, os.ModePerm)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-				return
+				return nil, false
+			}
+
+			var entries []string
+			ok := true
+
+			saveFile := func(sfx string, bfr bytes.Buffer) {
+				ent, good := writeFile(field, dpath, ky+"."+field+sfx, bfr)
+				if !good {
+					ok = false
+					return
+				}
+				entries = append(entries, ent)
 			}
 
-			writeFile(dpath, ky+"."+field+".trm", termList)
+			saveFile(".trm", termList)
 
-			writeFile(dpath, ky+"."+field+".pst", postList)
+			saveFile(".pst", postList)
 
-			writeFile(dpath, ky+"."+field+".mst", indxList)
+			saveFile(".mst", indxList)
 
 			// do not write position index and offset data files
 			// for fields with no position attributes recorded
 			if uqidList.Len() > 0 && ofstList.Len() > 0 {
 
-				writeFile(dpath, ky+"."+field+".uqi", uqidList)
+				saveFile(".uqi", uqidList)
 
-				writeFile(dpath, ky+"."+field+".ofs", ofstList)
+				saveFile(".ofs", ofstList)
 			}
+
+			return entries, ok
 		}
 
-		processOneField := func(field string, recs []string) {
+		processOneField := func(field, key string, recs []string) {
+
+			// skip prefix completed in an earlier run
+			if done[field][key] {
+				return
+			}
 
 			tag := ""
 
@@ -352,10 +455,18 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 				addOnePosting(term, data, atts)
 			}
 
+			var entries []string
+			ok := true
+
 			if tag != "" {
 
 				topOffMaster()
-				writeFiveFiles(field, tag)
+				entries, ok = writeFiveFiles(field, tag)
+			}
+
+			// only record prefix if all of its files were written
+			if ok {
+				recordPrefix(field, key, entries)
 			}
 
 			// reset buffers and position counters
@@ -404,7 +515,7 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 					// records with same identifier key as a unit
 					if prevTag != "" {
 						for _, fld := range flds {
-							processOneField(fld, arry)
+							processOneField(fld, prevTag, arry)
 						}
 						out <- prevTag
 					}
@@ -423,7 +534,7 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 
 			// remaining records with last identifier key
 			for _, fld := range flds {
-				processOneField(fld, arry)
+				processOneField(fld, prevTag, arry)
 			}
 			out <- prevTag
 		}
@@ -440,12 +551,191 @@ func CreatePromoters(prom, fields string, isLink bool, files []string) <-chan st
 	// launch separate anonymous goroutine to wait until all promoters are done
 	go func() {
 		wg.Wait()
+		for _, fl := range mnfs {
+			fl.Close()
+		}
 		close(out)
 	}()
 
 	return out
 }
 
+// promoteEntry is one postings file recorded in a promote.manifest file
+type promoteEntry struct {
+	key  string
+	path string
+	size string
+	hash string
+}
+
+// promoteManifest holds the latest completed file entries for each prefix,
+// plus entries from an interrupted write that never reached a completion line
+type promoteManifest struct {
+	files   map[string][]promoteEntry
+	pending map[string][]promoteEntry
+	valid   int64
+}
+
+// readPromoteManifest loads a promote.manifest file, returning nil if it does not exist
+func readPromoteManifest(mpath string) *promoteManifest {
+
+	fl, err := os.Open(mpath)
+	if err != nil {
+		return nil
+	}
+	defer fl.Close()
+
+	pm := &promoteManifest{files: make(map[string][]promoteEntry), pending: make(map[string][]promoteEntry)}
+
+	// lines are prefix, relative path, size, and crc32, followed by a prefix COMPLETE count line
+	var length int64
+
+	rdr := bufio.NewReader(fl)
+	for {
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			// ignore final line cut off by an interrupted write
+			break
+		}
+		length += int64(len(line))
+		cols := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		if len(cols) == 3 && cols[1] == "COMPLETE" {
+			key := cols[0]
+			num, err := strconv.Atoi(cols[2])
+			if err == nil && num == len(pm.pending[key]) {
+				// a later run replaces earlier entries for the same prefix
+				pm.files[key] = pm.pending[key]
+			}
+			delete(pm.pending, key)
+			pm.valid = length
+			continue
+		}
+		if len(cols) != 4 {
+			continue
+		}
+		key := cols[0]
+		pm.pending[key] = append(pm.pending[key], promoteEntry{key: key, path: cols[1], size: cols[2], hash: cols[3]})
+	}
+
+	return pm
+}
+
+// completedPrefixes returns prefixes whose recorded files are all present with the expected size
+func completedPrefixes(dpath string, pm *promoteManifest) map[string]bool {
+
+	done := make(map[string]bool)
+
+	if pm == nil {
+		return done
+	}
+
+	for key, ents := range pm.files {
+		ok := true
+		for _, ent := range ents {
+			info, err := os.Stat(filepath.Join(dpath, ent.path))
+			if err != nil || strconv.FormatInt(info.Size(), 10) != ent.size {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			done[key] = true
+		}
+	}
+
+	return done
+}
+
+// ValidatePromotion compares each field's promote.manifest against its postings directory,
+// returning a line for each missing, mismatched, incomplete, unlisted, or leftover temporary file
+func ValidatePromotion(prom, fields string) []string {
+
+	var problems []string
+
+	isPostings := func(name string) bool {
+		switch filepath.Ext(name) {
+		case ".trm", ".pst", ".mst", ".uqi", ".ofs":
+			return true
+		}
+		return false
+	}
+
+	for _, fld := range strings.Split(fields, " ") {
+
+		if fld == "" {
+			continue
+		}
+
+		dpath := filepath.Join(prom, fld)
+
+		pm := readPromoteManifest(filepath.Join(dpath, "promote.manifest"))
+		if pm == nil {
+			problems = append(problems, "MISSING\t"+fld+"\tpromote.manifest")
+			continue
+		}
+
+		var keys []string
+		for key := range pm.files {
+			keys = append(keys, key)
+		}
+		for key := range pm.pending {
+			if _, ok := pm.files[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		listed := make(map[string]bool)
+
+		for _, key := range keys {
+
+			ents, ok := pm.files[key]
+			if !ok {
+				problems = append(problems, "INCOMPLETE\t"+fld+"\t"+key)
+				continue
+			}
+
+			for _, ent := range ents {
+
+				listed[ent.path] = true
+
+				data, err := os.ReadFile(filepath.Join(dpath, ent.path))
+				if err != nil {
+					problems = append(problems, "MISSING\t"+fld+"\t"+ent.path)
+					continue
+				}
+
+				if strconv.Itoa(len(data)) != ent.size || strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10) != ent.hash {
+					problems = append(problems, "MISMATCH\t"+fld+"\t"+ent.path)
+				}
+			}
+		}
+
+		filepath.Walk(dpath, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil || info.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dpath, path)
+			if err != nil {
+				return nil
+			}
+
+			name := info.Name()
+			if strings.HasSuffix(name, ".tmp") && isPostings(strings.TrimSuffix(name, ".tmp")) {
+				problems = append(problems, "PARTIAL\t"+fld+"\t"+rel)
+			} else if isPostings(name) && !listed[rel] {
+				problems = append(problems, "UNLISTED\t"+fld+"\t"+rel)
+			}
+
+			return nil
+		})
+	}
+
+	return problems
+}
+
 // POSTINGS FILE LOW-LEVEL USAGE FUNCTIONS
 
 // Master points to a term and to its postings data
@@ -1237,6 +1527,13 @@ func excludeIDs(N, M []int32) []int32 {
 		}
 	}
 
+	// keep items remaining after exclusion list is exhausted
+	for i < n && j == m {
+		res[k] = N[i]
+		k++
+		i++
+	}
+
 	// truncate output array to actual size of result
 	res = res[:k]
 
@@ -448,14 +448,15 @@ func fsmSearcher(patterns []string, caseSensitive, wholeWord, relaxed, compress,
 
 		alias := ""
 
-		if !caseSensitive {
-			txt = strings.ToLower(txt)
-		}
-
+		// split before changing case so sequence aliases keep their capitalization
 		if isSequence {
 			txt, alias = SplitInTwoLeft(txt, ":")
 		}
 
+		if !caseSensitive {
+			txt = strings.ToLower(txt)
+		}
+
 		if relaxed {
 			txt = RelaxString(txt)
 		} else if compress {
@@ -565,62 +566,62 @@ func PatternSearcher(patterns []string, caseSensitive, wholeWord, relaxed, compr
 	return fsmSearcher(patterns, caseSensitive, wholeWord, relaxed, compress, circular, false, false)
 }
 
-// SequenceSearcher primes tables for searching on one or more nucleotide or protein sequences
-func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bool) *FSMSearcher {
+// ExpandNucleotidePattern instantiates every sequence matched by nucleotide ambiguity characters
+func ExpandNucleotidePattern(pat string) ([]string, bool) {
 
-	if patterns == nil {
-		return nil
+	if pat == "" {
+		return nil, false
 	}
 
-	if isProtein {
-		topStrandOnly = true
-	}
+	var expanded []string
 
-	// expands nucleotide ambiguity characters into individual instantiated patterns
-	expandPattern := func(pat string) ([]string, bool) {
+	overflowed := false
 
-		if pat == "" {
-			return nil, false
-		}
+	// recursive function definition
+	var expandNext func(prev, next string, level int)
 
-		var expanded []string
+	expandNext = func(prev, next string, level int) {
 
-		overflowed := false
+		// limits on recursion depth and number of expanded patterns
+		if overflowed {
+			return
+		} else if level > 256 {
+			overflowed = true
+		} else if len(expanded) > 256 {
+			overflowed = true
+		} else if next != "" {
+			// take next character
+			curr := next[:1]
+			next = next[1:]
+			// get set of bases if ambiguous
+			exp, ok := expandNuc[curr]
+			if !ok {
+				exp = curr
+			}
+			// recursively expand for each base at this position
+			for _, ch := range exp {
+				expandNext(prev+string(ch), next, level+1)
+			}
+		} else {
+			// at end of string, record one unambiguous pattern
+			expanded = append(expanded, prev)
+		}
+	}
 
-		// recursive function definition
-		var expandNext func(prev, next string, level int)
+	expandNext("", pat, 1)
 
-		expandNext = func(prev, next string, level int) {
+	return expanded, overflowed
+}
 
-			// limits on recursion depth and number of expanded patterns
-			if overflowed {
-				return
-			} else if level > 256 {
-				overflowed = true
-			} else if len(expanded) > 256 {
-				overflowed = true
-			} else if next != "" {
-				// take next character
-				curr := next[:1]
-				next = next[1:]
-				// get set of bases if ambiguous
-				exp, ok := expandNuc[curr]
-				if !ok {
-					exp = curr
-				}
-				// recursively expand for each base at this position
-				for _, ch := range exp {
-					expandNext(prev+string(ch), next, level+1)
-				}
-			} else {
-				// at end of string, record one unambiguous pattern
-				expanded = append(expanded, prev)
-			}
-		}
+// SequenceSearcher primes tables for searching on one or more nucleotide or protein sequences
+func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bool) *FSMSearcher {
 
-		expandNext("", pat, 1)
+	if patterns == nil {
+		return nil
+	}
 
-		return expanded, overflowed
+	if isProtein {
+		topStrandOnly = true
 	}
 
 	var arry []string
@@ -645,7 +646,7 @@ func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bo
 		}
 
 		// expand nucleotide ambiguity characters in pattern to instantiate all matching sequences
-		expanded, overflowed := expandPattern(txt)
+		expanded, overflowed := ExpandNucleotidePattern(txt)
 
 		if overflowed {
 			fmt.Fprintf(os.Stderr, "ERROR: Ignoring pattern expansion of '%s' due to overflow\n", pat)
@@ -670,7 +671,7 @@ func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bo
 		}
 	}
 
-	return fsmSearcher(arry, false, false, false, true, true, isCircular, !topStrandOnly)
+	return fsmSearcher(arry, false, false, false, true, isCircular, true, !topStrandOnly)
 }
 
 // Search uses precomputed Searcher tables to search a string or sequence
@@ -697,7 +698,7 @@ func (srch *FSMSearcher) Search(text string, proc func(string, string, int) bool
 	// original length of text before any duplication to simulate circularity
 	cutoff := len(text)
 
-	if cutoff < srch.maxpatlen {
+	if srch.circular && cutoff < srch.maxpatlen {
 		// fmt.Fprintf(os.Stderr, "ERROR: Search text is shorter than pattern\n")
 		return
 	}
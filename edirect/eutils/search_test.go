@@ -0,0 +1,38 @@
+package eutils
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSequenceSearchStrandsAndAmbiguity(t *testing.T) {
+
+	// primer with two N positions occurs once on each strand, and once across the origin
+	plasmid := "ATGGTTTTTTACGCATGGTTTTTTCCAGGCGTTTTTTTACGA"
+
+	tests := []struct {
+		circular bool
+		top      bool
+		want     []string
+	}{
+		{false, false, []string{"10 Primer", "24 (Primer)"}},
+		{false, true, []string{"10 Primer"}},
+		{true, false, []string{"10 Primer", "24 (Primer)", "38 Primer"}},
+		{true, true, []string{"10 Primer", "38 Primer"}},
+	}
+
+	for _, tt := range tests {
+		srch := SequenceSearcher([]string{"ACGNNTGG:Primer"}, false, tt.circular, tt.top)
+
+		var got []string
+		srch.Search(plasmid, func(str, pat string, pos int) bool {
+			got = append(got, strconv.Itoa(pos)+" "+pat)
+			return true
+		})
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("circular %v, top %v: got %q, want %q", tt.circular, tt.top, got, tt.want)
+		}
+	}
+}
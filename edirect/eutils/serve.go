@@ -0,0 +1,197 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  serve.go
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ServeArchive answers GET /fetch?db=pubmed&id=PMID and POST /fetch (newline-separated
+// UIDs) from a local trie-based archive, returning the same bytes as rchive -fetch
+func ServeArchive(addr, ftch, db string, zipp bool, maxc int) {
+
+	if addr == "" || ftch == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: Server address and fetch path are required\n")
+		os.Exit(1)
+	}
+
+	if db == "" {
+		db = "pubmed"
+	}
+
+	pfx := ""
+	if db == "pmc" {
+		pfx = "PMC"
+	}
+
+	if maxc < 1 {
+		maxc = NumServe()
+	}
+
+	// buffered channel limits the number of requests handled at once
+	slots := make(chan struct{}, maxc)
+
+	// fetchRecords writes archived records for a list of identifiers, returning found and missing counts
+	fetchRecords := func(w io.Writer, in io.Reader) (int, int) {
+
+		uidq := CreateUIDReader(in)
+		strq := CreateFetchers(ftch, db, pfx, ".xml", zipp, uidq)
+		unsq := CreateXMLUnshuffler(strq)
+
+		if uidq == nil || strq == nil || unsq == nil {
+			return 0, 0
+		}
+
+		wrtr := bufio.NewWriter(w)
+		defer wrtr.Flush()
+
+		found := 0
+		missing := 0
+
+		for curr := range unsq {
+
+			str := curr.Text
+
+			if str == "" {
+				if curr.Ident != "" {
+					missing++
+				}
+				continue
+			}
+
+			// same newline handling as rchive -fetch
+			wrtr.WriteString(str)
+			if !strings.HasSuffix(str, "\n") {
+				wrtr.WriteString("\n")
+			}
+			found++
+		}
+
+		return found, missing
+	}
+
+	handleFetch := func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		status := http.StatusOK
+		found := 0
+		missing := 0
+
+		defer func() {
+			fmt.Fprintf(os.Stderr, "%s %s %s %d %d found %d missing %s\n",
+				r.RemoteAddr, r.Method, r.URL.RequestURI(), status, found, missing, time.Since(start).Round(time.Millisecond))
+		}()
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-r.Context().Done():
+			status = http.StatusServiceUnavailable
+			return
+		}
+
+		// only the database given to -db is served
+		if qdb := strings.ToLower(r.URL.Query().Get("db")); qdb != "" && qdb != db {
+			status = http.StatusBadRequest
+			http.Error(w, "database '"+qdb+"' is not served, use db="+db, status)
+			return
+		}
+
+		var in io.Reader
+
+		switch r.Method {
+		case http.MethodGet:
+			id := r.URL.Query().Get("id")
+			if id == "" {
+				status = http.StatusBadRequest
+				http.Error(w, "missing id parameter", status)
+				return
+			}
+			// comma-separated identifiers are also accepted
+			in = strings.NewReader(strings.ReplaceAll(id, ",", "\n"))
+		case http.MethodPost:
+			in = http.MaxBytesReader(w, r.Body, 64<<20)
+		default:
+			status = http.StatusMethodNotAllowed
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "use GET or POST", status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		found, missing = fetchRecords(w, in)
+
+		// nothing has been written yet if no identifier was found
+		if r.Method == http.MethodGet && found == 0 && missing > 0 {
+			status = http.StatusNotFound
+			w.WriteHeader(status)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fetch", handleFetch)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	// shut down gracefully on interrupt or termination, letting requests in progress finish
+	done := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		fmt.Fprintf(os.Stderr, "Shutting down archive server\n")
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		close(done)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving %s archive at %s on %s\n", db, ftch, addr)
+
+	err := srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	<-done
+}
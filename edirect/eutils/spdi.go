@@ -277,6 +277,173 @@ func SequenceExtract(seq, featLoc string, isOneBased bool) string {
 	return buffer.String()
 }
 
+// ParseGenBankLocation converts a location such as "complement(join(<100..200,300..450))" into
+// intervals for SequenceExtract, with minus strand intervals reversed, and reports 5' and 3' completeness
+func ParseGenBankLocation(loc string) (string, bool, bool) {
+
+	loc = strings.Replace(loc, " ", "", -1)
+
+	// split on commas that are not within nested parentheses
+	splitItems := func(str string) []string {
+
+		var items []string
+
+		depth := 0
+		last := 0
+		for i, ch := range str {
+			switch ch {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					items = append(items, str[last:i])
+					last = i + 1
+				}
+			}
+		}
+		items = append(items, str[last:])
+
+		return items
+	}
+
+	var parseloc func(str string) []string
+
+	parseloc = func(str string) []string {
+
+		var acc []string
+
+		if strings.HasPrefix(str, "join(") && strings.HasSuffix(str, ")") {
+
+			str = strings.TrimPrefix(str, "join(")
+			str = strings.TrimSuffix(str, ")")
+			for _, item := range splitItems(str) {
+				acc = append(acc, parseloc(item)...)
+			}
+
+		} else if strings.HasPrefix(str, "order(") && strings.HasSuffix(str, ")") {
+
+			str = strings.TrimPrefix(str, "order(")
+			str = strings.TrimSuffix(str, ")")
+			for _, item := range splitItems(str) {
+				acc = append(acc, parseloc(item)...)
+			}
+
+		} else if strings.HasPrefix(str, "complement(") && strings.HasSuffix(str, ")") {
+
+			str = strings.TrimPrefix(str, "complement(")
+			str = strings.TrimSuffix(str, ")")
+			items := parseloc(str)
+
+			// reverse order of intervals, then swap ends and flip partial markers
+			for i := len(items) - 1; i >= 0; i-- {
+				fst, scd := SplitInTwoLeft(items[i], "..")
+				lf := ""
+				rt := ""
+				if strings.HasPrefix(fst, "<") {
+					fst = strings.TrimPrefix(fst, "<")
+					rt = ">"
+				}
+				if strings.HasPrefix(scd, ">") {
+					scd = strings.TrimPrefix(scd, ">")
+					lf = "<"
+				}
+				acc = append(acc, lf+scd+".."+rt+fst)
+			}
+
+		} else if strings.Index(str, ":") >= 0 {
+
+			fmt.Fprintf(os.Stderr, "\nERROR: Location interval '%s' refers to another sequence\n", str)
+			os.Exit(1)
+
+		} else if str != "" {
+
+			// single point is treated as a one-base interval
+			if strings.Index(str, "..") < 0 {
+				str = str + ".." + str
+			}
+			acc = append(acc, str)
+		}
+
+		return acc
+	}
+
+	items := parseloc(loc)
+	if len(items) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse location '%s'\n", loc)
+		os.Exit(1)
+	}
+
+	is5primeComplete := !strings.HasPrefix(items[0], "<")
+	_, lst := SplitInTwoLeft(items[len(items)-1], "..")
+	is3primeComplete := !strings.HasPrefix(lst, ">")
+
+	for i, item := range items {
+		item = strings.Replace(item, "<", "", -1)
+		item = strings.Replace(item, ">", "", -1)
+		items[i] = item
+	}
+
+	return strings.Join(items, ","), is5primeComplete, is3primeComplete
+}
+
+// gcPercent returns the percentage of G and C among unambiguous bases, with two decimal places
+func gcPercent(seq string) string {
+
+	gc := 0
+	at := 0
+
+	for _, ch := range seq {
+		switch ch {
+		case 'G', 'C', 'g', 'c':
+			gc++
+		case 'A', 'T', 'U', 'a', 't', 'u':
+			at++
+		}
+	}
+
+	if gc+at == 0 {
+		return "0.00"
+	}
+
+	return strconv.FormatFloat(float64(gc)*100.0/float64(gc+at), 'f', 2, 64)
+}
+
+// GCContent returns the GC percentage of an entire sequence
+func GCContent(seq string) string {
+
+	return gcPercent(seq)
+}
+
+// GCWindows returns 1-based window start and GC percentage for each window, including a final partial window
+func GCWindows(seq string, window, step int) []string {
+
+	var res []string
+
+	if window < 1 {
+		return res
+	}
+	if step < 1 {
+		step = window
+	}
+
+	ln := len(seq)
+
+	for pos := 0; pos < ln; pos += step {
+		end := pos + window
+		if end > ln {
+			end = ln
+		}
+		res = append(res, strconv.Itoa(pos+1)+"\t"+gcPercent(seq[pos:end]))
+		if end == ln {
+			break
+		}
+	}
+
+	return res
+}
+
 // ReverseComplement returns the reverse complement of a sequence
 func ReverseComplement(seq string) string {
 
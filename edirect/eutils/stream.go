@@ -0,0 +1,157 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  stream.go
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ctxReader stops supplying data once its context is canceled
+type ctxReader struct {
+	ctx context.Context
+	rdr io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+
+	if c.ctx.Err() != nil {
+		return 0, io.EOF
+	}
+
+	return c.rdr.Read(p)
+}
+
+// ExtractStream runs xtract extraction arguments, starting with -pattern, on XML from a reader,
+// and sends each non-empty record result to the out callback in the original record order.
+// Canceling the context, or an error returned by out, stops reading input and discards
+// remaining results. If input ends inside a record or an enclosing element, results of
+// complete records are sent, and an *XMLTruncation is returned. ExtractStream returns only
+// after all of its goroutines have exited, although a Read call that is already blocked on
+// the underlying reader must finish first. Settings made for the command-line programs,
+// such as SetRecordWindow, SetFlushOutput, and AddNamespace, do not apply.
+func ExtractStream(ctx context.Context, rdr io.Reader, args []string, out func(string) error) error {
+
+	if rdr == nil || out == nil {
+		return errors.New("Missing input reader or output function")
+	}
+
+	// allow -record as synonym of -pattern (undocumented)
+	if len(args) < 1 || (args[0] != "-pattern" && args[0] != "-Pattern" && args[0] != "-record" && args[0] != "-Record") {
+		return errors.New("No -pattern in command-line arguments")
+	}
+	if len(args) < 2 || args[1] == "" {
+		return errors.New("Item missing after -pattern command")
+	}
+
+	// copy arguments before converting -record, since the caller's slice must not change
+	args = append([]string(nil), args...)
+	args[0] = "-pattern"
+
+	// look for -pattern Parent/* construct for heterogeneous data
+	topPattern, star := SplitInTwoLeft(args[1], "/")
+	parent := ""
+	if star == "*" {
+		parent = topPattern
+	} else if star != "" {
+		return errors.New("-pattern Parent/Child construct is not supported")
+	}
+
+	cmds, err := ParseArgumentsErr(args, topPattern)
+	if err != nil {
+		return err
+	}
+	if cmds == nil {
+		return errors.New("Problem parsing command-line arguments")
+	}
+
+	// programs that do not call SetTunings get default performance parameters
+	if NumServe() < 1 {
+		SetTunings(0, 0, 0, 0, 0, 0, 0, false)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	histogram := make(map[string]int)
+
+	// settings are private to this call
+	run := &xmlRun{}
+
+	// reader returns end of file after cancellation, so the streamer, producer, consumers, and unshuffler all finish
+	rdrq := CreateXMLStreamer(&ctxReader{ctx: ctx, rdr: rdr})
+	xmlq := createXMLProducer(run, topPattern, star, false, rdrq)
+	tblq := CreateXMLConsumers(cmds, parent, "", "", nil, false, histogram, xmlq)
+	unsq := createXMLUnshuffler(run, tblq)
+
+	if rdrq == nil || xmlq == nil || tblq == nil || unsq == nil {
+		return errors.New("Unable to create servers")
+	}
+
+	var outErr error
+	var trunc *XMLTruncation
+
+	for curr := range unsq {
+
+		if outErr != nil || ctx.Err() != nil {
+			// drain remaining results so upstream goroutines are not blocked
+			continue
+		}
+
+		if curr.Truncated != nil {
+			trunc = curr.Truncated
+			continue
+		}
+
+		if curr.Text == "" {
+			continue
+		}
+
+		if err := out(curr.Text); err != nil {
+			outErr = err
+			cancel()
+		}
+	}
+
+	if outErr != nil {
+		return outErr
+	}
+
+	// end of input caused by cancellation is not reported as truncation
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if trunc != nil {
+		return trunc
+	}
+
+	return nil
+}
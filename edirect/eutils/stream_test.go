@@ -0,0 +1,160 @@
+package eutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordReader supplies an endless stream of numbered records
+type recordReader struct {
+	next int
+	buf  string
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+
+	for len(r.buf) < len(p) {
+		r.next++
+		r.buf += fmt.Sprintf("<Rec><Id>%d</Id><Title>Record number %d</Title></Rec>\n", r.next, r.next)
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+// captureStderr returns whatever fn writes to standard error
+func captureStderr(t *testing.T, fn func()) string {
+
+	t.Helper()
+
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := os.Stderr
+	os.Stderr = wr
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(rd)
+		done <- string(data)
+	}()
+
+	fn()
+
+	os.Stderr = saved
+	wr.Close()
+
+	return <-done
+}
+
+func TestExtractStreamTruncated(t *testing.T) {
+
+	xml := "<Set>\n<Rec><Id>1</Id></Rec>\n<Rec><Id>2</Id></Rec>\n<Rec><Id>3</Id><AuthorList><Au"
+
+	var got []string
+	var err error
+
+	msg := captureStderr(t, func() {
+		err = ExtractStream(context.Background(), strings.NewReader(xml), []string{"-pattern", "Rec", "-element", "Id"},
+			func(str string) error {
+				got = append(got, strings.TrimSpace(str))
+				return nil
+			})
+	})
+
+	if strings.Join(got, ",") != "1,2" {
+		t.Errorf("complete records gave %q", got)
+	}
+
+	var trunc *XMLTruncation
+	if !errors.As(err, &trunc) {
+		t.Fatalf("got error %v, want *XMLTruncation", err)
+	}
+	if !trunc.Record || trunc.Line != 4 || trunc.Element != "AuthorList" {
+		t.Errorf("got %+v", *trunc)
+	}
+	if msg != "" {
+		t.Errorf("unexpected diagnostic %q", msg)
+	}
+
+	// complete input is not an error
+	if err := ExtractStream(context.Background(), strings.NewReader("<Set><Rec><Id>1</Id></Rec></Set>"),
+		[]string{"-pattern", "Rec", "-element", "Id"}, func(string) error { return nil }); err != nil {
+		t.Errorf("complete input gave %v", err)
+	}
+}
+
+func TestExtractStreamCancel(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	var err error
+
+	// cancellation usually leaves a partial record, which must not be reported
+	msg := captureStderr(t, func() {
+		err = ExtractStream(ctx, &recordReader{}, []string{"-pattern", "Rec", "-element", "Id"},
+			func(str string) error {
+				count++
+				if count == 50 {
+					cancel()
+				}
+				return nil
+			})
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if count < 50 {
+		t.Errorf("only %d records before cancellation", count)
+	}
+	if msg != "" {
+		t.Errorf("cancellation printed %q", msg)
+	}
+
+	// goroutines exit before ExtractStream returns, allow the runtime a moment to account for them
+	after := 0
+	for i := 0; i < 50; i++ {
+		after = runtime.NumGoroutine()
+		if after <= before {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after > before {
+		t.Errorf("%d goroutines before and %d after", before, after)
+	}
+}
+
+func TestExtractStreamIgnoresCommandLineSettings(t *testing.T) {
+
+	SetRecordWindow(1, 1)
+	SetFlushOutput(true)
+	AddNamespace("x", "urn:x")
+	defer func() {
+		SetRecordWindow(0, 0)
+		SetFlushOutput(false)
+		cliRun.namespaces = nil
+	}()
+
+	xml := "<Set><Rec><Id>1</Id></Rec><Rec><Id>2</Id></Rec><Rec><Id>3</Id></Rec></Set>"
+
+	if got := extractText(t, xml, "-pattern", "Rec", "-element", "Id"); got != "1\n2\n3\n" {
+		t.Errorf("got %q", got)
+	}
+}
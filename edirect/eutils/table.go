@@ -33,6 +33,7 @@ package eutils
 import (
 	"bufio"
 	"bytes"
+	"encoding/csv"
 	"fmt"
 	"html"
 	"io"
@@ -42,7 +43,7 @@ import (
 )
 
 // TableConverter parses tab-delimited or comma-separated values files into XML object stream
-func TableConverter(inp io.Reader, delim, set, rec string, skip int, header, lower, upper, indent bool, fields []string) <-chan string {
+func TableConverter(inp io.Reader, delim, quote, set, rec string, skip int, header, lower, upper, indent bool, fields []string) <-chan string {
 
 	if inp == nil {
 		return nil
@@ -89,6 +90,30 @@ func TableConverter(inp io.Reader, delim, set, rec string, skip int, header, low
 
 		scanr := bufio.NewScanner(inp)
 
+		// nextRow reads one logical row, joining physical lines while inside a quoted field
+		nextRow := func() (string, []string, bool) {
+
+			if !scanr.Scan() {
+				return "", nil, false
+			}
+
+			line := scanr.Text()
+			row++
+
+			if quote == "" {
+				return line, strings.Split(line, delim), true
+			}
+
+			for {
+				cols, closed := splitQuotedFields(line, delim, quote)
+				if closed || !scanr.Scan() {
+					return line, cols, true
+				}
+				line += "\n" + scanr.Text()
+				row++
+			}
+		}
+
 		if head != "" {
 			buffer.WriteString(head)
 			buffer.WriteString("\n")
@@ -97,19 +122,18 @@ func TableConverter(inp io.Reader, delim, set, rec string, skip int, header, low
 		if header {
 
 			// uses fields from first row for column names
-			for scanr.Scan() {
+			for {
 
-				line := scanr.Text()
-
-				row++
+				_, cols, ok := nextRow()
+				if !ok {
+					break
+				}
 
 				if skip > 0 {
 					skip--
 					continue
 				}
 
-				cols := strings.Split(line, delim)
-
 				for _, str := range cols {
 					fields = append(fields, str)
 					numFlds++
@@ -123,19 +147,18 @@ func TableConverter(inp io.Reader, delim, set, rec string, skip int, header, low
 			}
 		}
 
-		for scanr.Scan() {
-
-			line := scanr.Text()
+		for {
 
-			row++
+			line, cols, ok := nextRow()
+			if !ok {
+				break
+			}
 
 			if skip > 0 {
 				skip--
 				continue
 			}
 
-			cols := strings.Split(line, delim)
-
 			if len(cols) != numFlds {
 				fmt.Fprintf(os.Stderr, "Mismatched columns in row %d - '%s'\n", row, line)
 				continue
@@ -210,6 +233,42 @@ func TableConverter(inp io.Reader, delim, set, rec string, skip int, header, low
 	return out
 }
 
+// splitQuotedFields separates RFC 4180 style fields, where a doubled quote inside a quoted
+// field is a literal quote, and reports false if the line ends within an open quoted field
+func splitQuotedFields(line, delim, quote string) ([]string, bool) {
+
+	var cols []string
+	var buffer strings.Builder
+
+	inQuote := false
+
+	for len(line) > 0 {
+		switch {
+		case inQuote && strings.HasPrefix(line, quote+quote):
+			buffer.WriteString(quote)
+			line = line[2*len(quote):]
+		case strings.HasPrefix(line, quote) && (inQuote || strings.TrimSpace(buffer.String()) == ""):
+			if !inQuote {
+				// ignore spaces before opening quote
+				buffer.Reset()
+			}
+			inQuote = !inQuote
+			line = line[len(quote):]
+		case !inQuote && strings.HasPrefix(line, delim):
+			cols = append(cols, buffer.String())
+			buffer.Reset()
+			line = line[len(delim):]
+		default:
+			buffer.WriteByte(line[0])
+			line = line[1:]
+		}
+	}
+
+	cols = append(cols, buffer.String())
+
+	return cols, !inQuote
+}
+
 // TableToMap reads a two-column tab-delimited file and populates the data into an existing map
 func TableToMap(tf string, mp map[string]string) {
 
@@ -245,6 +304,242 @@ func TableToMap(tf string, mp map[string]string) {
 	inFile.Close()
 }
 
+// JoinTables merges two tab-delimited tables on key columns, numbered from 1, loading the
+// lookup table into a map and streaming the other table in a single pass. Each output row
+// has the key, the other columns of the first table, and then those of the second, where
+// lookupFirst tells whether the lookup table is the first one. Lookup rows are padded with
+// "-" to the width of the widest lookup row. With outer, unmatched rows of either table are
+// also printed, with "-" in place of the missing columns. With header, the first lines of
+// the tables are merged as a header row. A duplicate lookup key is an error unless cartesian
+// is set, in which case each matching pair of rows is printed.
+func JoinTables(lookup, stream io.Reader, lookupKey, streamKey int, lookupFirst, outer, header, cartesian bool, out io.Writer) error {
+
+	if lookup == nil || stream == nil || out == nil {
+		return fmt.Errorf("Missing table reader or writer")
+	}
+	if lookupKey < 1 || streamKey < 1 {
+		return fmt.Errorf("Key column must be at least 1")
+	}
+
+	tableScanner := func(inp io.Reader) *bufio.Scanner {
+
+		scanr := bufio.NewScanner(inp)
+		scanr.Buffer(make([]byte, 65536), 16*1024*1024)
+
+		return scanr
+	}
+
+	// keyOf returns the key column and the remaining columns of a row
+	keyOf := func(line string, key int) (string, []string, bool) {
+
+		cols := strings.Split(line, "\t")
+		if key > len(cols) {
+			return "", cols, false
+		}
+
+		rest := make([]string, 0, len(cols)-1)
+		rest = append(rest, cols[:key-1]...)
+		rest = append(rest, cols[key:]...)
+
+		return cols[key-1], rest, true
+	}
+
+	type tableRow struct {
+		id   string
+		cols []string
+		used bool
+	}
+
+	table := make(map[string][]*tableRow)
+	var order []*tableRow
+	var lookupHead []string
+	lookupWidth := 0
+
+	scanr := tableScanner(lookup)
+
+	first := true
+	for scanr.Scan() {
+		line := scanr.Text()
+		id, rest, ok := keyOf(line, lookupKey)
+		if first && header {
+			lookupHead = rest
+			first = false
+			continue
+		}
+		first = false
+		if !ok {
+			continue
+		}
+		if len(rest) > lookupWidth {
+			lookupWidth = len(rest)
+		}
+		if table[id] != nil && !cartesian {
+			return fmt.Errorf("Duplicate key '%s' in lookup table, use -cartesian to pair all rows", id)
+		}
+		row := &tableRow{id: id, cols: rest}
+		table[id] = append(table[id], row)
+		if outer {
+			order = append(order, row)
+		}
+	}
+
+	if err := scanr.Err(); err != nil {
+		return fmt.Errorf("Unable to read lookup table - %s", err.Error())
+	}
+
+	if len(lookupHead) > lookupWidth {
+		lookupWidth = len(lookupHead)
+	}
+
+	wrtr := bufio.NewWriter(out)
+
+	// padded extends ragged rows so that later columns stay aligned
+	padded := func(cols []string, width int) []string {
+
+		for len(cols) < width {
+			cols = append(cols, "-")
+		}
+
+		return cols
+	}
+
+	// printJoined writes the key, then columns of the first table, then columns of the second
+	printJoined := func(id string, lookupCols, streamCols []string) {
+
+		wrtr.WriteString(id)
+		lft, rgt := streamCols, padded(lookupCols, lookupWidth)
+		if lookupFirst {
+			lft, rgt = rgt, lft
+		}
+		for _, col := range lft {
+			wrtr.WriteString("\t")
+			wrtr.WriteString(col)
+		}
+		for _, col := range rgt {
+			wrtr.WriteString("\t")
+			wrtr.WriteString(col)
+		}
+		wrtr.WriteString("\n")
+	}
+
+	scanr = tableScanner(stream)
+
+	streamWidth := 0
+
+	first = true
+	for scanr.Scan() {
+		line := scanr.Text()
+		id, rest, ok := keyOf(line, streamKey)
+		if len(rest) > streamWidth {
+			streamWidth = len(rest)
+		}
+		if first {
+			first = false
+			if header {
+				printJoined(id, lookupHead, rest)
+				continue
+			}
+		}
+		if !ok {
+			continue
+		}
+		rows := table[id]
+		if rows == nil {
+			if outer {
+				printJoined(id, nil, rest)
+			}
+			continue
+		}
+		for _, row := range rows {
+			row.used = true
+			printJoined(id, row.cols, rest)
+		}
+	}
+
+	if err := scanr.Err(); err != nil {
+		wrtr.Flush()
+		return fmt.Errorf("Unable to read streamed table - %s", err.Error())
+	}
+
+	// unmatched lookup rows follow in their original order
+	for _, row := range order {
+		if !row.used {
+			printJoined(row.id, row.cols, padded(nil, streamWidth))
+		}
+	}
+
+	return wrtr.Flush()
+}
+
+// TableToCSV converts tab-delimited lines, such as xtract output, to RFC 4180 values separated
+// by delim, quoting fields that contain the delimiter, a quote, or a line break. With bom, a
+// UTF-8 byte order mark is written first for Excel. With crlf, lines end with CRLF. With pad,
+// the input is buffered and ragged rows are padded with empty fields to the widest row.
+func TableToCSV(inp io.Reader, delim rune, bom, crlf, pad bool, out io.Writer) error {
+
+	if inp == nil || out == nil {
+		return fmt.Errorf("Missing table reader or writer")
+	}
+	if delim == '"' || delim == '\n' || delim == '\r' {
+		return fmt.Errorf("Delimiter must be a single character other than quote")
+	}
+
+	wrtr := bufio.NewWriter(out)
+
+	if bom {
+		// UTF-8 byte order mark lets Excel recognize the encoding
+		wrtr.WriteString("\uFEFF")
+	}
+
+	cw := csv.NewWriter(wrtr)
+	cw.Comma = delim
+	cw.UseCRLF = crlf
+
+	scanr := bufio.NewScanner(inp)
+	scanr.Buffer(make([]byte, 65536), 64*1024*1024)
+
+	// ragged rows are held until the widest row is known
+	var rows [][]string
+	widest := 0
+
+	for scanr.Scan() {
+
+		line := strings.TrimSuffix(scanr.Text(), "\r")
+		cols := strings.Split(line, "\t")
+
+		if !pad {
+			cw.Write(cols)
+			continue
+		}
+
+		if len(cols) > widest {
+			widest = len(cols)
+		}
+		rows = append(rows, cols)
+	}
+
+	if err := scanr.Err(); err != nil {
+		cw.Flush()
+		wrtr.Flush()
+		return fmt.Errorf("Unable to read table - %s", err.Error())
+	}
+
+	for _, cols := range rows {
+		for len(cols) < widest {
+			cols = append(cols, "")
+		}
+		cw.Write(cols)
+	}
+
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	return wrtr.Flush()
+}
+
 // TextBlock is a (multi-line) string that is trimmed back to end with the last newline.
 // The excluded characters are saved and prepended to the next buffer. Providing complete
 // lines simplifies subsequent parsing.
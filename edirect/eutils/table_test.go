@@ -0,0 +1,195 @@
+package eutils
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// joinStrings runs JoinTables on two tables and returns the output
+func joinStrings(t *testing.T, lookup, stream string, lookupKey, streamKey int, lookupFirst, outer, header, cartesian bool) (string, error) {
+
+	t.Helper()
+
+	var buf strings.Builder
+
+	err := JoinTables(strings.NewReader(lookup), strings.NewReader(stream), lookupKey, streamKey, lookupFirst, outer, header, cartesian, &buf)
+
+	return buf.String(), err
+}
+
+func TestJoinTables(t *testing.T) {
+
+	genes := "G1\tBRCA1\tchr17\n" +
+		"G2\tTP53\n" +
+		"G3\tEGFR\tchr7\n"
+
+	expr := "liver\tG1\t5.0\n" +
+		"brain\tG2\t7.5\n" +
+		"heart\tG4\t1.0\n"
+
+	tests := []struct {
+		name        string
+		lookupFirst bool
+		outer       bool
+		want        string
+	}{
+		{"inner", true, false,
+			"G1\tBRCA1\tchr17\tliver\t5.0\n" +
+				"G2\tTP53\t-\tbrain\t7.5\n"},
+		{"lookup second", false, false,
+			"G1\tliver\t5.0\tBRCA1\tchr17\n" +
+				"G2\tbrain\t7.5\tTP53\t-\n"},
+		{"outer", true, true,
+			"G1\tBRCA1\tchr17\tliver\t5.0\n" +
+				"G2\tTP53\t-\tbrain\t7.5\n" +
+				"G4\t-\t-\theart\t1.0\n" +
+				"G3\tEGFR\tchr7\t-\t-\n"},
+	}
+
+	// key is the first column of the gene table, and the second column of the expression table
+	for _, tt := range tests {
+		got, err := joinStrings(t, genes, expr, 1, 2, tt.lookupFirst, tt.outer, false, false)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	// every output row has the same number of columns
+	got, _ := joinStrings(t, genes, expr, 1, 2, true, true, false, false)
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if n := strings.Count(line, "\t"); n != 4 {
+			t.Errorf("row %q has %d tabs", line, n)
+		}
+	}
+
+	// header rows are merged, and the lookup header is padded like other lookup rows
+	got, err := joinStrings(t, "Id\tName\nG1\tBRCA1\tchr17\n", "Tissue\tId\tLevel\nliver\tG1\t5.0\n", 1, 2, true, false, true, false)
+	if err != nil || got != "Id\tName\t-\tTissue\tLevel\nG1\tBRCA1\tchr17\tliver\t5.0\n" {
+		t.Errorf("header: got %q, %v", got, err)
+	}
+}
+
+func TestJoinTablesDuplicates(t *testing.T) {
+
+	lookup := "K\ta\nK\tb\n"
+	stream := "K\tx\n"
+
+	if _, err := joinStrings(t, lookup, stream, 1, 1, true, false, false, false); err == nil || !strings.Contains(err.Error(), "Duplicate key 'K'") {
+		t.Errorf("duplicate key gave %v", err)
+	}
+
+	got, err := joinStrings(t, lookup, stream, 1, 1, true, false, false, true)
+	if err != nil || got != "K\ta\tx\nK\tb\tx\n" {
+		t.Errorf("cartesian: got %q, %v", got, err)
+	}
+}
+
+func TestJoinTablesReadErrors(t *testing.T) {
+
+	boom := errors.New("boom")
+
+	var buf strings.Builder
+
+	bad := io.MultiReader(strings.NewReader("K\ta\n"), iotest.ErrReader(boom))
+	if err := JoinTables(bad, strings.NewReader("K\tx\n"), 1, 1, true, false, false, false, &buf); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("lookup read error gave %v", err)
+	}
+
+	bad = io.MultiReader(strings.NewReader("K\tx\n"), iotest.ErrReader(boom))
+	if err := JoinTables(strings.NewReader("K\ta\n"), bad, 1, 1, true, false, false, false, &buf); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("stream read error gave %v", err)
+	}
+
+	// line longer than the scanner buffer limit
+	long := "K\t" + strings.Repeat("x", 17*1024*1024) + "\n"
+	if err := JoinTables(strings.NewReader(long), strings.NewReader("K\tx\n"), 1, 1, true, false, false, false, &buf); err == nil {
+		t.Errorf("overlong lookup line was not reported")
+	}
+}
+
+func TestTableToCSVRoundTrip(t *testing.T) {
+
+	rows := [][]string{
+		{"PMID", "Title", "Journal"},
+		{"1", `Cats, "dogs", and mice`, "J Biol"},
+		{"2", "Plain title", "Nature; Lond"},
+		{"3", "", "'quoted' name"},
+	}
+
+	var tab strings.Builder
+	for _, cols := range rows {
+		tab.WriteString(strings.Join(cols, "\t") + "\n")
+	}
+
+	for _, delim := range []rune{',', ';'} {
+
+		var buf strings.Builder
+		if err := TableToCSV(strings.NewReader(tab.String()), delim, false, false, false, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		rdr := csv.NewReader(strings.NewReader(buf.String()))
+		rdr.Comma = delim
+		got, err := rdr.ReadAll()
+		if err != nil {
+			t.Fatalf("output with delimiter %q is not valid CSV: %v\n%s", delim, err, buf.String())
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("delimiter %q: got %q, want %q", delim, got, rows)
+		}
+	}
+}
+
+func TestTableToCSVOptions(t *testing.T) {
+
+	tests := []struct {
+		name string
+		inp  string
+		bom  bool
+		crlf bool
+		pad  bool
+		want string
+	}{
+		{"quoting", "a,b\tc\"d\te\n", false, false, false, "\"a,b\",\"c\"\"d\",e\n"},
+		{"windows input", "a\tb\r\nc\td\r\n", false, false, false, "a,b\nc,d\n"},
+		{"bom", "a\tb\n", true, false, false, "\uFEFFa,b\n"},
+		{"crlf", "a\tb\nc\td\n", false, true, false, "a,b\r\nc,d\r\n"},
+		{"ragged", "a\nb\tc\td\ne\tf\n", false, false, false, "a\nb,c,d\ne,f\n"},
+		{"pad", "a\nb\tc\td\ne\tf\n", false, false, true, "a,,\nb,c,d\ne,f,\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := TableToCSV(strings.NewReader(tt.inp), ',', tt.bom, tt.crlf, tt.pad, &buf); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableToCSVErrors(t *testing.T) {
+
+	var buf strings.Builder
+
+	if err := TableToCSV(strings.NewReader("a\tb\n"), '"', false, false, false, &buf); err == nil {
+		t.Error("expected error for quote delimiter")
+	}
+
+	failure := errors.New("disk failure")
+	if err := TableToCSV(iotest.ErrReader(failure), ',', false, false, false, &buf); err == nil || !strings.Contains(err.Error(), "disk failure") {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
@@ -275,6 +275,12 @@ func getPrefixAndPadID(id string) (string, string) {
 // PadNumericID returns 8-character leading zero-padded numeric identifier
 func PadNumericID(id string) string {
 
+	return PadNumericIDWidth(id, 8)
+}
+
+// PadNumericIDWidth returns numeric identifier zero-padded to the requested width
+func PadNumericIDWidth(id string, width int) string {
+
 	// "2539356"
 
 	if len(id) > 64 {
@@ -285,11 +291,10 @@ func PadNumericID(id string) string {
 
 	if IsAllDigits(str) {
 
-		// pad numeric identifier to 8 characters with leading zeros
+		// pad numeric identifier with leading zeros
 		ln := len(str)
-		if ln < 8 {
-			zeros := "00000000"
-			str = zeros[ln:] + str
+		if ln < width {
+			str = strings.Repeat("0", width-ln) + str
 		}
 	}
 
@@ -298,6 +303,50 @@ func PadNumericID(id string) string {
 	return str
 }
 
+// NaturalSortKey zero-pads every run of digits so lexical sort gives natural order
+func NaturalSortKey(str string, width int) string {
+
+	// "NM_99999.10"
+
+	if str == "" || len(str) > 4096 {
+		return str
+	}
+
+	for i := 0; i < len(str); i++ {
+		// non-ASCII text passes through unchanged
+		if str[i] > 127 {
+			return str
+		}
+	}
+
+	var buffer strings.Builder
+
+	for len(str) > 0 {
+		j := 0
+		for j < len(str) && str[j] >= '0' && str[j] <= '9' {
+			j++
+		}
+		if j > 0 {
+			// digit runs longer than width are kept intact
+			if j < width {
+				buffer.WriteString(strings.Repeat("0", width-j))
+			}
+			buffer.WriteString(str[:j])
+			str = str[j:]
+			continue
+		}
+		for j < len(str) && (str[j] < '0' || str[j] > '9') {
+			j++
+		}
+		buffer.WriteString(str[:j])
+		str = str[j:]
+	}
+
+	// "NM_00099999.00000010"
+
+	return buffer.String()
+}
+
 // ArchiveTrie allows a short prefix of letters with an optional underscore,
 // and splits the remainder into character pairs
 func ArchiveTrie(id string) (string, string) {
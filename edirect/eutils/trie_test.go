@@ -0,0 +1,109 @@
+package eutils
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNaturalSortKey(t *testing.T) {
+
+	// RefSeq accessions in version-aware natural order
+	want := []string{
+		"NC_000001.10",
+		"NC_000001.11",
+		"NM_000123.2",
+		"NM_000123.10",
+		"NM_99999.1",
+		"NM_100000.1",
+		"NM_1000000.3",
+		"NR_024540.1",
+		"XM_005245.1",
+		"XM_005245.9",
+		"XM_005245.12",
+	}
+
+	got := make([]string, len(want))
+	copy(got, want)
+	// start from reverse lexical order
+	sort.Sort(sort.Reverse(sort.StringSlice(got)))
+
+	sort.SliceStable(got, func(i, j int) bool {
+		return NaturalSortKey(got[i], 12) < NaturalSortKey(got[j], 12)
+	})
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	tests := []struct {
+		str   string
+		width int
+		want  string
+	}{
+		{"NM_99999.10", 8, "NM_00099999.00000010"},
+		{"abc", 8, "abc"},
+		{"12345", 3, "12345"},
+		{"", 8, ""},
+		// non-ASCII input passes through unchanged
+		{"Müller 12", 8, "Müller 12"},
+	}
+
+	for _, tt := range tests {
+		if key := NaturalSortKey(tt.str, tt.width); key != tt.want {
+			t.Errorf("NaturalSortKey(%q, %d) = %q, want %q", tt.str, tt.width, key, tt.want)
+		}
+	}
+}
+
+func TestPadNumericIDWidth(t *testing.T) {
+
+	tests := []struct {
+		id    string
+		width int
+		want  string
+	}{
+		{"2539356", 8, "02539356"},
+		{"2539356", 12, "000002539356"},
+		{"123456789", 8, "123456789"},
+		{"NM_000123", 12, "NM_000123"},
+	}
+
+	for _, tt := range tests {
+		if str := PadNumericIDWidth(tt.id, tt.width); str != tt.want {
+			t.Errorf("PadNumericIDWidth(%q, %d) = %q, want %q", tt.id, tt.width, str, tt.want)
+		}
+	}
+
+	if str := PadNumericID("2539356"); str != "02539356" {
+		t.Errorf("PadNumericID default width gives %q", str)
+	}
+}
+
+func TestPadAndNaturalExtraction(t *testing.T) {
+
+	xml := "<Set><Rec><Id>2539356</Id><Accn>NM_000123.2</Accn></Rec></Set>\n"
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-pad", "Id"}, "02539356\n"},
+		{[]string{"-pad:12", "Id"}, "000002539356\n"},
+		{[]string{"-natural", "Accn"}, "NM_00000123.00000002\n"},
+		{[]string{"-natural:4", "Accn"}, "NM_000123.0002\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		if out := extractText(t, xml, args...); out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+
+	for _, arg := range []string{"-pad:0", "-natural:x", "-natural:65"} {
+		if _, err := ParseArgumentsErr([]string{"-pattern", "Rec", arg, "Id"}, "Rec"); err == nil {
+			t.Errorf("%s: expected error", arg)
+		}
+	}
+}
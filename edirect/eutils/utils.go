@@ -35,11 +35,16 @@ import (
 	"fmt"
 	"github.com/klauspost/cpuid"
 	"github.com/pbnjay/memory"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -130,6 +135,13 @@ var (
 	startTime time.Time
 )
 
+// progress counters, updated atomically by the input reader and unshuffler
+var (
+	progressOn      bool
+	progressRecords int64
+	progressBytes   int64
+)
+
 // SetTunings sets performance parameters
 func SetTunings(nmProcs, nmServe, svRatio, chnDepth, frmSize, hepSize, gogc int, turbo bool) {
 
@@ -306,6 +318,138 @@ func GetStringArg(args []string, name string) string {
 	return args[1]
 }
 
+// splitArgumentFile separates argument file contents into tokens at spaces and newlines,
+// skipping # comment lines, with single or double quotes keeping internal spaces
+func splitArgumentFile(text string) ([]string, []bool) {
+
+	var tokens []string
+	var quoted []bool
+
+	for _, line := range strings.Split(text, "\n") {
+
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		var buffer strings.Builder
+		inToken := false
+		wasQuoted := false
+		var quote rune
+
+		for _, ch := range line {
+			switch {
+			case quote != 0:
+				// backslashes and other characters are kept literally within quotes
+				if ch == quote {
+					quote = 0
+				} else {
+					buffer.WriteRune(ch)
+				}
+			case ch == '"' || ch == '\'':
+				quote = ch
+				inToken = true
+				wasQuoted = true
+			case ch == ' ' || ch == '\t':
+				if inToken {
+					tokens = append(tokens, buffer.String())
+					quoted = append(quoted, wasQuoted)
+					buffer.Reset()
+					inToken = false
+					wasQuoted = false
+				}
+			default:
+				buffer.WriteRune(ch)
+				inToken = true
+			}
+		}
+
+		if quote != 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unterminated quote in argument file line '%s'\n", line)
+			os.Exit(1)
+		}
+
+		if inToken {
+			tokens = append(tokens, buffer.String())
+			quoted = append(quoted, wasQuoted)
+		}
+	}
+
+	return tokens, quoted
+}
+
+// argumentFile returns the path named by an @@path argument, or by --args followed by a path,
+// and the number of arguments used, so that attribute arguments like "@*" or "@id" are never read as files
+func argumentFile(args []string, i int) (string, int, error) {
+
+	str := args[i]
+
+	if str == "--args" {
+		if i+1 >= len(args) || args[i+1] == "" {
+			return "", 0, fmt.Errorf("Argument file name is missing after --args")
+		}
+		return args[i+1], 2, nil
+	}
+
+	if strings.HasPrefix(str, "@@") {
+		if len(str) < 3 {
+			return "", 0, fmt.Errorf("Argument file name is missing after @@")
+		}
+		return str[2:], 1, nil
+	}
+
+	return "", 0, nil
+}
+
+// expandArgumentFiles replaces each @@path or --args path reference with the tokens read from that file
+func expandArgumentFiles(args []string) ([]string, error) {
+
+	var res []string
+
+	for i := 0; i < len(args); i++ {
+
+		path, used, err := argumentFile(args, i)
+		if err != nil {
+			return nil, err
+		}
+		if used == 0 {
+			res = append(res, args[i])
+			continue
+		}
+		i += used - 1
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read argument file '%s'", path)
+		}
+
+		tokens, quoted := splitArgumentFile(string(data))
+
+		for j, tkn := range tokens {
+			// reject nested references to prevent loops, quoted tokens are literal
+			if !quoted[j] && (tkn == "--args" || strings.HasPrefix(tkn, "@@")) {
+				return nil, fmt.Errorf("Nested argument file '%s' in '%s' is not allowed", tkn, path)
+			}
+			res = append(res, tkn)
+		}
+	}
+
+	return res, nil
+}
+
+// ExpandArgumentFiles replaces each @@path or --args path reference with the tokens read from that file,
+// exiting if a referenced file cannot be read
+func ExpandArgumentFiles(args []string) []string {
+
+	res, err := expandArgumentFiles(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	return res
+}
+
 // PrintDuration prints processing rate and program duration
 func PrintDuration(name string, recordCount, byteCount int) {
 
@@ -352,6 +496,279 @@ func PrintDuration(name string, recordCount, byteCount int) {
 	fmt.Fprintf(os.Stderr, "\n\n")
 }
 
+// progressReader counts bytes consumed from the underlying input
+type progressReader struct {
+	rdr io.Reader
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+
+	n, err := p.rdr.Read(b)
+	atomic.AddInt64(&progressBytes, int64(n))
+	return n, err
+}
+
+// CreateProgressReader wraps the input so that -progress can report bytes consumed
+func CreateProgressReader(in io.Reader) io.Reader {
+
+	if in == nil {
+		return nil
+	}
+
+	return &progressReader{rdr: in}
+}
+
+// InputSize returns the size of a regular file, or 0 for pipes and terminals
+func InputSize(fl *os.File) int64 {
+
+	if fl == nil {
+		return 0
+	}
+
+	fi, err := fl.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0
+	}
+
+	return fi.Size()
+}
+
+// formatBytes prints a byte count with a binary unit suffix
+func formatBytes(num int64) string {
+
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	val := float64(num)
+	idx := 0
+	for val >= 1024 && idx < len(units)-1 {
+		val /= 1024
+		idx++
+	}
+
+	if idx == 0 {
+		return strconv.FormatInt(num, 10) + " B"
+	}
+
+	return strconv.FormatFloat(val, 'f', 1, 64) + " " + units[idx]
+}
+
+// formatClock prints a duration as hours, minutes, and seconds
+func formatClock(seconds float64) string {
+
+	secs := int64(seconds + 0.5)
+
+	return fmt.Sprintf("%d:%02d:%02d", secs/3600, (secs/60)%60, secs%60)
+}
+
+// StartProgress periodically writes a one-line status to stderr. ETA is estimated from
+// totalBytes when the input size is known, otherwise from a totalRecords hint. The
+// returned function stops the reporter, leaving the final summary to PrintDuration.
+func StartProgress(name string, interval time.Duration, totalBytes, totalRecords int64) func() {
+
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	progressOn = true
+
+	// only rewrite the line in place when stderr is a terminal
+	isTTY := false
+	fi, err := os.Stderr.Stat()
+	if err == nil && (fi.Mode()&os.ModeCharDevice) != 0 {
+		isTTY = true
+	}
+
+	begin := time.Now()
+	lastTime := begin
+	var lastRecords int64
+	printed := false
+
+	report := func() {
+
+		now := time.Now()
+		recs := atomic.LoadInt64(&progressRecords)
+		byts := atomic.LoadInt64(&progressBytes)
+
+		elapsed := now.Sub(begin).Seconds()
+		delta := now.Sub(lastTime).Seconds()
+
+		var buffer strings.Builder
+
+		buffer.WriteString(strconv.FormatInt(recs, 10))
+		buffer.WriteString(" ")
+		buffer.WriteString(name)
+		if byts > 0 {
+			buffer.WriteString(", ")
+			buffer.WriteString(formatBytes(byts))
+			if totalBytes > 0 {
+				buffer.WriteString(" of ")
+				buffer.WriteString(formatBytes(totalBytes))
+			}
+		}
+
+		// current rate over the last interval
+		if delta > 0 {
+			rate := int64(float64(recs-lastRecords) / delta)
+			buffer.WriteString(", ")
+			buffer.WriteString(strconv.FormatInt(rate, 10))
+			buffer.WriteString(" ")
+			buffer.WriteString(name)
+			buffer.WriteString("/second")
+		}
+
+		// estimate remaining time from the average rate so far
+		frac := 0.0
+		if totalBytes > 0 && byts > 0 {
+			frac = float64(byts) / float64(totalBytes)
+		} else if totalRecords > 0 && recs > 0 {
+			frac = float64(recs) / float64(totalRecords)
+		}
+		if frac > 0 && frac < 1 {
+			buffer.WriteString(", ")
+			buffer.WriteString(strconv.Itoa(int(frac * 100)))
+			buffer.WriteString("%, ETA ")
+			buffer.WriteString(formatClock(elapsed/frac - elapsed))
+		}
+
+		buffer.WriteString(", elapsed ")
+		buffer.WriteString(formatClock(elapsed))
+
+		if isTTY {
+			// return to first column and erase remainder of previous line
+			fmt.Fprintf(os.Stderr, "\r%s\033[K", buffer.String())
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", buffer.String())
+		}
+
+		printed = true
+		lastTime = now
+		lastRecords = recs
+	}
+
+	done := make(chan bool)
+	stopped := make(chan bool)
+
+	go func() {
+
+		defer close(stopped)
+
+		tckr := time.NewTicker(interval)
+		defer tckr.Stop()
+
+		for {
+			select {
+			case <-tckr.C:
+				report()
+			case <-done:
+				// end status line before summary is printed
+				if isTTY && printed {
+					fmt.Fprintf(os.Stderr, "\n")
+				}
+				return
+			}
+		}
+	}()
+
+	// safe to call more than once
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// NCBI REQUEST RATE LIMITER
+
+// rateLimiter is a token bucket refilled from the send times of the last few requests,
+// so no window sees more requests than the rate, regardless of how many goroutines ask
+type rateLimiter struct {
+	mlock  sync.Mutex
+	window time.Duration
+	sent   []time.Time
+	pos    int
+}
+
+var (
+	rateOnce sync.Once
+	rateLmtr atomic.Pointer[rateLimiter]
+)
+
+// newRateLimiter allows ceil(rate) requests per ceil(rate)/rate seconds, e.g., 3 per second
+func newRateLimiter(rate float64) *rateLimiter {
+
+	num := int(math.Ceil(rate))
+	// small allowance for scheduling delay between acquiring a token and sending
+	window := time.Duration(float64(num)/rate*float64(time.Second)) + 10*time.Millisecond
+
+	return &rateLimiter{window: window, sent: make([]time.Time, num)}
+}
+
+// defaultRequestRate is 3 requests per second, or 10 with an API key, unless EDIRECT_RATE is set
+func defaultRequestRate() float64 {
+
+	if env := os.Getenv("EDIRECT_RATE"); env != "" {
+		if env == "off" || env == "none" {
+			return 0
+		}
+		rate, err := strconv.ParseFloat(env, 64)
+		if err != nil || rate < 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: EDIRECT_RATE value '%s' must be a non-negative number\n", env)
+			os.Exit(1)
+		}
+		return rate
+	}
+
+	if os.Getenv("NCBI_API_KEY") != "" {
+		return 10
+	}
+
+	return 3
+}
+
+// SetRequestRate overrides the process-wide limit in requests per second, with 0 disabling it
+// for paths, such as local -fetch, that never send network requests
+func SetRequestRate(rate float64) {
+
+	rateOnce.Do(func() {})
+
+	if rate <= 0 {
+		rateLmtr.Store(nil)
+		return
+	}
+
+	rateLmtr.Store(newRateLimiter(rate))
+}
+
+// WaitForRequest blocks until the next NCBI network request may be sent
+func WaitForRequest() {
+
+	rateOnce.Do(func() {
+		if rate := defaultRequestRate(); rate > 0 {
+			rateLmtr.Store(newRateLimiter(rate))
+		}
+	})
+
+	lmtr := rateLmtr.Load()
+	if lmtr == nil {
+		return
+	}
+
+	// waiting while holding the lock keeps later callers in line
+	lmtr.mlock.Lock()
+	defer lmtr.mlock.Unlock()
+
+	oldest := lmtr.sent[lmtr.pos]
+	if !oldest.IsZero() {
+		time.Sleep(time.Until(oldest.Add(lmtr.window)))
+	}
+
+	lmtr.sent[lmtr.pos] = time.Now()
+	lmtr.pos = (lmtr.pos + 1) % len(lmtr.sent)
+}
+
 // PrintMemory is adapted from PrintMemUsage in: https://golangcode.com/print-the-current-memory-usage/
 func PrintMemory() {
 
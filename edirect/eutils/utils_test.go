@@ -0,0 +1,166 @@
+package eutils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpandArgumentFiles(t *testing.T) {
+
+	dir := t.TempDir()
+
+	args := filepath.Join(dir, "args.txt")
+	if err := os.WriteFile(args, []byte("# authors\n-block Author -sep \" \"\n-element Initials,LastName\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(dir, "nested.txt")
+	if err := os.WriteFile(nested, []byte("-element PMID @@"+args+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	quoted := filepath.Join(dir, "quoted.txt")
+	if err := os.WriteFile(quoted, []byte("-lbl \"@@"+args+"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an existing file named by a plain @ argument is an attribute name, not an argument file
+	plain := "@" + args
+
+	want := "-pattern PubmedArticle -block Author -sep   -element Initials,LastName"
+
+	tests := []struct {
+		args []string
+		want string
+		err  string
+	}{
+		{[]string{"-pattern", "PubmedArticle", "@@" + args}, want, ""},
+		{[]string{"-pattern", "PubmedArticle", "--args", args}, want, ""},
+		{[]string{"-pattern", "Rec", "-element", "@*", plain}, "-pattern Rec -element @* " + plain, ""},
+		{[]string{"-lbl", "@@" + quoted}, "-lbl -lbl @@" + args, ""},
+		{[]string{"-pattern", "Rec", "@@" + filepath.Join(dir, "missing.txt")}, "", "Unable to read argument file '" + filepath.Join(dir, "missing.txt") + "'"},
+		{[]string{"-pattern", "Rec", "--args"}, "", "Argument file name is missing after --args"},
+		{[]string{"-pattern", "Rec", "@@"}, "", "Argument file name is missing after @@"},
+		{[]string{"@@" + nested}, "", "Nested argument file '@@" + args + "' in '" + nested + "' is not allowed"},
+	}
+
+	for _, tt := range tests {
+		res, err := expandArgumentFiles(tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%v: got error %v, want %q", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+			continue
+		}
+		if got := strings.Join(res, " "); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRequestRateLimit(t *testing.T) {
+
+	const rate = 40
+
+	var mlock sync.Mutex
+	var stamps []time.Time
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mlock.Lock()
+		stamps = append(stamps, time.Now())
+		mlock.Unlock()
+		w.Write([]byte("<eSummaryResult/>\n"))
+	}))
+	defer srv.Close()
+
+	SetRequestRate(rate)
+	defer SetRequestRate(0)
+
+	// 100 concurrent esummary calls
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			WaitForRequest()
+			resp, err := http.Get(srv.URL + "/esummary.fcgi?db=pubmed&id=" + strconv.Itoa(i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(stamps) != 100 {
+		t.Fatalf("server saw %d requests", len(stamps))
+	}
+
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Before(stamps[j]) })
+
+	// no one-second window may hold more than rate requests, allowing for network jitter at the boundary
+	for i := range stamps {
+		j := i
+		for j < len(stamps) && stamps[j].Sub(stamps[i]) < time.Second-20*time.Millisecond {
+			j++
+		}
+		if j-i > rate {
+			t.Fatalf("%d requests within one second starting at request %d", j-i, i+1)
+		}
+	}
+
+	// at least 100 requests at 40 per second take two full windows
+	if span := stamps[len(stamps)-1].Sub(stamps[0]); span < 2*time.Second {
+		t.Errorf("100 requests took only %v", span)
+	}
+}
+
+func TestRequestRateDisabled(t *testing.T) {
+
+	SetRequestRate(0)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		WaitForRequest()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("disabled limiter waited %v", elapsed)
+	}
+}
+
+func TestDefaultRequestRate(t *testing.T) {
+
+	tests := []struct {
+		rate string
+		key  string
+		want float64
+	}{
+		{"", "", 3},
+		{"", "abc123", 10},
+		{"5", "", 5},
+		{"0.5", "abc123", 0.5},
+		{"off", "abc123", 0},
+		{"none", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("EDIRECT_RATE", tt.rate)
+		t.Setenv("NCBI_API_KEY", tt.key)
+		if rate := defaultRequestRate(); rate != tt.want {
+			t.Errorf("EDIRECT_RATE=%q NCBI_API_KEY=%q: got %v, want %v", tt.rate, tt.key, rate, tt.want)
+		}
+	}
+}
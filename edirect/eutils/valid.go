@@ -24,8 +24,6 @@
 //
 // File Name:  valid.go
 //
-// Author:  Jonathan Kans
-//
 // ==========================================================================
 
 package eutils
@@ -260,3 +258,264 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 
 	return maxLine
 }
+
+// ReferenceElements collects element names found within records of a sample file, taking the
+// record name from the root element or, for a set wrapper, from its uniformly named children
+func ReferenceElements(rdr <-chan XMLBlock, pattern string) (string, map[string]bool) {
+
+	if rdr == nil {
+		return pattern, nil
+	}
+
+	tknq := CreateTokenizer(rdr)
+
+	if tknq == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create reference tokenizer\n")
+		os.Exit(1)
+	}
+
+	root := ""
+	child := ""
+	uniform := true
+	count := 0
+
+	// names within explicit pattern, or all names below root
+	within := make(map[string]bool)
+	below := make(map[string]bool)
+
+	depth := 0
+	inRecord := 0
+
+	record := func(name string) {
+		if depth > 0 {
+			below[name] = true
+		}
+		if inRecord > 0 || name == pattern {
+			within[name] = true
+		}
+		if depth == 1 {
+			count++
+			if child == "" {
+				child = name
+			} else if child != name {
+				uniform = false
+			}
+		}
+	}
+
+	for tkn := range tknq {
+
+		name := tkn.Name
+
+		switch tkn.Tag {
+		case STARTTAG:
+			if depth == 0 && root == "" {
+				root = name
+			}
+			record(name)
+			if name == pattern {
+				inRecord++
+			}
+			depth++
+		case SELFTAG:
+			record(name)
+		case STOPTAG:
+			if name == pattern && inRecord > 0 {
+				inRecord--
+			}
+			if depth > 0 {
+				depth--
+			}
+		default:
+		}
+	}
+
+	if pattern != "" {
+		return pattern, within
+	}
+
+	// a single record with varied children is itself the pattern
+	if child != "" && uniform && (count > 1 || strings.HasSuffix(root, "Set")) {
+		return child, below
+	}
+
+	below[root] = true
+
+	return root, below
+}
+
+// undefinedEntity returns the first entity reference other than the five predefined
+// XML entities or a numeric character reference, or a bare ampersand if unterminated
+func undefinedEntity(str string) string {
+
+	for {
+		pos := strings.Index(str, "&")
+		if pos < 0 {
+			return ""
+		}
+		str = str[pos+1:]
+
+		end := strings.Index(str, ";")
+		if end < 1 || end > 32 {
+			return "&"
+		}
+		ent := str[:end]
+
+		switch ent {
+		case "amp", "lt", "gt", "quot", "apos":
+			continue
+		}
+
+		if strings.HasPrefix(ent, "#x") || strings.HasPrefix(ent, "#X") {
+			if len(ent) > 2 && strings.Trim(ent[2:], "0123456789abcdefABCDEF") == "" {
+				continue
+			}
+		} else if strings.HasPrefix(ent, "#") {
+			if len(ent) > 1 && strings.Trim(ent[1:], "0123456789") == "" {
+				continue
+			}
+		}
+
+		return "&" + ent + ";"
+	}
+}
+
+// ValidateStructure checks nesting, entity references, and text outside of elements, and
+// optionally that elements within pattern records are in an allowed set, printing the
+// first limit violations with line numbers and returning the total number found
+func ValidateStructure(rdr <-chan XMLBlock, pattern string, allowed map[string]bool, limit int) int {
+
+	if rdr == nil {
+		return 0
+	}
+
+	countLines = true
+
+	tknq := CreateTokenizer(rdr)
+
+	if tknq == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create validator tokenizer\n")
+		os.Exit(1)
+	}
+
+	violations := 0
+
+	report := func(line int, format string, args ...interface{}) {
+		violations++
+		if limit > 0 && violations > limit {
+			return
+		}
+		fmt.Fprintf(os.Stdout, "%8d\t%s\n", line, fmt.Sprintf(format, args...))
+	}
+
+	var stack []string
+
+	inRecord := 0
+	line := 0
+
+	checkElement := func(name string) {
+		if allowed == nil || allowed[name] {
+			return
+		}
+		if inRecord > 0 || name == pattern {
+			report(line, "Unexpected <%s> in <%s>", name, pattern)
+		}
+	}
+
+	checkEntities := func(str, where string) {
+		if !strings.Contains(str, "&") {
+			return
+		}
+		ent := undefinedEntity(str)
+		if ent == "&" {
+			report(line, "Unescaped & in %s", where)
+		} else if ent != "" {
+			report(line, "Unknown entity %s in %s", ent, where)
+		}
+	}
+
+	for tkn := range tknq {
+
+		name := tkn.Name
+		line = tkn.Line
+
+		switch tkn.Tag {
+		case STARTTAG, SELFTAG:
+			checkElement(name)
+			checkEntities(tkn.Attr, "<"+name+"> attribute")
+			if tkn.Tag == SELFTAG {
+				break
+			}
+			if name == pattern {
+				inRecord++
+			}
+			stack = append(stack, name)
+		case STOPTAG:
+			top := len(stack) - 1
+			if top < 0 {
+				report(line, "Unexpected </%s> outside any element", name)
+				break
+			}
+			if stack[top] != name {
+				// find matching start tag further up, otherwise treat as stray end tag
+				pos := top - 1
+				for pos >= 0 && stack[pos] != name {
+					pos--
+				}
+				if pos < 0 {
+					report(line, "Unexpected </%s> in <%s>", name, stack[top])
+					break
+				}
+				report(line, "Expected </%s>, found </%s>", stack[top], name)
+				for top > pos {
+					if stack[top] == pattern && inRecord > 0 {
+						inRecord--
+					}
+					top--
+				}
+			}
+			if name == pattern && inRecord > 0 {
+				inRecord--
+			}
+			stack = stack[:top]
+		case CONTENTTAG:
+			if len(stack) < 1 {
+				report(line, "Text outside any element")
+				break
+			}
+			checkEntities(name, "<"+stack[len(stack)-1]+">")
+		default:
+		}
+	}
+
+	// the streamer holds back anything after the last > character, such as text following the root element
+	if frag, ok := streamFragments.LoadAndDelete(rdr); ok {
+		str := frag.(string)
+		txt := str
+		idx := strings.Index(str, "<")
+		if idx >= 0 {
+			txt = str[:idx]
+		}
+		if strings.TrimSpace(txt) != "" {
+			pos := 0
+			for inBlank[txt[pos]] {
+				pos++
+			}
+			if len(stack) < 1 {
+				report(line+strings.Count(txt[:pos], "\n"), "Text outside any element")
+			} else {
+				checkEntities(txt, "<"+stack[len(stack)-1]+">")
+			}
+		}
+		if idx >= 0 {
+			report(line+strings.Count(txt, "\n"), "Incomplete tag at end of data")
+		}
+		line += strings.Count(str, "\n")
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		report(line, "Unclosed <%s> at end of data", stack[i])
+	}
+
+	return violations
+}
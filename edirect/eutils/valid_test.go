@@ -0,0 +1,92 @@
+package eutils
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// validateText runs ValidateStructure on a string and returns the report and number of violations
+func validateText(t *testing.T, xml, pattern string, allowed map[string]bool) (string, int) {
+
+	t.Helper()
+
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := os.Stdout
+	os.Stdout = wr
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(rd)
+		done <- string(data)
+	}()
+
+	num := ValidateStructure(CreateXMLStreamer(strings.NewReader(xml)), pattern, allowed, 0)
+
+	os.Stdout = saved
+	wr.Close()
+
+	countLines = false
+
+	return <-done, num
+}
+
+func TestValidateStructure(t *testing.T) {
+
+	tests := []struct {
+		name string
+		xml  string
+		want []string
+	}{
+		{"valid", "<Set>\n<Rec><Id>1</Id></Rec>\n</Set>\n", nil},
+		{"stray text on first line", "junk\n<Set>\n<Rec><Id>1</Id></Rec>\n</Set>\n",
+			[]string{"1\tText outside any element"}},
+		{"stray text before blank lines", "junk \n\n<Set>\n</Set>\n",
+			[]string{"1\tText outside any element"}},
+		{"indented text inside record", "<Set>\n<Rec>\n\n  A & B</Rec>\n</Set>\n",
+			[]string{"4\tUnescaped & in <Rec>"}},
+		{"text between elements after root", "<Set>\n</Set>trailing<Set/>\n",
+			[]string{"2\tText outside any element"}},
+		{"text after closing root", "<Set>\n<Rec><Id>1</Id></Rec>\n</Set>\n\ntrailing\n",
+			[]string{"5\tText outside any element"}},
+		{"text after root on same line", "<Set>\n</Set> trailing",
+			[]string{"2\tText outside any element"}},
+		{"incomplete tag after root", "<Set>\n</Set>\n\n<Rec",
+			[]string{"4\tIncomplete tag at end of data"}},
+		{"unclosed root", "<Set>\n<Rec><Id>1</Id></Rec>\n",
+			[]string{"2\tUnclosed <Set> at end of data"}},
+		{"mismatched end tag", "<Set>\n<Rec><Id>1</Rec>\n</Set>\n",
+			[]string{"2\tExpected </Id>, found </Rec>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, num := validateText(t, tt.xml, "Rec", nil)
+			var got []string
+			for _, str := range strings.Split(strings.TrimSpace(out), "\n") {
+				if str = strings.TrimSpace(str); str != "" {
+					got = append(got, str)
+				}
+			}
+			if num != len(tt.want) || strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %d violations %q, want %q", num, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStructureAllowed(t *testing.T) {
+
+	allowed := map[string]bool{"Rec": true, "Id": true}
+
+	out, num := validateText(t, "<Set>\n<Rec>\n<Id>1</Id>\n<Extra/>\n</Rec>\n<Other/>\n</Set>\n", "Rec", allowed)
+
+	if num != 1 || strings.TrimSpace(out) != "4\tUnexpected <Extra> in <Rec>" {
+		t.Errorf("got %d violations %q", num, out)
+	}
+}
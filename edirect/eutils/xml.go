@@ -35,18 +35,284 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"github.com/klauspost/pgzip"
 	"html"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// gzipSniffer defers the check for compressed data until the first read
+type gzipSniffer struct {
+	in    io.Reader
+	force bool
+	rdr   io.Reader
+}
+
+func (g *gzipSniffer) Read(p []byte) (int, error) {
+
+	if g.rdr == nil {
+
+		brd := bufio.NewReaderSize(g.in, 65536)
+		g.rdr = brd
+
+		if !g.force {
+			magic, err := brd.Peek(2)
+			if err != nil || len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+				return g.rdr.Read(p)
+			}
+		}
+
+		// pgzip reader is in multistream mode by default, so concatenated members are all decompressed
+		zpr, err := pgzip.NewReader(brd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create gzip decompressor - %s\n", err.Error())
+			os.Exit(1)
+		}
+		g.rdr = zpr
+	}
+
+	return g.rdr.Read(p)
+}
+
+// DecompressIfGzipped wraps input in a parallel gzip decompressor if forced, or if sniffing
+// finds the gzip magic number at the start of the data
+func DecompressIfGzipped(in io.Reader, force, sniff bool) io.Reader {
+
+	if in == nil || (!force && !sniff) {
+		return in
+	}
+
+	return &gzipSniffer{in: in, force: force}
+}
+
+// READ MULTIPLE INPUT FILES AS A SINGLE STREAM
+
+// ExpandInputFiles returns the sorted files in a directory, the matches to a glob pattern, or a single file name
+func ExpandInputFiles(name string) []string {
+
+	if name == "" {
+		return nil
+	}
+
+	inf, err := os.Stat(name)
+	if err == nil && !inf.IsDir() {
+		return []string{name}
+	}
+
+	var files []string
+
+	if err == nil && inf.IsDir() {
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to read input directory '%s'\n", name)
+			os.Exit(1)
+		}
+		for _, ent := range entries {
+			// skip subdirectories and hidden files
+			if ent.IsDir() || strings.HasPrefix(ent.Name(), ".") {
+				continue
+			}
+			files = append(files, filepath.Join(name, ent.Name()))
+		}
+	} else {
+		matches, err := filepath.Glob(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Bad input file pattern '%s'\n", name)
+			os.Exit(1)
+		}
+		for _, fname := range matches {
+			inf, err := os.Stat(fname)
+			if err == nil && !inf.IsDir() {
+				files = append(files, fname)
+			}
+		}
+	}
+
+	if len(files) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No input files found for '%s'\n", name)
+		os.Exit(1)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+// openInputFile returns a reader for one file, decompressing if forced or if gzip magic number is found
+func openInputFile(fname string, force, sniff bool) (io.Reader, func(), error) {
+
+	inFile, err := os.Open(fname)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	brd := bufio.NewReaderSize(inFile, 65536)
+
+	if !force {
+		magic, err := brd.Peek(2)
+		if !sniff || err != nil || len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+			return brd, func() { inFile.Close() }, nil
+		}
+	}
+
+	zpr, err := pgzip.NewReader(brd)
+	if err != nil {
+		inFile.Close()
+		return nil, nil, err
+	}
+
+	return zpr, func() { zpr.Close(); inFile.Close() }, nil
+}
+
+// CreateMultiFileReader concatenates decompressed files, in order, into one stream for CreateXMLStreamer.
+// With parallel greater than 1, up to that many files are streamed and decompressed ahead of the writer.
+// Unreadable files are reported and skipped, or are fatal if strict is set.
+func CreateMultiFileReader(files []string, force, sniff, strict bool, parallel int) io.Reader {
+
+	if len(files) < 1 {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+
+	report := func(fname string, err error) {
+		if strict {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to read input file '%s' - %s\n", fname, err.Error())
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "\nWARNING: Skipping input file '%s' - %s\n", fname, err.Error())
+	}
+
+	// readSequentially streams each file directly into the pipe
+	readSequentially := func() {
+
+		defer pw.Close()
+
+		for _, fname := range files {
+			rdr, cls, err := openInputFile(fname, force, sniff)
+			if err != nil {
+				report(fname, err)
+				continue
+			}
+			_, err = io.Copy(pw, rdr)
+			cls()
+			if err == io.ErrClosedPipe {
+				return
+			}
+			if err != nil {
+				report(fname, err)
+			}
+			// separate last record of one file from XML declaration of the next
+			pw.Write([]byte("\n"))
+		}
+	}
+
+	// fileChunk holds one block of decompressed data, or the error that ended a file
+	type fileChunk struct {
+		data []byte
+		err  error
+	}
+
+	// readInParallel decompresses files concurrently, but writes them in their original order
+	readInParallel := func() {
+
+		defer pw.Close()
+
+		// closing done stops the file readers if the consumer closes the pipe early
+		done := make(chan struct{})
+		defer close(done)
+
+		// each file can run a limited number of chunks ahead of the writer
+		results := make([]chan fileChunk, len(files))
+		for i := range results {
+			results[i] = make(chan fileChunk, 16)
+		}
+
+		send := func(res chan<- fileChunk, chk fileChunk) bool {
+			select {
+			case res <- chk:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		// semaphore limits the number of files open at once
+		sem := make(chan bool, parallel)
+
+		go func() {
+			for i, fname := range files {
+				select {
+				case sem <- true:
+				case <-done:
+					return
+				}
+				go func(fname string, res chan<- fileChunk) {
+
+					defer close(res)
+
+					rdr, cls, err := openInputFile(fname, force, sniff)
+					if err != nil {
+						send(res, fileChunk{err: err})
+						return
+					}
+					defer cls()
+
+					for {
+						buf := make([]byte, 65536)
+						n, err := rdr.Read(buf)
+						if n > 0 && !send(res, fileChunk{data: buf[:n]}) {
+							return
+						}
+						if err == io.EOF {
+							return
+						}
+						if err != nil {
+							send(res, fileChunk{err: err})
+							return
+						}
+					}
+				}(fname, results[i])
+			}
+		}()
+
+		for i, fname := range files {
+			for chk := range results[i] {
+				if chk.err != nil {
+					report(fname, chk.err)
+					continue
+				}
+				if _, err := pw.Write(chk.data); err != nil {
+					return
+				}
+			}
+			// separate last record of one file from XML declaration of the next
+			if _, err := pw.Write([]byte("\n")); err != nil {
+				return
+			}
+			<-sem
+		}
+	}
+
+	if parallel > 1 && len(files) > 1 {
+		go readInParallel()
+	} else {
+		go readSequentially()
+	}
+
+	return pr
+}
+
 // READ XML INPUT FILE INTO CHANNEL OF TRIMMED BLOCKS
 
 // XMLBlock is a string that begins with a left angle bracket and is trimmed back to
@@ -69,6 +335,9 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 		os.Exit(1)
 	}
 
+	// key for looking up the unterminated text left at end of input
+	key := (<-chan XMLBlock)(out)
+
 	// xmlReader sends trimmed XML blocks through the output channel.
 	xmlReader := func(in io.Reader, out chan<- XMLBlock) {
 
@@ -87,6 +356,14 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 		position := int64(0)
 		delta := 0
 		isClosed := false
+		fragment := ""
+
+		// noteFragment keeps unterminated text left at end of file for truncation diagnostics
+		noteFragment := func(str string) {
+			if strings.TrimSpace(str) != "" {
+				fragment = str
+			}
+		}
 
 		// htmlBehind is used in strict mode to trim back further when a lower-case tag
 		// is encountered. This may be a formatting decoration, such as <i> or </i> for
@@ -112,6 +389,8 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 		nextBuffer := func() ([]byte, bool, bool) {
 
 			if isClosed {
+				noteFragment(remainder)
+				remainder = ""
 				return nil, false, true
 			}
 
@@ -142,6 +421,7 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 				if n == 0 {
 					// if EOF and no more data, do not send final remainder (not terminated
 					// by right angle bracket that is used as a sentinel)
+					noteFragment(string(buffer[:m]))
 					return nil, false, true
 				}
 			}
@@ -216,6 +496,7 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 					line, cont, closed = nextBuffer()
 					if closed {
 						// no sentinel in multi-block buffer at end of file
+						noteFragment(buff.String())
 						return ""
 					}
 				}
@@ -243,6 +524,11 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 				}
 			}
 
+			if str == "" && fragment != "" {
+				// must be stored before the sentinel is sent
+				streamFragments.Store(key, fragment)
+			}
+
 			out <- XMLBlock(str)
 
 			// bail after sending empty string sentinel
@@ -260,30 +546,155 @@ func CreateXMLStreamer(in io.Reader) <-chan XMLBlock {
 
 // PARSE XML BLOCK STREAM INTO STRINGS FROM <PATTERN> TO </PATTERN>
 
+// streamFragments maps each CreateXMLStreamer channel to the text after its last > character,
+// stored before the final empty block is sent, and removed by PartitionXML at end of input
+var streamFragments sync.Map
+
+// XMLTruncation describes input that ended inside a record, inside an enclosing element,
+// or in the middle of a tag. Line is where the partial record starts, or else the line of
+// the text following the last complete tag. Element is the innermost unclosed element,
+// and is empty if input ended within a tag outside of any element.
+type XMLTruncation struct {
+	Record  bool
+	Line    int
+	Element string
+}
+
+func (t *XMLTruncation) Error() string {
+
+	if t.Record {
+		return fmt.Sprintf("Record starting at line %d truncated inside <%s>", t.Line, t.Element)
+	}
+
+	inner := "a tag"
+	if t.Element != "" {
+		inner = "<" + t.Element + ">"
+	}
+
+	return fmt.Sprintf("Input truncated inside %s after line %d", inner, t.Line)
+}
+
+// trackOpenElements updates a stack of unclosed element names with the tags in a string
+func trackOpenElements(stack []string, str string) []string {
+
+	for {
+		idx := strings.Index(str, "<")
+		if idx < 0 {
+			break
+		}
+		str = str[idx+1:]
+		if str == "" {
+			break
+		}
+
+		end := strings.Index(str, ">")
+		if end < 0 {
+			// truncated inside a tag
+			break
+		}
+		tag := str[:end]
+		str = str[end+1:]
+
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+		if tag[0] == '/' {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		if tag[len(tag)-1] == '/' {
+			continue
+		}
+		if sp := strings.IndexAny(tag, " \t\n\r"); sp > 0 {
+			tag = tag[:sp]
+		}
+		stack = append(stack, tag)
+	}
+
+	return stack
+}
+
+// innermostOpenElement returns the name of the deepest element left unclosed in a partial record
+func innermostOpenElement(str string) string {
+
+	stack := trackOpenElements(nil, str)
+	if len(stack) > 0 {
+		return stack[len(stack)-1]
+	}
+
+	return ""
+}
+
+// findTruncation checks for input that ended inside a record, inside an enclosing
+// set element, or in the middle of a tag
+func findTruncation(inPattern bool, line int, partial, pat string, open []string, fragment string) *XMLTruncation {
+
+	// line of the text following the last complete tag
+	if idx := strings.Index(fragment, "<"); idx > 0 && !inPattern {
+		line += strings.Count(fragment[:idx], "\n")
+	}
+
+	if inPattern || strings.HasPrefix(strings.TrimSpace(fragment), "<"+pat) {
+		partial += fragment
+		inner := innermostOpenElement(partial)
+		if inner == "" {
+			inner = pat
+		}
+		return &XMLTruncation{Record: true, Line: line, Element: inner}
+	}
+
+	open = trackOpenElements(open, fragment)
+	if len(open) > 0 {
+		return &XMLTruncation{Line: line, Element: open[len(open)-1]}
+	}
+	if fragment != "" {
+		return &XMLTruncation{Line: line}
+	}
+
+	return nil
+}
+
 // PartitionXML splits XML input from <pattern> to </pattern> and sends individual
 // records to a callback. Requiring the input to be an XMLBlock channel of trimmed
 // strings, generated by CreateXMLStreamer, simplifies the code by eliminating the
-// need to check for an incomplete object tag at the end.
-func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(string)) {
+// need to check for an incomplete object tag at the end. If input ends prematurely,
+// the partial record is not sent, and the truncation is returned.
+func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(string)) *XMLTruncation {
 
-	if pat == "" || inp == nil || proc == nil {
-		return
+	if proc == nil {
+		return nil
 	}
 
-	patlen := len(pat)
+	return partitionXML(pat, star, turbo, inp, false,
+		func(str string, outer map[string]string) {
+			proc(str)
+		})
+}
+
+// partitionXML optionally tracks xmlns declarations outside of -pattern objects,
+// and passes the declarations in effect at the start of each record to the callback
+func partitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, scoped bool, proc func(string, map[string]string)) *XMLTruncation {
 
-	// position of last character in pattern
-	last := patlen - 1
+	if pat == "" || inp == nil || proc == nil {
+		return nil
+	}
 
-	var skip [256]int
+	var trunc *XMLTruncation
 
-	// initialize Boyer-Moore-Horspool bad character displacement table
-	for i := range skip {
-		skip[i] = patlen
+	// trailingFragment returns the unterminated text left at end of input by the streamer
+	trailingFragment := func() string {
+		if frag, ok := streamFragments.LoadAndDelete(inp); ok {
+			return frag.(string)
+		}
+		return ""
 	}
-	for i := 0; i < last; i++ {
-		ch := pat[i]
-		skip[ch] = last - i
+
+	// -pattern can list alternative record names separated by commas, e.g., PubmedArticle,PubmedBookArticle
+	alts := []string{pat}
+	if star == "" && strings.Contains(pat, ",") {
+		alts = strings.Split(pat, ",")
 	}
 
 	// isAnElement checks surroundings of match candidate
@@ -299,53 +710,97 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 		return false
 	}
 
-	// findNextMatch is a modified Boyer-Moore-Horspool search function for maximum partitioning speed
-	findNextMatch := func(text string, offset int) (int, int, int) {
-
-		if text == "" {
-			return -1, -1, -1
-		}
+	// makeMatcher returns a modified Boyer-Moore-Horspool search function for maximum partitioning speed
+	makeMatcher := func(pat string) func(string, int) (int, int, int) {
 
-		txtlen := len(text)
+		patlen := len(pat)
 
-		max := txtlen - patlen
+		// position of last character in pattern
 		last := patlen - 1
 
-		i := offset
+		var skip [256]int
+
+		// initialize Boyer-Moore-Horspool bad character displacement table
+		for i := range skip {
+			skip[i] = patlen
+		}
+		for i := 0; i < last; i++ {
+			ch := pat[i]
+			skip[ch] = last - i
+		}
 
-		for i <= max {
+		return func(text string, offset int) (int, int, int) {
 
-			// start at right-most character
-			j := last
-			k := i + last
-			for j >= 0 && text[k] == pat[j] {
-				j--
-				k--
+			if text == "" {
+				return -1, -1, -1
 			}
 
-			// require match candidate to be element name, i.e.,
-			// <pattern ... >, </pattern ... >, or <pattern ... />
-			if j < 0 && isAnElement(text, i-1, i+patlen, txtlen) {
+			txtlen := len(text)
+
+			max := txtlen - patlen
+
+			i := offset
 
-				// find positions of flanking angle brackets
-				lf := i - 1
-				for lf > 0 && text[lf] != '<' {
-					lf--
+			for i <= max {
+
+				// start at right-most character
+				j := last
+				k := i + last
+				for j >= 0 && text[k] == pat[j] {
+					j--
+					k--
 				}
-				rt := i + patlen
-				for rt < txtlen && text[rt] != '>' {
-					rt++
+
+				// require match candidate to be element name, i.e.,
+				// <pattern ... >, </pattern ... >, or <pattern ... />
+				if j < 0 && isAnElement(text, i-1, i+patlen, txtlen) {
+
+					// find positions of flanking angle brackets
+					lf := i - 1
+					for lf > 0 && text[lf] != '<' {
+						lf--
+					}
+					rt := i + patlen
+					for rt < txtlen && text[rt] != '>' {
+						rt++
+					}
+					return i + 1, lf, rt + 1
 				}
-				return i + 1, lf, rt + 1
+
+				// find character in text above last character in pattern
+				ch := text[i+last]
+				// displacement table can shift pattern by one or more positions
+				i += skip[ch]
 			}
 
-			// find character in text above last character in pattern
-			ch := text[i+last]
-			// displacement table can shift pattern by one or more positions
-			i += skip[ch]
+			return -1, -1, -1
+		}
+	}
+
+	var matchers []func(string, int) (int, int, int)
+	for _, alt := range alts {
+		if alt != "" {
+			matchers = append(matchers, makeMatcher(alt))
+		}
+	}
+
+	// findNextMatch returns the earliest match of any alternative record name
+	findNextMatch := func(text string, offset int) (int, int, int) {
+
+		if len(matchers) == 1 {
+			return matchers[0](text, offset)
+		}
+
+		next, start, stop := -1, -1, -1
+
+		for _, mtchr := range matchers {
+			nx, st, sp := mtchr(text, offset)
+			if nx >= 0 && (next < 0 || nx < next) {
+				next, start, stop = nx, st, sp
+			}
 		}
 
-		return -1, -1, -1
+		return next, start, stop
 	}
 
 	// pattern type keys for XML parsing
@@ -393,6 +848,14 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 
 		var accumulator strings.Builder
 
+		// approximate line numbers and enclosing elements for truncation diagnostics
+		lineBase := 0
+		beginLine := 0
+		var enclosing []string
+
+		// xmlns declarations outside of records, copied when changed so each record keeps its own
+		var outer map[string]string
+
 		for {
 
 			match := noPat
@@ -401,9 +864,15 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 			next := 0
 
 			begin := 0
+			outside := 0
 
 			text := string(<-inp)
 			if text == "" {
+				if inPattern {
+					trunc = findTruncation(true, beginLine, accumulator.String(), pat, enclosing, trailingFragment())
+				} else {
+					trunc = findTruncation(false, lineBase+1, "", pat, enclosing, trailingFragment())
+				}
 				return
 			}
 
@@ -413,32 +882,46 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 					if level == 0 {
 						inPattern = true
 						begin = start
+						beginLine = lineBase + strings.Count(text[:start], "\n") + 1
+						enclosing = trackOpenElements(enclosing, text[outside:start])
+						if scoped {
+							outer = outerNamespaces(outer, text[outside:start])
+						}
 					}
 					level++
 				} else if match == stopPat {
 					level--
 					if level == 0 {
 						inPattern = false
+						outside = stop
 						accumulator.WriteString(text[begin:stop])
 						// read and process one -pattern object at a time
 						str := accumulator.String()
 						if str != "" {
-							proc(str[:])
+							proc(str[:], outer)
 						}
 						// reset accumulator
 						accumulator.Reset()
 					}
 				} else if match == selfPat {
 					if level == 0 {
+						enclosing = trackOpenElements(enclosing, text[outside:start])
+						if scoped {
+							outer = outerNamespaces(outer, text[outside:start])
+						}
+						outside = stop
 						str := text[start:stop]
 						if str != "" {
-							proc(str[:])
+							proc(str[:], outer)
 						}
 					}
 				} else {
 					if inPattern {
 						accumulator.WriteString(text[begin:])
+					} else if outside < len(text) {
+						enclosing = trackOpenElements(enclosing, text[outside:])
 					}
+					lineBase += strings.Count(text, "\n")
 					break
 				}
 			}
@@ -509,7 +992,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 						res := prev + rec
 						res = strings.TrimPrefix(res, "\n")
 						res = strings.TrimSuffix(res, "\n")
-						proc(res[:])
+						proc(res[:], nil)
 						break
 					}
 
@@ -524,7 +1007,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 						res := accumulator.String()
 						res = strings.TrimPrefix(res, "\n")
 						res = strings.TrimSuffix(res, "\n")
-						proc(res[:])
+						proc(res[:], nil)
 						return
 					}
 					// and keep going until desired size is collected
@@ -598,6 +1081,8 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 
 		text := ""
 
+		lineBase := 0
+
 		match := noPat
 		start := 0
 		stop := 0
@@ -608,6 +1093,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 
 			next = 0
 
+			lineBase += strings.Count(text, "\n")
 			text = string(<-inp)
 			if text == "" {
 				break
@@ -632,8 +1118,25 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 
 		var accumulator strings.Builder
 
+		// approximate line numbers for truncation diagnostics
+		beginLine := 0
+
 		last := ""
 
+		// parent element closed by </pattern>, only then is end of input expected
+		closedParent := false
+
+		// check for end of input inside an object or its parent
+		defer func() {
+			if inPattern {
+				trunc = findTruncation(true, beginLine, accumulator.String(), last, nil, trailingFragment())
+			} else if closedParent {
+				trunc = findTruncation(false, lineBase+1, "", last, nil, trailingFragment())
+			} else {
+				trunc = findTruncation(false, lineBase+1, "", last, []string{pat}, trailingFragment())
+			}
+		}()
+
 		// string search for inner objects
 		var scr *BMHSearcher
 
@@ -677,6 +1180,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 				begin = 0
 				next = 0
 
+				lineBase += strings.Count(text, "\n")
 				text = string(<-inp)
 				if text == "" {
 					break
@@ -695,6 +1199,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 				if match != stopPat {
 					return
 				}
+				closedParent = true
 				// now look for a new start <pattern> tag
 				for {
 					match, start, stop, next = nextPattern(text, next)
@@ -702,6 +1207,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 						break
 					}
 					next = 0
+					lineBase += strings.Count(text, "\n")
 					text = string(<-inp)
 					if text == "" {
 						break
@@ -710,6 +1216,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 				if match != startPat {
 					return
 				}
+				closedParent = false
 				// continue with processing loop
 				continue
 			}
@@ -729,6 +1236,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 					if level == 0 {
 						inPattern = true
 						begin = start
+						beginLine = lineBase + strings.Count(text[:start], "\n") + 1
 					}
 					level++
 				} else if match == stopPat {
@@ -739,7 +1247,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 						// read and process one -pattern/* object at a time
 						str := accumulator.String()
 						if str != "" {
-							proc(str[:])
+							proc(str[:], nil)
 						}
 						// reset accumulator
 						accumulator.Reset()
@@ -749,7 +1257,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 					if level == 0 {
 						str := text[start:stop]
 						if str != "" {
-							proc(str[:])
+							proc(str[:], nil)
 						}
 					}
 				} else {
@@ -760,6 +1268,7 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 					begin = 0
 					next = 0
 
+					lineBase += strings.Count(text, "\n")
 					text = string(<-inp)
 					if text == "" {
 						break
@@ -777,16 +1286,23 @@ func PartitionXML(pat, star string, turbo bool, inp <-chan XMLBlock, proc func(s
 	} else if star == "*" {
 		doStar()
 	}
+
+	return trunc
 }
 
 // XMLRecord wraps a numbered XML record or the results of data extraction on
 // that record. The Index field stores the record's original position in the
 // input stream. The Data field is used for binary compressed PubmedArticle XML.
+// CreateXMLProducer reports premature end of input in the Truncated field of an
+// extra final record with no text.
 type XMLRecord struct {
-	Index int
-	Ident string
-	Text  string
-	Data  []byte
+	Index     int
+	Ident     string
+	Text      string
+	Data      []byte
+	Truncated *XMLTruncation
+	// namespace context for parsing the record
+	scope *recordScope
 }
 
 // CreateXMLProducer partitions an XML set and sends records down a channel.
@@ -794,7 +1310,13 @@ type XMLRecord struct {
 // original order can be restored by passage through the XMLUnshuffler.
 func CreateXMLProducer(pat, star string, turbo bool, rdr <-chan XMLBlock) <-chan XMLRecord {
 
-	if rdr == nil {
+	return createXMLProducer(&cliRun, pat, star, turbo, rdr)
+}
+
+// createXMLProducer applies the record window, flow control, and -namespace settings of one run
+func createXMLProducer(run *xmlRun, pat, star string, turbo bool, rdr <-chan XMLBlock) <-chan XMLRecord {
+
+	if run == nil || rdr == nil {
 		return nil
 	}
 
@@ -812,12 +1334,45 @@ func CreateXMLProducer(pat, star string, turbo bool, rdr <-chan XMLBlock) <-chan
 
 		rec := 0
 
+		// -namespace mappings do not change during a run
+		mapped := run.namespaces
+
 		// partition all input by pattern and send XML substring to available consumer through channel
-		PartitionXML(pat, star, turbo, rdr,
-			func(str string) {
+		trunc := partitionXML(pat, star, turbo, rdr, mapped != nil,
+			func(str string, outer map[string]string) {
 				rec++
-				out <- XMLRecord{rec, "", str, nil}
+				if rec <= run.windowSkip {
+					// only records in the selected window are parsed and extracted
+					return
+				}
+				if run.flowWindow != nil {
+					// wait until the unshuffler has released an earlier record
+					run.flowWindow <- true
+				}
+				if maxRecordBytes > 0 && len(str) > maxRecordBytes {
+					// send empty record so the unshuffler still sees every index
+					recordLimitExceeded(rec, str, fmt.Sprintf("%d bytes exceeds -max-record-bytes %d", len(str), maxRecordBytes))
+					str = ""
+				}
+				var rs *recordScope
+				if mapped != nil {
+					rs = &recordScope{mapped: mapped, outer: outer}
+				}
+				out <- XMLRecord{Index: rec, Text: str, scope: rs}
+				if run.windowTake > 0 && rec >= run.windowSkip+run.windowTake {
+					// stop reading input once the window is complete, deferred close still runs
+					runtime.Goexit()
+				}
 			})
+
+		if trunc != nil {
+			// index follows the last complete record, or the skipped records, so the unshuffler sends it last
+			rec++
+			if rec <= run.windowSkip {
+				rec = run.windowSkip + 1
+			}
+			out <- XMLRecord{Index: rec, Truncated: trunc}
+		}
 	}
 
 	// launch single producer goroutine
@@ -826,19 +1381,130 @@ func CreateXMLProducer(pat, star string, turbo bool, rdr <-chan XMLBlock) <-chan
 	return out
 }
 
-// UNSHUFFLER USES HEAP TO RESTORE OUTPUT OF MULTIPLE CONSUMERS TO ORIGINAL RECORD ORDER
+// SETTINGS SHARED BY A PRODUCER AND ITS UNSHUFFLER
+
+// xmlRun holds the settings of one pass from producer to unshuffler, so that concurrent
+// ExtractStream calls do not depend on each other or on command-line settings
+type xmlRun struct {
+	// record window
+	windowSkip int
+	windowTake int
+	// limits the number of records between the producer and the unshuffler output
+	flowWindow chan bool
+	// prefix to URI mappings from -namespace arguments
+	namespaces map[string]string
+}
 
-// xmlRecordHeap collects asynchronous processing results for presentation in the original order.
-type xmlRecordHeap []XMLRecord
+// cliRun holds the settings made by SetRecordWindow, SetFlushOutput, and AddNamespace,
+// which apply to CreateXMLProducer, CreateXMLUnshuffler, and ProcessExtract
+var cliRun xmlRun
 
-// methods that satisfy heap.Interface
-func (h xmlRecordHeap) Len() int {
-	return len(h)
+// OPTIONAL RECORD WINDOW
+
+// SetRecordWindow limits processing to take records after the first skip records, with zero take
+// meaning no limit. Record indices keep their absolute positions. It must be called before
+// CreateXMLProducer.
+func SetRecordWindow(skip, take int) {
+
+	cliRun.windowSkip = skip
+	cliRun.windowTake = take
 }
-func (h xmlRecordHeap) Less(i, j int) bool {
-	return h[i].Index < h[j].Index
+
+// OPTIONAL RECORD SIZE LIMITS
+
+// record size guards applied by CreateXMLProducer and ProcessExtract
+var (
+	maxRecordBytes int
+	maxRecordNodes int
+	failOnLimit    bool
+)
+
+// recordTooLarge is returned by parseXML in place of an identifier when maxRecordNodes is exceeded
+const recordTooLarge = "-max-record-nodes"
+
+// SetRecordLimits skips records larger than bytes characters or nodes parsed nodes, with zero
+// meaning no limit, or makes them fatal errors if fail is set. It must be called before
+// CreateXMLProducer.
+func SetRecordLimits(bytes, nodes int, fail bool) {
+
+	maxRecordBytes = bytes
+	maxRecordNodes = nodes
+	failOnLimit = fail
 }
-func (h xmlRecordHeap) Swap(i, j int) {
+
+// recordIdentifier looks near the start of a record for a common identifier element
+func recordIdentifier(text string) string {
+
+	if len(text) > 65536 {
+		text = text[:65536]
+	}
+
+	for _, tag := range []string{"PMID", "Id", "Gene-track_geneid", "INSDSeq_accession-version", "Accession", "article-id"} {
+		pos := strings.Index(text, "<"+tag)
+		if pos < 0 {
+			continue
+		}
+		text = text[pos+len(tag)+1:]
+		if text == "" || (text[0] != '>' && text[0] != ' ') {
+			continue
+		}
+		pos = strings.Index(text, ">")
+		end := strings.Index(text, "<")
+		if pos < 0 || end <= pos {
+			continue
+		}
+		return tag + " " + strings.TrimSpace(text[pos+1:end])
+	}
+
+	return ""
+}
+
+// recordLimitExceeded warns about a skipped record, or exits if -fail-on-limit was requested
+func recordLimitExceeded(rec int, text, reason string) {
+
+	label := fmt.Sprintf("Record %d", rec)
+	if id := recordIdentifier(text); id != "" {
+		label += " (" + id + ")"
+	}
+
+	if failOnLimit {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s, %s\n", label, reason)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nWARNING: Skipping %s, %s\n", label, reason)
+}
+
+// OPTIONAL FLOW CONTROL FOR SLOW OUTPUT CONSUMERS
+
+// flushPerRecord writes each result to stdout as soon as it is in order
+var flushPerRecord bool
+
+// SetFlushOutput enables per-record output with a record window of four times the unshuffler heap size.
+// It must be called before CreateXMLProducer.
+func SetFlushOutput(flush bool) {
+
+	flushPerRecord = flush
+	cliRun.flowWindow = nil
+
+	if flush {
+		cliRun.flowWindow = make(chan bool, heapSize*4)
+	}
+}
+
+// UNSHUFFLER USES HEAP TO RESTORE OUTPUT OF MULTIPLE CONSUMERS TO ORIGINAL RECORD ORDER
+
+// xmlRecordHeap collects asynchronous processing results for presentation in the original order.
+type xmlRecordHeap []XMLRecord
+
+// methods that satisfy heap.Interface
+func (h xmlRecordHeap) Len() int {
+	return len(h)
+}
+func (h xmlRecordHeap) Less(i, j int) bool {
+	return h[i].Index < h[j].Index
+}
+func (h xmlRecordHeap) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
 }
 func (h *xmlRecordHeap) Push(x interface{}) {
@@ -856,7 +1522,13 @@ func (h *xmlRecordHeap) Pop() interface{} {
 // a heap, which releases results in the same order as the original records.
 func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 
-	if inp == nil {
+	return createXMLUnshuffler(&cliRun, inp)
+}
+
+// createXMLUnshuffler uses the record window and flow control of the run that produced the records
+func createXMLUnshuffler(run *xmlRun, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if run == nil || inp == nil {
 		return nil
 	}
 
@@ -876,8 +1548,8 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 		hp := &xmlRecordHeap{}
 		heap.Init(hp)
 
-		// index of next desired result
-		next := 1
+		// index of next desired result, skipped records are never sent
+		next := 1 + run.windowSkip
 
 		delay := 0
 
@@ -888,7 +1560,8 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 
 			// Read several values before checking to see if next record to print has been processed.
 			// The default heapSize value has been tuned by experiment for maximum performance.
-			if delay < heapSize {
+			// Flow control checks every time, since the window could otherwise stall the producer.
+			if delay < heapSize && run.flowWindow == nil {
 				delay++
 				continue
 			}
@@ -909,7 +1582,17 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 				}
 
 				// send even if empty to get all record counts for reordering
-				out <- XMLRecord{curr.Index, curr.Ident, curr.Text, curr.Data}
+				out <- curr
+
+				if progressOn {
+					atomic.AddInt64(&progressRecords, 1)
+				}
+
+				// allow producer to send another record, without blocking if record bypassed the window
+				select {
+				case <-run.flowWindow:
+				default:
+				}
 
 				// prevent ambiguous -limit filter from clogging heap (deprecated)
 				if curr.Index == next {
@@ -925,7 +1608,16 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 		for hp.Len() > 0 {
 			curr := heap.Pop(hp).(XMLRecord)
 
-			out <- XMLRecord{curr.Index, curr.Ident, curr.Text, curr.Data}
+			out <- curr
+
+			if progressOn {
+				atomic.AddInt64(&progressRecords, 1)
+			}
+
+			select {
+			case <-run.flowWindow:
+			default:
+			}
 		}
 	}
 
@@ -935,6 +1627,386 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 	return out
 }
 
+// CreateJSONSeparator places commas between the ordered -jsonpkg records
+func CreateJSONSeparator(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create JSON separator channel\n")
+		os.Exit(1)
+	}
+
+	// jsonSeparator holds back each non-empty record until it knows whether another one follows
+	jsonSeparator := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// close channel when all records have been processed
+		defer close(out)
+
+		var prev *XMLRecord
+
+		for curr := range inp {
+
+			if curr.Text == "" {
+				// send even if empty to get all record counts
+				out <- curr
+				continue
+			}
+
+			if prev != nil {
+				prev.Text = strings.TrimSuffix(prev.Text, "\n") + ",\n"
+				out <- *prev
+			}
+
+			rec := curr
+			prev = &rec
+		}
+
+		if prev != nil {
+			out <- *prev
+		}
+	}
+
+	// launch single separator goroutine
+	go jsonSeparator(inp, out)
+
+	return out
+}
+
+// CreateFeatureJoiner pairs -insd -joined feature rows within each record
+func CreateFeatureJoiner(features string, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create feature joiner channel\n")
+		os.Exit(1)
+	}
+
+	ftrs := strings.Split(features, ",")
+
+	// featureJoiner runs after the unshuffler, since rows for one record are all in the same result
+	featureJoiner := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// close channel when all records have been processed
+		defer close(out)
+
+		for curr := range inp {
+
+			if curr.Text != "" {
+				curr.Text = JoinINSDFeatures(curr.Text, ftrs)
+			}
+
+			out <- curr
+		}
+	}
+
+	// launch single joiner goroutine
+	go featureJoiner(inp, out)
+
+	return out
+}
+
+// OPTIONAL RECORD SORTING
+
+// -sort-records keys, with each consumer extracting one value per key block into Ident
+var (
+	sortKeyBlocks []*Block
+	sortNumeric   bool
+	sortReverse   bool
+	sortMemory    int
+)
+
+// sortKeySep joins multiple sort key values in Ident
+const sortKeySep = "\x1F"
+
+// SetRecordSort makes consumers record the first value of each key block, for ordering records with
+// CreateRecordSorter, which keeps about mem bytes of output in memory before spilling to temporary
+// files. It must be called before CreateXMLConsumers.
+func SetRecordSort(keys []*Block, numeric, reverse bool, mem int) {
+
+	sortKeyBlocks = keys
+	sortNumeric = numeric
+	sortReverse = reverse
+	sortMemory = mem
+
+	if sortMemory < 1 {
+		sortMemory = 256 << 20
+	}
+}
+
+// sortItem holds a record with its split sort keys and their numeric values
+type sortItem struct {
+	Index int
+	Ident string
+	Text  string
+	keys  []string
+	nums  []float64
+}
+
+func newSortItem(idx int, ident, text string) sortItem {
+
+	itm := sortItem{Index: idx, Ident: ident, Text: text}
+
+	if ident == "" {
+		return itm
+	}
+
+	itm.keys = strings.Split(ident, sortKeySep)
+
+	if sortNumeric {
+		itm.nums = make([]float64, len(itm.keys))
+		for i, ky := range itm.keys {
+			val, err := strconv.ParseFloat(ky, 64)
+			if err != nil {
+				val = math.NaN()
+			}
+			itm.nums[i] = val
+		}
+	}
+
+	return itm
+}
+
+// sortItemLess compares keys in order, placing missing or non-numeric keys last in either
+// direction, and breaks remaining ties by original record position
+func sortItemLess(a, b sortItem) bool {
+
+	for i := 0; i < len(sortKeyBlocks); i++ {
+
+		x, y := "", ""
+		if i < len(a.keys) {
+			x = a.keys[i]
+		}
+		if i < len(b.keys) {
+			y = b.keys[i]
+		}
+
+		if x == y {
+			continue
+		}
+		if x == "" || y == "" {
+			return y == ""
+		}
+
+		if sortNumeric {
+			fx, fy := a.nums[i], b.nums[i]
+			nx, ny := math.IsNaN(fx), math.IsNaN(fy)
+			if nx != ny {
+				return ny
+			}
+			if !nx {
+				if fx == fy {
+					continue
+				}
+				return (fx < fy) != sortReverse
+			}
+		}
+
+		return (x < y) != sortReverse
+	}
+
+	return a.Index < b.Index
+}
+
+// sortRun reads one sorted temporary run during the final merge
+type sortRun struct {
+	rdr  *bufio.Reader
+	curr sortItem
+}
+
+func (r *sortRun) next() bool {
+
+	line, err := r.rdr.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	// index, keys, and text length precede the text of each record
+	cols := strings.SplitN(strings.TrimSuffix(line, "\n"), "\t", 3)
+	if len(cols) != 3 {
+		return false
+	}
+	idx, _ := strconv.Atoi(cols[0])
+	num, _ := strconv.Atoi(cols[2])
+
+	buf := make([]byte, num)
+	if _, err := io.ReadFull(r.rdr, buf); err != nil {
+		return false
+	}
+
+	r.curr = newSortItem(idx, cols[1], string(buf))
+	return true
+}
+
+// sortRunHeap orders runs by their current record
+type sortRunHeap []*sortRun
+
+func (h sortRunHeap) Len() int {
+	return len(h)
+}
+func (h sortRunHeap) Less(i, j int) bool {
+	return sortItemLess(h[i].curr, h[j].curr)
+}
+func (h sortRunHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// Push works on pointer to sortRunHeap
+func (h *sortRunHeap) Push(x interface{}) {
+	*h = append(*h, x.(*sortRun))
+}
+
+// Pop works on pointer to sortRunHeap
+func (h *sortRunHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// CreateRecordSorter holds extraction results until the end of input, then sends them in sort key
+// order, using an external merge sort once buffered output exceeds the -sort-mem threshold
+func CreateRecordSorter(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create record sorter channel\n")
+		os.Exit(1)
+	}
+
+	// xmlSorter buffers, sorts, and merges records
+	xmlSorter := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		var items []sortItem
+		var runs []string
+
+		size := 0
+		tmpDir := ""
+
+		sortItems := func() {
+			sort.Slice(items, func(i, j int) bool { return sortItemLess(items[i], items[j]) })
+		}
+
+		// spill writes the current records as a sorted run to keep memory bounded
+		spill := func() {
+
+			if len(items) < 1 {
+				return
+			}
+
+			if tmpDir == "" {
+				dir, err := os.MkdirTemp("", "xtract-sort-")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unable to create temporary directory for -sort-records\n")
+					os.Exit(1)
+				}
+				tmpDir = dir
+			}
+
+			sortItems()
+
+			fpath := filepath.Join(tmpDir, "run"+strconv.Itoa(len(runs)))
+			fl, err := os.Create(fpath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create sort run '%s'\n", fpath)
+				os.Exit(1)
+			}
+
+			wrtr := bufio.NewWriter(fl)
+			for _, itm := range items {
+				wrtr.WriteString(strconv.Itoa(itm.Index))
+				wrtr.WriteString("\t")
+				wrtr.WriteString(itm.Ident)
+				wrtr.WriteString("\t")
+				wrtr.WriteString(strconv.Itoa(len(itm.Text)))
+				wrtr.WriteString("\n")
+				wrtr.WriteString(itm.Text)
+			}
+			if err := wrtr.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to write sort run '%s'\n", fpath)
+				os.Exit(1)
+			}
+			fl.Close()
+
+			runs = append(runs, fpath)
+			items = nil
+			size = 0
+
+			debug.FreeOSMemory()
+		}
+
+		for curr := range inp {
+
+			items = append(items, newSortItem(curr.Index, curr.Ident, curr.Text))
+
+			size += len(curr.Text) + len(curr.Ident) + 64
+			if size >= sortMemory {
+				spill()
+			}
+		}
+
+		if len(runs) == 0 {
+
+			// everything fit in memory
+			sortItems()
+			for _, itm := range items {
+				out <- XMLRecord{Index: itm.Index, Text: itm.Text}
+			}
+			return
+		}
+
+		spill()
+
+		defer os.RemoveAll(tmpDir)
+
+		// merge sorted runs
+		hp := &sortRunHeap{}
+		heap.Init(hp)
+
+		for _, fpath := range runs {
+			fl, err := os.Open(fpath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to open sort run '%s'\n", fpath)
+				os.Exit(1)
+			}
+			defer fl.Close()
+
+			run := &sortRun{rdr: bufio.NewReader(fl)}
+			if run.next() {
+				heap.Push(hp, run)
+			}
+		}
+
+		for hp.Len() > 0 {
+			run := heap.Pop(hp).(*sortRun)
+			out <- XMLRecord{Index: run.curr.Index, Text: run.curr.Text}
+			if run.next() {
+				heap.Push(hp, run)
+			}
+		}
+	}
+
+	// launch single sorter goroutine
+	go xmlSorter(inp, out)
+
+	return out
+}
+
 // CONCURRENT CONSUMER GOROUTINES PARSE AND PROCESS PARTITIONED XML OBJECTS
 
 // StreamBlocks -> SplitPattern => XmlParse => StreamTokens => ProcessExtract -> MergeResults
@@ -959,8 +2031,20 @@ func CreateXMLConsumers(cmds *Block, parent, hd, tl string, transform map[string
 
 	if forClassify {
 		var patterns []string
-		for ky := range transform {
+		normal := make(map[string]string)
+		for ky, vl := range transform {
 			patterns = append(patterns, ky)
+			// searcher reports lower-case relaxed phrases, also index the table by that form
+			nm := strings.TrimSpace(RelaxString(strings.ToLower(ky)))
+			if prev, ok := normal[nm]; ok && prev != vl {
+				vl = prev + "," + vl
+			}
+			normal[nm] = vl
+		}
+		for nm, vl := range normal {
+			if _, ok := transform[nm]; !ok {
+				transform[nm] = vl
+			}
 		}
 		// initialize string searcher from transform table
 		srchr = PatternSearcher(patterns, false, true, true, false, false)
@@ -980,15 +2064,26 @@ func CreateXMLConsumers(cmds *Block, parent, hd, tl string, transform map[string
 			text := ext.Text
 
 			if text == "" {
-				// should never see empty input data
-				out <- XMLRecord{Index: idx, Ident: ident, Text: text}
+				// should never see empty input data, except for a truncation report
+				out <- ext
 				continue
 			}
 
-			str := ProcessExtract(text[:], parent, idx, hd, tl, transform, srchr, histogram, cmds)
+			str := processExtract(text[:], parent, idx, hd, tl, transform, srchr, histogram, cmds, ext.scope)
+
+			// record -sort-records keys for records with output
+			if sortKeyBlocks != nil && str != "" {
+				ident = recordSortKey(text[:], parent, idx, ext.scope)
+			}
+
+			// carry raw record along for -tee
+			var data []byte
+			if teeRecords && str != "" {
+				data = []byte(text)
+			}
 
 			// send even if empty to get all record counts for reordering
-			out <- XMLRecord{Index: idx, Ident: ident, Text: str}
+			out <- XMLRecord{Index: idx, Ident: ident, Text: str, Data: data}
 		}
 	}
 
@@ -1009,6 +2104,73 @@ func CreateXMLConsumers(cmds *Block, parent, hd, tl string, transform map[string
 	return out
 }
 
+// teeRecords keeps the raw text of each record that produces output
+var teeRecords bool
+
+// SetRecordTee makes consumers pass raw record text to CreateRecordTee. It must be called before CreateXMLConsumers.
+func SetRecordTee(tee bool) {
+
+	teeRecords = tee
+}
+
+// CreateRecordTee appends the raw XML of each record with output to a file, in restored record order,
+// framed by optional header and trailer lines
+func CreateRecordTee(fname, head, tail string, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if fname == "" || inp == nil {
+		return nil
+	}
+
+	fl, err := os.OpenFile(fname, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open tee file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create tee channel\n")
+		os.Exit(1)
+	}
+
+	// recordTee is the only writer, so records are never interleaved
+	recordTee := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		defer close(out)
+		defer fl.Close()
+
+		wrtr := bufio.NewWriter(fl)
+		defer wrtr.Flush()
+
+		if head != "" {
+			wrtr.WriteString(head)
+			wrtr.WriteString("\n")
+		}
+
+		for curr := range inp {
+
+			if curr.Data != nil {
+				wrtr.Write(curr.Data)
+				if curr.Data[len(curr.Data)-1] != '\n' {
+					wrtr.WriteString("\n")
+				}
+				curr.Data = nil
+			}
+
+			out <- curr
+		}
+
+		if tail != "" {
+			wrtr.WriteString(tail)
+			wrtr.WriteString("\n")
+		}
+	}
+
+	go recordTee(inp, out)
+
+	return out
+}
+
 // -select SUPPORT FUNCTIONS
 
 // CreateSelectors supports xtract -select parent/element@attribute^version -in file_of_identifiers
@@ -1048,36 +2210,233 @@ func CreateSelectors(parent, indx string, order map[string]bool, inp <-chan XMLR
 					}
 				})
 
-			if !found {
-				// identifier field not found or not in identifier list, send empty placeholder for unshuffler
-				out <- XMLRecord{Index: ext.Index, Ident: ext.Ident}
-				continue
-			}
+			if !found {
+				// identifier field not found or not in identifier list, send empty placeholder for unshuffler
+				out <- XMLRecord{Index: ext.Index, Ident: ext.Ident}
+				continue
+			}
+
+			// send selected record
+			out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: text}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple selector goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlSelector(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all selectors are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// CreateUnicoders supports xtract -pattern record_name -select -nonascii
+func CreateUnicoders(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create selector channel\n")
+		os.Exit(1)
+	}
+
+	// xmlUnicoder reads partitioned XML from channel and keeps records with non-ASCII characters
+	xmlUnicoder := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// report when this selector has no more records to process
+		defer wg.Done()
+
+		// read partitioned XML from producer channel
+		for ext := range inp {
+
+			text := ext.Text
+
+			if !IsNotASCII(text) {
+				// if only ASCII, send empty placeholder for unshuffler
+				out <- XMLRecord{Index: ext.Index, Ident: ext.Ident}
+				continue
+			}
+
+			// send selected record
+			out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: text}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple unicoder goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlUnicoder(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all unicoders are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// firstIdentifier returns the first identifier in the record, or an empty string if not present
+func firstIdentifier(text, parent string, find *XMLFind) string {
+
+	key := ""
+
+	FindIdentifiers(text[:], parent, find,
+		func(id string) {
+			if key == "" {
+				key = id
+			}
+		})
+
+	return key
+}
+
+// CreateDeduplicator supports xtract -dedup-by and -dedup-last, removing records with repeated identifiers
+func CreateDeduplicator(parent, indx string, keepLast bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if parent == "" || indx == "" || inp == nil {
+		return nil
+	}
+
+	find := ParseIndex(indx)
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create deduplicator channel\n")
+		os.Exit(1)
+	}
+
+	recordKey := func(text string) string {
+		return firstIdentifier(text, parent, find)
+	}
+
+	// xmlDeduplicator runs as a single goroutine on producer output, which is still in original order
+	xmlDeduplicator := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// close channel when all records have been processed
+		defer close(out)
+
+		if !keepLast {
+
+			seen := make(map[string]bool)
+
+			for ext := range inp {
+
+				key := recordKey(ext.Text)
+
+				if key != "" {
+					if seen[key] {
+						// send empty placeholder for unshuffler
+						out <- XMLRecord{Index: ext.Index, Ident: ext.Ident}
+						continue
+					}
+					seen[key] = true
+				}
+
+				out <- ext
+			}
+
+			return
+		}
+
+		// keeping the last occurrence means any keyed record might still be superseded, so it is held until the
+		// end of the input, but an earlier duplicate is reduced to a placeholder as soon as its successor arrives,
+		// so memory grows with the number of distinct keys rather than the number of records
+		var pending []XMLRecord
+		var keys []string
+		last := make(map[string]int)
+
+		// first is the position in the original stream of pending[0]
+		first := 0
+
+		for ext := range inp {
+
+			key := recordKey(ext.Text)
+
+			if key != "" {
+				if prev, found := last[key]; found {
+					// drop text of superseded record, but keep its slot for the unshuffler
+					old := pending[prev-first]
+					pending[prev-first] = XMLRecord{Index: old.Index, Ident: old.Ident}
+					keys[prev-first] = ""
+				}
+				last[key] = first + len(pending)
+			}
+
+			pending = append(pending, ext)
+			keys = append(keys, key)
+
+			// records before the first live keyed record can be sent immediately
+			for len(pending) > 0 && keys[0] == "" {
+				out <- pending[0]
+				pending[0] = XMLRecord{}
+				pending = pending[1:]
+				keys = keys[1:]
+				first++
+			}
+		}
+
+		for _, ext := range pending {
+			out <- ext
+		}
+	}
+
+	// launch single deduplicator goroutine
+	go xmlDeduplicator(inp, out)
+
+	return out
+}
+
+// CreateRecordKeyer supports xtract -join-records, saving the first identifier of each record in Ident
+func CreateRecordKeyer(parent, indx string, inp <-chan XMLRecord) <-chan XMLRecord {
 
-			// send selected record
-			out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: text}
-		}
+	if parent == "" || indx == "" || inp == nil {
+		return nil
 	}
 
-	var wg sync.WaitGroup
+	find := ParseIndex(indx)
 
-	// launch multiple selector goroutines
-	for i := 0; i < NumServe(); i++ {
-		wg.Add(1)
-		go xmlSelector(&wg, inp, out)
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create record keyer channel\n")
+		os.Exit(1)
 	}
 
-	// launch separate anonymous goroutine to wait until all selectors are done
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
+	// xmlKeyer runs as a single goroutine on producer output
+	xmlKeyer := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// close channel when all records have been processed
+		defer close(out)
+
+		for ext := range inp {
+			ext.Ident = firstIdentifier(ext.Text, parent, find)
+			out <- ext
+		}
+	}
+
+	// launch single keyer goroutine
+	go xmlKeyer(inp, out)
 
 	return out
 }
 
-// CreateUnicoders supports xtract -pattern record_name -select -nonascii
-func CreateUnicoders(inp <-chan XMLRecord) <-chan XMLRecord {
+// CreateRecordJoiner merges tab-delimited output rows of records with the same Ident, in order of first
+// occurrence, joining differing values in each column with sep and keeping identical values once
+func CreateRecordJoiner(sep string, inp <-chan XMLRecord) <-chan XMLRecord {
 
 	if inp == nil {
 		return nil
@@ -1085,51 +2444,106 @@ func CreateUnicoders(inp <-chan XMLRecord) <-chan XMLRecord {
 
 	out := make(chan XMLRecord, ChanDepth())
 	if out == nil {
-		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create selector channel\n")
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create record joiner channel\n")
 		os.Exit(1)
 	}
 
-	// xmlUnicoder reads partitioned XML from channel and keeps records with non-ASCII characters
-	xmlUnicoder := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+	// xmlJoiner holds one merged row per key until the end of input
+	xmlJoiner := func(inp <-chan XMLRecord, out chan<- XMLRecord) {
 
-		// report when this selector has no more records to process
-		defer wg.Done()
+		// close channel when all records have been processed
+		defer close(out)
 
-		// read partitioned XML from producer channel
-		for ext := range inp {
+		// distinct values of each column, in order of appearance
+		var rows [][][]string
 
-			text := ext.Text
+		slot := make(map[string]int)
 
-			if !IsNotASCII(text) {
-				// if only ASCII, send empty placeholder for unshuffler
-				out <- XMLRecord{Index: ext.Index, Ident: ext.Ident}
+		for curr := range inp {
+
+			str := strings.TrimSuffix(curr.Text, "\n")
+			if str == "" {
 				continue
 			}
 
-			// send selected record
-			out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: text}
+			cols := strings.Split(str, "\t")
+
+			// records without an identifier are not merged
+			pos, found := slot[curr.Ident]
+			if !found || curr.Ident == "" {
+				pos = len(rows)
+				rows = append(rows, nil)
+				if curr.Ident != "" {
+					slot[curr.Ident] = pos
+				}
+			}
+
+			row := rows[pos]
+			for len(row) < len(cols) {
+				row = append(row, nil)
+			}
+
+			for i, val := range cols {
+				if val == "" {
+					continue
+				}
+				dup := false
+				for _, prev := range row[i] {
+					if prev == val {
+						dup = true
+						break
+					}
+				}
+				if !dup {
+					row[i] = append(row[i], val)
+				}
+			}
+
+			rows[pos] = row
 		}
-	}
 
-	var wg sync.WaitGroup
+		for i, row := range rows {
 
-	// launch multiple unicoder goroutines
-	for i := 0; i < NumServe(); i++ {
-		wg.Add(1)
-		go xmlUnicoder(&wg, inp, out)
+			var buffer strings.Builder
+
+			for j, vals := range row {
+				if j > 0 {
+					buffer.WriteString("\t")
+				}
+				buffer.WriteString(strings.Join(vals, sep))
+			}
+			buffer.WriteString("\n")
+
+			out <- XMLRecord{Index: i + 1, Text: buffer.String()}
+		}
 	}
 
-	// launch separate anonymous goroutine to wait until all unicoders are done
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
+	// launch single joiner goroutine
+	go xmlJoiner(inp, out)
 
 	return out
 }
 
 // DRAIN OUTPUT CHANNEL TO EXECUTE EXTRACTION COMMANDS, RESTORE OUTPUT ORDER WITH HEAP
 
+// -suppress-empty defers -head until the first output, and records whether anything was written
+var (
+	suppressEmpty bool
+	emptyOutput   bool
+)
+
+// SetSuppressEmpty holds -head until a record produces output, so no wrapper is printed for an empty result
+func SetSuppressEmpty(suppress bool) {
+
+	suppressEmpty = suppress
+}
+
+// EmptyOutput reports that DrainExtractions wrote no record output
+func EmptyOutput() bool {
+
+	return emptyOutput
+}
+
 // DrainExtractions reads from the unshuffler and writes XML extraction output,
 // for xtract and for rchive -e2index if used without -e2invert
 func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[string]int, inp <-chan XMLRecord) (int, int) {
@@ -1148,6 +2562,14 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 
 	wrtr := bufio.NewWriter(os.Stdout)
 
+	// deferred -head is written just before the first output
+	writeHead := func() {
+		if !okay && suppressEmpty && head != "" {
+			buffer.WriteString(head[:])
+			buffer.WriteString("\n")
+		}
+	}
+
 	// printResult prints output for current pattern, handles -empty and -ident flags, and periodically flushes buffer
 	printResult := func(curr XMLRecord) {
 
@@ -1157,6 +2579,7 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 
 			if str == "" {
 
+				writeHead()
 				okay = true
 
 				idx := curr.Index
@@ -1169,6 +2592,7 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 
 		} else if str != "" {
 
+			writeHead()
 			okay = true
 
 			if idnt {
@@ -1188,19 +2612,22 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 		duration := thisTime.Sub(lastTime)
 		milliSeconds := duration.Milliseconds()
 
-		if count > 1000 || milliSeconds > 4999 {
+		if count > 1000 || milliSeconds > 4999 || flushPerRecord {
 			count = 0
 			lastTime = thisTime
 			txt := buffer.String()
 			if txt != "" {
 				// print current buffer
 				wrtr.WriteString(txt[:])
+				if flushPerRecord {
+					wrtr.Flush()
+				}
 			}
 			buffer.Reset()
 		}
 	}
 
-	if head != "" {
+	if head != "" && !suppressEmpty {
 		buffer.WriteString(head[:])
 		buffer.WriteString("\n")
 	}
@@ -1295,11 +2722,13 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 		}
 	}
 
-	if tail != "" {
+	if tail != "" && okay {
 		buffer.WriteString(tail[:])
 		buffer.WriteString("\n")
 	}
 
+	emptyOutput = !okay
+
 	// do not print head or tail if no extraction output
 	if okay {
 		txt := buffer.String()
@@ -1313,44 +2742,105 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 	wrtr.Flush()
 
 	// print -histogram results, if populated
+	PrintHistogram(histogram, 0, false)
+
+	// force garbage collection and return memory before calculating processing rate
+	debug.FreeOSMemory()
+
+	return recordCount, byteCount
+}
+
+// sortedByCount returns histogram keys by decreasing count, with ties in alphabetical or numeric order,
+// truncated to the first topn keys if topn is positive
+func sortedByCount(histogram map[string]int, topn int) []string {
+
 	var keys []string
 	for ky := range histogram {
 		keys = append(keys, ky)
 	}
-	if len(keys) > 0 {
-		// sort fields in alphabetical or numeric order
-		sort.Slice(keys, func(i, j int) bool {
-			// numeric sort on strings checks lengths first
-			if IsAllDigits(keys[i]) && IsAllDigits(keys[j]) {
-				lni := len(keys[i])
-				lnj := len(keys[j])
-				// shorter string is numerically less, assuming no leading zeros
-				if lni < lnj {
-					return true
-				}
-				if lni > lnj {
-					return false
-				}
+
+	sort.Slice(keys, func(i, j int) bool {
+		ci := histogram[keys[i]]
+		cj := histogram[keys[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		// numeric sort on strings checks lengths first
+		if IsAllDigits(keys[i]) && IsAllDigits(keys[j]) {
+			lni := len(keys[i])
+			lnj := len(keys[j])
+			// shorter string is numerically less, assuming no leading zeros
+			if lni != lnj {
+				return lni < lnj
 			}
-			// same length or non-numeric, can now do string comparison on contents
-			return keys[i] < keys[j]
-		})
+		}
+		// same length or non-numeric, can now do string comparison on contents
+		return keys[i] < keys[j]
+	})
+
+	if topn > 0 && len(keys) > topn {
+		keys = keys[:topn]
+	}
+
+	return keys
+}
+
+// PrintHistogram prints -histogram results, count then value, sorted by decreasing count,
+// optionally as Term objects with count attributes
+func PrintHistogram(histogram map[string]int, topn int, asXML bool) {
+
+	keys := sortedByCount(histogram, topn)
+	if len(keys) < 1 {
+		return
+	}
+
+	wrtr := bufio.NewWriter(os.Stdout)
+
+	if asXML {
+		wrtr.WriteString("<Histogram>\n")
+	}
+
+	for _, str := range keys {
 
-		for _, str := range keys {
+		val := strconv.Itoa(histogram[str])
 
-			count := histogram[str]
-			val := strconv.Itoa(count)
-			os.Stdout.WriteString(val)
-			os.Stdout.WriteString("\t")
-			os.Stdout.WriteString(str)
-			os.Stdout.WriteString("\n")
+		if asXML {
+			wrtr.WriteString("  <Term count=\"")
+			wrtr.WriteString(val)
+			wrtr.WriteString("\">")
+			wrtr.WriteString(html.EscapeString(str))
+			wrtr.WriteString("</Term>\n")
+			continue
 		}
+
+		wrtr.WriteString(val)
+		wrtr.WriteString("\t")
+		wrtr.WriteString(str)
+		wrtr.WriteString("\n")
 	}
 
-	// force garbage collection and return memory before calculating processing rate
-	debug.FreeOSMemory()
+	if asXML {
+		wrtr.WriteString("</Histogram>\n")
+	}
 
-	return recordCount, byteCount
+	wrtr.Flush()
+}
+
+// PrintGroupCounts prints -group-by results, sorted by decreasing count, with ties in alphabetical order
+func PrintGroupCounts(histogram map[string]int, topn int) {
+
+	keys := sortedByCount(histogram, topn)
+
+	wrtr := bufio.NewWriter(os.Stdout)
+
+	for _, str := range keys {
+		wrtr.WriteString(str)
+		wrtr.WriteString("\t")
+		wrtr.WriteString(strconv.Itoa(histogram[str]))
+		wrtr.WriteString("\n")
+	}
+
+	wrtr.Flush()
 }
 
 // PARSE XML INTO TOKENS, IDENTIFIERS, OR STRUCTURED RECORD OBJECT
@@ -1399,8 +2889,11 @@ type XMLNode struct {
 	Contents   string
 	Attributes string
 	Attribs    []string
+	Namespaces map[string]string
 	Children   *XMLNode
 	Next       *XMLNode
+	// prefix to URI mappings from -namespace arguments
+	mapped map[string]string
 }
 
 // XMLFind contains individual field values for finding a particular object
@@ -1425,6 +2918,12 @@ type XMLToken struct {
 // ParseAttributes produces tag/value pairs, only run on request
 func ParseAttributes(attrb string) []string {
 
+	return parseAttributesInto(attrb, nil)
+}
+
+// parseAttributesInto produces tag/value pairs, reusing the array from a recycled node if large enough
+func parseAttributesInto(attrb string, arry []string) []string {
+
 	if attrb == "" {
 		return nil
 	}
@@ -1450,9 +2949,10 @@ func ParseAttributes(attrb string) []string {
 	}
 
 	// allocate array of proper size
-	arry := make([]string, num)
-	if arry == nil {
-		return nil
+	if cap(arry) >= num {
+		arry = arry[:num]
+	} else {
+		arry = make([]string, num)
 	}
 
 	start := 0
@@ -1517,8 +3017,9 @@ func ParseAttributes(attrb string) []string {
 
 // parseXML calls XML parser on a partitioned string or on an XMLBlock channel of trimmed strings.
 // It is optimized for maximum processing speed, sends tokens for CDATA and COMMENT sections (for
-// unpacking by NormalizeXML), and optionally tracks line numbers (for ValidateXML).
-func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken), find *XMLFind, ids func(string)) (*XMLNode, string) {
+// unpacking by NormalizeXML), and optionally tracks line numbers (for ValidateXML). If farms is
+// not nil, node arrays are taken from a pool and recorded so the caller can release them.
+func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken), find *XMLFind, ids func(string), farms *[]*[]XMLNode) (*XMLNode, string) {
 
 	if record == "" && (inp == nil || tokens == nil) {
 		return nil, ""
@@ -2042,14 +3543,41 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 	// node farm variables
 	farmPos := 0
 	farmMax := farmSize
-	farmItems := make([]XMLNode, farmMax)
+
+	// node count guard only applies to pooled extraction parsing
+	nodeLimit := 0
+	if farms != nil {
+		nodeLimit = maxRecordNodes
+	}
+	nodeCount := 0
+	overLimit := false
+
+	// newFarm allocates a node array, or obtains a recycled one from the pool
+	newFarm := func() []XMLNode {
+		if farms == nil {
+			return make([]XMLNode, farmMax)
+		}
+		fp := getNodeFarm(farmMax)
+		*farms = append(*farms, fp)
+		return *fp
+	}
+
+	farmItems := newFarm()
+
+	// countNode applies the -max-record-nodes guard, which also covers self-closing tags that are not kept
+	countNode := func() {
+		nodeCount++
+		if nodeLimit > 0 && nodeCount > nodeLimit {
+			overLimit = true
+		}
+	}
 
 	// nextNode allocates multiple nodes in a large array for memory management efficiency
 	nextNode := func(strt, attr, prnt string) *XMLNode {
 
 		// if farm array slots used up, allocate new array
 		if farmPos >= farmMax {
-			farmItems = make([]XMLNode, farmMax)
+			farmItems = newFarm()
 			farmPos = 0
 		}
 
@@ -2066,6 +3594,8 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 
 		farmPos++
 
+		countNode()
+
 		return node
 	}
 
@@ -2090,6 +3620,10 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 
 		status := START
 		for {
+			if overLimit {
+				// abandon oversized record
+				return node, false
+			}
 			tag, _, name, attr, idx := nextToken(Idx)
 			Idx = idx
 
@@ -2134,6 +3668,7 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 			case SELFTAG:
 				if attr == "" && !doSelf {
 					// ignore if self-closing tag has no attributes
+					countNode()
 					continue
 				}
 
@@ -2181,6 +3716,10 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 
 		status := START
 		for {
+			if overLimit {
+				// abandon oversized record
+				return node, false
+			}
 			tag, ctype, name, attr, idx := nextToken(Idx)
 			Idx = idx
 
@@ -2256,6 +3795,7 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 			case SELFTAG:
 				if attr == "" && !doSelf {
 					// ignore if self-closing tag has no attributes
+					countNode()
 					continue
 				}
 
@@ -2378,9 +3918,22 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 			tag, ctype, name, attr, idx := nextToken(Idx)
 			Idx = idx
 
+			line := 0
+			if countLines && tag == CONTENTTAG {
+				// report contents at the line of the first visible character, not where they end
+				pos := lag
+				for pos < idx && inBlank[record[pos]] {
+					pos++
+				}
+				line = currentLineCount(pos)
+			}
+
 			if countLines && Idx > 0 {
 				updateLineCount(Idx)
 			}
+			if line == 0 {
+				line = lineNum
+			}
 
 			if tag == BADTAG {
 				if countLines {
@@ -2391,7 +3944,7 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 				break
 			}
 
-			tkn := XMLToken{tag, ctype, name, attr, idx, lineNum}
+			tkn := XMLToken{tag, ctype, name, attr, idx, line}
 
 			tokens(tkn)
 
@@ -2462,21 +4015,21 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 		tag, _, name, attr, idx = nextToken(Idx)
 	}
 
+	var top *XMLNode
+	ok := false
+
 	if contentMods {
 		// slower parser also handles mixed content
-		top, ok := parseLevel(name, attr, parent)
-
-		if !ok {
-			return nil, ""
-		}
-
-		return top, ""
+		top, ok = parseLevel(name, attr, parent)
+	} else {
+		// fastest parsing with no contentMods flags
+		top, ok = parseSpecial(name, attr, parent)
 	}
 
-	// fastest parsing with no contentMods flags
-	top, ok := parseSpecial(name, attr, parent)
-
 	if !ok {
+		if overLimit {
+			return nil, recordTooLarge
+		}
 		return nil, ""
 	}
 
@@ -2488,15 +4041,62 @@ func parseXML(record, parent string, inp <-chan XMLBlock, tokens func(XMLToken),
 // ParseRecord is the main public access to parseXML
 func ParseRecord(text, parent string) *XMLNode {
 
-	pat, _ := parseXML(text, parent, nil, nil, nil, nil)
+	pat, _ := parseXML(text, parent, nil, nil, nil, nil, nil)
+
+	applyNamespaces(pat, defaultScope())
 
 	return pat
 }
 
+// nodeFarmPool recycles node arrays between records to reduce garbage collection
+var nodeFarmPool sync.Pool
+
+// getNodeFarm returns a cleared node array from the pool, or a new one
+func getNodeFarm(size int) *[]XMLNode {
+
+	fp, ok := nodeFarmPool.Get().(*[]XMLNode)
+	if ok && len(*fp) == size {
+		return fp
+	}
+
+	farm := make([]XMLNode, size)
+	return &farm
+}
+
+// parsePooledRecord parses with recycled node arrays, and returns a function that releases them
+// back to the pool, and whether the node limit was exceeded. No node, or slice of node attributes,
+// may be used after release is called.
+func parsePooledRecord(text, parent string, rs *recordScope) (*XMLNode, func(), bool) {
+
+	var farms []*[]XMLNode
+
+	pat, res := parseXML(text, parent, nil, nil, nil, nil, &farms)
+
+	applyNamespaces(pat, rs)
+
+	release := func() {
+		for _, fp := range farms {
+			farm := *fp
+			for i := range farm {
+				// keep attribute array for reuse, but drop references to record strings
+				atts := farm[i].Attribs[:cap(farm[i].Attribs)]
+				for j := range atts {
+					atts[j] = ""
+				}
+				farm[i] = XMLNode{Attribs: atts[:0]}
+			}
+			nodeFarmPool.Put(fp)
+		}
+		farms = nil
+	}
+
+	return pat, release, res == recordTooLarge
+}
+
 // FindIdentifier returns a single identifier
 func FindIdentifier(text, parent string, find *XMLFind) string {
 
-	_, id := parseXML(text, parent, nil, nil, find, nil)
+	_, id := parseXML(text, parent, nil, nil, find, nil, nil)
 
 	return id
 }
@@ -2504,13 +4104,13 @@ func FindIdentifier(text, parent string, find *XMLFind) string {
 // FindIdentifiers returns a set of identifiers through a callback
 func FindIdentifiers(text, parent string, find *XMLFind, ids func(string)) {
 
-	parseXML(text, parent, nil, nil, find, ids)
+	parseXML(text, parent, nil, nil, find, ids, nil)
 }
 
 // StreamTokens streams tokens from a reader through a callback
 func StreamTokens(inp <-chan XMLBlock, streamer func(tkn XMLToken)) {
 
-	parseXML("", "", inp, streamer, nil, nil)
+	parseXML("", "", inp, streamer, nil, nil, nil)
 }
 
 // StreamValues streams token values from a parsed record through a callback
@@ -2532,7 +4132,7 @@ func StreamValues(text, parent string, stream func(string, string, string)) {
 		}
 	}
 
-	parseXML(text, parent, nil, streamer, nil, nil)
+	parseXML(text, parent, nil, streamer, nil, nil, nil)
 }
 
 // CreateTokenizer streams tokens through a channel
@@ -2555,7 +4155,7 @@ func CreateTokenizer(inp <-chan XMLBlock) <-chan XMLToken {
 		defer close(out)
 
 		// parse XML and send tokens through channel
-		parseXML("", "", inp, func(tkn XMLToken) { out <- tkn }, nil, nil)
+		parseXML("", "", inp, func(tkn XMLToken) { out <- tkn }, nil, nil, nil)
 	}
 
 	// launch single tokenizer goroutine
@@ -2564,6 +4164,172 @@ func CreateTokenizer(inp <-chan XMLBlock) <-chan XMLToken {
 	return out
 }
 
+// NAMESPACE PREFIX MAPPING
+
+// recordScope is the namespace context sent with each record, since the producer may
+// have passed later xmlns declarations by the time a consumer parses the record
+type recordScope struct {
+	// prefix to URI mappings from -namespace arguments
+	mapped map[string]string
+	// xmlns declarations seen outside of the record, e.g., on the set element
+	outer map[string]string
+}
+
+// defaultScope applies -namespace mappings to records parsed outside of the producer pipeline
+func defaultScope() *recordScope {
+
+	if cliRun.namespaces == nil {
+		return nil
+	}
+
+	return &recordScope{mapped: cliRun.namespaces}
+}
+
+// AddNamespace maps a prefix used in extraction arguments to a namespace URI, so that
+// elements and attributes match by URI regardless of the prefix bound in the data
+func AddNamespace(prefix, uri string) {
+
+	if cliRun.namespaces == nil {
+		cliRun.namespaces = make(map[string]string)
+	}
+	cliRun.namespaces[prefix] = uri
+}
+
+// namespaceDeclarations returns the scope extended by any xmlns attributes, copying only if changed
+func namespaceDeclarations(attrs string, scope map[string]string) map[string]string {
+
+	if !strings.Contains(attrs, "xmlns") {
+		return scope
+	}
+
+	copied := false
+
+	atts := ParseAttributes(attrs)
+	for i := 0; i < len(atts)-1; i += 2 {
+		tag := atts[i]
+		pfx := ""
+		if tag == "xmlns" {
+			// default namespace applies to unprefixed element names
+		} else if strings.HasPrefix(tag, "xmlns:") {
+			pfx = tag[6:]
+		} else {
+			continue
+		}
+		if !copied {
+			next := make(map[string]string, len(scope)+1)
+			for k, v := range scope {
+				next[k] = v
+			}
+			scope = next
+			copied = true
+		}
+		scope[pfx] = atts[i+1]
+	}
+
+	return scope
+}
+
+// recordNamespaces saves the -namespace mappings and the in-scope namespace declarations
+// on each node of a parsed record
+func recordNamespaces(node *XMLNode, scope, mapped map[string]string) {
+
+	for ; node != nil; node = node.Next {
+		if node.Attributes != "" {
+			scope := namespaceDeclarations(node.Attributes, scope)
+			node.Namespaces = scope
+			node.mapped = mapped
+			recordNamespaces(node.Children, scope, mapped)
+			continue
+		}
+		node.Namespaces = scope
+		node.mapped = mapped
+		recordNamespaces(node.Children, scope, mapped)
+	}
+}
+
+// applyNamespaces resolves prefixes in scope at each node for -namespace matching
+func applyNamespaces(top *XMLNode, rs *recordScope) {
+
+	if top == nil || rs == nil || rs.mapped == nil {
+		return
+	}
+
+	recordNamespaces(top, rs.outer, rs.mapped)
+}
+
+// outerNamespaces returns the scope extended by xmlns declarations found in text between records
+func outerNamespaces(scope map[string]string, str string) map[string]string {
+
+	if !strings.Contains(str, "xmlns") {
+		return scope
+	}
+
+	for {
+		idx := strings.Index(str, "<")
+		if idx < 0 {
+			return scope
+		}
+		str = str[idx+1:]
+		end := strings.Index(str, ">")
+		if end < 0 {
+			return scope
+		}
+		tag := str[:end]
+		str = str[end+1:]
+		if tag == "" || tag[0] == '/' || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+		_, attrs := SplitInTwoLeft(strings.TrimSuffix(tag, "/"), " ")
+		scope = namespaceDeclarations(attrs, scope)
+	}
+}
+
+// namespaceMapped reports whether an argument uses a prefix declared with -namespace
+func namespaceMapped(mapped map[string]string, arg string) bool {
+
+	if mapped == nil {
+		return false
+	}
+
+	pfx, local := SplitInTwoLeft(arg, ":")
+	if local == "" {
+		return false
+	}
+	_, ok := mapped[pfx]
+
+	return ok
+}
+
+// namespaceMatch resolves a prefixed argument through -namespace mappings and compares
+// the namespace URI and local name with those of an element or attribute name in the data
+func namespaceMatch(curr *XMLNode, name, arg string, isAttrib bool) bool {
+
+	apfx, alocal := SplitInTwoLeft(arg, ":")
+	if alocal == "" {
+		return false
+	}
+	uri, ok := curr.mapped[apfx]
+	if !ok {
+		return false
+	}
+
+	npfx, nlocal := SplitInTwoLeft(name, ":")
+	if nlocal == "" {
+		// unprefixed element is in the default namespace, unprefixed attribute is in none
+		if isAttrib {
+			return false
+		}
+		npfx, nlocal = "", name
+	}
+	if nlocal != alocal {
+		return false
+	}
+
+	found, ok := curr.Namespaces[npfx]
+
+	return ok && found == uri
+}
+
 // EXPLORE XML ELEMENTS
 
 // ExploreElements returns matching element values to callback
@@ -2580,6 +4346,11 @@ func ExploreElements(curr *XMLNode, mask, prnt, match, attrib string, wildcard,
 		deep = true
 	}
 
+	// prefix declared with -namespace matches by namespace URI instead of literal name
+	matchNS := namespaceMapped(curr.mapped, match)
+	prntNS := namespaceMapped(curr.mapped, prnt)
+	attrNS := namespaceMapped(curr.mapped, attrib)
+
 	// exploreChildren recursive definition
 	var exploreChildren func(curr *XMLNode, acc func(string))
 
@@ -2611,26 +4382,29 @@ func ExploreElements(curr *XMLNode, mask, prnt, match, attrib string, wildcard,
 			return
 		}
 
-		if curr.Name == match ||
+		if (curr.Name == match && !matchNS) ||
 			// parent/* matches any subfield
 			(match == "*" && prnt != "") ||
 			// wildcard (internal colon) matches any namespace prefix
 			(wildcard && strings.HasPrefix(match, ":") && strings.HasSuffix(curr.Name, match)) ||
+			(matchNS && namespaceMatch(curr, curr.Name, match, false)) ||
 			(match == "" && attrib != "") {
 
 			if prnt == "" ||
-				curr.Parent == prnt ||
-				(wildcard && strings.HasPrefix(prnt, ":") && strings.HasSuffix(curr.Parent, prnt)) {
+				(curr.Parent == prnt && !prntNS) ||
+				(wildcard && strings.HasPrefix(prnt, ":") && strings.HasSuffix(curr.Parent, prnt)) ||
+				(prntNS && namespaceMatch(curr, curr.Parent, prnt, false)) {
 
 				if attrib != "" {
-					if curr.Attributes != "" && curr.Attribs == nil {
+					if curr.Attributes != "" && len(curr.Attribs) == 0 {
 						// parse attributes on-the-fly if queried
-						curr.Attribs = ParseAttributes(curr.Attributes)
+						curr.Attribs = parseAttributesInto(curr.Attributes, curr.Attribs)
 					}
 					for i := 0; i < len(curr.Attribs)-1; i += 2 {
 						// attributes now parsed into array as [ tag, value, tag, value, tag, value, ... ]
-						if curr.Attribs[i] == attrib ||
-							(wildcard && strings.HasPrefix(attrib, ":") && strings.HasSuffix(curr.Attribs[i], attrib)) {
+						if (curr.Attribs[i] == attrib && !attrNS) ||
+							(wildcard && strings.HasPrefix(attrib, ":") && strings.HasSuffix(curr.Attribs[i], attrib)) ||
+							(attrNS && namespaceMatch(curr, curr.Attribs[i], attrib, true)) {
 							proc(curr.Attribs[i+1], level)
 							return
 						}
@@ -2719,6 +4493,10 @@ func ExploreNodes(curr *XMLNode, prnt, match string, index, level int, proc func
 		wildcard = true
 	}
 
+	// prefix declared with -namespace matches by namespace URI instead of literal name
+	matchNS := namespaceMapped(curr.mapped, match)
+	prntNS := namespaceMapped(curr.mapped, prnt)
+
 	// Single * allows exploration of heterogeneous data construct without knowing current component name
 	if prnt == "" && match == "*" {
 		match = curr.Name
@@ -2749,14 +4527,16 @@ func ExploreNodes(curr *XMLNode, prnt, match string, index, level int, proc func
 
 		// match is "*" for heterogeneous data constructs, e.g., -group PubmedArticleSet/*
 		// wildcard matches any namespace prefix
-		if curr.Name == match ||
+		if (curr.Name == match && !matchNS) ||
 			match == "*" ||
-			(wildcard && strings.HasPrefix(match, ":") && strings.HasSuffix(curr.Name, match)) {
+			(wildcard && strings.HasPrefix(match, ":") && strings.HasSuffix(curr.Name, match)) ||
+			(matchNS && namespaceMatch(curr, curr.Name, match, false)) {
 
 			if prnt == "" ||
-				curr.Parent == prnt ||
+				(curr.Parent == prnt && !prntNS) ||
 				force ||
-				(wildcard && strings.HasPrefix(prnt, ":") && strings.HasSuffix(curr.Parent, prnt)) {
+				(wildcard && strings.HasPrefix(prnt, ":") && strings.HasSuffix(curr.Parent, prnt)) ||
+				(prntNS && namespaceMatch(curr, curr.Parent, prnt, false)) {
 
 				proc(curr, indx, levl)
 				indx++
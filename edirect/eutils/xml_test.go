@@ -0,0 +1,404 @@
+package eutils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dedupTexts runs records through CreateDeduplicator and returns the surviving texts in output order
+func dedupTexts(t *testing.T, keepLast bool, texts []string) []string {
+
+	t.Helper()
+
+	inp := make(chan XMLRecord, len(texts))
+	for i, txt := range texts {
+		inp <- XMLRecord{Index: i + 1, Text: txt}
+	}
+	close(inp)
+
+	out := CreateDeduplicator("PubmedArticle", "PMID", keepLast, inp)
+	if out == nil {
+		t.Fatal("unable to create deduplicator")
+	}
+
+	var res []string
+	idx := 0
+	for ext := range out {
+		idx++
+		if ext.Index != idx {
+			t.Errorf("record %d arrived in position %d", ext.Index, idx)
+		}
+		if ext.Text != "" {
+			res = append(res, ext.Text)
+		}
+	}
+	if idx != len(texts) {
+		t.Errorf("got %d records, want %d including placeholders", idx, len(texts))
+	}
+
+	return res
+}
+
+func TestDeduplicator(t *testing.T) {
+
+	rec := func(pmid, ver string) string {
+		return "<PubmedArticle><PMID>" + pmid + "</PMID><V>" + ver + "</V></PubmedArticle>"
+	}
+
+	// same set concatenated twice gives each article once
+	set := []string{rec("1", "a"), rec("2", "a"), rec("3", "a")}
+	twice := append(append([]string{}, set...), set...)
+
+	if got := dedupTexts(t, false, twice); strings.Join(got, "") != strings.Join(set, "") {
+		t.Errorf("keep first: got %q", got)
+	}
+
+	updates := []string{rec("1", "a"), rec("2", "a"), "<PubmedArticle><V>none</V></PubmedArticle>", rec("1", "b"), rec("3", "a"), rec("2", "b")}
+
+	want := []string{rec("1", "a"), rec("2", "a"), "<PubmedArticle><V>none</V></PubmedArticle>", rec("3", "a")}
+	if got := dedupTexts(t, false, updates); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("keep first: got %q, want %q", got, want)
+	}
+
+	want = []string{"<PubmedArticle><V>none</V></PubmedArticle>", rec("1", "b"), rec("3", "a"), rec("2", "b")}
+	if got := dedupTexts(t, true, updates); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("keep last: got %q, want %q", got, want)
+	}
+}
+
+func TestMultiFileReader(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// alternate plain and compressed files, each larger than one read chunk
+	var want strings.Builder
+	for i := 0; i < 10; i++ {
+		var buf strings.Builder
+		buf.WriteString("<?xml version=\"1.0\"?>\n<Set>\n")
+		for j := 0; j < 2000; j++ {
+			fmt.Fprintf(&buf, "<Rec><File>%d</File><Num>%d</Num></Rec>\n", i, j)
+		}
+		buf.WriteString("</Set>")
+
+		name := filepath.Join(dir, fmt.Sprintf("chunk%02d.xml", i))
+		if i%2 == 0 {
+			if err := os.WriteFile(name, []byte(buf.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			fl, err := os.Create(name + ".gz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			zpr := gzip.NewWriter(fl)
+			zpr.Write([]byte(buf.String()))
+			zpr.Close()
+			fl.Close()
+		}
+		want.WriteString(buf.String() + "\n")
+	}
+
+	files := ExpandInputFiles(dir)
+	if len(files) != 10 {
+		t.Fatalf("expected 10 files, got %d", len(files))
+	}
+
+	for _, parallel := range []int{0, 1, 3, 16} {
+		data, err := io.ReadAll(CreateMultiFileReader(files, false, true, true, parallel))
+		if err != nil {
+			t.Fatalf("parallel %d: %v", parallel, err)
+		}
+		if string(data) != want.String() {
+			t.Errorf("parallel %d: concatenation differs, got %d bytes, want %d", parallel, len(data), want.Len())
+		}
+	}
+
+	// closing the reader early must not leave file readers behind
+	before := runtime.NumGoroutine()
+	for k := 0; k < 5; k++ {
+		rdr := CreateMultiFileReader(files, false, true, true, 4)
+		buf := make([]byte, 1000)
+		if _, err := io.ReadFull(rdr, buf); err != nil {
+			t.Fatal(err)
+		}
+		rdr.(io.Closer).Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked, %d before and %d after", before, after)
+	}
+}
+
+// pmcLikeRecord builds a BioC-style record with many small nodes and attributes
+func pmcLikeRecord() string {
+
+	var buf strings.Builder
+	buf.WriteString(`<document><id>PMC123</id>`)
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&buf, `<passage offset="%d" type="paragraph"><infon key="section_type">INTRO</infon>`, i*100)
+		fmt.Fprintf(&buf, `<text>Sentence %d describes a gene &amp; its product.</text>`, i)
+		fmt.Fprintf(&buf, `<annotation id="A%d"><infon key="type">Gene</infon><location offset="%d" length="4"/><text>TP53</text></annotation>`, i, i*100+5)
+		buf.WriteString(`</passage>`)
+	}
+	buf.WriteString(`</document>`)
+
+	return buf.String()
+}
+
+// visitAttributes parses the attributes of every node, as attribute queries during extraction do
+func visitAttributes(node *XMLNode) int {
+
+	count := 0
+	for ; node != nil; node = node.Next {
+		if node.Attributes != "" && len(node.Attribs) == 0 {
+			node.Attribs = parseAttributesInto(node.Attributes, node.Attribs)
+		}
+		count += len(node.Attribs)
+		count += visitAttributes(node.Children)
+	}
+
+	return count
+}
+
+// findNode returns the first node with the given name in depth-first order
+func findNode(node *XMLNode, name string) *XMLNode {
+
+	for ; node != nil; node = node.Next {
+		if node.Name == name {
+			return node
+		}
+		if fnd := findNode(node.Children, name); fnd != nil {
+			return fnd
+		}
+	}
+
+	return nil
+}
+
+func TestPooledRecordAttributes(t *testing.T) {
+
+	text := pmcLikeRecord()
+
+	pat := ParseRecord(text, "")
+	want := visitAttributes(pat)
+
+	// recycled nodes and attribute arrays must not carry values from an earlier record
+	for i := 0; i < 3; i++ {
+		pat, release, _ := parsePooledRecord(text, "", nil)
+		if got := visitAttributes(pat); got != want {
+			t.Errorf("pass %d: %d attribute slots, want %d", i, got, want)
+		}
+		release()
+
+		pat, release, _ = parsePooledRecord(`<Rec><A x="1">a</A><B>b</B></Rec>`, "", nil)
+		a, b := findNode(pat, "A"), findNode(pat, "B")
+		if got := visitAttributes(pat); got != 2 || a == nil || b == nil || a.Attribs[1] != "1" || len(b.Attribs) != 0 {
+			t.Errorf("pass %d: stale attributes after reuse", i)
+		}
+		release()
+	}
+
+	xml := `<Set><Rec><A x="1" y="2">a</A></Rec><Rec><A z="3">b</A></Rec><Rec><A>c</A></Rec></Set>
+`
+	for i := 0; i < 3; i++ {
+		out := extractText(t, xml, "-pattern", "Rec", "-block", "A", "-element", "@*", "A")
+		if out != "x=1\ty=2\ta\nz=3\tb\nc\n" {
+			t.Errorf("unexpected attribute output %q", out)
+		}
+	}
+}
+
+// go test -bench ParseRecord -benchmem shows allocations per record with and without recycling
+func BenchmarkParseRecord(b *testing.B) {
+
+	text := pmcLikeRecord()
+
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pat := ParseRecord(text, "")
+			visitAttributes(pat)
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pat, release, _ := parsePooledRecord(text, "", nil)
+			visitAttributes(pat)
+			release()
+		}
+	})
+}
+
+// produceRecords partitions XML text with CreateXMLProducer, and returns record texts and any truncation report
+func produceRecords(t *testing.T, pat, star, text string) ([]string, *XMLTruncation) {
+
+	t.Helper()
+
+	xmlq := CreateXMLProducer(pat, star, false, CreateXMLStreamer(strings.NewReader(text)))
+	if xmlq == nil {
+		t.Fatal("unable to create producer")
+	}
+
+	var recs []string
+	var trunc *XMLTruncation
+	for ext := range xmlq {
+		if ext.Truncated != nil {
+			if trunc != nil {
+				t.Errorf("more than one truncation report")
+			}
+			if ext.Text != "" || ext.Index != len(recs)+1 {
+				t.Errorf("truncation report has index %d and text %q after %d records", ext.Index, ext.Text, len(recs))
+			}
+			trunc = ext.Truncated
+			continue
+		}
+		if trunc != nil {
+			t.Errorf("record %d sent after truncation report", ext.Index)
+		}
+		recs = append(recs, ext.Text)
+	}
+
+	return recs, trunc
+}
+
+func TestTruncatedInput(t *testing.T) {
+
+	full := "<Set>\n" +
+		"<Rec><Id>1</Id><AuthorList><Author>A</Author></AuthorList></Rec>\n" +
+		"<Rec><Id>2</Id><AuthorList><Author>B</Author></AuthorList></Rec>\n" +
+		"<Rec><Id>3</Id><Empty/></Rec>\n" +
+		"</Set>\n"
+
+	recs, trunc := produceRecords(t, "Rec", "", full)
+	if len(recs) != 3 || trunc != nil {
+		t.Fatalf("complete input gave %d records and truncation %v", len(recs), trunc)
+	}
+
+	// every cut before the closing set tag reports truncation, and only complete records are sent
+	end := strings.LastIndex(full, "</Set>") + len("</Set>")
+	for n := 1; n < end; n++ {
+		recs, trunc := produceRecords(t, "Rec", "", full[:n])
+		if trunc == nil {
+			t.Errorf("input cut at byte %d, %q, was not reported", n, full[n-10:n])
+			continue
+		}
+		for _, rec := range recs {
+			if !strings.HasPrefix(rec, "<Rec>") || !strings.HasSuffix(rec, "</Rec>") {
+				t.Errorf("input cut at byte %d sent partial record %q", n, rec)
+			}
+		}
+		complete := strings.Count(full[:n], "</Rec>")
+		if len(recs) != complete {
+			t.Errorf("input cut at byte %d gave %d records, want %d", n, len(recs), complete)
+		}
+		if trunc.Record == (strings.Count(full[:n], "<Rec") == complete) {
+			t.Errorf("input cut at byte %d reported %v", n, trunc)
+		}
+	}
+
+	tests := []struct {
+		cut  string
+		want string
+	}{
+		{"<Rec><Id>2</Id><AuthorList><Au", "Record starting at line 3 truncated inside <AuthorList>"},
+		{"<Rec><Id>2</Id><AuthorList><Author>B", "Record starting at line 3 truncated inside <Author>"},
+		{"<Rec><Id>3</Id><Empty/>", "Record starting at line 4 truncated inside <Rec>"},
+		{"<Rec><Id>3</Id><Empty/></Rec>\n", "Input truncated inside <Set> after line 4"},
+		{"<Rec><Id>3</Id><Empty/></Rec>\n</Se", "Input truncated inside <Set> after line 5"},
+	}
+
+	for _, tt := range tests {
+		n := strings.Index(full, tt.cut) + len(tt.cut)
+		_, trunc := produceRecords(t, "Rec", "", full[:n])
+		if trunc == nil || trunc.Error() != tt.want {
+			t.Errorf("input ending in %q reported %v, want %q", tt.cut, trunc, tt.want)
+		}
+	}
+
+	// record cut inside its start tag, without an enclosing set
+	_, trunc = produceRecords(t, "Rec", "", "<Rec><Id>1</Id></Rec>\n<Rec")
+	if trunc == nil || trunc.Error() != "Record starting at line 2 truncated inside <Rec>" {
+		t.Errorf("record cut in start tag reported %v", trunc)
+	}
+
+	// heterogeneous objects under -pattern Set/*
+	recs, trunc = produceRecords(t, "Set", "*", full[:strings.Index(full, "<Id>3")])
+	if len(recs) != 2 || trunc == nil || trunc.Error() != "Record starting at line 4 truncated inside <Rec>" {
+		t.Errorf("Set/* gave %d records and %v", len(recs), trunc)
+	}
+}
+
+func TestNamespaceScopePerRecord(t *testing.T) {
+
+	AddNamespace("dc", "urn:one")
+	defer func() { cliRun.namespaces = nil }()
+
+	if NumServe() < 1 {
+		SetTunings(0, 0, 0, 0, 0, 0, 0, false)
+	}
+
+	// concatenated sets rebind the same prefix, so the producer runs ahead of the consumers into a different scope
+	var sb strings.Builder
+	var want strings.Builder
+	for set, uri := range []string{"urn:one", "urn:two", "urn:one"} {
+		fmt.Fprintf(&sb, "<Set xmlns:a=\"%s\">\n", uri)
+		for i := 0; i < 200; i++ {
+			fmt.Fprintf(&sb, "<Rec><a:title>%d.%d</a:title></Rec>\n", set, i)
+			if uri == "urn:one" {
+				fmt.Fprintf(&want, "%d.%d\n", set, i)
+			}
+		}
+		sb.WriteString("</Set>\n")
+	}
+	// record binding the same URI to a different prefix
+	sb.WriteString("<Rec xmlns:dcterms=\"urn:one\"><dcterms:title>own</dcterms:title></Rec>\n")
+	want.WriteString("own\n")
+
+	cmds := ParseArguments([]string{"-pattern", "Rec", "-element", "dc:title"}, "Rec")
+
+	xmlq := CreateXMLProducer("Rec", "", false, CreateXMLStreamer(strings.NewReader(sb.String())))
+	tblq := CreateXMLConsumers(cmds, "", "", "", nil, false, nil, xmlq)
+	unsq := CreateXMLUnshuffler(tblq)
+
+	var got strings.Builder
+	for ext := range unsq {
+		got.WriteString(ext.Text)
+	}
+
+	if got.String() != want.String() {
+		t.Errorf("dc:title matched %q", strings.Fields(got.String()))
+	}
+}
+
+func TestMaxRecordNodes(t *testing.T) {
+
+	SetRecordLimits(0, 4, false)
+	defer SetRecordLimits(0, 0, false)
+
+	// second record has only two element nodes with content, but three bare self-closing tags
+	xml := "<Set>\n<Rec><Id>1</Id></Rec>\n<Rec><Id>2</Id><A/><B/><C/></Rec>\n<Rec><Id>3</Id><A/></Rec>\n</Set>\n"
+
+	var out string
+	errs := captureStderr(t, func() {
+		out = extractText(t, xml, "-pattern", "Rec", "-element", "Id")
+	})
+
+	if out != "1\n3\n" {
+		t.Errorf("got output %q, want %q", out, "1\n3\n")
+	}
+	if !strings.Contains(errs, "Skipping Record 2 (Id 2), node count exceeds -max-record-nodes 4") {
+		t.Errorf("missing warning for oversized record, got %q", errs)
+	}
+}
@@ -31,6 +31,7 @@
 package eutils
 
 import (
+	"bufio"
 	"encoding/base64"
 	"fmt"
 	"github.com/fatih/color"
@@ -46,6 +47,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 )
 
@@ -92,7 +95,14 @@ const (
 	ELEMENT
 	FIRST
 	LAST
+	FIRSTN
+	LASTN
 	BACKWARD
+	UNIQUE
+	UNIQLOWER
+	AUTHKEY
+	SORTVALUES
+	SORTNUMERIC
 	ENCODE
 	DECODE
 	UPPER
@@ -110,6 +120,7 @@ const (
 	YEAR
 	MONTH
 	DATE
+	AGE
 	PAGE
 	AUTH
 	INITIALS
@@ -136,6 +147,7 @@ const (
 	MATRIX
 	CLASSIFY
 	HISTOGRAM
+	GROUPBY
 	ACCENTED
 	TEST
 	SCAN
@@ -161,12 +173,20 @@ const (
 	WRP
 	ENC
 	PKG
+	KPG
 	RST
 	DEF
+	DEFS
 	REG
 	EXP
+	DATEFMT
+	ASOF
+	NUMFMT
 	COLOR
+	DEFLINE
 	POSITION
+	BETWEEN
+	WINDOW
 	SELECT
 	IF
 	UNLESS
@@ -183,8 +203,10 @@ const (
 	ISNOT
 	ISBEFORE
 	ISAFTER
+	ISBETWEEN
 	MATCHES
 	RESEMBLES
+	REGEX
 	ISEQUALTO
 	DIFFERSFROM
 	GT
@@ -204,6 +226,9 @@ const (
 	SUB
 	AVG
 	DEV
+	DEVP
+	VAR
+	VARP
 	MED
 	MUL
 	DIV
@@ -216,6 +241,7 @@ const (
 	HEX
 	BIT
 	PAD
+	NATURAL
 	RAW
 	ZEROBASED
 	ONEBASED
@@ -225,19 +251,27 @@ const (
 	FASTA
 	NCBI2NA
 	NCBI4NA
+	HEX2NA
 	MOLWT
+	AA3TO1
+	AA1TO3
+	GCPCT
+	ORFS
 	HGVS
 	ELSE
 	VARIABLE
 	ACCUMULATOR
 	VALUE
+	ARITHMETIC
 	QUESTION
 	TILDE
 	STAR
 	DOT
 	PRCNT
+	EQLSIGN
 	DOLLAR
 	ATSIGN
+	ATSTAR
 	COUNT
 	LENGTH
 	DEPTH
@@ -313,6 +347,8 @@ var argTypeIs = map[string]ArgumentType{
 	"-pattern":      EXPLORATION,
 	"-Pattern":      EXPLORATION,
 	"-position":     CONDITIONAL,
+	"-between":      CONDITIONAL,
+	"-window":       CONDITIONAL,
 	"-select":       CONDITIONAL,
 	"-if":           CONDITIONAL,
 	"-unless":       CONDITIONAL,
@@ -329,8 +365,10 @@ var argTypeIs = map[string]ArgumentType{
 	"-is-not":       CONDITIONAL,
 	"-is-before":    CONDITIONAL,
 	"-is-after":     CONDITIONAL,
+	"-is-between":   CONDITIONAL,
 	"-matches":      CONDITIONAL,
 	"-resembles":    CONDITIONAL,
+	"-regex":        CONDITIONAL,
 	"-is-equal-to":  CONDITIONAL,
 	"-differs-from": CONDITIONAL,
 	"-gt":           CONDITIONAL,
@@ -342,7 +380,14 @@ var argTypeIs = map[string]ArgumentType{
 	"-element":      EXTRACTION,
 	"-first":        EXTRACTION,
 	"-last":         EXTRACTION,
+	"-first-n":      EXTRACTION,
+	"-last-n":       EXTRACTION,
 	"-backward":     EXTRACTION,
+	"-uniq-element": EXTRACTION,
+	"-uniq-lower":   EXTRACTION,
+	"-authkey":      EXTRACTION,
+	"-sort-values":  EXTRACTION,
+	"-sort-numeric": EXTRACTION,
 	"-encode":       EXTRACTION,
 	"-decode":       EXTRACTION,
 	"-decode64":     EXTRACTION,
@@ -361,6 +406,7 @@ var argTypeIs = map[string]ArgumentType{
 	"-year":         EXTRACTION,
 	"-month":        EXTRACTION,
 	"-date":         EXTRACTION,
+	"-age":          EXTRACTION,
 	"-page":         EXTRACTION,
 	"-auth":         EXTRACTION,
 	"-initials":     EXTRACTION,
@@ -387,6 +433,7 @@ var argTypeIs = map[string]ArgumentType{
 	"-matrix":       EXTRACTION,
 	"-classify":     EXTRACTION,
 	"-histogram":    EXTRACTION,
+	"-group-by":     EXTRACTION,
 	"-accented":     EXTRACTION,
 	"-test":         EXTRACTION,
 	"-scan":         EXTRACTION,
@@ -401,6 +448,9 @@ var argTypeIs = map[string]ArgumentType{
 	"-sub":          EXTRACTION,
 	"-avg":          EXTRACTION,
 	"-dev":          EXTRACTION,
+	"-devp":         EXTRACTION,
+	"-var":          EXTRACTION,
+	"-varp":         EXTRACTION,
 	"-med":          EXTRACTION,
 	"-mul":          EXTRACTION,
 	"-div":          EXTRACTION,
@@ -413,6 +463,7 @@ var argTypeIs = map[string]ArgumentType{
 	"-hex":          EXTRACTION,
 	"-bit":          EXTRACTION,
 	"-pad":          EXTRACTION,
+	"-natural":      EXTRACTION,
 	"-raw":          EXTRACTION,
 	"-0-based":      EXTRACTION,
 	"-zero-based":   EXTRACTION,
@@ -428,7 +479,12 @@ var argTypeIs = map[string]ArgumentType{
 	"-fasta":        EXTRACTION,
 	"-ncbi2na":      EXTRACTION,
 	"-ncbi4na":      EXTRACTION,
+	"-hex2na":       EXTRACTION,
 	"-molwt":        EXTRACTION,
+	"-aa3to1":       EXTRACTION,
+	"-aa1to3":       EXTRACTION,
+	"-gc":           EXTRACTION,
+	"-orfs":         EXTRACTION,
 	"-hgvs":         EXTRACTION,
 	"-else":         EXTRACTION,
 	"-pfx":          CUSTOMIZATION,
@@ -453,18 +509,31 @@ var argTypeIs = map[string]ArgumentType{
 	"-wrp":          CUSTOMIZATION,
 	"-enc":          CUSTOMIZATION,
 	"-pkg":          CUSTOMIZATION,
+	"-keep-pkg":     CUSTOMIZATION,
 	"-rst":          CUSTOMIZATION,
 	"-def":          CUSTOMIZATION,
+	"-defs":         CUSTOMIZATION,
 	"-reg":          CUSTOMIZATION,
 	"-exp":          CUSTOMIZATION,
+	"-datefmt":      CUSTOMIZATION,
+	"-asof":         CUSTOMIZATION,
+	"-fmt":          CUSTOMIZATION,
 	"-color":        CUSTOMIZATION,
+	"-defline":      CUSTOMIZATION,
 }
 
 var opTypeIs = map[string]OpType{
 	"-element":      ELEMENT,
 	"-first":        FIRST,
 	"-last":         LAST,
+	"-first-n":      FIRSTN,
+	"-last-n":       LASTN,
 	"-backward":     BACKWARD,
+	"-uniq-element": UNIQUE,
+	"-uniq-lower":   UNIQLOWER,
+	"-authkey":      AUTHKEY,
+	"-sort-values":  SORTVALUES,
+	"-sort-numeric": SORTNUMERIC,
 	"-encode":       ENCODE,
 	"-decode":       DECODE,
 	"-decode64":     DECODE,
@@ -483,6 +552,7 @@ var opTypeIs = map[string]OpType{
 	"-year":         YEAR,
 	"-month":        MONTH,
 	"-date":         DATE,
+	"-age":          AGE,
 	"-page":         PAGE,
 	"-auth":         AUTH,
 	"-initials":     INITIALS,
@@ -509,6 +579,7 @@ var opTypeIs = map[string]OpType{
 	"-matrix":       MATRIX,
 	"-classify":     CLASSIFY,
 	"-histogram":    HISTOGRAM,
+	"-group-by":     GROUPBY,
 	"-accented":     ACCENTED,
 	"-test":         TEST,
 	"-scan":         SCAN,
@@ -534,12 +605,20 @@ var opTypeIs = map[string]OpType{
 	"-wrp":          WRP,
 	"-enc":          ENC,
 	"-pkg":          PKG,
+	"-keep-pkg":     KPG,
 	"-rst":          RST,
 	"-def":          DEF,
+	"-defs":         DEFS,
 	"-reg":          REG,
 	"-exp":          EXP,
+	"-datefmt":      DATEFMT,
+	"-asof":         ASOF,
+	"-fmt":          NUMFMT,
 	"-color":        COLOR,
+	"-defline":      DEFLINE,
 	"-position":     POSITION,
+	"-between":      BETWEEN,
+	"-window":       WINDOW,
 	"-select":       SELECT,
 	"-if":           IF,
 	"-unless":       UNLESS,
@@ -556,8 +635,10 @@ var opTypeIs = map[string]OpType{
 	"-is-not":       ISNOT,
 	"-is-before":    ISBEFORE,
 	"-is-after":     ISAFTER,
+	"-is-between":   ISBETWEEN,
 	"-matches":      MATCHES,
 	"-resembles":    RESEMBLES,
+	"-regex":        REGEX,
 	"-is-equal-to":  ISEQUALTO,
 	"-differs-from": DIFFERSFROM,
 	"-gt":           GT,
@@ -577,6 +658,9 @@ var opTypeIs = map[string]OpType{
 	"-sub":          SUB,
 	"-avg":          AVG,
 	"-dev":          DEV,
+	"-devp":         DEVP,
+	"-var":          VAR,
+	"-varp":         VARP,
 	"-med":          MED,
 	"-mul":          MUL,
 	"-div":          DIV,
@@ -589,6 +673,7 @@ var opTypeIs = map[string]OpType{
 	"-hex":          HEX,
 	"-bit":          BIT,
 	"-pad":          PAD,
+	"-natural":      NATURAL,
 	"-raw":          RAW,
 	"-0-based":      ZEROBASED,
 	"-zero-based":   ZEROBASED,
@@ -604,7 +689,12 @@ var opTypeIs = map[string]OpType{
 	"-fasta":        FASTA,
 	"-ncbi2na":      NCBI2NA,
 	"-ncbi4na":      NCBI4NA,
+	"-hex2na":       HEX2NA,
 	"-molwt":        MOLWT,
+	"-aa3to1":       AA3TO1,
+	"-aa1to3":       AA1TO3,
+	"-gc":           GCPCT,
+	"-orfs":         ORFS,
 	"-hgvs":         HGVS,
 	"-else":         ELSE,
 }
@@ -640,6 +730,78 @@ var sequenceTypeIs = map[string]SequenceType{
 	"RS:@structLoc":                   {0, ISPOS},
 }
 
+// LoadSequenceTypes reads pattern:element, basis, and role lines into sequenceTypeIs
+func LoadSequenceTypes(fname string) {
+
+	inFile, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open sequence coordinate file '%s'\n", fname)
+		os.Exit(1)
+	}
+	defer inFile.Close()
+
+	slock.Lock()
+	defer slock.Unlock()
+
+	scanr := bufio.NewScanner(inFile)
+
+	row := 0
+	for scanr.Scan() {
+
+		line := scanr.Text()
+		row++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Expected 3 columns in sequence coordinate file line %d\n", row)
+			os.Exit(1)
+		}
+
+		key := strings.TrimSpace(cols[0])
+		if !strings.Contains(key, ":") {
+			fmt.Fprintf(os.Stderr, "\nERROR: Expected pattern:element in sequence coordinate file line %d\n", row)
+			os.Exit(1)
+		}
+
+		based := 0
+		switch strings.TrimSpace(cols[1]) {
+		case "0":
+			based = 0
+		case "1":
+			based = 1
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Basis must be 0 or 1 in sequence coordinate file line %d\n", row)
+			os.Exit(1)
+		}
+
+		var which SeqEndType
+		switch strings.ToLower(strings.TrimSpace(cols[2])) {
+		case "start":
+			which = ISSTART
+		case "stop":
+			which = ISSTOP
+		case "pos":
+			which = ISPOS
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Role must be start, stop, or pos in sequence coordinate file line %d\n", row)
+			os.Exit(1)
+		}
+
+		seqtype := SequenceType{based, which}
+
+		if prev, ok := sequenceTypeIs[key]; ok && prev != seqtype {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Redefining sequence coordinate '%s'\n", key)
+		}
+
+		sequenceTypeIs[key] = seqtype
+	}
+}
+
 var monthTable = map[string]int{
 	"jan":       1,
 	"january":   1,
@@ -710,6 +872,8 @@ type Step struct {
 	Norm   bool
 	Wild   bool
 	Unesc  bool
+	Regx   *regexp.Regexp
+	Limit  string // count for -first-n and -last-n, line width for -fasta, digit width for -pad and -natural, field for -indices, unit for -age
 }
 
 // Operation breaks commands into sequential steps
@@ -717,6 +881,7 @@ type Operation struct {
 	Type   OpType
 	Value  string
 	Stages []*Step
+	Regx   *regexp.Regexp // compiled -reg pattern
 }
 
 // Block contains nested instructions for executing commands
@@ -728,12 +893,26 @@ type Block struct {
 	Working    []string
 	Parsed     []string
 	Position   string
+	Range      *SiblingRange
 	Foreword   string
 	Afterword  string
+	Sparse     bool
 	Conditions []*Operation
 	Commands   []*Operation
 	Failure    []*Operation
 	Subtasks   []*Block
+	CSV        bool
+	JSON       bool
+}
+
+// SiblingRange restricts exploration to siblings that follow a start marker element,
+// ending at the next stop marker (-between) or after a fixed number of siblings (-window)
+type SiblingRange struct {
+	StartName  string
+	StartValue string
+	StopName   string
+	StopValue  string
+	Count      int
 }
 
 // Limiter is used for collecting specific nodes (e.g., first and last)
@@ -778,9 +957,229 @@ func DebugBlock(blk *Block, depth int) {
 
 // PARSE COMMAND-LINE ARGUMENTS
 
-// ParseArguments parses nested exploration instruction from command-line arguments
+// deprecated argument policy, original command-line positions of the arguments, and count of warnings issued
+var (
+	strictArgs       bool
+	argPositions     []int
+	deprecationCount int
+)
+
+// SetStrictArgs makes deprecated command-line constructs fatal errors
+func SetStrictArgs(strict bool) {
+
+	strictArgs = strict
+}
+
+// SetArgumentPositions records the 1-based command-line position of each argument that will be
+// passed to ParseArguments, with 0 for added arguments, so that warnings can cite the original position
+func SetArgumentPositions(posn []int) {
+
+	argPositions = posn
+}
+
+// -require-all policy, with missing column flag kept in a variable name that cannot be assigned
+var (
+	requireAll       bool
+	discardedRecords int64
+)
+
+const missingColumn = "-require-all"
+
+// SetRequireAll discards any record in which an -element clause produced no output
+func SetRequireAll(require bool) {
+
+	requireAll = require
+}
+
+// DiscardedRecords returns the number of records dropped by -require-all
+func DiscardedRecords() int {
+
+	return int(atomic.LoadInt64(&discardedRecords))
+}
+
+// PrintDeprecationSummary reports the number of deprecation warnings issued while parsing arguments
+func PrintDeprecationSummary() {
+
+	if deprecationCount > 0 {
+		fmt.Fprintf(os.Stderr, "\nWARNING: %d deprecated construct(s) in arguments, -strict-args makes them errors\n", deprecationCount)
+	}
+}
+
+// takesValue reports whether an extraction argument is followed by a value that may itself start with a hyphen
+func takesValue(str string) bool {
+
+	switch argTypeIs[str] {
+	case EXPLORATION, CONDITIONAL:
+		return true
+	case CUSTOMIZATION:
+		switch str {
+		case "-clr", "-rst", "-cls", "-slf":
+			return false
+		}
+		return true
+	}
+
+	// -VARIABLE value
+	if len(str) > 1 && str[0] == '-' && IsAllCapsOrDigits(strings.TrimPrefix(str[1:], "-")) {
+		return true
+	}
+
+	return false
+}
+
+// findDeprecated scans arguments for legacy constructs, skipping argument values, and returns a
+// message with the 1-based position of each, on the original command line if it is known
+func findDeprecated(cmdargs []string, posn []int) []string {
+
+	var notes []string
+
+	note := func(pos int, format string, args ...interface{}) {
+		num := pos + 1
+		if len(posn) == len(cmdargs) && posn[pos] > 0 {
+			num = posn[pos]
+		}
+		notes = append(notes, fmt.Sprintf("Argument %d: ", num)+fmt.Sprintf(format, args...))
+	}
+
+	upper := map[string]string{
+		"-Unit":     "-unit",
+		"-Subset":   "-subset",
+		"-Section":  "-section",
+		"-Block":    "-block",
+		"-Branch":   "-branch",
+		"-Group":    "-group",
+		"-Division": "-division",
+		"-Path":     "-path",
+		"-Pattern":  "-pattern",
+	}
+
+	for i := 0; i < len(cmdargs); i++ {
+		str := cmdargs[i]
+
+		if lc, ok := upper[str]; ok {
+			note(i, "Upper-case '%s' exploration command is deprecated, use lower-case '%s' instead", str, lc)
+			i++
+			continue
+		}
+
+		if i+1 >= len(cmdargs) || !takesValue(str) {
+			continue
+		}
+		pos := i
+		val := cmdargs[i+1]
+		// the value cannot be a command, as in -lbl -Block
+		i++
+
+		switch str {
+		case "-match", "-avoid":
+			rep := "-if"
+			if str == "-avoid" {
+				rep = "-unless"
+			}
+			if strings.Contains(val, ":") && !strings.HasPrefix(val, ":") {
+				elm, vl := SplitInTwoLeft(val, ":")
+				note(pos, "'%s %s' is deprecated, use %s %s -equals %s instead", str, val, rep, elm, vl)
+			} else {
+				note(pos, "'%s' is deprecated, use '%s' instead", str, rep)
+			}
+		case "-if", "-unless", "-and", "-or":
+			if len(val) > 1 && val[0] == '&' && strings.Contains(val, ":") {
+				vr, vl := SplitInTwoLeft(val[1:], ":")
+				if IsAllCapsOrDigits(vr) {
+					note(pos, "'%s %s' is deprecated, use %s &%s -equals %s instead", str, val, str, vr, vl)
+				}
+			}
+		case "-wrp":
+			if strings.Contains(val, ",") && strings.Index(val, "@") < 1 {
+				lft, rgt := SplitInTwoRight(val, ",")
+				note(pos, "'-wrp %s' is deprecated, use -enc %s -wrp %s instead", val, lft, rgt)
+			}
+		}
+	}
+
+	return notes
+}
+
+// ParseArguments parses nested exploration instruction from command-line arguments, exiting on error
 func ParseArguments(cmdargs []string, pttrn string) *Block {
 
+	cmds, err := ParseArgumentsErr(cmdargs, pttrn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	return cmds
+}
+
+// expandKeepArguments rewrites -keep Object [conditions] as a -block that prints each
+// satisfying Object as an XML subtree, so only matching child objects of a record are kept,
+// grouped in a wrapper named for the record that is omitted when nothing was kept
+func expandKeepArguments(cmdargs []string, pttrn string) []string {
+
+	found := false
+	for _, str := range cmdargs {
+		if str == "-keep" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return cmdargs
+	}
+
+	var args []string
+
+	for i := 0; i < len(cmdargs); i++ {
+		str := cmdargs[i]
+		if str != "-keep" || i+1 >= len(cmdargs) {
+			args = append(args, str)
+			continue
+		}
+
+		args = append(args, "-block", cmdargs[i+1])
+		i += 2
+
+		// conditional clauses are evaluated on each explored object
+		for i+1 < len(cmdargs) && argTypeIs[cmdargs[i]] == CONDITIONAL {
+			args = append(args, cmdargs[i], cmdargs[i+1])
+			i += 2
+		}
+
+		// a heterogeneous Parent/* pattern has no single record name
+		if pttrn != "" && !strings.Contains(pttrn, "*") {
+			args = append(args, "-keep-pkg", pttrn)
+		}
+		args = append(args, "-tab", "", "-element", "****")
+
+		// later record-level commands must not be absorbed into the -keep block
+		if i < len(cmdargs) && argTypeIs[cmdargs[i]] != EXPLORATION && cmdargs[i] != "-keep" {
+			args = append(args, "-block", "*")
+		}
+		i--
+	}
+
+	return args
+}
+
+// ParseArgumentsErr parses nested exploration instruction from command-line arguments,
+// returning an error with the usual message text instead of exiting the program (the
+// Block is nil without an error if no exploration structure could be built)
+func ParseArgumentsErr(cmdargs []string, pttrn string) (*Block, error) {
+
+	// legacy constructs warn by default, or all fail together under -strict-args
+	if notes := findDeprecated(cmdargs, argPositions); len(notes) > 0 {
+		if strictArgs {
+			return nil, fmt.Errorf("Deprecated constructs not allowed with -strict-args\n  %s", strings.Join(notes, "\n  "))
+		}
+		for _, str := range notes {
+			fmt.Fprintf(os.Stderr, "\nWARNING: %s\n", str)
+		}
+		deprecationCount += len(notes)
+	}
+
+	cmdargs = expandKeepArguments(cmdargs, pttrn)
+
 	// different names of exploration control arguments allow multiple levels of nested "for" loops in a linear command line
 	// (capitalized versions for backward-compatibility with original Perl implementation handling of recursive definitions)
 	var (
@@ -821,6 +1220,39 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			return op, false
 		}
 
+		// -fasta:60 sets line width
+		if strings.HasPrefix(str, "-fasta:") {
+			return FASTA, true
+		}
+
+		// -gc:1000,200 sets window and step
+		if strings.HasPrefix(str, "-gc:") {
+			return GCPCT, true
+		}
+
+		// -orfs:min=100,code=11,atg,prot sets open reading frame options
+		if strings.HasPrefix(str, "-orfs:") {
+			return ORFS, true
+		}
+
+		// -pad:12 and -natural:12 set zero-padded digit width
+		if strings.HasPrefix(str, "-pad:") {
+			return PAD, true
+		}
+		if strings.HasPrefix(str, "-natural:") {
+			return NATURAL, true
+		}
+
+		// -indices:GRNT sets positional index field name
+		if strings.HasPrefix(str, "-indices:") {
+			return INDICES, true
+		}
+
+		// -age:days and -age:years set units of date difference
+		if strings.HasPrefix(str, "-age:") {
+			return AGE, true
+		}
+
 		if len(str) > 1 && str[0] == '-' && IsAllCapsOrDigits(str[1:]) {
 			return VARIABLE, true
 		}
@@ -836,6 +1268,34 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 		return UNSET, false
 	}
 
+	// parseAgeUnit returns days or years from -age or -age:unit
+	parseAgeUnit := func(str string) (string, error) {
+
+		unit := strings.TrimPrefix(strings.TrimPrefix(str, "-age"), ":")
+		switch unit {
+		case "", "day", "days":
+			return "days", nil
+		case "year", "years":
+			return "years", nil
+		}
+
+		return "", fmt.Errorf("Unit in '%s' must be days or years", str)
+	}
+
+	// isAgeModifier recognizes -age or -age:unit between a conditional object and its comparison
+	isAgeModifier := func(args []string, cur int) bool {
+
+		str := args[cur]
+		if str != "-age" && !strings.HasPrefix(str, "-age:") {
+			return false
+		}
+		if cur < 2 || cur+1 >= len(args) {
+			return false
+		}
+
+		return argTypeIs[args[cur-2]] == CONDITIONAL && argTypeIs[args[cur+1]] == CONDITIONAL
+	}
+
 	// parseCommands recursive definition
 	var parseCommands func(parent *Block, startLevel LevelType)
 
@@ -861,7 +1321,6 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 							return level, lctag, uctag
 						}
 						if txt == uctag {
-							fmt.Fprintf(os.Stderr, "\nWARNING: Upper-case '%s' exploration command is deprecated, use lower-case '%s' instead\n", uctag, lctag)
 							return level, lctag, uctag
 						}
 					}
@@ -968,7 +1427,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 	}
 
 	// parse optional [min:max], [&VAR:&VAR], or [after|before] range specification
-	parseRange := func(item, rnge string) (typL RangeType, strL string, intL int, typR RangeType, strR string, intR int) {
+	parseRange := func(item, rnge string) (typL RangeType, strL string, intL int, typR RangeType, strR string, intR int, err error) {
 
 		typL = NORANGE
 		typR = NORANGE
@@ -984,15 +1443,15 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 
 		// check if last character is right square bracket
 		if !strings.HasSuffix(rnge, "]") {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized range %s\n", rnge)
-			os.Exit(1)
+			err = fmt.Errorf("Unrecognized range %s", rnge)
+			return
 		}
 
 		rnge = strings.TrimSuffix(rnge, "]")
 
 		if rnge == "" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Empty range %s[]\n", item)
-			os.Exit(1)
+			err = fmt.Errorf("Empty range %s[]", item)
+			return
 		}
 
 		// check for [after|before] variant
@@ -1002,8 +1461,8 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			// spacing matters, so do not call TrimSpace
 
 			if strL == "" && strR == "" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Empty range %s[|]\n", item)
-				os.Exit(1)
+				err = fmt.Errorf("Empty range %s[|]", item)
+				return
 			}
 
 			typL = STRINGRANGE
@@ -1015,8 +1474,8 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 
 		// otherwise must have colon within brackets
 		if !strings.Contains(rnge, ":") {
-			fmt.Fprintf(os.Stderr, "\nERROR: Colon missing in range %s[%s]\n", item, rnge)
-			os.Exit(1)
+			err = fmt.Errorf("Colon missing in range %s[%s]", item, rnge)
+			return
 		}
 
 		// split at colon
@@ -1026,16 +1485,15 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 		rgt = strings.TrimSpace(rgt)
 
 		if lft == "" && rgt == "" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Empty range %s[:]\n", item)
-			os.Exit(1)
+			err = fmt.Errorf("Empty range %s[:]", item)
+			return
 		}
 
 		// for variable, parse optional +/- offset suffix
-		parseOffset := func(str string) (string, int) {
+		parseOffset := func(str string) (string, int, error) {
 
 			if str == "" || str[0] == ' ' {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variable '&%s'\n", str)
-				os.Exit(1)
+				return "", 0, fmt.Errorf("Unrecognized variable '&%s'", str)
 			}
 
 			pls := ""
@@ -1050,110 +1508,163 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			if pls != "" {
 				val, err := strconv.Atoi(pls)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized range adjustment &%s+%s\n", str, pls)
-					os.Exit(1)
+					return "", 0, fmt.Errorf("Unrecognized range adjustment &%s+%s", str, pls)
 				}
 				ofs = val
 			} else if mns != "" {
 				val, err := strconv.Atoi(mns)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized range adjustment &%s-%s\n", str, mns)
-					os.Exit(1)
+					return "", 0, fmt.Errorf("Unrecognized range adjustment &%s-%s", str, mns)
 				}
 				ofs = -val
 			}
 
-			return str, ofs
+			return str, ofs, nil
 		}
 
 		// parse integer position, 1-based coordinate must be greater than 0
-		parseInteger := func(str string, mustBePositive bool) int {
+		parseInteger := func(str string, mustBePositive bool) (int, error) {
 			if str == "" {
-				return 0
+				return 0, nil
 			}
 
 			val, err := strconv.Atoi(str)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized range component %s[%s:]\n", item, str)
-				os.Exit(1)
+				return 0, fmt.Errorf("Unrecognized range component %s[%s:]", item, str)
 			}
 			if mustBePositive {
 				if val < 1 {
-					fmt.Fprintf(os.Stderr, "\nERROR: Range component %s[%s:] must be positive\n", item, str)
-					os.Exit(1)
+					return 0, fmt.Errorf("Range component %s[%s:] must be positive", item, str)
 				}
 			} else {
 				if val == 0 {
-					fmt.Fprintf(os.Stderr, "\nERROR: Range component %s[%s:] must not be zero\n", item, str)
-					os.Exit(1)
+					return 0, fmt.Errorf("Range component %s[%s:] must not be zero", item, str)
 				}
 			}
 
-			return val
+			return val, nil
 		}
 
 		if lft != "" {
 			if lft[0] == '&' {
 				lft = lft[1:]
-				strL, intL = parseOffset(lft)
+				strL, intL, err = parseOffset(lft)
 				typL = VARIABLERANGE
 			} else {
-				intL = parseInteger(lft, true)
+				intL, err = parseInteger(lft, true)
 				typL = INTEGERRANGE
 			}
+			if err != nil {
+				return
+			}
 		}
 
 		if rgt != "" {
 			if rgt[0] == '&' {
 				rgt = rgt[1:]
-				strR, intR = parseOffset(rgt)
+				strR, intR, err = parseOffset(rgt)
 				typR = VARIABLERANGE
 			} else {
-				intR = parseInteger(rgt, false)
+				intR, err = parseInteger(rgt, false)
 				typR = INTEGERRANGE
 			}
+			if err != nil {
+				return
+			}
 		}
 
 		// return statement required to return named variables
 		return
 	}
 
-	parseConditionals := func(cmds *Block, arguments []string) []*Operation {
+	parseConditionals := func(cmds *Block, arguments []string) ([]*Operation, error) {
 
 		max := len(arguments)
 		if max < 1 {
-			return nil
+			return nil, nil
 		}
 
 		// check for missing condition command
 		txt := arguments[0]
-		if txt != "-if" && txt != "-unless" && txt != "-select" && txt != "-match" && txt != "-avoid" && txt != "-position" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Missing -if command before '%s'\n", txt)
-			os.Exit(1)
+		if txt != "-if" && txt != "-unless" && txt != "-select" && txt != "-match" && txt != "-avoid" && txt != "-position" &&
+			txt != "-between" && txt != "-window" {
+			return nil, fmt.Errorf("Missing -if command before '%s'", txt)
 		}
-		if txt == "-position" && max > 2 {
-			fmt.Fprintf(os.Stderr, "\nERROR: Cannot combine -position with -if or -unless commands\n")
-			os.Exit(1)
+		if txt == "-position" && max > 2 && arguments[2] != "-between" && arguments[2] != "-window" {
+			return nil, fmt.Errorf("Cannot combine -position with -if or -unless commands")
 		}
-		// check for missing argument after last condition
+		// check for missing argument after last condition, allowing negative -position index
 		txt = arguments[max-1]
-		if len(txt) > 0 && txt[0] == '-' {
-			fmt.Fprintf(os.Stderr, "\nERROR: Item missing after %s command\n", txt)
-			os.Exit(1)
+		if len(txt) > 0 && txt[0] == '-' && (max < 2 || arguments[max-2] != "-position" || !isPositionRange(txt)) &&
+			(max < 2 || !isNegativeOperand(arguments[max-2], txt)) {
+			return nil, fmt.Errorf("Item missing after %s command", txt)
 		}
 
 		cond := make([]*Operation, 0, max)
 
+		// parseOperand reads the second argument of a comparison, which can be a literal,
+		// #count, %length, ^depth, &VARIABLE, or element specifier
+		parseOperand := func(status OpType, str string) (*Step, error) {
+
+			if len(str) > 1 && str[0] == '\\' {
+				// first character may be backslash protecting minus sign (undocumented)
+				return &Step{Type: status, Value: str[1:]}, nil
+			}
+			if len(str) < 1 {
+				return nil, fmt.Errorf("Empty comparison argument")
+			}
+			if len(str) > 1 && str[0] == '(' && str[len(str)-1] == ')' {
+				// parentheses force a literal value, as in -eq "(5)"
+				return &Step{Type: status, Value: str[1 : len(str)-1]}, nil
+			}
+
+			ch := str[0]
+
+			if ch == '&' {
+				if len(str) < 2 || !IsAllCapsOrDigits(str[1:]) {
+					return nil, fmt.Errorf("Unrecognized variable '%s'", str)
+				}
+				return &Step{Type: status, Value: str, Match: str}, nil
+			}
+
+			orig := str
+			if ch == '#' || ch == '%' || ch == '^' {
+				str = str[1:]
+				if len(str) < 1 {
+					return nil, fmt.Errorf("Element missing after '%s'", orig)
+				}
+				ch = str[0]
+			}
+
+			if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || ch == ':' {
+				prnt, match := SplitInTwoRight(str, "/")
+				match, attrib := SplitInTwoLeft(match, "@")
+				wildcard := false
+				if strings.HasPrefix(prnt, ":") || strings.HasPrefix(match, ":") || strings.HasPrefix(attrib, ":") {
+					wildcard = true
+				}
+				return &Step{Type: status, Value: orig, Parent: prnt, Match: match, Attrib: attrib, Wild: wildcard}, nil
+			}
+
+			if orig != str {
+				return nil, fmt.Errorf("Unexpected comparison constraint '%s'", orig)
+			}
+
+			// literal value
+			return &Step{Type: status, Value: str}, nil
+		}
+
 		// parse conditional clause into execution step
-		parseStep := func(op *Operation, elementColonValue bool) {
+		parseStep := func(op *Operation, elementColonValue bool) error {
 
 			if op == nil {
-				return
+				return nil
 			}
 
 			str := op.Value
 
 			status := ELEMENT
+			legacy := ""
 
 			// isolate and parse optional [min:max], [&VAR:&VAR], or [after|before] range specification
 			str, rnge := SplitInTwoLeft(str, "[")
@@ -1162,11 +1673,13 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			rnge = strings.TrimSpace(rnge)
 
 			if str == "" && rnge != "" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Variable missing in range specification [%s\n", rnge)
-				os.Exit(1)
+				return fmt.Errorf("Variable missing in range specification [%s", rnge)
 			}
 
-			typL, strL, intL, typR, strR, intR := parseRange(str, rnge)
+			typL, strL, intL, typR, strR, intR, err := parseRange(str, rnge)
+			if err != nil {
+				return err
+			}
 
 			// check for pound, percent, or caret character at beginning of name
 			if len(str) > 1 {
@@ -1175,12 +1688,15 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					if IsAllCapsOrDigits(str[1:]) {
 						status = VARIABLE
 						str = str[1:]
+					} else if vr, vl := SplitInTwoLeft(str[1:], ":"); vl != "" && IsAllCapsOrDigits(vr) {
+						// legacy &VARIABLE:value is treated as &VARIABLE -equals value
+						status = VARIABLE
+						str = vr
+						legacy = vl
 					} else if strings.Contains(str, ":") {
-						fmt.Fprintf(os.Stderr, "\nERROR: Unsupported construct '%s', use -if &VARIABLE -equals VALUE instead\n", str)
-						os.Exit(1)
+						return fmt.Errorf("Unsupported construct '%s', use -if &VARIABLE -equals VALUE instead", str)
 					} else {
-						fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variable '%s'\n", str)
-						os.Exit(1)
+						return fmt.Errorf("Unrecognized variable '%s'", str)
 					}
 				case '#':
 					status = COUNT
@@ -1235,6 +1751,12 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				tsk := &Step{Type: EQUALS, Value: val}
 				op.Stages = append(op.Stages, tsk)
 			}
+			if legacy != "" {
+				tsk := &Step{Type: EQUALS, Value: legacy}
+				op.Stages = append(op.Stages, tsk)
+			}
+
+			return nil
 		}
 
 		idx := 0
@@ -1250,26 +1772,35 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 
 		status := UNSET
 
-		numIf := 0
-		numUnless := 0
-		lastCond := ""
-
 		// parse command strings into operation structure
 		for idx < max {
 			str := arguments[idx]
 			idx++
 
+			// -age modifier converts date object to days or years before comparison
+			if expectDash && op != nil && len(op.Stages) == 1 && isAgeModifier(arguments, idx-1) {
+				stage := op.Stages[0]
+				if stage.Type != ELEMENT && stage.Type != VARIABLE {
+					return nil, fmt.Errorf("Unexpected '%s' command after '%s'", str, last)
+				}
+				unit, err := parseAgeUnit(str)
+				if err != nil {
+					return nil, err
+				}
+				stage.Limit = unit
+				last = str
+				continue
+			}
+
 			// conditionals should alternate between command and object/value
 			if expectDash {
 				if len(str) < 1 || str[0] != '-' {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected '%s' argument after '%s'\n", str, last)
-					os.Exit(1)
+					return nil, fmt.Errorf("Unexpected '%s' argument after '%s'", str, last)
 				}
 				expectDash = false
 			} else {
-				if len(str) > 0 && str[0] == '-' {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected '%s' command after '%s'\n", str, last)
-					os.Exit(1)
+				if len(str) > 0 && str[0] == '-' && (last != "-position" || !isPositionRange(str)) && !isNegativeOperand(last, str) {
+					return nil, fmt.Errorf("Unexpected '%s' command after '%s'", str, last)
 				}
 				expectDash = true
 			}
@@ -1280,40 +1811,41 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				status, _ = parseFlag(str)
 			case POSITION:
 				if cmds.Position != "" {
-					fmt.Fprintf(os.Stderr, "\nERROR: -position '%s' conflicts with existing '%s'\n", str, cmds.Position)
-					os.Exit(1)
+					return nil, fmt.Errorf("-position '%s' conflicts with existing '%s'", str, cmds.Position)
+				}
+				if err := checkPosition(str); err != nil {
+					return nil, err
 				}
 				cmds.Position = str
 				status = UNSET
-			case MATCH, AVOID:
-				elementColonValue = true
-				fallthrough
-			case IF:
-				numIf++
-				if numIf > 1 || numUnless > 1 || numIf > 0 && numUnless > 0 {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected '-if %s' after '%s'\n", str, lastCond)
-					os.Exit(1)
+			case BETWEEN, WINDOW:
+				if cmds.Range != nil {
+					return nil, fmt.Errorf("Cannot combine multiple -between or -window commands")
 				}
-				lastCond = "-if " + str
-				op = &Operation{Type: status, Value: str}
-				cond = append(cond, op)
-				parseStep(op, elementColonValue)
-				status = UNSET
-			case UNLESS:
-				numUnless++
-				if numIf > 1 || numUnless > 1 || numIf > 0 && numUnless > 0 {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected '-unless %s' after '%s'\n", str, lastCond)
-					os.Exit(1)
+				cmds.Range = parseSiblingRange(status, str)
+				if cmds.Range == nil {
+					if status == WINDOW {
+						return nil, fmt.Errorf("-window '%s' must be a marker and a count, e.g., \"SectionTitle:Methods,3\"", str)
+					}
+					return nil, fmt.Errorf("-between '%s' must be two comma-separated markers, e.g., \"SectionTitle:Methods,SectionTitle:*\"", str)
 				}
-				lastCond = "-unless " + str
+				status = UNSET
+			case MATCH, AVOID, IF, UNLESS:
+				// multiple -if and -unless clauses are combined with AND semantics,
+				// element:value construct only applies within a deprecated -match or -avoid clause
+				elementColonValue = (status == MATCH || status == AVOID)
 				op = &Operation{Type: status, Value: str}
 				cond = append(cond, op)
-				parseStep(op, elementColonValue)
+				if err := parseStep(op, elementColonValue); err != nil {
+					return nil, err
+				}
 				status = UNSET
 			case SELECT, AND, OR:
 				op = &Operation{Type: status, Value: str}
 				cond = append(cond, op)
-				parseStep(op, elementColonValue)
+				if err := parseStep(op, elementColonValue); err != nil {
+					return nil, err
+				}
 				status = UNSET
 			case EQUALS, CONTAINS, INCLUDES, ISWITHIN, STARTSWITH, ENDSWITH, ISNOT, ISBEFORE, ISAFTER:
 				if op != nil {
@@ -1325,8 +1857,20 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					op.Stages = append(op.Stages, tsk)
 					op = nil
 				} else {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected adjacent string match constraints\n")
-					os.Exit(1)
+					return nil, fmt.Errorf("Unexpected adjacent string match constraints")
+				}
+				status = UNSET
+			case ISBETWEEN:
+				if op != nil {
+					lft, rgt, found := strings.Cut(str, ",")
+					if !found || (strings.TrimSpace(lft) == "" && strings.TrimSpace(rgt) == "") {
+						return nil, fmt.Errorf("-is-between '%s' must be a comma-separated date range", str)
+					}
+					tsk := &Step{Type: status, Value: str}
+					op.Stages = append(op.Stages, tsk)
+					op = nil
+				} else {
+					return nil, fmt.Errorf("Unexpected adjacent string match constraints")
 				}
 				status = UNSET
 			case MATCHES:
@@ -1340,8 +1884,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					op.Stages = append(op.Stages, tsk)
 					op = nil
 				} else {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected adjacent string match constraints\n")
-					os.Exit(1)
+					return nil, fmt.Errorf("Unexpected adjacent string match constraints")
 				}
 				status = UNSET
 			case RESEMBLES:
@@ -1355,122 +1898,66 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					op.Stages = append(op.Stages, tsk)
 					op = nil
 				} else {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected adjacent string match constraints\n")
-					os.Exit(1)
-				}
-				status = UNSET
-			case ISEQUALTO, DIFFERSFROM:
-				if op != nil {
-					if len(str) < 1 {
-						fmt.Fprintf(os.Stderr, "\nERROR: Empty conditional argument\n")
-						os.Exit(1)
-					}
-					ch := str[0]
-					// uses element as second argument
-					orig := str
-					if ch == '#' || ch == '%' || ch == '^' {
-						// check for pound, percent, or caret character at beginning of element (undocumented)
-						str = str[1:]
-						if len(str) < 1 {
-							fmt.Fprintf(os.Stderr, "\nERROR: Unexpected conditional constraints\n")
-							os.Exit(1)
-						}
-						ch = str[0]
-					}
-					if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') {
-						prnt, match := SplitInTwoRight(str, "/")
-						match, attrib := SplitInTwoLeft(match, "@")
-						wildcard := false
-						if strings.HasPrefix(prnt, ":") || strings.HasPrefix(match, ":") || strings.HasPrefix(attrib, ":") {
-							wildcard = true
-						}
-						tsk := &Step{Type: status, Value: orig, Parent: prnt, Match: match, Attrib: attrib, Wild: wildcard}
-						op.Stages = append(op.Stages, tsk)
-					} else {
-						fmt.Fprintf(os.Stderr, "\nERROR: Unexpected conditional constraints\n")
-						os.Exit(1)
-					}
-					op = nil
+					return nil, fmt.Errorf("Unexpected adjacent string match constraints")
 				}
 				status = UNSET
-			case GT, GE, LT, LE, EQ, NE:
+			case REGEX:
 				if op != nil {
 					if len(str) > 1 && str[0] == '\\' {
-						// first character may be backslash protecting minus sign (undocumented)
+						// first character may be backslash protecting dash (undocumented)
 						str = str[1:]
 					}
-					if len(str) < 1 {
-						fmt.Fprintf(os.Stderr, "\nERROR: Empty numeric match constraints\n")
-						os.Exit(1)
-					}
-					ch := str[0]
-					if (ch >= '0' && ch <= '9') || ch == '-' || ch == '+' {
-						// literal numeric constant
-						tsk := &Step{Type: status, Value: str}
-						op.Stages = append(op.Stages, tsk)
-					} else {
-						// numeric test allows element as second argument
-						orig := str
-						if ch == '#' || ch == '%' || ch == '^' {
-							// check for pound, percent, or caret character at beginning of element (undocumented)
-							str = str[1:]
-							if len(str) < 1 {
-								fmt.Fprintf(os.Stderr, "\nERROR: Unexpected numeric match constraints\n")
-								os.Exit(1)
-							}
-							ch = str[0]
-						}
-						if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || ch == '&' {
-							prnt, match := SplitInTwoRight(str, "/")
-							match, attrib := SplitInTwoLeft(match, "@")
-							wildcard := false
-							if strings.HasPrefix(prnt, ":") || strings.HasPrefix(match, ":") || strings.HasPrefix(attrib, ":") {
-								wildcard = true
-							}
-							tsk := &Step{Type: status, Value: orig, Parent: prnt, Match: match, Attrib: attrib, Wild: wildcard}
-							op.Stages = append(op.Stages, tsk)
-						} else {
-							fmt.Fprintf(os.Stderr, "\nERROR: Unexpected numeric match constraints\n")
-							os.Exit(1)
-						}
+					// compile once here instead of for every record
+					re, err := regexp.Compile(str)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid regular expression '%s'", str)
 					}
+					tsk := &Step{Type: status, Value: str, Regx: re}
+					op.Stages = append(op.Stages, tsk)
 					op = nil
 				} else {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unexpected adjacent numeric match constraints\n")
-					os.Exit(1)
+					return nil, fmt.Errorf("Unexpected adjacent string match constraints")
+				}
+				status = UNSET
+			case ISEQUALTO, DIFFERSFROM, GT, GE, LT, LE, EQ, NE:
+				if op != nil {
+					tsk, err := parseOperand(status, str)
+					if err != nil {
+						return nil, err
+					}
+					op.Stages = append(op.Stages, tsk)
+					op = nil
+				} else {
+					return nil, fmt.Errorf("Unexpected adjacent comparison constraints")
 				}
 				status = UNSET
 			case UNRECOGNIZED:
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized argument '%s'\n", str)
-				os.Exit(1)
+				return nil, fmt.Errorf("Unrecognized argument '%s'", str)
 			default:
-				fmt.Fprintf(os.Stderr, "\nERROR: Unexpected argument '%s'\n", str)
-				os.Exit(1)
+				return nil, fmt.Errorf("Unexpected argument '%s'", str)
 			}
 		}
 
-		return cond
+		return cond, nil
 	}
 
-	parseExtractions := func(cmds *Block, arguments []string) []*Operation {
+	parseExtractions := func(cmds *Block, arguments []string) ([]*Operation, error) {
 
 		max := len(arguments)
 		if max < 1 {
-			return nil
+			return nil, nil
 		}
 
 		// check for missing -element (or -first, etc.) command
 		txt := arguments[0]
 		if len(txt) < 1 || txt[0] != '-' {
-			fmt.Fprintf(os.Stderr, "\nERROR: Missing -element command before '%s'\n", txt)
-			os.Exit(1)
+			return nil, fmt.Errorf("Missing -element command before '%s'", txt)
 		}
 		// check for missing argument after last -element (or -first, etc.) command
 		txt = arguments[max-1]
 		if len(txt) > 0 && txt[0] == '-' {
 			if txt == "-rst" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unexpected position for %s command\n", txt)
-				os.Exit(1)
+				return nil, fmt.Errorf("Unexpected position for %s command", txt)
 			} else if txt == "-clr" {
 				// main loop runs out after trailing -clr, add another one so this one will be executed
 				arguments = append(arguments, "-clr")
@@ -1478,21 +1965,61 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			} else if txt == "-cls" || txt == "-slf" {
 				// okay at end
 			} else if max < 2 || arguments[max-2] != "-lbl" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Item missing after %s command\n", txt)
-				os.Exit(1)
+				return nil, fmt.Errorf("Item missing after %s command", txt)
 			} else if max < 3 || (arguments[max-3] != "-att" && arguments[max-3] != "-atr") {
-				fmt.Fprintf(os.Stderr, "\nERROR: Item missing after %s command\n", txt)
-				os.Exit(1)
+				return nil, fmt.Errorf("Item missing after %s command", txt)
 			}
 		}
 
 		comm := make([]*Operation, 0, max)
 
+		// line width from most recent -fasta:N argument
+		width := ""
+
 		// parse next argument
-		nextStatus := func(str string) (OpType, bool) {
+		nextStatus := func(str string) (OpType, bool, error) {
 
 			status, isExtraction := parseFlag(str)
 
+			width = ""
+			if status == FASTA && strings.HasPrefix(str, "-fasta:") {
+				width = strings.TrimPrefix(str, "-fasta:")
+				if num, err := strconv.Atoi(width); err != nil || num < 1 {
+					return status, isExtraction, fmt.Errorf("Line width in '%s' must be a positive integer", str)
+				}
+			}
+			if status == GCPCT && strings.HasPrefix(str, "-gc:") {
+				width = strings.TrimPrefix(str, "-gc:")
+				if _, _, err := parseWindowOptions(width); err != nil {
+					return status, isExtraction, err
+				}
+			}
+			if status == ORFS && strings.HasPrefix(str, "-orfs:") {
+				width = strings.TrimPrefix(str, "-orfs:")
+				if _, _, _, _, err := parseOrfOptions(width); err != nil {
+					return status, isExtraction, err
+				}
+			}
+			if (status == PAD || status == NATURAL) && strings.Contains(str, ":") {
+				_, width, _ = strings.Cut(str, ":")
+				if num, err := strconv.Atoi(width); err != nil || num < 1 || num > 64 {
+					return status, isExtraction, fmt.Errorf("Digit width in '%s' must be an integer from 1 to 64", str)
+				}
+			}
+			if status == INDICES && strings.HasPrefix(str, "-indices:") {
+				width = strings.TrimPrefix(str, "-indices:")
+				if width == "" || !IsAllCapsOrDigits(width) {
+					return status, isExtraction, fmt.Errorf("Field name in '%s' must be upper-case letters or digits", str)
+				}
+			}
+			if status == AGE {
+				unit, err := parseAgeUnit(str)
+				if err != nil {
+					return status, isExtraction, err
+				}
+				width = unit
+			}
+
 			// no-argument flags are supported here to prevent subsequent "No -element before" error
 			switch status {
 			case VARIABLE:
@@ -1508,7 +2035,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				comm = append(comm, op)
 				status = UNSET
 			case ELEMENT:
-			case TAB, RET, PFX, SFX, SEP, LBL, TAG, ATT, ATR, END, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, COLOR:
+			case TAB, RET, PFX, SFX, SEP, LBL, TAG, ATT, ATR, END, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, DEFS, REG, EXP, DATEFMT, NUMFMT, COLOR, DEFLINE, ASOF:
 			case CLS:
 				op := &Operation{Type: LBL, Value: ">"}
 				comm = append(comm, op)
@@ -1517,29 +2044,26 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				op := &Operation{Type: LBL, Value: " />"}
 				comm = append(comm, op)
 				status = UNSET
-			case FWD, AWD, PKG:
+			case FWD, AWD, PKG, KPG:
 			case UNSET:
-				fmt.Fprintf(os.Stderr, "\nERROR: No -element before '%s'\n", str)
-				os.Exit(1)
+				return status, isExtraction, fmt.Errorf("No -element before '%s'", str)
 			case UNRECOGNIZED:
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized argument '%s'\n", str)
-				os.Exit(1)
+				return status, isExtraction, fmt.Errorf("Unrecognized argument '%s'", str)
 			default:
 				if !isExtraction {
 					// not ELEMENT through HGVS
-					fmt.Fprintf(os.Stderr, "\nERROR: Misplaced %s command\n", str)
-					os.Exit(1)
+					return status, isExtraction, fmt.Errorf("Misplaced %s command", str)
 				}
 			}
 
-			return status, isExtraction
+			return status, isExtraction, nil
 		}
 
 		// parse extraction clause into individual steps
-		parseSteps := func(op *Operation, pttrn string) {
+		parseSteps := func(op *Operation, pttrn string) error {
 
 			if op == nil {
-				return
+				return nil
 			}
 
 			stat := op.Type
@@ -1559,11 +2083,13 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				rnge = strings.TrimSpace(rnge)
 
 				if item == "" && rnge != "" {
-					fmt.Fprintf(os.Stderr, "\nERROR: Variable missing in range specification [%s\n", rnge)
-					os.Exit(1)
+					return fmt.Errorf("Variable missing in range specification [%s", rnge)
 				}
 
-				typL, strL, intL, typR, strR, intR := parseRange(item, rnge)
+				typL, strL, intL, typR, strR, intR, err := parseRange(item, rnge)
+				if err != nil {
+					return err
+				}
 
 				// check for special character at beginning of name
 				if len(item) > 1 {
@@ -1573,8 +2099,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 							status = VARIABLE
 							item = item[1:]
 						} else {
-							fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized variable '%s'\n", item)
-							os.Exit(1)
+							return fmt.Errorf("Unrecognized variable '%s'", item)
 						}
 					case '#':
 						status = COUNT
@@ -1592,6 +2117,10 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 							}
 						}
 						status = STAR
+					case '@':
+						if item == "@*" {
+							status = ATSTAR
+						}
 					default:
 					}
 				} else {
@@ -1604,6 +2133,8 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 						status = DOT
 					case "%":
 						status = PRCNT
+					case "=":
+						status = EQLSIGN
 					case "*":
 						status = STAR
 					case "$":
@@ -1642,8 +2173,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					seqtype, ok := sequenceTypeIs[seq]
 					slock.RUnlock()
 					if !ok {
-						fmt.Fprintf(os.Stderr, "\nERROR: Element '%s' is not suitable for sequence coordinate conversion\n", item)
-						os.Exit(1)
+						return fmt.Errorf("Element '%s' is not suitable for sequence coordinate conversion", item)
 					}
 					switch status {
 					case ZEROBASED:
@@ -1687,6 +2217,8 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 
 				op.Stages = append(op.Stages, tsk)
 			}
+
+			return nil
 		}
 
 		idx := 0
@@ -1694,22 +2226,59 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 		status := UNSET
 		isExtraction := false
 
+		var err error
+
 		// parse command strings into operation structure
 		for idx < max {
 			str := arguments[idx]
 			idx++
 
 			if argTypeIs[str] == CONDITIONAL {
-				fmt.Fprintf(os.Stderr, "\nERROR: Misplaced %s command\n", str)
-				os.Exit(1)
+				return nil, fmt.Errorf("Misplaced %s command", str)
 			}
 
 			switch status {
 			case UNSET:
-				status, isExtraction = nextStatus(str)
-			case TAB, RET, PFX, SFX, SEP, LBL, CLS, SLF, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, COLOR:
+				status, isExtraction, err = nextStatus(str)
+				if err != nil {
+					return nil, err
+				}
+			case TAB, RET, PFX, SFX, SEP, LBL, CLS, SLF, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, DEFS, REG, EXP, DATEFMT, NUMFMT, COLOR:
 				op := &Operation{Type: status, Value: ConvertSlash(str)}
+				if status == REG {
+					// compile once here instead of for every value, identical patterns share one object
+					re, err := replaceRegexp(op.Value)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid -reg pattern '%s': %s", op.Value, err.Error())
+					}
+					op.Regx = re
+				}
+				if status == NUMFMT && op.Value != "" {
+					if _, _, err := parseNumberFormat(op.Value); err != nil {
+						return nil, err
+					}
+				}
+				comm = append(comm, op)
+				status = UNSET
+			case DEFLINE:
+				// -defline takes object or &variable name
+				op := &Operation{Type: status, Value: str}
+				comm = append(comm, op)
+				if !strings.HasPrefix(str, "&") {
+					if err := parseSteps(op, pttrn); err != nil {
+						return nil, err
+					}
+				}
+				status = UNSET
+			case ASOF:
+				// -asof takes literal date, &variable, or date object name
+				op := &Operation{Type: status, Value: str}
 				comm = append(comm, op)
+				if !strings.HasPrefix(str, "&") && !isLiteralDate(str) {
+					if err := parseSteps(op, pttrn); err != nil {
+						return nil, err
+					}
+				}
 				status = UNSET
 			case TAG:
 				// when starting to construct XML tag and attributes from components, first clear -tab and -sep values
@@ -1742,7 +2311,9 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 						comm = append(comm, op)
 						op = &Operation{Type: ELEMENT, Value: val}
 						comm = append(comm, op)
-						parseSteps(op, pttrn)
+						if err := parseSteps(op, pttrn); err != nil {
+							return nil, err
+						}
 						op = &Operation{Type: LBL, Value: "\""}
 						comm = append(comm, op)
 					}
@@ -1758,6 +2329,11 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			case AWD:
 				cmds.Afterword = ConvertSlash(str)
 				status = UNSET
+			case KPG:
+				cmds.Foreword = "<" + ConvertSlash(str) + ">\n"
+				cmds.Afterword = "</" + ConvertSlash(str) + ">"
+				cmds.Sparse = true
+				status = UNSET
 			case PKG:
 				pkg := ConvertSlash(str)
 				cmds.Foreword = ""
@@ -1781,21 +2357,58 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				comm = append(comm, op)
 				status = VALUE
 			case VALUE:
-				op := &Operation{Type: status, Value: str}
-				comm = append(comm, op)
-				parseSteps(op, pttrn)
+				length := len(str)
+				if length > 1 && str[0] == '(' && str[length-1] == ')' && IsArithmeticExpression(str[1:length-1]) {
+					// -LEN "(&TO - &FR + 1)" is evaluated when assigned, check syntax now
+					expr := str[1 : length-1]
+					if _, _, err := EvaluateArithmetic(expr, nil); err != nil {
+						return nil, fmt.Errorf("Arithmetic expression '%s' %s", str, err.Error())
+					}
+					op := &Operation{Type: ARITHMETIC, Value: expr}
+					comm = append(comm, op)
+				} else {
+					op := &Operation{Type: status, Value: str}
+					comm = append(comm, op)
+					if err := parseSteps(op, pttrn); err != nil {
+						return nil, err
+					}
+				}
 				status = UNSET
 			case UNRECOGNIZED:
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized argument '%s'\n", str)
-				os.Exit(1)
+				return nil, fmt.Errorf("Unrecognized argument '%s'", str)
 			default:
 				if isExtraction {
 					// ELEMENT through HGVS
+					limit := ""
+					if status == FASTA || status == GCPCT || status == ORFS || status == PAD || status == NATURAL || status == INDICES || status == AGE {
+						limit = width
+					}
+					if status == FIRSTN || status == LASTN {
+						// first argument is number of values to print, or variable containing the number
+						limit = str
+						if strings.HasPrefix(limit, "&") {
+							if len(limit) < 2 || !IsAllCapsOrDigits(limit[1:]) {
+								return nil, fmt.Errorf("Unrecognized variable '%s'", limit)
+							}
+						} else if num, err := strconv.Atoi(limit); err != nil || num < 1 {
+							return nil, fmt.Errorf("Count '%s' must be a positive integer", limit)
+						}
+						if idx >= max {
+							return nil, fmt.Errorf("Element missing after count '%s'", limit)
+						}
+						str = arguments[idx]
+						idx++
+					}
 					for !strings.HasPrefix(str, "-") {
 						// create one operation per argument, even if under a single -element statement
 						op := &Operation{Type: status, Value: str}
 						comm = append(comm, op)
-						parseSteps(op, pttrn)
+						if err := parseSteps(op, pttrn); err != nil {
+							return nil, err
+						}
+						for _, stage := range op.Stages {
+							stage.Limit = limit
+						}
 						if idx >= max {
 							break
 						}
@@ -1804,20 +2417,23 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 					}
 					status = UNSET
 					if idx < max {
-						status, isExtraction = nextStatus(str)
+						status, isExtraction, err = nextStatus(str)
+						if err != nil {
+							return nil, err
+						}
 					}
 				}
 			}
 		}
 
-		return comm
+		return comm, nil
 	}
 
 	// parseOperations recursive definition
-	var parseOperations func(parent *Block)
+	var parseOperations func(parent *Block) error
 
 	// parseOperations converts parsed arguments to operations lists
-	parseOperations = func(parent *Block) {
+	parseOperations = func(parent *Block) error {
 
 		args := parent.Parsed
 
@@ -1832,6 +2448,21 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				continue
 			}
 
+			// negative -position index is not a command
+			if cur > 0 && args[cur-1] == "-position" && isPositionRange(str) {
+				continue
+			}
+
+			// negative number after numeric comparison is not a command
+			if cur > 0 && isNegativeOperand(args[cur-1], str) {
+				continue
+			}
+
+			// -if PubDate -age:days -le 90 compares date difference
+			if isAgeModifier(args, cur) {
+				continue
+			}
+
 			if argTypeIs[str] != CONDITIONAL {
 				partition = cur
 				break
@@ -1869,63 +2500,108 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 		}
 
 		// validate argument structure and convert to operations lists
-		parent.Conditions = parseConditionals(parent, conditionals)
-		parent.Commands = parseExtractions(parent, extractions)
-		parent.Failure = parseExtractions(parent, alternative)
+		var err error
+		if parent.Conditions, err = parseConditionals(parent, conditionals); err != nil {
+			return err
+		}
+		if parent.Commands, err = parseExtractions(parent, extractions); err != nil {
+			return err
+		}
+		if parent.Failure, err = parseExtractions(parent, alternative); err != nil {
+			return err
+		}
 
 		// reality checks on placement of -else command
 		if foundElse {
 			if len(conditionals) < 1 {
-				fmt.Fprintf(os.Stderr, "\nERROR: Misplaced -else command\n")
-				os.Exit(1)
+				return fmt.Errorf("Misplaced -else command")
 			}
 			if len(alternative) < 1 {
-				fmt.Fprintf(os.Stderr, "\nERROR: Misplaced -else command\n")
-				os.Exit(1)
+				return fmt.Errorf("Misplaced -else command")
 			}
 			if len(parent.Subtasks) > 0 {
-				fmt.Fprintf(os.Stderr, "\nERROR: Misplaced -else command\n")
-				os.Exit(1)
+				return fmt.Errorf("Misplaced -else command")
 			}
 		}
 
 		for _, sub := range parent.Subtasks {
-			parseOperations(sub)
+			if err := parseOperations(sub); err != nil {
+				return err
+			}
 		}
+
+		return nil
 	}
 
 	// ParseArguments
 
 	head := &Block{}
 
-	for _, txt := range cmdargs {
+	// -csv can appear anywhere, and switches the entire command tree to comma-separated output
+	doCSV := false
+
+	// -jsonpkg similarly switches the command tree to JSON key-value output
+	doJSON := false
+
+	numPatterns := 0
+
+	for i, txt := range cmdargs {
+		if txt == "-csv" {
+			doCSV = true
+			continue
+		}
+		if txt == "-jsonpkg" {
+			doJSON = true
+			continue
+		}
+		// -group-by always counts, so a following -count is accepted and ignored
+		if txt == "-count" && i > 1 && cmdargs[i-2] == "-group-by" {
+			continue
+		}
+		if txt == "-pattern" || txt == "-Pattern" {
+			numPatterns++
+		}
 		head.Working = append(head.Working, txt)
 	}
 
+	// check for multiple -pattern commands before parsing, which would otherwise fail without explanation
+	if numPatterns < 1 {
+		return nil, fmt.Errorf("No -pattern in command-line arguments")
+	}
+
+	if numPatterns > 1 {
+		return nil, fmt.Errorf("Only one -pattern command is permitted")
+	}
+
 	// initial parsing of exploration command structure
 	parseCommands(head, PATTERN)
 
 	if len(head.Subtasks) != 1 {
-		return nil
+		return nil, nil
 	}
 
 	// skip past empty placeholder
 	head = head.Subtasks[0]
 
 	// convert command strings to array of operations for faster processing
-	parseOperations(head)
+	if err := parseOperations(head); err != nil {
+		return nil, err
+	}
+
+	// comma-separated -pattern alternatives match whichever record type was partitioned
+	if strings.Contains(head.Match, ",") && head.Parent == "" && head.Position == "" {
+		head.Match = "*"
+	}
 
-	// check for no -element or multiple -pattern commands
+	// check for no -element commands
 	noElement := true
 	noClose := true
-	numPatterns := 0
 	for _, txt := range cmdargs {
-		if argTypeIs[txt] == EXTRACTION {
+		if argTypeIs[txt] == EXTRACTION || strings.HasPrefix(txt, "-fasta:") || strings.HasPrefix(txt, "-gc:") || strings.HasPrefix(txt, "-orfs:") ||
+			strings.HasPrefix(txt, "-pad:") || strings.HasPrefix(txt, "-natural:") || strings.HasPrefix(txt, "-indices:") || strings.HasPrefix(txt, "-age:") {
 			noElement = false
 		}
-		if txt == "-pattern" || txt == "-Pattern" {
-			numPatterns++
-		} else if txt == "-select" {
+		if txt == "-select" {
 			noElement = false
 			head.Position = "select"
 		} else if txt == "-cls" || txt == "-slf" {
@@ -1933,22 +2609,152 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 		}
 	}
 
-	if numPatterns < 1 {
-		fmt.Fprintf(os.Stderr, "\nERROR: No -pattern in command-line arguments\n")
-		os.Exit(1)
+	if noElement && noClose {
+		return nil, fmt.Errorf("No -element statement in argument list")
 	}
 
-	if numPatterns > 1 {
-		fmt.Fprintf(os.Stderr, "\nERROR: Only one -pattern command is permitted\n")
-		os.Exit(1)
+	if doCSV && doJSON {
+		return nil, fmt.Errorf("Cannot combine -csv with -jsonpkg")
 	}
 
-	if noElement && noClose {
-		fmt.Fprintf(os.Stderr, "\nERROR: No -element statement in argument list\n")
-		os.Exit(1)
+	if doCSV || doJSON {
+
+		frmt := "-csv"
+		if doJSON {
+			frmt = "-jsonpkg"
+		}
+
+		// XML wrapping would be broken by RFC 4180 quoting or JSON packaging
+		for _, txt := range cmdargs {
+			if txt == "-wrp" || (txt == "-tag" && doCSV) {
+				return nil, fmt.Errorf("Cannot combine %s with %s", txt, frmt)
+			}
+		}
+
+		// markFormat recursive definition
+		var markFormat func(blk *Block)
+
+		markFormat = func(blk *Block) {
+			blk.CSV = doCSV
+			blk.JSON = doJSON
+			for _, sub := range blk.Subtasks {
+				markFormat(sub)
+			}
+		}
+
+		markFormat(head)
+	}
+
+	return head, nil
+}
+
+// ColumnHeader derives a header row from the extraction commands, using -lbl text or element names
+func ColumnHeader(cmds *Block) string {
+
+	if cmds == nil {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	// separators follow processInstructions, so the header lines up with the data rows
+	tab := ""
+
+	// addColumn prints a column name preceded by the pending separator
+	addColumn := func(name, col string) {
+		buffer.WriteString(tab)
+		if cmds.CSV {
+			name = csvEncode(name)
+		}
+		buffer.WriteString(name)
+		tab = col
+	}
+
+	// stageName strips the parent from parent/element@attribute
+	stageName := func(stage *Step) string {
+		if stage.Attrib != "" {
+			return stage.Attrib
+		}
+		if stage.Match != "" {
+			return stage.Match
+		}
+		return stage.Value
+	}
+
+	// collectColumns recursive definition
+	var collectColumns func(blk *Block)
+
+	// collectColumns follows the order of processCommands, with commands preceding nested blocks
+	collectColumns = func(blk *Block) {
+
+		// column and group separators are reset for each block, as in processInstructions
+		col := "\t"
+		if cmds.CSV {
+			col = ","
+		}
+		sep := "\t"
+
+		inTag := false
+
+		for _, op := range blk.Commands {
+			switch op.Type {
+			case LBL:
+				if inTag {
+					// -att, -cls, and -end pieces belong to the -tag column
+					if strings.HasPrefix(op.Value, "</") {
+						inTag = false
+					}
+					break
+				}
+				addColumn(op.Value, col)
+			case TAG:
+				// XML constructed by -tag through -end prints as one column named by the tag
+				addColumn(strings.TrimPrefix(op.Value, "<"), col)
+				inTag = true
+			case TAB:
+				col = op.Value
+			case SEP:
+				sep = op.Value
+			case RST:
+				sep = "\t"
+			case WRP:
+				if op.Value == "" || op.Value == "-" {
+					sep = "\t"
+				} else {
+					// wrapped values are joined by XML tags, name the group as a single column
+					sep = ","
+				}
+			case PFC, CLR:
+				tab = ""
+			case DEQ:
+				tab = op.Value
+			case RET, PFX, SFX, PLG, ELG, ENC, DEF, DEFS, DEFLINE, REG, EXP, DATEFMT, ASOF, NUMFMT, COLOR,
+				VARIABLE, ACCUMULATOR, VALUE, ARITHMETIC, HISTOGRAM, GROUPBY:
+				// customizations and variables do not print columns
+			default:
+				var names []string
+				for _, stage := range op.Stages {
+					names = append(names, stageName(stage))
+				}
+				if len(names) < 1 {
+					names = append(names, op.Value)
+				}
+				if inTag {
+					break
+				}
+				// members of a comma-separated group are joined by -sep, so the default tab gives each its own column
+				addColumn(strings.Join(names, sep), col)
+			}
+		}
+
+		for _, sub := range blk.Subtasks {
+			collectColumns(sub)
+		}
 	}
 
-	return head
+	collectColumns(cmds)
+
+	return buffer.String()
 }
 
 // printXMLtree supports XML compression styles selected by -element "*" through "****"
@@ -2117,6 +2923,29 @@ func printXMLtree(node *XMLNode, style IndentType, printAttrs bool, proc func(st
 	doSubtree(node, initial)
 }
 
+// asnEntityFix decodes XML entities for ASN.1 output, converting double quotes to single quotes
+var asnEntityFix = strings.NewReplacer(
+	"&lt;", "<",
+	"&gt;", ">",
+	"&amp;", "&",
+	"&apos;", "'",
+	"&#39;", "'",
+	"&quot;", "'",
+	"&#34;", "'",
+	"\"", "'",
+)
+
+// xmlEntityFix decodes XML entities for JSON and YAML output
+var xmlEntityFix = strings.NewReplacer(
+	"&lt;", "<",
+	"&gt;", ">",
+	"&amp;", "&",
+	"&apos;", "'",
+	"&#39;", "'",
+	"&quot;", "\"",
+	"&#34;", "\"",
+)
+
 // printASNtree prints ASN.1 selected by -element "."
 func printASNtree(node *XMLNode, proc func(string)) {
 
@@ -2151,17 +2980,6 @@ func printASNtree(node *XMLNode, proc func(string)) {
 		proc(indentSpaces[i])
 	}
 
-	afix := strings.NewReplacer(
-		"&lt;", "<",
-		"&gt;", ">",
-		"&amp;", "&",
-		"&apos;", "'",
-		"&#39;", "'",
-		"&quot;", "'",
-		"&#34;", "'",
-		"\"", "'",
-	)
-
 	// doASNtree recursive definition
 	var doASNtree func(*XMLNode, int, bool)
 
@@ -2214,7 +3032,7 @@ func printASNtree(node *XMLNode, proc func(string)) {
 			if HasAdjacentSpaces(str) {
 				str = CompressRunsOfSpaces(str)
 			}
-			str = afix.Replace(str)
+			str = asnEntityFix.Replace(str)
 			proc(str)
 
 			if quot {
@@ -2251,11 +3069,38 @@ func printASNtree(node *XMLNode, proc func(string)) {
 	doASNtree(node, 0, false)
 }
 
+// isEmptyTreeNode suppresses what would be an empty self-closing tag in JSON or YAML output
+func isEmptyTreeNode(curr *XMLNode) bool {
+	return !IsNotJustWhitespace(curr.Attributes) && curr.Contents == "" && curr.Children == nil
+}
+
+// parseTreeName applies the JSON and YAML naming conventions:
+// just a hyphen   - unnamed brackets
+// leading hyphen  - array instead of object
+// trailing hyphen - unquoted value
+// internal hyphen - convert to space
+func parseTreeName(curr *XMLNode) (string, bool, bool) {
+
+	name := curr.Name
+	array := false
+	quot := true
+	if name == "_" {
+		name = ""
+	} else if strings.HasPrefix(name, "_") {
+		array = true
+	} else if strings.HasSuffix(name, "_") {
+		name = strings.TrimSuffix(name, "_")
+		quot = false
+	}
+	name = strings.Replace(name, "_", " ", -1)
+	name = strings.TrimSpace(name)
+
+	return name, array, quot
+}
+
 // printJSONtree prints JSON selected by -element "%"
 func printJSONtree(node *XMLNode, proc func(string)) {
 
-	// COPIED FROM printASNtree, MODIFICATIONS NOT YET COMPLETE
-
 	if node == nil || proc == nil {
 		return
 	}
@@ -2287,107 +3132,712 @@ func printJSONtree(node *XMLNode, proc func(string)) {
 		proc(indentSpaces[i])
 	}
 
-	// doJSONtree recursive definition
-	var doJSONtree func(*XMLNode, int, bool)
+	// doScalar prints contents, leaving numbers and booleans unquoted
+	doScalar := func(str string, quot bool) {
 
-	doJSONtree = func(curr *XMLNode, depth int, comma bool) {
+		if HasBadSpace(str) {
+			str = CleanupBadSpaces(str)
+		}
+		if IsNotASCII(str) {
+			str = TransformAccents(str, false, false)
+		}
+		if HasAdjacentSpaces(str) {
+			str = CompressRunsOfSpaces(str)
+		}
+		str = xmlEntityFix.Replace(str)
 
-		// suppress if it would be an empty self-closing tag
-		if !IsNotJustWhitespace(curr.Attributes) && curr.Contents == "" && curr.Children == nil {
+		if !quot || str == "true" || str == "false" || isJSONNumber(str) {
+			proc(str)
 			return
 		}
 
-		name := curr.Name
-		if name == "" {
-			name = "_"
-		}
+		proc(jsonEncode(str))
+	}
 
-		// just a hyphen   - unnamed brackets
-		// leading hyphen  - array instead of object
-		// trailing hyphen - unquoted value
-		show := true
-		array := false
-		quot := true
-		if name == "_" {
-			show = false
-		} else if strings.HasPrefix(name, "_") {
-			array = true
-		} else if strings.HasSuffix(name, "_") {
-			name = strings.TrimSuffix(name, "_")
-			quot = false
-		}
-		name = strings.Replace(name, "_", " ", -1)
-		name = strings.TrimSpace(name)
+	// doJSONvalue and doJSONmembers are mutually recursive
+	var doJSONvalue func(*XMLNode, int)
+	var doJSONmembers func(*XMLNode, int)
 
-		if curr.Contents != "" {
+	// doJSONvalue prints a scalar, array, or object, starting at the current output position
+	doJSONvalue = func(curr *XMLNode, depth int) {
 
-			doIndent(depth)
-			proc("\"")
-			proc(name)
-			proc("\": ")
+		_, array, quot := parseTreeName(curr)
 
-			if quot {
-				proc("\"")
+		if curr.Children == nil {
+			if curr.Contents != "" {
+				doScalar(curr.Contents, quot)
+			} else {
+				// attributes are not represented
+				proc("{}")
 			}
+			return
+		}
 
-			str := curr.Contents[:]
-			if HasBadSpace(str) {
-				str = CleanupBadSpaces(str)
-			}
-			if IsNotASCII(str) {
-				str = TransformAccents(str, false, false)
-			}
-			if HasAdjacentSpaces(str) {
-				str = CompressRunsOfSpaces(str)
+		if !array {
+			proc("{\n")
+			doJSONmembers(curr, depth+1)
+			doIndent(depth)
+			proc("}")
+			return
+		}
+
+		var items []*XMLNode
+		for chld := curr.Children; chld != nil; chld = chld.Next {
+			if !isEmptyTreeNode(chld) {
+				items = append(items, chld)
 			}
-			proc(str)
+		}
 
-			if quot {
-				proc("\"")
+		// array elements are printed without names
+		proc("[\n")
+		for i, chld := range items {
+			doIndent(depth + 1)
+			doJSONvalue(chld, depth+1)
+			// do not print comma after last element
+			if i < len(items)-1 {
+				proc(",")
 			}
+			proc("\n")
+		}
+		doIndent(depth)
+		proc("]")
+	}
 
-		} else {
+	// doJSONmembers prints object members, collapsing repeated child names into arrays
+	doJSONmembers = func(curr *XMLNode, depth int) {
 
-			doIndent(depth)
-			if show && depth > 0 {
-				proc("\"")
-				proc(name)
-				proc("\": ")
+		// keep names in order of first appearance
+		var order []string
+		groups := make(map[string][]*XMLNode)
+
+		for chld := curr.Children; chld != nil; chld = chld.Next {
+			if isEmptyTreeNode(chld) {
+				continue
 			}
-			if array {
-				proc("[")
-			} else {
-				proc("{")
+			name, _, _ := parseTreeName(chld)
+			if _, ok := groups[name]; !ok {
+				order = append(order, name)
 			}
-			proc("\n")
+			groups[name] = append(groups[name], chld)
+		}
 
-			for chld := curr.Children; chld != nil; chld = chld.Next {
-				// do not print comma after last child object in chain
-				doJSONtree(chld, depth+1, (chld.Next != nil))
-			}
+		for i, name := range order {
+
+			nodes := groups[name]
 
 			doIndent(depth)
-			if array {
+			proc(jsonEncode(name))
+			proc(": ")
+
+			if len(nodes) == 1 {
+				doJSONvalue(nodes[0], depth)
+			} else {
+				proc("[\n")
+				for j, nd := range nodes {
+					doIndent(depth + 1)
+					doJSONvalue(nd, depth+1)
+					if j < len(nodes)-1 {
+						proc(",")
+					}
+					proc("\n")
+				}
+				doIndent(depth)
 				proc("]")
+			}
+
+			// do not print comma after last member
+			if i < len(order)-1 {
+				proc(",")
+			}
+			proc("\n")
+		}
+	}
+
+	if isEmptyTreeNode(node) {
+		return
+	}
+
+	if node.Children != nil {
+		// top-level object or array is unnamed
+		doJSONvalue(node, 0)
+	} else {
+		// wrap top-level scalar in an object to keep its name
+		name, _, _ := parseTreeName(node)
+		proc("{\n")
+		doIndent(1)
+		proc(jsonEncode(name))
+		proc(": ")
+		doJSONvalue(node, 1)
+		proc("\n}")
+	}
+	proc("\n")
+}
+
+// printYAMLtree prints YAML selected by -element "="
+func printYAMLtree(node *XMLNode, proc func(string)) {
+
+	if node == nil || proc == nil {
+		return
+	}
+
+	// needsQuotes detects strings that a YAML parser would not read back as the same plain string
+	needsQuotes := func(str string) bool {
+
+		if str == "" || IsNotASCII(str) {
+			return true
+		}
+		if strings.ContainsAny(str[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.HasSuffix(str, " ") || strings.HasSuffix(str, ":") {
+			return true
+		}
+		if strings.Contains(str, ": ") || strings.Contains(str, " #") {
+			return true
+		}
+		for _, ch := range str {
+			if ch < 0x20 {
+				return true
+			}
+		}
+
+		// implicitly typed values other than JSON-compatible numbers and booleans
+		switch strings.ToLower(str) {
+		case "yes", "no", "on", "off", "y", "n", "null", "~", ".inf", ".nan":
+			return true
+		}
+		if _, err := strconv.ParseFloat(str, 64); err == nil && !isJSONNumber(str) {
+			return true
+		}
+
+		return false
+	}
+
+	// yamlKey quotes mapping keys that would not be read back as strings
+	yamlKey := func(str string) string {
+
+		if needsQuotes(str) || str == "true" || str == "false" || isJSONNumber(str) {
+			// double-quoted YAML is a superset of JSON strings
+			return jsonEncode(str)
+		}
+
+		return str
+	}
+
+	// yamlScalar cleans up contents, leaving numbers and booleans unquoted
+	yamlScalar := func(str string, quot bool) string {
+
+		if HasBadSpace(str) {
+			str = CleanupBadSpaces(str)
+		}
+		if HasAdjacentSpaces(str) {
+			str = CompressRunsOfSpaces(str)
+		}
+		str = xmlEntityFix.Replace(str)
+
+		if quot && needsQuotes(str) {
+			return jsonEncode(str)
+		}
+
+		// YAML 1.1 parsers only recognize exponents with a decimal point and signed power
+		if isJSONNumber(str) {
+			if idx := strings.IndexAny(str, "eE"); idx > 0 {
+				mant, pwr := str[:idx], str[idx+1:]
+				if !strings.Contains(mant, ".") {
+					mant += ".0"
+				}
+				if !strings.HasPrefix(pwr, "-") && !strings.HasPrefix(pwr, "+") {
+					pwr = "+" + pwr
+				}
+				str = mant + "e" + pwr
+			}
+		}
+
+		return str
+	}
+
+	// nonEmpty returns children that will be printed
+	nonEmpty := func(curr *XMLNode) []*XMLNode {
+
+		var items []*XMLNode
+		for chld := curr.Children; chld != nil; chld = chld.Next {
+			if !isEmptyTreeNode(chld) {
+				items = append(items, chld)
+			}
+		}
+
+		return items
+	}
+
+	// doYAMLitems and doYAMLmembers are mutually recursive
+	var doYAMLitems func([]*XMLNode, string)
+	var doYAMLmembers func(*XMLNode, string, string)
+
+	// doYAMLentry prints a key with its scalar value, or with a nested block on the following lines
+	doYAMLentry := func(key string, nodes []*XMLNode, lead, indent string) {
+
+		if len(nodes) > 1 {
+			// repeated sibling elements become a sequence
+			proc(lead + key + ":\n")
+			doYAMLitems(nodes, indent+"  ")
+			return
+		}
+
+		curr := nodes[0]
+		_, array, quot := parseTreeName(curr)
+
+		if curr.Children == nil {
+			if curr.Contents != "" {
+				proc(lead + key + ": " + yamlScalar(curr.Contents, quot) + "\n")
 			} else {
-				proc("}")
+				// attributes are not represented
+				proc(lead + key + ": {}\n")
 			}
+			return
 		}
 
-		if comma {
-			proc(",")
+		proc(lead + key + ":\n")
+		if array {
+			doYAMLitems(nonEmpty(curr), indent+"  ")
+		} else {
+			doYAMLmembers(curr, indent+"  ", indent+"  ")
 		}
-		proc("\n")
 	}
 
-	doJSONtree(node, 0, false)
+	// doYAMLitems prints sequence entries, with nested mappings starting on the same line as the dash
+	doYAMLitems = func(nodes []*XMLNode, indent string) {
+
+		for _, curr := range nodes {
+
+			_, array, quot := parseTreeName(curr)
+
+			if curr.Children == nil {
+				if curr.Contents != "" {
+					proc(indent + "- " + yamlScalar(curr.Contents, quot) + "\n")
+				} else {
+					proc(indent + "- {}\n")
+				}
+			} else if array {
+				proc(indent + "-\n")
+				doYAMLitems(nonEmpty(curr), indent+"  ")
+			} else {
+				doYAMLmembers(curr, indent+"- ", indent+"  ")
+			}
+		}
+	}
+
+	// doYAMLmembers prints mapping entries, using lead instead of indent on the first line
+	doYAMLmembers = func(curr *XMLNode, lead, indent string) {
+
+		// keep names in order of first appearance
+		var order []string
+		groups := make(map[string][]*XMLNode)
+
+		for _, chld := range nonEmpty(curr) {
+			name, _, _ := parseTreeName(chld)
+			if _, ok := groups[name]; !ok {
+				order = append(order, name)
+			}
+			groups[name] = append(groups[name], chld)
+		}
+
+		if len(order) < 1 {
+			proc(lead + "{}\n")
+			return
+		}
+
+		for i, name := range order {
+			pfx := indent
+			if i == 0 {
+				pfx = lead
+			}
+			doYAMLentry(yamlKey(name), groups[name], pfx, indent)
+		}
+	}
+
+	if isEmptyTreeNode(node) {
+		return
+	}
+
+	// each subtree is a separate document, so multiple records form a valid YAML stream
+	proc("---\n")
+
+	name, array, _ := parseTreeName(node)
+
+	if node.Children == nil {
+		// keep name of top-level scalar
+		doYAMLentry(yamlKey(name), []*XMLNode{node}, "", "")
+	} else if array {
+		doYAMLitems(nonEmpty(node), "")
+	} else {
+		// top-level mapping is unnamed, as in printJSONtree
+		doYAMLmembers(node, "", "")
+	}
 }
 
+// replx caches compiled -reg patterns while arguments are parsed, records use the copy in each Operation
 var (
 	rlock sync.Mutex
 	replx map[string]*regexp.Regexp
 )
 
+// replaceRule is one -reg/-exp substitution, applied in order by -replace
+type replaceRule struct {
+	Reg    string
+	Regx   *regexp.Regexp
+	Exp    string
+	hasExp bool
+}
+
+// maxReplaceCache bounds the number of compiled -reg patterns kept in replx
+const maxReplaceCache = 1024
+
+// replaceRegexp returns the compiled -reg pattern, compiling each distinct pattern only once
+func replaceRegexp(reg string) (*regexp.Regexp, error) {
+
+	rlock.Lock()
+	defer rlock.Unlock()
+
+	if replx == nil {
+		replx = make(map[string]*regexp.Regexp)
+	}
+
+	if re, found := replx[reg]; found {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	// cache is keyed by pattern, so it only fills if patterns are generated on the fly
+	if len(replx) >= maxReplaceCache {
+		replx = make(map[string]*regexp.Regexp)
+	}
+	replx[reg] = re
+
+	return re, nil
+}
+
+// csvEncode applies RFC 4180 quoting to a single -csv output field
+func csvEncode(str string) string {
+
+	if !strings.ContainsAny(str, ",\"\r\n") {
+		return str
+	}
+
+	// wrap in double quotes, doubling any internal quotes
+	return "\"" + strings.Replace(str, "\"", "\"\"", -1) + "\""
+}
+
+// jsonEncode quotes and escapes a string for -jsonpkg output
+func jsonEncode(str string) string {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("\"")
+
+	for _, ch := range str {
+		switch ch {
+		case '"':
+			buffer.WriteString("\\\"")
+		case '\\':
+			buffer.WriteString("\\\\")
+		case '\n':
+			buffer.WriteString("\\n")
+		case '\r':
+			buffer.WriteString("\\r")
+		case '\t':
+			buffer.WriteString("\\t")
+		default:
+			if ch < 0x20 {
+				buffer.WriteString(fmt.Sprintf("\\u%04x", ch))
+			} else {
+				buffer.WriteRune(ch)
+			}
+		}
+	}
+
+	buffer.WriteString("\"")
+
+	return buffer.String()
+}
+
+// isJSONNumber checks for a valid JSON integer or floating-point number
+func isJSONNumber(str string) bool {
+
+	str = strings.TrimPrefix(str, "-")
+	if str == "" || str[0] < '0' || str[0] > '9' {
+		return false
+	}
+
+	// JSON does not permit leading zeros
+	if len(str) > 1 && str[0] == '0' && str[1] >= '0' && str[1] <= '9' {
+		return false
+	}
+
+	// JSON requires digits on both sides of a decimal point
+	if strings.HasSuffix(str, ".") || strings.Contains(str, ".e") || strings.Contains(str, ".E") {
+		return false
+	}
+
+	// exclude hexadecimal and underscore forms accepted by the float parser
+	if strings.ContainsAny(str, "xXpP_") {
+		return false
+	}
+
+	_, err := strconv.ParseFloat(str, 64)
+
+	return err == nil
+}
+
+// formatFloat prints a floating-point result with trailing zeros trimmed
+func formatFloat(num float64) string {
+
+	return strconv.FormatFloat(num, 'f', -1, 64)
+}
+
+// formatStatistic prints -avg, -dev, -devp, -var, and -varp results with two decimal places,
+// except that integer input is truncated to an integer, as before, and -fmt takes full precision
+func formatStatistic(num float64, allInt bool, nmf string) string {
+
+	if nmf != "" {
+		return formatFloat(num)
+	}
+	if allInt {
+		return strconv.Itoa(int(num))
+	}
+
+	return strconv.FormatFloat(num, 'f', 2, 64)
+}
+
+// jsonValue leaves integers unquoted, and quotes everything else
+func jsonValue(str string) string {
+
+	num := strings.TrimPrefix(str, "-")
+
+	// JSON does not permit leading zeros
+	if num != "" && (num == "0" || num[0] != '0') && IsAllDigits(num) {
+		return str
+	}
+
+	return jsonEncode(str)
+}
+
+// jsonObject converts the -jsonpkg fields of a record to "key":value pairs,
+// collecting all values of a repeated key, e.g., from a -block loop, into one array
+func jsonObject(str string) string {
+
+	var keys []string
+	values := make(map[string][]string)
+
+	for _, fld := range strings.Split(str, "\x1E") {
+		if fld == "" {
+			continue
+		}
+		name, txt := SplitInTwoLeft(fld, "\x1F")
+		if _, ok := values[name]; !ok {
+			keys = append(keys, name)
+		}
+		values[name] = append(values[name], strings.Split(txt, "\x1F")...)
+	}
+
+	var buffer strings.Builder
+
+	for i, name := range keys {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(jsonEncode(name))
+		buffer.WriteString(":")
+
+		vals := values[name]
+		if len(vals) == 1 {
+			buffer.WriteString(jsonValue(vals[0]))
+			continue
+		}
+		buffer.WriteString("[")
+		for j, val := range vals {
+			if j > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(jsonValue(val))
+		}
+		buffer.WriteString("]")
+	}
+
+	return buffer.String()
+}
+
+// JSONScalar leaves numbers, booleans, and null unquoted, encoding all other strings
+func JSONScalar(str string) string {
+
+	if str == "true" || str == "false" || str == "null" || isJSONNumber(str) {
+		return str
+	}
+
+	return jsonEncode(str)
+}
+
+// groupThousands inserts commas between groups of three digits in the integer part of a number
+func groupThousands(str string) string {
+
+	start := strings.IndexAny(str, "0123456789")
+	if start < 0 {
+		return str
+	}
+	stop := start
+	for stop < len(str) && str[stop] >= '0' && str[stop] <= '9' {
+		stop++
+	}
+
+	digits := str[start:stop]
+	if len(digits) <= 3 {
+		return str
+	}
+
+	var buffer strings.Builder
+
+	lead := len(digits) % 3
+	if lead > 0 {
+		buffer.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if buffer.Len() > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(digits[i : i+3])
+	}
+
+	return str[:start] + buffer.String() + str[stop:]
+}
+
+// parseNumberFormat checks a -fmt specification, which must contain a single numeric directive
+// ("%d", "%.2f", "%,d", etc.) or consist only of "%%", and returns its verb and grouping flag
+func parseNumberFormat(spec string) (byte, bool, error) {
+
+	if spec == "%%" {
+		return '%', false, nil
+	}
+
+	verb := byte(0)
+	group := false
+
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			continue
+		}
+		if i+1 < len(spec) && spec[i+1] == '%' {
+			i++
+			continue
+		}
+		if verb != 0 {
+			return 0, false, fmt.Errorf("Multiple directives in -fmt specification '%s'", spec)
+		}
+		j := i + 1
+		if j < len(spec) && spec[j] == ',' {
+			group = true
+			j++
+		}
+		for j < len(spec) && strings.IndexByte("+- #0123456789.", spec[j]) >= 0 {
+			j++
+		}
+		if j >= len(spec) || strings.IndexByte("dfFeEgG", spec[j]) < 0 {
+			return 0, false, fmt.Errorf("Unsupported -fmt specification '%s'", spec)
+		}
+		verb = spec[j]
+		i = j
+	}
+
+	if verb == 0 {
+		return 0, false, fmt.Errorf("No numeric directive in -fmt specification '%s'", spec)
+	}
+
+	return verb, group, nil
+}
+
+// formatNumber applies a -fmt printf-like specification to a numeric value, leaving other strings
+// untouched. "%,d" groups thousands with commas, and "%%" multiplies by 100 for a percentage, either
+// alongside a directive, as in "%.1f%%", or alone, which prints the percentage with trailing zeros trimmed.
+func formatNumber(str, spec string) string {
+
+	if spec == "" || str == "" {
+		return str
+	}
+
+	flt, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(flt) || math.IsInf(flt, 0) {
+		return str
+	}
+
+	// specification was validated when the arguments were parsed
+	verb, group, err := parseNumberFormat(spec)
+	if err != nil {
+		return str
+	}
+
+	if strings.Contains(spec, "%%") {
+		flt *= 100
+	}
+
+	if group {
+		spec = strings.Replace(spec, "%,", "%", 1)
+	}
+
+	txt := ""
+	switch verb {
+	case '%':
+		// twelve significant digits hide binary rounding noise from the multiplication
+		txt = strconv.FormatFloat(flt, 'g', 12, 64) + "%"
+	case 'd':
+		txt = fmt.Sprintf(spec, int64(math.Round(flt)))
+	default:
+		txt = fmt.Sprintf(spec, flt)
+	}
+
+	if group {
+		txt = groupThousands(txt)
+	}
+
+	return txt
+}
+
+// formatDate fills YYYY, MM, and DD tokens in a -datefmt template, truncating at the first missing component
+func formatDate(year, month, day, tmpl string) string {
+
+	var buffer strings.Builder
+
+	pending := ""
+
+	for tmpl != "" {
+		val := ""
+		ln := 0
+		switch {
+		case strings.HasPrefix(tmpl, "YYYY"):
+			val, ln = year, 4
+		case strings.HasPrefix(tmpl, "MM"):
+			val, ln = month, 2
+		case strings.HasPrefix(tmpl, "DD"):
+			val, ln = day, 2
+		default:
+			// literal text is only written if followed by an available component
+			pending += tmpl[:1]
+			tmpl = tmpl[1:]
+			continue
+		}
+		if val == "" {
+			return buffer.String()
+		}
+		buffer.WriteString(pending)
+		buffer.WriteString(val)
+		pending = ""
+		tmpl = tmpl[ln:]
+	}
+
+	// trailing literal is kept when the template is complete
+	buffer.WriteString(pending)
+
+	return buffer.String()
+}
+
 // processClause handles comma-separated -element arguments
 func processClause(
 	curr *XMLNode,
@@ -2401,7 +3851,12 @@ func processClause(
 	def string,
 	reg string,
 	exp string,
+	rules []replaceRule,
+	dtf string,
+	ref string,
+	nmf string,
 	wrp bool,
+	csv bool,
 	status OpType,
 	index int,
 	level int,
@@ -2415,14 +3870,6 @@ func processClause(
 		return "", false
 	}
 
-	if replx == nil {
-		rlock.Lock()
-		if replx == nil {
-			replx = make(map[string]*regexp.Regexp)
-		}
-		rlock.Unlock()
-	}
-
 	// processElement handles individual -element constructs
 	processElement := func(acc func(string)) {
 
@@ -2459,7 +3906,7 @@ func processClause(
 				// handle usual situation with no range first
 				if norm {
 					if wrp && stat != REPLACE {
-						str = html.EscapeString(str)
+						str = EscapeIfNeeded(str)
 					}
 					acc(str)
 					return
@@ -2491,7 +3938,7 @@ func processClause(
 					}
 					if str != "" {
 						if wrp && stat != REPLACE {
-							str = html.EscapeString(str)
+							str = EscapeIfNeeded(str)
 						}
 						acc(str)
 					}
@@ -2567,7 +4014,7 @@ func processClause(
 						str = strings.ToUpper(str)
 					}
 					if wrp && stat != REPLACE {
-						str = html.EscapeString(str)
+						str = EscapeIfNeeded(str)
 					}
 					acc(str)
 				} else if max == 0 {
@@ -2581,7 +4028,7 @@ func processClause(
 								str = strings.ToUpper(str)
 							}
 							if wrp && stat != REPLACE {
-								str = html.EscapeString(str)
+								str = EscapeIfNeeded(str)
 							}
 							acc(str)
 						}
@@ -2597,7 +4044,7 @@ func processClause(
 								str = strings.ToUpper(str)
 							}
 							if wrp && stat != REPLACE {
-								str = html.EscapeString(str)
+								str = EscapeIfNeeded(str)
 							}
 							acc(str)
 						}
@@ -2613,7 +4060,7 @@ func processClause(
 								str = strings.ToUpper(str)
 							}
 							if wrp && stat != REPLACE {
-								str = html.EscapeString(str)
+								str = EscapeIfNeeded(str)
 							}
 							acc(str)
 						}
@@ -2723,6 +4170,24 @@ func processClause(
 						}
 					})
 
+				txt := buffer.String()
+				if txt != "" {
+					if strings.HasSuffix(txt, "\n") {
+						txt = strings.TrimSuffix(txt, "\n")
+					}
+					acc(txt)
+				}
+			case EQLSIGN:
+				// -element "=" prints current XML subtree as YAML
+				var buffer strings.Builder
+
+				printYAMLtree(curr,
+					func(str string) {
+						if str != "" {
+							buffer.WriteString(str)
+						}
+					})
+
 				txt := buffer.String()
 				if txt != "" {
 					if strings.HasSuffix(txt, "\n") {
@@ -2767,12 +4232,29 @@ func processClause(
 					acc(chld.Name)
 				}
 			case ATSIGN:
-				if curr.Attributes != "" && curr.Attribs == nil {
-					curr.Attribs = ParseAttributes(curr.Attributes)
+				if curr.Attributes != "" && len(curr.Attribs) == 0 {
+					curr.Attribs = parseAttributesInto(curr.Attributes, curr.Attribs)
 				}
 				for i := 0; i < len(curr.Attribs)-1; i += 2 {
 					acc(curr.Attribs[i])
 				}
+			case ATSTAR:
+				// -element "@*" prints all attributes as name=value pairs in document order
+				if curr.Attributes != "" && len(curr.Attribs) == 0 {
+					curr.Attribs = parseAttributesInto(curr.Attributes, curr.Attribs)
+				}
+				for i := 0; i < len(curr.Attribs)-1; i += 2 {
+					name := curr.Attribs[i]
+					val := curr.Attribs[i+1]
+					if HasAmpOrNotASCII(val) {
+						val = html.UnescapeString(val)
+					}
+					if wrp {
+						acc("<Attr name=\"" + EscapeIfNeeded(name) + "\">" + EscapeIfNeeded(val) + "</Attr>")
+					} else {
+						acc(name + "=" + val)
+					}
+				}
 			default:
 				exploreElements(func(str string, lvl int) {
 					if str != "" {
@@ -2783,6 +4265,32 @@ func processClause(
 		}
 	}
 
+	// collectNumbers gathers integer or floating-point values, noting whether all were integers for backward-compatible output
+	collectNumbers := func() ([]int, []float64, bool) {
+
+		var ints []int
+		var flts []float64
+		allInt := true
+
+		processElement(func(str string) {
+			flt, err := strconv.ParseFloat(str, 64)
+			if err != nil || math.IsNaN(flt) || math.IsInf(flt, 0) {
+				return
+			}
+			flts = append(flts, flt)
+			if allInt {
+				num, err := strconv.Atoi(str)
+				if err != nil {
+					allInt = false
+				} else {
+					ints = append(ints, num)
+				}
+			}
+		})
+
+		return ints, flts, allInt
+	}
+
 	ok := false
 
 	// format results in buffer
@@ -2790,64 +4298,186 @@ func processClause(
 
 	buffer.WriteString(prev)
 	buffer.WriteString(plg)
+	// prefix, values, and suffix are quoted as a single field in -csv mode
+	start := buffer.Len()
 	buffer.WriteString(pfx)
 	between := ""
 
-	switch status {
-	case ELEMENT:
+	switch status {
+	case ELEMENT:
+		processElement(func(str string) {
+			if str != "" {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(formatNumber(str, nmf))
+				between = sep
+			}
+		})
+
+	case FIRST:
+		single := ""
+
+		processElement(func(str string) {
+			ok = true
+			if single == "" {
+				single = str
+			}
+		})
+
+		if single != "" {
+			buffer.WriteString(between)
+			buffer.WriteString(formatNumber(single, nmf))
+			between = sep
+		}
+
+	case LAST:
+		single := ""
+
+		processElement(func(str string) {
+			ok = true
+			single = str
+		})
+
+		if single != "" {
+			buffer.WriteString(between)
+			buffer.WriteString(formatNumber(single, nmf))
+			between = sep
+		}
+
+	case FIRSTN, LASTN:
+		limit := ""
+		if len(stages) > 0 {
+			limit = stages[0].Limit
+		}
+		if strings.HasPrefix(limit, "&") {
+			// expand variable to get actual count
+			limit = variables[limit[1:]]
+		}
+		count, err := strconv.Atoi(limit)
+		if err != nil || count < 1 {
+			break
+		}
+
+		var arry []string
+
+		processElement(func(str string) {
+			if str != "" {
+				arry = append(arry, str)
+			}
+		})
+
+		// print all values if fewer than requested
+		if len(arry) > count {
+			if status == FIRSTN {
+				arry = arry[:count]
+			} else {
+				arry = arry[len(arry)-count:]
+			}
+		}
+
+		for _, str := range arry {
+			ok = true
+			buffer.WriteString(between)
+			buffer.WriteString(formatNumber(str, nmf))
+			between = sep
+		}
+
+	case BACKWARD:
+		var arry []string
+
+		processElement(func(str string) {
+			if str != "" {
+				ok = true
+				arry = append(arry, str)
+			}
+		})
+
+		if ok {
+			for i := len(arry) - 1; i >= 0; i-- {
+				buffer.WriteString(between)
+				buffer.WriteString(arry[i])
+				between = sep
+			}
+		}
+
+	case UNIQUE, UNIQLOWER, AUTHKEY:
+		// remove duplicate values, keeping first-seen order
+		seen := make(map[string]bool)
+
 		processElement(func(str string) {
+			if status == AUTHKEY {
+				// compare normalized "lastname first-initial" author keys
+				str = AuthorKey(str)
+			}
 			if str != "" {
+				if status == UNIQLOWER {
+					// fold case before comparing
+					str = strings.ToLower(str)
+				}
+				if seen[str] {
+					return
+				}
+				seen[str] = true
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
 
-	case FIRST:
-		single := ""
+	case SORTVALUES:
+		var arry []string
 
 		processElement(func(str string) {
-			ok = true
-			if single == "" {
-				single = str
+			if str != "" {
+				ok = true
+				arry = append(arry, str)
 			}
 		})
 
-		if single != "" {
-			buffer.WriteString(between)
-			buffer.WriteString(single)
-			between = sep
-		}
-
-	case LAST:
-		single := ""
-
-		processElement(func(str string) {
-			ok = true
-			single = str
-		})
-
-		if single != "" {
-			buffer.WriteString(between)
-			buffer.WriteString(single)
-			between = sep
+		if ok {
+			// case-insensitive, stable for equal keys
+			sort.SliceStable(arry, func(i, j int) bool { return strings.ToLower(arry[i]) < strings.ToLower(arry[j]) })
+			for _, str := range arry {
+				buffer.WriteString(between)
+				buffer.WriteString(formatNumber(str, nmf))
+				between = sep
+			}
 		}
 
-	case BACKWARD:
-		var arry []string
+	case SORTNUMERIC:
+		var nums []string
+		var vals []float64
+		var other []string
 
 		processElement(func(str string) {
 			if str != "" {
 				ok = true
-				arry = append(arry, str)
+				flt, err := strconv.ParseFloat(str, 64)
+				if err != nil || math.IsNaN(flt) {
+					// unparseable values go at the end in original order
+					other = append(other, str)
+					return
+				}
+				nums = append(nums, str)
+				vals = append(vals, flt)
 			}
 		})
 
 		if ok {
-			for i := len(arry) - 1; i >= 0; i-- {
+			idx := make([]int, len(vals))
+			for i := range idx {
+				idx[i] = i
+			}
+			sort.SliceStable(idx, func(i, j int) bool { return vals[idx[i]] < vals[idx[j]] })
+			for _, i := range idx {
 				buffer.WriteString(between)
-				buffer.WriteString(arry[i])
+				buffer.WriteString(nums[i])
+				between = sep
+			}
+			for _, str := range other {
+				buffer.WriteString(between)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		}
@@ -2857,10 +4487,10 @@ func processClause(
 			if str != "" {
 				ok = true
 				if !wrp {
-					str = html.EscapeString(str)
+					str = EscapeIfNeeded(str)
 				}
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2885,7 +4515,7 @@ func processClause(
 				ok = true
 				str = strings.ToUpper(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2896,7 +4526,7 @@ func processClause(
 				ok = true
 				str = strings.ToLower(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2907,7 +4537,7 @@ func processClause(
 				ok = true
 				str = strings.Replace(str, " ", "_", -1)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2921,7 +4551,7 @@ func processClause(
 				csr := cases.Title(language.English)
 				str = csr.String(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2936,7 +4566,7 @@ func processClause(
 				}
 				str = string(runes)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -2954,7 +4584,7 @@ func processClause(
 				if str != "" {
 					ok = true
 					buffer.WriteString(between)
-					buffer.WriteString(str)
+					buffer.WriteString(formatNumber(str, nmf))
 					between = sep
 				}
 			}
@@ -2987,7 +4617,7 @@ func processClause(
 					str = FixMisusedLetters(str, true, false, true)
 					str = TransformAccents(str, false, false)
 					if wrp {
-						str = html.EscapeString(str)
+						str = EscapeIfNeeded(str)
 					}
 				}
 
@@ -3011,7 +4641,7 @@ func processClause(
 					str = RemoveExtraSpaces(str)
 				}
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3022,7 +4652,7 @@ func processClause(
 				ok = true
 				str = SortStringByWords(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3146,7 +4776,7 @@ func processClause(
 						year = raw[0:4]
 					}
 
-				} else if strings.Contains(str, "PubDate") {
+				} else if strings.Contains(str, "PubDate") && !strings.Contains(str, "<Year>") {
 
 					str = extractBetweenTags(str, "PubDate")
 					items := strings.Split(str, " ")
@@ -3187,27 +4817,29 @@ func processClause(
 			}
 		})
 
+		if month != "" && len(month) == 1 {
+			month = "0" + month
+		}
+		if day != "" && len(day) == 1 {
+			day = "0" + day
+		}
+
 		slash := "/"
 		if reg == "/" && exp != "" {
 			slash = exp
 		}
 
 		txt := ""
-		if year != "" {
+		if year != "" && dtf != "" {
+			txt = formatDate(year, month, day, dtf)
+			ok = true
+		} else if year != "" {
 			buffer.WriteString(between)
 			txt = year
 			if month != "" {
-				if len(month) == 1 {
-					txt += slash + "0" + month
-				} else {
-					txt += slash + month
-				}
+				txt += slash + month
 				if day != "" {
-					if len(day) == 1 {
-						txt += slash + "0" + day
-					} else {
-						txt += slash + day
-					}
+					txt += slash + day
 				}
 			}
 			ok = true
@@ -3219,6 +4851,44 @@ func processClause(
 			between = sep
 		}
 
+	case AGE:
+		// xtract -pattern PubmedArticle -asof 2024/06/30 -age:years PubDate
+		unit := "days"
+		if len(stages) > 0 && stages[0].Limit != "" {
+			unit = stages[0].Limit
+		}
+
+		sendAge := func(date string) {
+			txt := dateAge(date, ref, unit)
+			if txt != "" {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(txt)
+				between = sep
+			}
+		}
+
+		for _, stage := range stages {
+			switch stage.Type {
+			case VARIABLE:
+				val, found := variables[stage.Match]
+				if found {
+					sendAge(normalizeDateText(val))
+				}
+			default:
+				if stage.Attrib != "" {
+					ExploreElements(curr, mask, stage.Parent, stage.Match, stage.Attrib, stage.Wild, true, level, func(str string, lvl int) {
+						sendAge(normalizeDateText(str))
+					})
+					break
+				}
+				// date containers, e.g., PubDate with Year, Month, and Day children
+				ExploreNodes(curr, stage.Parent, stage.Match, 0, level, func(node *XMLNode, idx, lvl int) {
+					sendAge(normalizeDateNode(node))
+				})
+			}
+		}
+
 	case PAGE:
 		processElement(func(str string) {
 			if str != "" {
@@ -3244,7 +4914,7 @@ func processClause(
 				// convert GenBank author to searchable form
 				str = GenBankToMedlineAuthors(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3270,7 +4940,7 @@ func processClause(
 				}
 				str = strings.ToUpper(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3281,7 +4951,7 @@ func processClause(
 				ok = true
 				str = CleanJournal(str)
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3321,7 +4991,7 @@ func processClause(
 				if str != "" {
 					ok = true
 					buffer.WriteString(between)
-					buffer.WriteString(str)
+					buffer.WriteString(formatNumber(str, nmf))
 					between = sep
 				}
 			}
@@ -3361,7 +5031,7 @@ func processClause(
 			// total number of words
 			val := strconv.Itoa(count)
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
@@ -3377,7 +5047,7 @@ func processClause(
 				str = url.QueryEscape(str)
 				str = "https://doi.org/" + str
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3399,31 +5069,22 @@ func processClause(
 	case REPLACE:
 		processElement(func(str string) {
 			if str != "" {
-				rlock.Lock()
-				re, found := replx[str]
-				if !found {
-					re, found = replx[str]
-					if !found {
-						nw, err := regexp.Compile(reg)
-						if err == nil {
-							replx[str] = nw
-							re = nw
-						}
+				// each -reg/-exp rule rewrites the result of the previous one, $1 and ${name} expand capture groups
+				txt := str
+				for _, rl := range rules {
+					if rl.Regx != nil {
+						txt = rl.Regx.ReplaceAllString(txt, rl.Exp)
 					}
 				}
-				rlock.Unlock()
-				if re != nil {
-					txt := re.ReplaceAllString(str, exp)
-					if txt != "" {
-						ok = true
-						buffer.WriteString(between)
-						// wrp-directed EscapeString was delayed for REPLACE
-						if wrp {
-							txt = html.EscapeString(txt)
-						}
-						buffer.WriteString(txt)
-						between = sep
+				if txt != "" {
+					ok = true
+					buffer.WriteString(between)
+					// wrp-directed EscapeString was delayed for REPLACE
+					if wrp {
+						txt = EscapeIfNeeded(txt)
 					}
+					buffer.WriteString(txt)
+					between = sep
 				}
 			}
 		})
@@ -3433,7 +5094,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3443,7 +5104,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3453,7 +5114,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3463,7 +5124,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3473,7 +5134,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3483,7 +5144,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3493,7 +5154,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3503,7 +5164,7 @@ func processClause(
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -3520,266 +5181,258 @@ func processClause(
 			// length of element strings
 			val := strconv.Itoa(length)
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case SUM:
-		sum := 0
+		ints, flts, allInt := collectNumbers()
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				sum += value
-				ok = true
-			}
-		})
-
-		if ok {
+		if len(flts) > 0 {
+			ok = true
 			// sum of element values
-			val := strconv.Itoa(sum)
+			val := ""
+			if allInt {
+				sum := 0
+				for _, num := range ints {
+					sum += num
+				}
+				val = strconv.Itoa(sum)
+			} else {
+				sum := 0.0
+				for _, flt := range flts {
+					sum += flt
+				}
+				val = formatFloat(sum)
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case ACC:
-		sum := 0
+		ints, flts, allInt := collectNumbers()
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				sum += value
-				ok = true
-				// running sum of element values
-				val := strconv.Itoa(sum)
-				buffer.WriteString(between)
-				buffer.WriteString(val)
-				between = sep
+		isum := 0
+		fsum := 0.0
+
+		for i, flt := range flts {
+			ok = true
+			// running sum of element values
+			val := ""
+			if allInt {
+				isum += ints[i]
+				val = strconv.Itoa(isum)
+			} else {
+				fsum += flt
+				val = formatFloat(fsum)
 			}
-		})
+			buffer.WriteString(between)
+			buffer.WriteString(formatNumber(val, nmf))
+			between = sep
+		}
 
 	case MIN:
-		min := 0
+		ints, flts, allInt := collectNumbers()
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				if !ok || value < min {
-					min = value
+		if len(flts) > 0 {
+			ok = true
+			// minimum of element values
+			val := ""
+			if allInt {
+				min := ints[0]
+				for _, num := range ints {
+					if num < min {
+						min = num
+					}
 				}
-				ok = true
+				val = strconv.Itoa(min)
+			} else {
+				min := flts[0]
+				for _, flt := range flts {
+					if flt < min {
+						min = flt
+					}
+				}
+				val = formatFloat(min)
 			}
-		})
-
-		if ok {
-			// minimum of element values
-			val := strconv.Itoa(min)
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case MAX:
-		max := 0
+		ints, flts, allInt := collectNumbers()
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				if !ok || value > max {
-					max = value
+		if len(flts) > 0 {
+			ok = true
+			// maximum of element values
+			val := ""
+			if allInt {
+				max := ints[0]
+				for _, num := range ints {
+					if num > max {
+						max = num
+					}
 				}
-				ok = true
+				val = strconv.Itoa(max)
+			} else {
+				max := flts[0]
+				for _, flt := range flts {
+					if flt > max {
+						max = flt
+					}
+				}
+				val = formatFloat(max)
 			}
-		})
-
-		if ok {
-			// maximum of element values
-			val := strconv.Itoa(max)
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case SUB:
-		first := 0
-		second := 0
-		count := 0
-
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				count++
-				if count == 1 {
-					first = value
-				} else if count == 2 {
-					second = value
-				}
-			}
-		})
+		ints, flts, allInt := collectNumbers()
 
-		if count == 2 {
+		if len(flts) == 2 {
 			// must have exactly 2 elements
 			ok = true
 			// difference of element values
-			val := strconv.Itoa(first - second)
+			val := ""
+			if allInt {
+				val = strconv.Itoa(ints[0] - ints[1])
+			} else {
+				val = formatFloat(flts[0] - flts[1])
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case AVG:
-		sum := 0
-		count := 0
-
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				sum += value
-				count++
-				ok = true
-			}
-		})
+		_, flts, allInt := collectNumbers()
 
-		if ok {
+		if len(flts) > 0 {
+			ok = true
 			// average of element values
-			avg := int(float64(sum) / float64(count))
-			val := strconv.Itoa(avg)
+			sum := 0.0
+			for _, flt := range flts {
+				sum += flt
+			}
+			avg := sum / float64(len(flts))
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(formatStatistic(avg, allInt, nmf), nmf))
 			between = sep
 		}
 
-	case DEV:
+	case DEV, DEVP, VAR, VARP:
+		_, flts, allInt := collectNumbers()
+
 		count := 0
 		mean := 0.0
 		m2 := 0.0
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				// Welford algorithm for one-pass standard deviation
-				count++
-				x := float64(value)
-				delta := x - mean
-				mean += delta / float64(count)
-				m2 += delta * (x - mean)
-			}
-		})
+		for _, x := range flts {
+			// Welford algorithm for one-pass standard deviation
+			count++
+			delta := x - mean
+			mean += delta / float64(count)
+			m2 += delta * (x - mean)
+		}
 
-		if count > 1 {
-			// must have at least 2 elements
+		// sample statistics need at least 2 elements, population statistics need 1
+		if count > 1 || (count > 0 && (status == DEVP || status == VARP)) {
 			ok = true
-			// standard deviation of element values
-			vrc := m2 / float64(count-1)
-			dev := int(math.Sqrt(vrc))
-			val := strconv.Itoa(dev)
+			vrc := 0.0
+			if status == DEVP || status == VARP {
+				vrc = m2 / float64(count)
+			} else {
+				vrc = m2 / float64(count-1)
+			}
+			res := vrc
+			if status == DEV || status == DEVP {
+				// standard deviation of element values
+				res = math.Sqrt(vrc)
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(formatStatistic(res, allInt, nmf), nmf))
 			between = sep
 		}
 
 	case MED:
-		var arry []int
-		count := 0
+		ints, flts, allInt := collectNumbers()
 
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				arry = append(arry, value)
-				count++
-				ok = true
-			}
-		})
+		count := len(flts)
 
-		if ok {
+		if count > 0 {
+			ok = true
 			// median of element values
-			sort.Slice(arry, func(i, j int) bool { return arry[i] < arry[j] })
-			med := arry[count/2]
-			val := strconv.Itoa(med)
+			val := ""
+			if allInt {
+				sort.Slice(ints, func(i, j int) bool { return ints[i] < ints[j] })
+				val = strconv.Itoa(ints[count/2])
+			} else {
+				sort.Slice(flts, func(i, j int) bool { return flts[i] < flts[j] })
+				val = formatFloat(flts[count/2])
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case MUL:
-		first := 0
-		second := 0
-		count := 0
-
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				count++
-				if count == 1 {
-					first = value
-				} else if count == 2 {
-					second = value
-				}
-			}
-		})
+		ints, flts, allInt := collectNumbers()
 
-		if count == 2 {
+		if len(flts) == 2 {
 			// must have exactly 2 elements
 			ok = true
 			// product of element values
-			val := strconv.Itoa(first * second)
+			val := ""
+			if allInt {
+				val = strconv.Itoa(ints[0] * ints[1])
+			} else {
+				val = formatFloat(flts[0] * flts[1])
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case DIV:
-		first := 0
-		second := 0
-		count := 0
-
-		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
-			if err == nil {
-				count++
-				if count == 1 {
-					first = value
-				} else if count == 2 {
-					second = value
-				}
-			}
-		})
+		ints, flts, allInt := collectNumbers()
 
-		if count == 2 {
-			// must have exactly 2 elements
+		// must have exactly 2 elements, and cannot divide by zero
+		if len(flts) == 2 && flts[1] != 0 {
 			ok = true
 			// quotient of element values
-			val := strconv.Itoa(first / second)
+			val := ""
+			if allInt {
+				val = strconv.Itoa(ints[0] / ints[1])
+			} else {
+				val = formatFloat(flts[0] / flts[1])
+			}
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
 	case MOD:
-		first := 0
-		second := 0
-		count := 0
+		var ints []int
 
+		// modulus is only defined for integers, so fractional values are skipped
 		processElement(func(str string) {
-			value, err := strconv.Atoi(str)
+			num, err := strconv.Atoi(str)
 			if err == nil {
-				count++
-				if count == 1 {
-					first = value
-				} else if count == 2 {
-					second = value
-				}
+				ints = append(ints, num)
 			}
 		})
 
-		if count == 2 {
+		if len(ints) == 2 && ints[1] != 0 {
 			// must have exactly 2 elements
 			ok = true
 			// modulus of element values
-			val := strconv.Itoa(first % second)
+			val := strconv.Itoa(ints[0] % ints[1])
 			buffer.WriteString(between)
-			buffer.WriteString(val)
+			buffer.WriteString(formatNumber(val, nmf))
 			between = sep
 		}
 
@@ -3799,7 +5452,7 @@ func processClause(
 				dec, _ := math.Modf(lg)
 				val := strconv.Itoa(int(dec))
 				buffer.WriteString(between)
-				buffer.WriteString(val)
+				buffer.WriteString(formatNumber(val, nmf))
 				between = sep
 				ok = true
 			}
@@ -3812,7 +5465,7 @@ func processClause(
 				// convert to binary representation
 				val := strconv.FormatInt(int64(num), 2)
 				buffer.WriteString(between)
-				buffer.WriteString(val)
+				buffer.WriteString(formatNumber(val, nmf))
 				between = sep
 				ok = true
 			}
@@ -3825,7 +5478,7 @@ func processClause(
 				// convert to octal representation
 				val := strconv.FormatInt(int64(num), 8)
 				buffer.WriteString(between)
-				buffer.WriteString(val)
+				buffer.WriteString(formatNumber(val, nmf))
 				between = sep
 				ok = true
 			}
@@ -3840,7 +5493,7 @@ func processClause(
 				val = strings.ToUpper(val)
 				// val := fmt.Sprintf("%X", num)
 				buffer.WriteString(between)
-				buffer.WriteString(val)
+				buffer.WriteString(formatNumber(val, nmf))
 				between = sep
 				ok = true
 			}
@@ -3858,16 +5511,37 @@ func processClause(
 				}
 				val := strconv.Itoa(count)
 				buffer.WriteString(between)
-				buffer.WriteString(val)
+				buffer.WriteString(formatNumber(val, nmf))
+				between = sep
+				ok = true
+			}
+		})
+
+	case PAD:
+		width := 8
+		if len(stages) > 0 && stages[0].Limit != "" {
+			// width was validated by -pad:N parser
+			width, _ = strconv.Atoi(stages[0].Limit)
+		}
+		processElement(func(str string) {
+			if str != "" {
+				str = PadNumericIDWidth(str, width)
+				buffer.WriteString(between)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 				ok = true
 			}
 		})
 
-	case PAD:
+	case NATURAL:
+		width := 8
+		if len(stages) > 0 && stages[0].Limit != "" {
+			// width was validated by -natural:N parser
+			width, _ = strconv.Atoi(stages[0].Limit)
+		}
 		processElement(func(str string) {
 			if str != "" {
-				str = PadNumericID(str)
+				str = NaturalSortKey(str, width)
 				buffer.WriteString(between)
 				buffer.WriteString(str)
 				between = sep
@@ -3880,7 +5554,7 @@ func processClause(
 		processElement(func(str string) {
 			if str != "" {
 				buffer.WriteString(between)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 				ok = true
 			}
@@ -3892,17 +5566,22 @@ func processClause(
 				ok = true
 				buffer.WriteString(between)
 				str = ReverseComplement(str)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
 
 	case FASTA:
+		width := 70
+		if len(stages) > 0 && stages[0].Limit != "" {
+			// width was validated by -fasta:N parser
+			width, _ = strconv.Atoi(stages[0].Limit)
+		}
 		processElement(func(str string) {
 			for str != "" {
 				mx := len(str)
-				if mx > 70 {
-					mx = 70
+				if mx > width {
+					mx = width
 				}
 				item := str[:mx]
 				str = str[mx:]
@@ -3914,35 +5593,107 @@ func processClause(
 			}
 		})
 
-	case NCBI2NA:
+	case NCBI2NA, HEX2NA, NCBI4NA:
+		// residues per byte
+		per := 4
+		if status == NCBI4NA {
+			per = 2
+		}
+		// sequence length recorded in Seq-inst, if unambiguous, removes padding
+		length := packedSequenceLength(curr)
 		processElement(func(str string) {
 			if str != "" {
+				seq := ""
+				switch status {
+				case NCBI2NA:
+					seq = Ncbi2naToIupac(str)
+				case HEX2NA:
+					seq = Hex2naToIupac(str)
+				case NCBI4NA:
+					seq = Ncbi4naToIupac(str)
+				}
+				if seq == "" {
+					if len(str) > 40 {
+						str = str[:40] + "..."
+					}
+					fmt.Fprintf(os.Stderr, "\nWARNING: Unable to decode packed sequence '%s'\n", str)
+					return
+				}
+				seq, match := TrimPackedSequence(seq, length, per)
+				if !match {
+					fmt.Fprintf(os.Stderr, "\nWARNING: Decoded %d residues, but sequence length is %d\n", len(seq), length)
+				}
 				ok = true
 				buffer.WriteString(between)
-				str = Ncbi2naToIupac(str)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(seq, nmf))
 				between = sep
 			}
 		})
 
-	case NCBI4NA:
+	case MOLWT:
 		processElement(func(str string) {
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				str = Ncbi4naToIupac(str)
-				buffer.WriteString(str)
+				str = ProteinMass(str, true, massOpts)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
 
-	case MOLWT:
+	case AA3TO1:
 		processElement(func(str string) {
 			if str != "" {
 				ok = true
 				buffer.WriteString(between)
-				str = ProteinWeight(str, true)
-				buffer.WriteString(str)
+				str = AminoAcid3to1(str)
+				buffer.WriteString(formatNumber(str, nmf))
+				between = sep
+			}
+		})
+
+	case AA1TO3:
+		processElement(func(str string) {
+			if str != "" {
+				ok = true
+				buffer.WriteString(between)
+				str = AminoAcid1to3(str)
+				buffer.WriteString(formatNumber(str, nmf))
+				between = sep
+			}
+		})
+
+	case GCPCT:
+		window, step := 0, 0
+		if len(stages) > 0 && stages[0].Limit != "" {
+			// window and step were validated by -gc: parser
+			window, step, _ = parseWindowOptions(stages[0].Limit)
+		}
+		processElement(func(str string) {
+			if str == "" {
+				return
+			}
+			ok = true
+			buffer.WriteString(between)
+			if window > 0 {
+				buffer.WriteString(strings.Join(GCWindows(str, window, step), "\n"))
+			} else {
+				buffer.WriteString(GCContent(str))
+			}
+			between = sep
+		})
+
+	case ORFS:
+		minLen, genCode, atgOnly, doProtein := 300, 1, false, false
+		if len(stages) > 0 && stages[0].Limit != "" {
+			// settings were validated by -orfs: parser
+			minLen, genCode, atgOnly, doProtein, _ = parseOrfOptions(stages[0].Limit)
+		}
+		processElement(func(str string) {
+			for _, item := range FindOpenReadingFrames(str, genCode, minLen, atgOnly, doProtein, wrp) {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(item)
 				between = sep
 			}
 		})
@@ -3953,7 +5704,7 @@ func processClause(
 				ok = true
 				buffer.WriteString(between)
 				str = ParseHGVS(str)
-				buffer.WriteString(str)
+				buffer.WriteString(formatNumber(str, nmf))
 				between = sep
 			}
 		})
@@ -4174,6 +5925,9 @@ func processClause(
 			switch status {
 			case INDICES:
 				label = "TIAB"
+				if len(stages) > 0 && stages[0].Limit != "" {
+					label = stages[0].Limit
+				}
 			case ARTICLE:
 				label = "TITL"
 			case ABSTRACT:
@@ -4491,25 +6245,61 @@ func processClause(
 			if str != "" {
 				kywds := make(map[string]bool)
 
+				type phraseHit struct {
+					start int
+					stop  int
+					pat   string
+				}
+
+				var hits []phraseHit
+
 				// search for whole word or whole phrase substrings
 				srchr.Search(str[:],
 					func(str, pat string, pos int) bool {
-						mtch := strings.TrimSpace(pat)
-						rslt := transform[mtch]
-						if rslt != "" {
-							items := strings.Split(rslt, ",")
-							for _, itm := range items {
-								tag, val := SplitInTwoRight(itm, ":")
-								txt := val
-								if tag != "" {
-									txt = "<" + tag + ">" + val + "</" + tag + ">"
-								}
-								kywds[txt] = true
-							}
-						}
+						// whole-word patterns include a flanking space on each side
+						hits = append(hits, phraseHit{start: pos + 1, stop: pos + len(pat) - 1, pat: pat})
 						return true
 					})
 
+				// overlapping phrases prefer the longest match, e.g., lung cancer over cancer
+				sort.Slice(hits, func(i, j int) bool {
+					li := hits[i].stop - hits[i].start
+					lj := hits[j].stop - hits[j].start
+					if li != lj {
+						return li > lj
+					}
+					return hits[i].start < hits[j].start
+				})
+
+				var taken []phraseHit
+				for _, ht := range hits {
+					overlaps := false
+					for _, tk := range taken {
+						if ht.start < tk.stop && tk.start < ht.stop {
+							overlaps = true
+							break
+						}
+					}
+					if overlaps {
+						continue
+					}
+					taken = append(taken, ht)
+
+					mtch := strings.TrimSpace(ht.pat)
+					rslt := transform[mtch]
+					if rslt != "" {
+						items := strings.Split(rslt, ",")
+						for _, itm := range items {
+							tag, val := SplitInTwoRight(itm, ":")
+							txt := val
+							if tag != "" {
+								txt = "<" + tag + ">" + val + "</" + tag + ">"
+							}
+							kywds[txt] = true
+						}
+					}
+				}
+
 				var keys []string
 				for ky := range kywds {
 					keys = append(keys, ky)
@@ -4577,6 +6367,30 @@ func processClause(
 			}
 		})
 
+	case GROUPBY:
+		var arry []string
+
+		processElement(func(str string) {
+			if str != "" {
+				arry = append(arry, str)
+			}
+		})
+
+		if len(arry) > 0 {
+			ok = true
+
+			// all values in comma-separated group form a single key
+			key := strings.Join(arry, "\t")
+
+			hlock.Lock()
+
+			val := histogram[key]
+			val++
+			histogram[key] = val
+
+			hlock.Unlock()
+		}
+
 	case ACCENTED:
 		processElement(func(str string) {
 			if str != "" {
@@ -4590,7 +6404,7 @@ func processClause(
 				if found {
 					ok = true
 					buffer.WriteString(between)
-					buffer.WriteString(str)
+					buffer.WriteString(formatNumber(str, nmf))
 					between = sep
 				}
 			}
@@ -4608,7 +6422,7 @@ func processClause(
 					if found {
 						ok = true
 						buffer.WriteString(between)
-						buffer.WriteString(str)
+						buffer.WriteString(formatNumber(str, nmf))
 						between = sep
 					}
 				}
@@ -4779,6 +6593,10 @@ func processClause(
 
 	txt := buffer.String()
 
+	if csv {
+		txt = txt[:start] + csvEncode(txt[start:])
+	}
+
 	return txt, true
 }
 
@@ -4791,6 +6609,8 @@ func processInstructions(
 	ret string,
 	index int,
 	level int,
+	csv bool,
+	jsn bool,
 	variables map[string]string,
 	transform map[string]string,
 	srchr *FSMSearcher,
@@ -4811,12 +6631,43 @@ func processInstructions(
 
 	def := ""
 
+	// -defs values are used positionally by the following extraction commands, then revert to -def
+	var defs []string
+
+	// nextDefault returns the placeholder for the next extraction command, and whether it came from -defs
+	nextDefault := func() (string, bool) {
+		if len(defs) == 0 {
+			return def, false
+		}
+		str := defs[0]
+		defs = defs[1:]
+		return str, true
+	}
+
 	reg := ""
 	exp := ""
+	dtf := ""
+	nmf := ""
+
+	// -asof reference date for -age, current date if empty
+	ref := ""
+
+	// -reg/-exp pairs since the last -replace, a -reg or -exp after a -replace starts a new list
+	var rules []replaceRule
+	var rgx *regexp.Regexp
+	replaced := false
+
+	// -defline value printed before the next -fasta sequence
+	dfl := ""
+	hasDfl := false
 
 	col := "\t"
 	lin := "\n"
 
+	if csv {
+		col = ","
+	}
+
 	varname := ""
 	isAccum := false
 
@@ -4836,14 +6687,78 @@ func processInstructions(
 		}
 	}
 
+	// pending -lbl or -tag text is used as the next -jsonpkg key
+	key := ""
+
+	// jsonField passes one -jsonpkg key and its values to jsonObject, which builds the record object
+	jsonField := func(op *Operation) {
+
+		dflt, _ := nextDefault()
+
+		// unit separator cannot appear in XML content
+		txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", "\x1F", dflt, reg, exp, rules, dtf, ref, nmf, false, false, op.Type, index, level, variables, transform, srchr, histogram)
+
+		name := key
+		key = ""
+
+		// key is omitted if element is missing and there is no -def value
+		if !ok {
+			return
+		}
+
+		if name == "" {
+			// default key is element or attribute name
+			name = op.Value
+			if len(op.Stages) == 1 {
+				stage := op.Stages[0]
+				if stage.Attrib != "" {
+					name = stage.Attrib
+				} else if stage.Match != "" {
+					name = stage.Match
+				}
+			}
+		}
+
+		// record separator starts each field, jsonObject later merges fields with the same key
+		accum("\x1E")
+		accum(name)
+		accum("\x1F")
+		accum(txt)
+
+		ret = lin
+	}
+
 	// process commands
 	for _, op := range commands {
 
 		str := op.Value
 
+		if op.Type == REPLACE {
+			replaced = true
+			// -exp alone reuses the current -reg
+			for i := range rules {
+				if rules[i].Reg == "" {
+					rules[i].Reg = reg
+					rules[i].Regx = rgx
+				}
+			}
+		}
+
 		switch op.Type {
 		case ELEMENT:
-			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+			if jsn {
+				jsonField(op)
+				break
+			}
+			dflt, scoped := nextDefault()
+			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, dflt, reg, exp, rules, dtf, ref, nmf, wrp, csv, op.Type, index, level, variables, transform, srchr, histogram)
+			if !ok && scoped {
+				// empty -defs entry still holds its column
+				txt, ok = tab, true
+			}
+			if !ok && requireAll {
+				variables[missingColumn] = "Y"
+			}
 			if ok {
 				plg = ""
 				lst = elg
@@ -4855,8 +6770,8 @@ func processInstructions(
 					printInColor(txt)
 				}
 			}
-		case HISTOGRAM:
-			txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", "", "", "", "", wrp, op.Type, index, level, variables, transform, srchr, histogram)
+		case HISTOGRAM, GROUPBY:
+			txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", "", "", "", "", nil, "", "", "", wrp, csv, op.Type, index, level, variables, transform, srchr, histogram)
 			if ok {
 				accum(txt)
 			}
@@ -4875,15 +6790,30 @@ func processInstructions(
 			fallthrough
 		case LBL:
 			lbl := str
+			if jsn {
+				// -tag is prefixed by an angle bracket
+				if op.Type == TAG {
+					lbl = strings.TrimPrefix(lbl, "<")
+				}
+				key = lbl
+				break
+			}
 			accum(tab)
 			accum(plg)
-			accum(pfx)
+			if csv {
+				// prefix, label, and suffix are quoted as a single field
+				lbl = csvEncode(pfx + lbl + sfx)
+			} else {
+				accum(pfx)
+			}
 			if plain {
 				accum(lbl)
 			} else {
 				printInColor(lbl)
 			}
-			accum(sfx)
+			if !csv {
+				accum(sfx)
+			}
 			plg = ""
 			lst = elg
 			tab = col
@@ -4913,6 +6843,35 @@ func processInstructions(
 				wrp = false
 				break
 			}
+			if strings.Index(str, "@") > 0 {
+				// Tag@name=value,name=&VARIABLE adds attributes to the opening tag
+				tg, atts := SplitInTwoLeft(str, "@")
+				var buffer strings.Builder
+				buffer.WriteString("<")
+				buffer.WriteString(tg)
+				for _, item := range strings.Split(atts, ",") {
+					item = strings.TrimPrefix(item, "@")
+					name, val := SplitInTwoLeft(item, "=")
+					if name == "" {
+						continue
+					}
+					if len(val) > 1 && val[0] == '&' {
+						// expand variable to get attribute value
+						val = variables[val[1:]]
+					}
+					buffer.WriteString(" ")
+					buffer.WriteString(name)
+					buffer.WriteString("=\"")
+					buffer.WriteString(EscapeIfNeeded(val))
+					buffer.WriteString("\"")
+				}
+				buffer.WriteString(">")
+				pfx = buffer.String()
+				sfx = "</" + tg + ">"
+				sep = sfx + pfx
+				wrp = true
+				break
+			}
 			if strings.Index(str, ",") >= 0 {
 				// -wrp with comma-separated arguments is deprecated, but supported for backward compatibility
 				lft, rgt := SplitInTwoRight(str, ",")
@@ -4981,13 +6940,75 @@ func processInstructions(
 			elg = ""
 			sep = "\t"
 			def = ""
+			defs = nil
 			wrp = false
+			dfl = ""
+			hasDfl = false
 		case DEF:
 			def = str
+		case DEFS:
+			defs = strings.Split(str, ",")
+		case DEFLINE:
+			hasDfl = true
+			if len(str) > 1 && str[0] == '&' {
+				dfl = variables[str[1:]]
+				break
+			}
+			dfl, _ = processClause(curr, op.Stages, mask, "", "", "", "", " ", "", "", "", nil, "", "", "", false, false, ELEMENT, index, level, variables, transform, srchr, histogram)
 		case REG:
 			reg = str
+			rgx = op.Regx
+			if replaced {
+				rules = nil
+				replaced = false
+			}
+			if n := len(rules); n > 0 && rules[n-1].Reg == "" {
+				// -exp came first
+				rules[n-1].Reg = str
+				rules[n-1].Regx = rgx
+			} else {
+				// a -reg without its own -exp deletes matches, it does not reuse an earlier -exp
+				rules = append(rules, replaceRule{Reg: str, Regx: rgx})
+			}
 		case EXP:
 			exp = str
+			if replaced {
+				rules = nil
+				replaced = false
+			}
+			if n := len(rules); n > 0 && !rules[n-1].hasExp {
+				rules[n-1].Exp = str
+				rules[n-1].hasExp = true
+			} else {
+				// -reg may follow, otherwise the current -reg is filled in at -replace
+				rules = append(rules, replaceRule{Exp: str, hasExp: true})
+			}
+		case DATEFMT:
+			dtf = str
+		case ASOF:
+			ref = ""
+			if len(str) > 1 && str[0] == '&' {
+				ref = normalizeDateText(variables[str[1:]])
+			} else if isLiteralDate(str) {
+				ref = normalizeDateText(str)
+			}
+			// first parsable date in named object
+			for _, stage := range op.Stages {
+				if ref != "" {
+					break
+				}
+				ExploreNodes(curr, stage.Parent, stage.Match, index, level, func(node *XMLNode, idx, lvl int) {
+					if ref == "" {
+						ref = normalizeDateNode(node)
+					}
+				})
+			}
+			if ref == "" {
+				// unresolved reference date suppresses -age instead of using the current date
+				ref = "-"
+			}
+		case NUMFMT:
+			nmf = str
 		case COLOR:
 			currColor = color.New()
 			if str == "-" || str == "reset" || str == "clear" {
@@ -5033,7 +7054,7 @@ func processInstructions(
 				// -if "&VARIABLE" will fail if initialized with empty string ""
 				delete(variables, varname)
 			} else {
-				txt, ok := processClause(curr, op.Stages, mask, "", pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+				txt, ok := processClause(curr, op.Stages, mask, "", pfx, sfx, plg, sep, def, reg, exp, rules, dtf, ref, nmf, wrp, csv, op.Type, index, level, variables, transform, srchr, histogram)
 				if ok {
 					plg = ""
 					lst = elg
@@ -5049,9 +7070,53 @@ func processInstructions(
 				}
 			}
 			varname = ""
+		case ARITHMETIC:
+			// integer result, or variable left unset if a referenced variable is missing
+			num, ok, err := EvaluateArithmetic(str, variables)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Arithmetic expression '(%s)' %s\n", str, err.Error())
+				os.Exit(1)
+			}
+			if ok {
+				val := strconv.Itoa(num)
+				if isAccum && variables[varname] != "" {
+					variables[varname] += sep + val
+				} else {
+					variables[varname] = val
+				}
+			} else if !isAccum {
+				delete(variables, varname)
+			}
+			varname = ""
 			isAccum = false
 		default:
-			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+			if jsn {
+				jsonField(op)
+				break
+			}
+			dflt, scoped := nextDefault()
+			if op.Type == FASTA && hasDfl {
+				// definition line followed by one sequence segment per line
+				txt, ok := processClause(curr, op.Stages, mask, tab, pfx+">"+dfl+"\n", sfx, plg, "\n", dflt, reg, exp, rules, dtf, ref, nmf, wrp, csv, op.Type, index, level, variables, transform, srchr, histogram)
+				if !ok && requireAll {
+					variables[missingColumn] = "Y"
+				}
+				if ok {
+					plg = ""
+					lst = elg
+					tab = col
+					ret = lin
+					accum(txt)
+				}
+				break
+			}
+			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, dflt, reg, exp, rules, dtf, ref, nmf, wrp, csv, op.Type, index, level, variables, transform, srchr, histogram)
+			if !ok && scoped {
+				txt, ok = tab, true
+			}
+			if !ok && requireAll {
+				variables[missingColumn] = "Y"
+			}
 			if ok {
 				plg = ""
 				lst = elg
@@ -5066,16 +7131,339 @@ func processInstructions(
 		}
 	}
 
-	if plain {
-		accum(lst)
-	} else {
-		printInColor(lst)
+	if jsn {
+		return tab, ret
+	}
+
+	if plain {
+		accum(lst)
+	} else {
+		printInColor(lst)
+	}
+
+	return tab, ret
+}
+
+// packedSequenceLength returns the Seq-inst length in a record, or 0 if missing or ambiguous
+func packedSequenceLength(curr *XMLNode) int {
+
+	length := 0
+	count := 0
+
+	var visit func(node *XMLNode)
+	visit = func(node *XMLNode) {
+		for ; node != nil; node = node.Next {
+			// -a2x writes length, NCBI XML writes Seq-inst_length
+			if (node.Name == "length" || node.Name == "Seq-inst_length") && IsAllDigits(node.Contents) {
+				val, err := strconv.Atoi(node.Contents)
+				if err == nil && val != length {
+					length = val
+					count++
+				}
+			}
+			visit(node.Children)
+		}
+	}
+
+	if curr != nil {
+		visit(curr.Children)
+	}
+
+	if count != 1 {
+		return 0
+	}
+
+	return length
+}
+
+// compareNumbers returns -1, 0, or 1, using exact integer comparison if possible, and floating point otherwise
+func compareNumbers(str, val string) (int, bool) {
+
+	x, errx := strconv.Atoi(str)
+	y, erry := strconv.Atoi(val)
+
+	if errx == nil && erry == nil {
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	// fall back to floating point, e.g., for expression levels or e-values
+	fx, errx := strconv.ParseFloat(str, 64)
+	fy, erry := strconv.ParseFloat(val, 64)
+
+	if errx != nil || erry != nil || math.IsNaN(fx) || math.IsNaN(fy) {
+		return 0, false
+	}
+
+	if fx < fy {
+		return -1, true
+	} else if fx > fy {
+		return 1, true
+	}
+
+	return 0, true
+}
+
+// CONDITIONAL EXECUTION USES -if AND -unless STATEMENT, WITH SUPPORT FOR DEPRECATED -match AND -avoid STATEMENTS
+
+// normalizeDateText converts PubDate, MedlineDate, or ISO date text to YYYY/MM/DD, omitting missing month or day
+func normalizeDateText(str string) string {
+
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return ""
+	}
+
+	year := ""
+	month := ""
+	day := ""
+
+	if IsAllDigits(str) {
+		// 20201214, 202012, or 2020
+		switch len(str) {
+		case 8:
+			year, month, day = str[0:4], str[4:6], str[6:8]
+		case 6:
+			year, month = str[0:4], str[4:6]
+		case 4:
+			year = str
+		}
+	} else {
+		words := strings.FieldsFunc(str, func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		})
+		for _, item := range words {
+			if year == "" {
+				if len(item) == 4 && IsAllDigits(item) {
+					year = item
+				}
+				continue
+			}
+			// take leading digits, e.g., day of 2020-03-15T10:00:00
+			num := item
+			for i, ch := range item {
+				if !unicode.IsDigit(ch) {
+					num = item[:i]
+					break
+				}
+			}
+			if month == "" {
+				if num != "" && len(num) <= 2 {
+					month = num
+					continue
+				}
+				val, found := monthTable[strings.ToLower(item)]
+				if found {
+					month = strconv.Itoa(val)
+					continue
+				}
+				// MedlineDate "2008 Dec-2009 Jan" or season name, stop at first unrecognized word
+				break
+			}
+			if num != "" && len(num) <= 2 {
+				day = num
+			}
+			break
+		}
+	}
+
+	if year == "" {
+		return ""
+	}
+
+	pad := func(str string) string {
+		if len(str) == 1 {
+			return "0" + str
+		}
+		return str
+	}
+
+	res := year
+	if month != "" {
+		res += "/" + pad(month)
+		if day != "" {
+			res += "/" + pad(day)
+		}
+	}
+
+	return res
+}
+
+// normalizeDateNode gets YYYY/MM/DD from date text or from Year, Month, Day, and MedlineDate children
+func normalizeDateNode(node *XMLNode) string {
+
+	if node == nil {
+		return ""
+	}
+
+	if node.Contents != "" {
+		return normalizeDateText(node.Contents)
+	}
+
+	year := ""
+	month := ""
+	day := ""
+
+	for chld := node.Children; chld != nil; chld = chld.Next {
+		switch chld.Name {
+		case "MedlineDate":
+			return normalizeDateText(chld.Contents)
+		case "Year":
+			year = chld.Contents
+		case "Month":
+			month = chld.Contents
+		case "Day":
+			day = chld.Contents
+		}
+	}
+
+	return normalizeDateText(strings.TrimSpace(year + " " + month + " " + day))
+}
+
+// ageToday is the -age reference date set by xtract -today, otherwise the current date is used
+var ageToday string
+
+// SetAgeReference fixes the date used by -age in place of the current date, returning false if unparsable
+func SetAgeReference(str string) bool {
+
+	ref := normalizeDateText(str)
+	if ref == "" {
+		return false
 	}
 
-	return tab, ret
+	ageToday = ref
+
+	return true
 }
 
-// CONDITIONAL EXECUTION USES -if AND -unless STATEMENT, WITH SUPPORT FOR DEPRECATED -match AND -avoid STATEMENTS
+// isLiteralDate distinguishes -asof 2024/06/30 from an element name
+func isLiteralDate(str string) bool {
+
+	return str != "" && str[0] >= '0' && str[0] <= '9' && normalizeDateText(str) != ""
+}
+
+// dateAge returns the time from a YYYY/MM/DD date to the reference date in days, or in years with one decimal
+func dateAge(str, ref, unit string) string {
+
+	if str == "" {
+		return ""
+	}
+
+	if ref == "" {
+		ref = ageToday
+	}
+	if ref == "" {
+		ref = time.Now().Format("2006/01/02")
+	}
+
+	// missing month or day is taken as the first
+	from, err := time.Parse("2006/01/02", completeDate(str, false))
+	if err != nil {
+		return ""
+	}
+	to, err := time.Parse("2006/01/02", completeDate(ref, false))
+	if err != nil {
+		return ""
+	}
+
+	days := int(to.Sub(from).Hours() / 24)
+
+	if unit == "years" {
+		return strconv.FormatFloat(float64(days)/365.25, 'f', 1, 64)
+	}
+
+	return strconv.Itoa(days)
+}
+
+// completeDate fills in missing month and day with the start or end of the period
+func completeDate(str string, upper bool) string {
+
+	if str == "" {
+		return ""
+	}
+
+	switch strings.Count(str, "/") {
+	case 0:
+		if upper {
+			return str + "/12/31"
+		}
+		return str + "/01/01"
+	case 1:
+		if upper {
+			return str + "/31"
+		}
+		return str + "/01"
+	}
+
+	return str
+}
+
+// resolveOperand evaluates the second argument of a comparison with the same exploration
+// rules used for the first, returning an empty string if a variable is missing, and the
+// argument itself if it names an element that is not present
+func resolveOperand(constraint *Step, curr *XMLNode, mask string, level int, variables map[string]string, numeric bool) string {
+
+	val := constraint.Value
+
+	// literal value
+	if constraint.Parent == "" && constraint.Match == "" && constraint.Attrib == "" {
+		return val
+	}
+
+	exploreElements := func(proc func(string, int)) {
+		ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, proc)
+	}
+
+	switch val[0] {
+	case '&':
+		return variables[val[1:]]
+	case '#':
+		count := 0
+		exploreElements(func(str string, lvl int) {
+			count++
+		})
+		return strconv.Itoa(count)
+	case '%':
+		length := 0
+		exploreElements(func(str string, lvl int) {
+			length += len(str)
+		})
+		return strconv.Itoa(length)
+	case '^':
+		depth := 0
+		exploreElements(func(str string, lvl int) {
+			depth = lvl
+		})
+		return strconv.Itoa(depth)
+	}
+
+	// last non-empty value, which must be a number for numeric tests
+	res := ""
+	found := false
+	exploreElements(func(str string, lvl int) {
+		found = true
+		if str == "" {
+			return
+		}
+		if numeric {
+			if _, err := strconv.ParseFloat(str, 64); err != nil {
+				return
+			}
+		}
+		res = str
+	})
+
+	// a name that is not present is compared as literal text, as in -is-equal-to hi
+	if !found {
+		return val
+	}
+
+	return res
+}
 
 // conditionsAreSatisfied tests a set of conditions to determine if extraction should proceed
 func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string, index, level int, variables map[string]string) bool {
@@ -5138,6 +7526,11 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 			stat := constraint.Type
 
 			switch stat {
+			case REGEX:
+				// case-sensitive unless expression starts with (?i)
+				if constraint.Regx != nil && constraint.Regx.MatchString(str) {
+					return true
+				}
 			case EQUALS, CONTAINS, INCLUDES, ISWITHIN, STARTSWITH, ENDSWITH, ISNOT, ISBEFORE, ISAFTER, MATCHES, RESEMBLES:
 				// substring test on element values
 				str = strings.ToUpper(str)
@@ -5192,40 +7585,22 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 					}
 				default:
 				}
-			case ISEQUALTO, DIFFERSFROM:
-				// conditional argument is element specifier
-				if constraint.Parent != "" || constraint.Match != "" || constraint.Attrib != "" {
-					ch := val[0]
-					// pound, percent, and caret prefixes supported (undocumented)
-					switch ch {
-					case '#':
-						count := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							count++
-						})
-						val = strconv.Itoa(count)
-					case '%':
-						length := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							if stn != "" {
-								length += len(stn)
-							}
-						})
-						val = strconv.Itoa(length)
-					case '^':
-						depth := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							depth = lvl
-						})
-						val = strconv.Itoa(depth)
-					default:
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							if stn != "" {
-								val = stn
-							}
-						})
-					}
+			case ISBETWEEN:
+				// dates normalized to YYYY/MM/DD, empty side of range is open
+				lft, rgt, _ := strings.Cut(val, ",")
+				lft = completeDate(normalizeDateText(lft), false)
+				rgt = completeDate(normalizeDateText(rgt), true)
+				str = completeDate(str, false)
+				if lft != "" && str < lft {
+					return false
+				}
+				if rgt != "" && str > rgt {
+					return false
 				}
+				return true
+			case ISEQUALTO, DIFFERSFROM:
+				// conditional argument can be element specifier
+				val = resolveOperand(constraint, curr, mask, level, variables, false)
 				str = strings.ToUpper(str)
 				val = strings.ToUpper(val)
 
@@ -5242,80 +7617,39 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 				}
 			case GT, GE, LT, LE, EQ, NE:
 				// second argument of numeric test can be element specifier
-				if constraint.Parent != "" || constraint.Match != "" || constraint.Attrib != "" {
-					ch := val[0]
-					// pound, percent, and caret prefixes supported as potentially useful for data QA (undocumented)
-					switch ch {
-					case '#':
-						count := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							count++
-						})
-						val = strconv.Itoa(count)
-					case '%':
-						length := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							if stn != "" {
-								length += len(stn)
-							}
-						})
-						val = strconv.Itoa(length)
-					case '^':
-						depth := 0
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							depth = lvl
-						})
-						val = strconv.Itoa(depth)
-					case '&':
-						if len(val) > 1 {
-							val = val[1:]
-							// expand variable to get actual tag
-							val = variables[val]
-						}
-					default:
-						ExploreElements(curr, mask, constraint.Parent, constraint.Match, constraint.Attrib, constraint.Wild, true, level, func(stn string, lvl int) {
-							if stn != "" {
-								_, errz := strconv.Atoi(stn)
-								if errz == nil {
-									val = stn
-								}
-							}
-						})
-					}
-				}
+				val = resolveOperand(constraint, curr, mask, level, variables, true)
 
 				// numeric tests on element values
-				x, errx := strconv.Atoi(str)
-				y, erry := strconv.Atoi(val)
+				cmp, ok := compareNumbers(str, val)
 
-				// both arguments must resolve to integers
-				if errx != nil || erry != nil {
+				// both arguments must resolve to numbers
+				if !ok {
 					return false
 				}
 
 				switch stat {
 				case GT:
-					if x > y {
+					if cmp > 0 {
 						return true
 					}
 				case GE:
-					if x >= y {
+					if cmp >= 0 {
 						return true
 					}
 				case LT:
-					if x < y {
+					if cmp < 0 {
 						return true
 					}
 				case LE:
-					if x <= y {
+					if cmp <= 0 {
 						return true
 					}
 				case EQ:
-					if x == y {
+					if cmp == 0 {
 						return true
 					}
 				case NE:
-					if x != y {
+					if cmp != 0 {
 						return true
 					}
 				default:
@@ -5444,6 +7778,42 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 
 		switch status {
 		case ELEMENT:
+			if stage.Limit != "" {
+				// -if PubDate -age:days -le 90 tests days or years since date
+				testAge := func(date string) {
+					txt := dateAge(date, "", stage.Limit)
+					if txt != "" && (constraint == nil || checkConstraint(txt)) {
+						found = true
+					}
+				}
+				if attrib != "" {
+					exploreElements(func(str string, lvl int) {
+						testAge(normalizeDateText(str))
+					})
+					break
+				}
+				ExploreNodes(curr, prnt, match, 0, level, func(node *XMLNode, idx, lvl int) {
+					testAge(normalizeDateNode(node))
+				})
+				break
+			}
+			if constraint != nil && constraint.Type == ISBETWEEN {
+				if attrib != "" {
+					exploreElements(func(str string, lvl int) {
+						if checkConstraint(normalizeDateText(str)) {
+							found = true
+						}
+					})
+					break
+				}
+				// date containers, e.g., PubDate with Year, Month, and Day children
+				ExploreNodes(curr, prnt, match, 0, level, func(node *XMLNode, idx, lvl int) {
+					if checkConstraint(normalizeDateNode(node)) {
+						found = true
+					}
+				})
+				break
+			}
 			exploreElements(func(str string, lvl int) {
 				// match to XML container object sends empty string, so do not check for str != "" here
 				// test every selected element individually if value is specified
@@ -5454,6 +7824,10 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 		case VARIABLE:
 			// use value of stored variable
 			str, ok := variables[match]
+			if ok && stage.Limit != "" {
+				str = dateAge(normalizeDateText(str), "", stage.Limit)
+				ok = (str != "")
+			}
 			if ok {
 				//  -if &VARIABLE -equals VALUE is the supported construct
 				if constraint == nil || checkConstraint(str) {
@@ -5514,7 +7888,7 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 		switch op.Type {
 		// -if tests for presence of element (deprecated -match can test element:value)
 		case SELECT, IF, MATCH:
-			// checking for failure here allows for multiple -if [ -and / -or ] clauses
+			// checking for failure here allows for multiple -if and -unless clauses, each with its own -and / -or tests
 			if isMatch && observed < required {
 				return false
 			}
@@ -5570,6 +7944,105 @@ func conditionsAreSatisfied(conditions []*Operation, curr *XMLNode, mask string,
 
 // RECURSIVELY PROCESS EXPLORATION COMMANDS AND XML DATA STRUCTURE
 
+// parseSiblingRange splits -between "element:value,element:value" or -window "element:value,count"
+func parseSiblingRange(status OpType, str string) *SiblingRange {
+
+	lft, rgt, found := strings.Cut(str, ",")
+	lft = strings.TrimSpace(lft)
+	rgt = strings.TrimSpace(rgt)
+	if !found || lft == "" || rgt == "" {
+		return nil
+	}
+
+	// element:value marker, with missing value or * matching any contents
+	splitMarker := func(spec string) (string, string) {
+		name, value, _ := strings.Cut(spec, ":")
+		if value == "*" {
+			value = ""
+		}
+		return name, value
+	}
+
+	rng := &SiblingRange{}
+	rng.StartName, rng.StartValue = splitMarker(lft)
+
+	if status == WINDOW {
+		num, err := strconv.Atoi(rgt)
+		if err != nil || num < 1 {
+			return nil
+		}
+		rng.Count = num
+	} else {
+		rng.StopName, rng.StopValue = splitMarker(rgt)
+	}
+
+	if rng.StartName == "" || (status == BETWEEN && rng.StopName == "") {
+		return nil
+	}
+
+	return rng
+}
+
+// siblingsInRange records nodes that lie between sibling markers, including their descendants
+func siblingsInRange(curr *XMLNode, rng *SiblingRange) map[*XMLNode]bool {
+
+	inside := make(map[*XMLNode]bool)
+
+	if curr == nil || rng == nil {
+		return inside
+	}
+
+	isMarker := func(node *XMLNode, name, value string) bool {
+		if node.Name != name {
+			return false
+		}
+		return value == "" || strings.EqualFold(strings.TrimSpace(node.Contents), value)
+	}
+
+	var markTree func(*XMLNode)
+
+	markTree = func(node *XMLNode) {
+		inside[node] = true
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			markTree(chld)
+		}
+	}
+
+	var scanTree func(*XMLNode)
+
+	// scanTree checks the children of each node in document order
+	scanTree = func(node *XMLNode) {
+
+		active := false
+		left := 0
+
+		for chld := node.Children; chld != nil; chld = chld.Next {
+
+			// stop marker closes range before a new start marker can reopen it
+			if active && rng.Count == 0 && isMarker(chld, rng.StopName, rng.StopValue) {
+				active = false
+			}
+			if active && rng.Count > 0 && left < 1 {
+				active = false
+			}
+
+			if isMarker(chld, rng.StartName, rng.StartValue) {
+				active = true
+				left = rng.Count
+			} else if active {
+				markTree(chld)
+				left--
+			}
+
+			scanTree(chld)
+		}
+	}
+
+	scanTree(curr)
+
+	return inside
+}
+
 // processCommands visits XML nodes, performs conditional tests, and executes data extraction instructions
 func processCommands(
 	cmds *Block,
@@ -5600,7 +8073,7 @@ func processCommands(
 
 			// execute data extraction commands
 			if len(cmds.Commands) > 0 {
-				tab, ret = processInstructions(cmds.Commands, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, accum)
+				tab, ret = processInstructions(cmds.Commands, node, match, tab, ret, idx, lvl, cmds.CSV, cmds.JSON, variables, transform, srchr, histogram, accum)
 			}
 
 			// process sub commands on child node
@@ -5612,7 +8085,7 @@ func processCommands(
 
 			// execute commands after -else statement
 			if len(cmds.Failure) > 0 {
-				tab, ret = processInstructions(cmds.Failure, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, accum)
+				tab, ret = processInstructions(cmds.Failure, node, match, tab, ret, idx, lvl, cmds.CSV, cmds.JSON, variables, transform, srchr, histogram, accum)
 			}
 		}
 	}
@@ -5647,7 +8120,43 @@ func processCommands(
 		return indx
 	}
 
-	if cmds.Foreword != "" {
+	// exploreNodes applies any -between or -window sibling range before the -position test
+	exploreNodes := func(proc func(*XMLNode, int, int)) {
+
+		if cmds.Range == nil {
+			ExploreNodes(curr, prnt, match, index, level, proc)
+			return
+		}
+
+		inside := siblingsInRange(curr, cmds.Range)
+		indx := index
+
+		ExploreNodes(curr, prnt, match, index, level,
+			func(node *XMLNode, idx, lvl int) {
+				if inside[node] {
+					// renumber so that -position counts only nodes within the range
+					proc(node, indx, lvl)
+					indx++
+				}
+			})
+	}
+
+	wrapped := false
+	lead := tab
+
+	if cmds.Foreword != "" && cmds.Sparse {
+		// -keep prints its record wrapper only around objects that were kept, after any pending separator
+		inner := accum
+		tab = ""
+		accum = func(str string) {
+			if str != "" && !wrapped {
+				inner(lead)
+				inner(cmds.Foreword)
+				wrapped = true
+			}
+			inner(str)
+		}
+	} else if cmds.Foreword != "" {
 		accum(cmds.Foreword)
 	}
 
@@ -5655,11 +8164,11 @@ func processCommands(
 
 	if cmds.Position == "" || cmds.Position == "all" {
 
-		ExploreNodes(curr, prnt, match, index, level, processNode)
+		exploreNodes(processNode)
 
 	} else if cmds.Position == "path" {
 
-		ExploreNodes(curr, prnt, match, index, level,
+		exploreNodes(
 			func(node *XMLNode, idx, lvl int) {
 				// exploreNodes callback has matched first path component, now explore remainder one level and component at a time
 				explorePath(node, cmds.Path, idx, lvl, processNode)
@@ -5673,7 +8182,7 @@ func processCommands(
 
 		if cmds.Position == "first" {
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					if single == nil {
 						single = node
@@ -5684,7 +8193,7 @@ func processCommands(
 
 		} else if cmds.Position == "last" {
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					single = node
 					ind = idx
@@ -5697,7 +8206,7 @@ func processCommands(
 			var beg *Limiter
 			var end *Limiter
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					if beg == nil {
 						beg = &Limiter{node, idx, lvl}
@@ -5720,7 +8229,7 @@ func processCommands(
 			var next *Limiter
 			first := true
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					if first {
 						first = false
@@ -5739,7 +8248,7 @@ func processCommands(
 
 			okay := false
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					if okay {
 						processNode(node, idx, lvl)
@@ -5751,7 +8260,7 @@ func processCommands(
 
 			okay := true
 
-			ExploreNodes(curr, prnt, match, index, level,
+			exploreNodes(
 				func(node *XMLNode, idx, lvl int) {
 					if okay {
 						processNode(node, idx, lvl)
@@ -5761,39 +8270,201 @@ func processCommands(
 
 		} else {
 
-			// use numeric position
-			number, err := strconv.Atoi(cmds.Position)
-			if err == nil {
+			// use numeric position or range, already checked by ParseArgumentsErr
+			beg, end, isRange, ok := ParsePosition(cmds.Position)
+			if !ok {
+				return tab, ret
+			}
+
+			if beg >= 0 && end >= 0 {
+
+				pos := 0
+
+				exploreNodes(
+					func(node *XMLNode, idx, lvl int) {
+						pos++
+						if isRange {
+							if (beg == 0 || pos >= beg) && (end == 0 || pos <= end) {
+								processNode(node, idx, lvl)
+							}
+						} else if pos == beg {
+							processNode(node, idx, lvl)
+						}
+					})
+
+			} else {
+
+				// negative index counts from end, so collect all matching nodes first
+				var nodes []Limiter
+
+				exploreNodes(
+					func(node *XMLNode, idx, lvl int) {
+						nodes = append(nodes, Limiter{node, idx, lvl})
+					})
+
+				lo, hi := PositionBounds(len(nodes), beg, end, isRange)
+				for i := lo; i <= hi; i++ {
+					lmt := nodes[i-1]
+					processNode(lmt.Obj, lmt.Idx, lmt.Lvl)
+				}
+			}
+		}
+
+		if single != nil {
+			processNode(single, ind, lev)
+		}
+	}
+
+	if cmds.Afterword != "" && !cmds.Sparse {
+		accum(cmds.Afterword)
+	} else if wrapped {
+		accum(cmds.Afterword)
+		// later record-level output starts on its own line
+		tab = "\n"
+		ret = "\n"
+	} else if cmds.Sparse {
+		tab = lead
+	}
+
+	return tab, ret
+}
+
+// ParsePosition parses N, N:M, N:, and :M -position values, 1-based and inclusive,
+// with negative numbers counting from the end, and zero returned only for an open end of a range
+func ParsePosition(str string) (int, int, bool, bool) {
+
+	if str == "" {
+		return 0, 0, false, false
+	}
+
+	if !strings.Contains(str, ":") {
+		num, err := strconv.Atoi(str)
+		if err != nil || num == 0 {
+			return 0, 0, false, false
+		}
+		return num, num, false, true
+	}
+
+	lft, rgt := SplitInTwoLeft(str, ":")
+
+	beg, end := 0, 0
+
+	if lft != "" {
+		num, err := strconv.Atoi(lft)
+		if err != nil || num == 0 {
+			return 0, 0, true, false
+		}
+		beg = num
+	}
+	if rgt != "" {
+		num, err := strconv.Atoi(rgt)
+		if err != nil || num == 0 {
+			return 0, 0, true, false
+		}
+		end = num
+	}
+
+	return beg, end, true, true
+}
+
+// positionNames are the -position keywords, any other value must be a number or range
+var positionNames = map[string]bool{
+	"first": true,
+	"last":  true,
+	"outer": true,
+	"inner": true,
+	"even":  true,
+	"odd":   true,
+	"all":   true,
+}
+
+// checkPosition rejects a malformed or zero -position value while arguments are parsed
+func checkPosition(str string) error {
+
+	if positionNames[str] {
+		return nil
+	}
+
+	if _, _, _, ok := ParsePosition(str); ok {
+		return nil
+	}
+
+	for _, part := range strings.Split(str, ":") {
+		if num, err := strconv.Atoi(part); err == nil && num == 0 {
+			return fmt.Errorf("Position '%s' cannot be 0, count from 1 at the start or from -1 at the end", str)
+		}
+	}
+
+	return fmt.Errorf("Unrecognized position '%s'", str)
+}
+
+// isPositionRange allows a negative -position value that would otherwise look like a command,
+// leaving checkPosition to report a zero or malformed number
+func isPositionRange(str string) bool {
+
+	hasDigit := false
+	for _, ch := range str {
+		if ch >= '0' && ch <= '9' {
+			hasDigit = true
+		} else if ch != '-' && ch != ':' {
+			return false
+		}
+	}
+
+	return hasDigit
+}
+
+// isNegativeOperand recognizes a negative number following a numeric comparison, e.g., -if Score -lt -2.5
+func isNegativeOperand(prev, str string) bool {
+
+	switch opTypeIs[prev] {
+	case GT, GE, LT, LE, EQ, NE:
+	default:
+		return false
+	}
+
+	if len(str) < 2 || str[0] != '-' {
+		return false
+	}
 
-				pos := 0
+	_, err := strconv.ParseFloat(str, 64)
 
-				ExploreNodes(curr, prnt, match, index, level,
-					func(node *XMLNode, idx, lvl int) {
-						pos++
-						if pos == number {
-							single = node
-							ind = idx
-							lev = lvl
-						}
-					})
+	return err == nil
+}
 
-			} else {
+// PositionBounds converts a parsed -position value to 1-based first and last
+// indices for n items, clipping a range but ignoring an out-of-range single index
+func PositionBounds(n, beg, end int, isRange bool) (int, int) {
 
-				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized position '%s'\n", cmds.Position)
-				os.Exit(1)
-			}
+	if !isRange {
+		if beg < 0 {
+			beg += n + 1
 		}
-
-		if single != nil {
-			processNode(single, ind, lev)
+		if beg < 1 || beg > n {
+			return 1, 0
 		}
+		return beg, beg
 	}
 
-	if cmds.Afterword != "" {
-		accum(cmds.Afterword)
+	if beg < 0 {
+		beg += n + 1
+	} else if beg == 0 {
+		beg = 1
+	}
+	if end < 0 {
+		end += n + 1
+	} else if end == 0 {
+		end = n
 	}
 
-	return tab, ret
+	if beg < 1 {
+		beg = 1
+	}
+	if end > n {
+		end = n
+	}
+
+	return beg, end
 }
 
 // PROCESS ONE XML COMPONENT RECORD
@@ -5801,12 +8472,24 @@ func processCommands(
 // ProcessExtract perform data extraction driven by command-line arguments
 func ProcessExtract(text, parent string, index int, hd, tl string, transform map[string]string, srchr *FSMSearcher, histogram map[string]int, cmds *Block) string {
 
+	return processExtract(text, parent, index, hd, tl, transform, srchr, histogram, cmds, defaultScope())
+}
+
+// processExtract takes the namespace context sent with the record by CreateXMLProducer
+func processExtract(text, parent string, index int, hd, tl string, transform map[string]string, srchr *FSMSearcher, histogram map[string]int, cmds *Block, rs *recordScope) string {
+
 	if text == "" || cmds == nil {
 		return ""
 	}
 
-	// exit from function will collect garbage of node structure for current XML object
-	pat := ParseRecord(text, parent)
+	// exit from function returns node arrays for current XML object to the pool
+	pat, release, tooLarge := parsePooledRecord(text, parent, rs)
+	defer release()
+
+	if tooLarge {
+		recordLimitExceeded(index, text, fmt.Sprintf("node count exceeds -max-record-nodes %d", maxRecordNodes))
+		return ""
+	}
 
 	if pat == nil {
 		return ""
@@ -5835,14 +8518,32 @@ func ProcessExtract(text, parent string, index int, hd, tl string, transform map
 
 	} else {
 
+		// -jsonpkg fields are gathered for the entire record before being written as one object
+		var fields strings.Builder
+
+		out := &buffer
+		if cmds.JSON {
+			out = &fields
+		}
+
 		// start processing at top of command tree and top of XML subregion selected by -pattern
 		_, ret = processCommands(cmds, pat, "", "", index, 1, variables, transform, srchr, histogram,
 			func(str string) {
 				if str != "" {
 					ok = true
-					buffer.WriteString(str)
+					out.WriteString(str)
 				}
 			})
+
+		if cmds.JSON {
+			buffer.WriteString(jsonObject(fields.String()))
+		}
+	}
+
+	// an empty column under -require-all discards the entire record
+	if requireAll && variables[missingColumn] != "" {
+		atomic.AddInt64(&discardedRecords, 1)
+		return ""
 	}
 
 	if tl != "" {
@@ -5869,6 +8570,103 @@ func ProcessExtract(text, parent string, index int, hd, tl string, transform map
 	return txt
 }
 
+// recordSortKey extracts the first value of each -sort-records key block, joined for CreateRecordSorter
+func recordSortKey(text, parent string, index int, rs *recordScope) string {
+
+	pat, release, _ := parsePooledRecord(text, parent, rs)
+	defer release()
+
+	if pat == nil {
+		return ""
+	}
+
+	var keys []string
+
+	for _, blk := range sortKeyBlocks {
+
+		var buffer strings.Builder
+
+		variables := make(map[string]string)
+
+		processCommands(blk, pat, "", "", index, 1, variables, nil, nil, nil,
+			func(str string) {
+				buffer.WriteString(str)
+			})
+
+		// keys cannot contain tabs or newlines, which frame records in temporary runs
+		key := strings.TrimSpace(buffer.String())
+		key = strings.Map(func(ch rune) rune {
+			if ch == '\t' || ch == '\n' || ch == '\r' || ch == 0x1F {
+				return ' '
+			}
+			return ch
+		}, key)
+
+		keys = append(keys, key)
+	}
+
+	return strings.Join(keys, sortKeySep)
+}
+
+// parseWindowOptions reads the window size and optional step of -gc:1000,200
+func parseWindowOptions(str string) (int, int, error) {
+
+	win, stp := SplitInTwoLeft(str, ",")
+
+	window, err := strconv.Atoi(win)
+	if err != nil || window < 1 {
+		return 0, 0, fmt.Errorf("Window size in '%s' must be a positive integer", str)
+	}
+
+	step := window
+	if stp != "" {
+		step, err = strconv.Atoi(stp)
+		if err != nil || step < 1 {
+			return 0, 0, fmt.Errorf("Step size in '%s' must be a positive integer", str)
+		}
+	}
+
+	return window, step, nil
+}
+
+// parseOrfOptions reads the comma-separated settings of -orfs:min=100,code=11,atg,prot
+func parseOrfOptions(str string) (int, int, bool, bool, error) {
+
+	minLen := 300
+	genCode := 1
+	atgOnly := false
+	doProtein := false
+
+	for _, item := range strings.Split(str, ",") {
+		key, val := SplitInTwoLeft(item, "=")
+		switch key {
+		case "atg":
+			atgOnly = true
+		case "prot", "protein":
+			doProtein = true
+		case "min", "code":
+			num, err := strconv.Atoi(val)
+			if err != nil || num < 1 {
+				return 0, 0, false, false, fmt.Errorf("Value in '%s' must be a positive integer", item)
+			}
+			if key == "min" {
+				minLen = num
+			} else {
+				genCode = num
+			}
+		default:
+			// lone number is minimum length
+			num, err := strconv.Atoi(item)
+			if err != nil || num < 1 {
+				return 0, 0, false, false, fmt.Errorf("Unrecognized -orfs setting '%s'", item)
+			}
+			minLen = num
+		}
+	}
+
+	return minLen, genCode, atgOnly, doProtein, nil
+}
+
 // INSDSEQ EXTRACTION COMMAND GENERATOR
 
 // e.g., xtract -insd complete mat_peptide "%peptide" product peptide
@@ -6247,6 +9045,39 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 
 	var acc []string
 
+	// -joined prints one row per group of features with the same locus_tag, gene, or location
+	joined := ""
+	if len(args) > 0 && args[0] == "-joined" {
+		args = args[1:]
+		if doIndex {
+			fmt.Fprintf(os.Stderr, "\nERROR: -joined cannot be used with -insd-idx\n")
+			os.Exit(1)
+		}
+		for i, str := range args {
+			if strings.HasPrefix(str, "INSD") || str == "+" || str == "complete" || str == "-" || str == "partial" {
+				continue
+			}
+			if str == "-insd" {
+				fmt.Fprintf(os.Stderr, "\nERROR: -joined requires a single clause with comma-separated features\n")
+				os.Exit(1)
+			}
+			// pairing columns follow feature key, and are removed again by JoinINSDFeatures
+			joined = str
+			var tmp []string
+			tmp = append(tmp, args[:i+1]...)
+			tmp = append(tmp, "INSDFeature_key", "locus_tag", "gene", "feat_location")
+			args = append(tmp, args[i+1:]...)
+			break
+		}
+		if joined == "" {
+			fmt.Fprintf(os.Stderr, "\nERROR: No features supplied to xtract -insd -joined\n")
+			os.Exit(1)
+		}
+		acc = append(acc, "-join-features", joined)
+		// pairing relies on a fixed number of columns per row
+		addDash = true
+	}
+
 	max := len(args)
 	if max < 1 {
 		fmt.Fprintf(os.Stderr, "\nERROR: Insufficient command-line arguments supplied to xtract -insd\n")
@@ -6472,6 +9303,14 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 				} else {
 					acc = append(acc, "-deq", "\"\\t\"")
 				}
+				if joined != "" {
+					// -joined rows need a placeholder column for a feature without intervals
+					if isPipe {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\\-")
+					} else {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\"\\-\"")
+					}
+				}
 
 			} else if str == "feat_location" {
 
@@ -6493,6 +9332,14 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 				} else {
 					acc = append(acc, "-deq", "\"\\t\"")
 				}
+				if joined != "" {
+					// -joined rows need a placeholder column for a feature without intervals
+					if isPipe {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\\-")
+					} else {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\"\\-\"")
+					}
+				}
 
 			} else if str == "feat_intervals" {
 
@@ -6514,6 +9361,14 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 				} else {
 					acc = append(acc, "-deq", "\"\\t\"")
 				}
+				if joined != "" {
+					// -joined rows need a placeholder column for a feature without intervals
+					if isPipe {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\\-")
+					} else {
+						acc = append(acc, "-block", "INSDFeature", "-unless", "INSDInterval", "-lbl", "\"\\-\"")
+					}
+				}
 
 			} else if str == "chloroplast" ||
 				str == "chromoplast" ||
@@ -6648,6 +9503,375 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 	return acc
 }
 
+// JoinINSDFeatures combines -insd -joined rows, which start with accession, feature key,
+// locus_tag, gene, and location, into one row per locus_tag (or gene, or location). Repeated
+// features with the same key are paired in order. Each row has the qualifier columns of every
+// requested feature, in the order the features were requested, with dashes for a missing feature.
+func JoinINSDFeatures(text string, features []string) string {
+
+	const pairingCols = 5
+
+	priority := make(map[string]int)
+	for i, ftr := range features {
+		priority[ftr] = i
+	}
+
+	type joinGroup struct {
+		rows [][][]string
+	}
+
+	var order []string
+	groups := make(map[string]*joinGroup)
+
+	// number of qualifier columns requested for each feature
+	width := 0
+
+	var buffer strings.Builder
+
+	lines := strings.Split(text, "\n")
+
+	for _, line := range lines {
+
+		cols := strings.Split(line, "\t")
+		idx, ok := 0, false
+		if len(cols) >= pairingCols {
+			idx, ok = priority[cols[1]]
+		}
+		if !ok {
+			// pass through descriptor lines
+			if line != "" {
+				buffer.WriteString(line)
+				buffer.WriteString("\n")
+			}
+			continue
+		}
+
+		key := cols[2]
+		if key == "-" {
+			key = cols[3]
+		}
+		if key == "-" {
+			key = cols[4]
+		}
+		key = cols[0] + "\t" + key
+
+		grp, found := groups[key]
+		if !found {
+			grp = &joinGroup{rows: make([][][]string, len(features))}
+			groups[key] = grp
+			order = append(order, key)
+		}
+		grp.rows[idx] = append(grp.rows[idx], cols)
+
+		if len(cols)-pairingCols > width {
+			width = len(cols) - pairingCols
+		}
+	}
+
+	for _, key := range order {
+
+		grp := groups[key]
+
+		num := 0
+		for _, rows := range grp.rows {
+			if len(rows) > num {
+				num = len(rows)
+			}
+		}
+
+		accn, _ := SplitInTwoLeft(key, "\t")
+
+		for n := 0; n < num; n++ {
+
+			buffer.WriteString(accn)
+
+			for _, rows := range grp.rows {
+				for j := 0; j < width; j++ {
+					val := "-"
+					if n < len(rows) && pairingCols+j < len(rows[n]) {
+						val = rows[n][pairingCols+j]
+					}
+					buffer.WriteString("\t")
+					buffer.WriteString(val)
+				}
+			}
+
+			buffer.WriteString("\n")
+		}
+	}
+
+	return buffer.String()
+}
+
+// BIOC EXTRACTION COMMAND GENERATOR
+
+// e.g., xtract -bioc abstract,paragraph section_type text offset
+
+// ProcessBioC generates extraction commands for PMC passages in BioC format
+func ProcessBioC(args []string, isPipe bool) []string {
+
+	// friendly section names map to the infon value that identifies the passage
+	sections := map[string]string{
+		"title":          "TITLE",
+		"abstract":       "ABSTRACT",
+		"paragraph":      "paragraph",
+		"table":          "TABLE",
+		"figure-caption": "fig_caption",
+	}
+
+	// passage fields, infon keys are extracted by subset on the key attribute
+	fields := []string{
+		"section_type",
+		"type",
+		"offset",
+		"text",
+	}
+
+	checkAgainstVocabulary := func(str string) {
+
+		for _, txt := range fields {
+			if str == txt {
+				return
+			}
+			if strings.ToUpper(str) == strings.ToUpper(txt) {
+				fmt.Fprintf(os.Stderr, "\nERROR: Incorrect capitalization of '%s' field, change to '%s'\n", str, txt)
+				os.Exit(1)
+			}
+		}
+		for txt := range sections {
+			if strings.ToUpper(str) == strings.ToUpper(txt) {
+				fmt.Fprintf(os.Stderr, "\nERROR: Incorrect capitalization of '%s' section, change to '%s'\n", str, txt)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\nERROR: Item '%s' is not a legal -bioc field\n", str)
+		os.Exit(1)
+	}
+
+	quote := func(str string) string {
+		if isPipe {
+			return str
+		}
+		return "\"" + str + "\""
+	}
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Insufficient command-line arguments supplied to xtract -bioc\n")
+		os.Exit(1)
+	}
+
+	// optional comma-separated list of section names restricts passages
+	var wanted []string
+	for _, str := range strings.Split(args[0], ",") {
+		val, ok := sections[str]
+		if !ok {
+			wanted = nil
+			break
+		}
+		wanted = append(wanted, val)
+	}
+	if wanted != nil {
+		args = args[1:]
+	}
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No passage fields supplied to xtract -bioc\n")
+		os.Exit(1)
+	}
+
+	var acc []string
+
+	acc = append(acc, "-pattern", "document", "-ID", "id", "-block", "passage")
+
+	for i, val := range wanted {
+		if i == 0 {
+			acc = append(acc, "-if")
+		} else {
+			acc = append(acc, "-or")
+		}
+		acc = append(acc, "infon", "-equals", val)
+	}
+
+	// each passage starts a new row with the document identifier
+	if isPipe {
+		acc = append(acc, "-clr", "-pfx", "\\n", "-element", "&ID")
+	} else {
+		acc = append(acc, "-clr", "-pfx", "\"\\n\"", "-element", "\"&ID\"")
+	}
+
+	for _, str := range args {
+
+		checkAgainstVocabulary(str)
+
+		switch str {
+		case "section_type", "type":
+			acc = append(acc, "-subset", "infon", "-if", quote("@key"), "-equals", str, "-element", "infon")
+		default:
+			acc = append(acc, "-subset", str, "-element", str)
+		}
+	}
+
+	return acc
+}
+
+// AUTHOR EXTRACTION COMMAND GENERATOR
+
+// e.g., xtract -authors -first-only
+
+// ProcessAuthors generates extraction commands for one row per PubMed author
+func ProcessAuthors(args []string, isPipe bool) []string {
+
+	quote := func(str string) string {
+		if isPipe {
+			return str
+		}
+		return "\"" + str + "\""
+	}
+
+	firstOnly := false
+
+	for _, str := range args {
+		switch str {
+		case "-first-only":
+			firstOnly = true
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized argument '%s' supplied to xtract -authors\n", str)
+			os.Exit(1)
+		}
+	}
+
+	var acc []string
+
+	acc = append(acc, "-pattern", "PubmedArticle", "-ID", "MedlineCitation/PMID", "-block", "Author")
+	if firstOnly {
+		acc = append(acc, "-position", "first")
+	}
+
+	// each author starts a new row with the PMID, matching -insd accession behavior
+	acc = append(acc, "-clr", "-pfx", quote("\\n"), "-element", quote("&ID"), "-pfx", quote(""))
+
+	// name falls back to CollectiveName when LastName and ForeName are absent
+	acc = append(acc, "-def", quote("\\-"), "-sep", quote(" "), "-element", "LastName,ForeName,CollectiveName")
+	acc = append(acc, "-sep", quote(""), "-element", "Initials")
+
+	// ORCID is stripped of any https://orcid.org/ prefix
+	acc = append(acc, "-subset", "Author", "-if", "Identifier@Source", "-equals", "ORCID")
+	acc = append(acc, "-reg", quote("^.*/"), "-exp", quote(""), "-replace", "Identifier", "-else", "-lbl", quote("\\-"))
+
+	acc = append(acc, "-subset", "Author", "-def", quote("\\-"), "-sep", quote("; "), "-element", "Affiliation")
+
+	return acc
+}
+
+// TAXONOMY EXTRACTION COMMAND GENERATOR
+
+// e.g., xtract -tax superkingdom phylum class order family genus
+
+// ProcessTaxonomy generates extraction commands for lineage ranks in TaxaSet records
+func ProcessTaxonomy(args []string, isPipe bool) []string {
+
+	// NCBI taxonomic rank vocabulary
+	ranks := []string{
+		"superkingdom",
+		"domain",
+		"realm",
+		"kingdom",
+		"subkingdom",
+		"superphylum",
+		"phylum",
+		"subphylum",
+		"superclass",
+		"class",
+		"subclass",
+		"infraclass",
+		"cohort",
+		"subcohort",
+		"superorder",
+		"order",
+		"suborder",
+		"infraorder",
+		"parvorder",
+		"superfamily",
+		"family",
+		"subfamily",
+		"tribe",
+		"subtribe",
+		"genus",
+		"subgenus",
+		"section",
+		"subsection",
+		"series",
+		"subseries",
+		"species group",
+		"species subgroup",
+		"species",
+		"subspecies",
+		"forma specialis",
+		"varietas",
+		"subvariety",
+		"forma",
+		"serogroup",
+		"serotype",
+		"biotype",
+		"genotype",
+		"morph",
+		"pathogroup",
+		"strain",
+		"isolate",
+		"clade",
+		"no rank",
+	}
+
+	checkAgainstVocabulary := func(str string) {
+
+		for _, txt := range ranks {
+			if str == txt {
+				return
+			}
+			if strings.ToUpper(str) == strings.ToUpper(txt) {
+				fmt.Fprintf(os.Stderr, "\nERROR: Incorrect capitalization of '%s' rank, change to '%s'\n", str, txt)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\nERROR: Item '%s' is not a legal -tax rank\n", str)
+		os.Exit(1)
+	}
+
+	quote := func(str string) string {
+		if isPipe {
+			return str
+		}
+		return "\"" + str + "\""
+	}
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Insufficient command-line arguments supplied to xtract -tax\n")
+		os.Exit(1)
+	}
+
+	var acc []string
+
+	// TaxId and ScientificName of the record itself are always the lead columns
+	acc = append(acc, "-pattern", "Taxon", "-first", "TaxId", "ScientificName")
+
+	for i, str := range args {
+
+		checkAgainstVocabulary(str)
+
+		// rank may be that of the record itself or of an ancestor in the lineage
+		vr := "RANK" + strconv.Itoa(i+1)
+		acc = append(acc, "-block", "Taxon", "-if", "Rank", "-equals", quote(str), "-"+vr, "ScientificName")
+		acc = append(acc, "-block", "LineageEx/Taxon", "-if", "Rank", "-equals", quote(str), "-"+vr, "ScientificName")
+
+		// dash placeholder keeps columns aligned when the rank is absent, even without a LineageEx
+		acc = append(acc, "-block", "Taxon", "-def", quote("\\-"), "-element", quote("&"+vr))
+	}
+
+	return acc
+}
+
 // BIOTHINGS EXTRACTION COMMAND GENERATOR
 
 // ProcessBiopath generates extraction commands for BioThings resources (undocumented)
@@ -0,0 +1,1428 @@
+package eutils
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// extractText runs xtract arguments on an XML string and returns the concatenated record results
+func extractText(t *testing.T, xml string, args ...string) string {
+
+	t.Helper()
+
+	var buf strings.Builder
+
+	err := ExtractStream(context.Background(), strings.NewReader(xml), args, func(str string) error {
+		buf.WriteString(str)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExtractStream %v: %v", args, err)
+	}
+
+	return buf.String()
+}
+
+func TestCSVQuoting(t *testing.T) {
+
+	xml := `<Set>
+<PubmedArticle><PMID>1</PMID><ArticleTitle>Cats, "dogs", and mice</ArticleTitle><AbstractText>line one
+line two</AbstractText></PubmedArticle>
+<PubmedArticle><PMID>2</PMID><ArticleTitle>Plain title</ArticleTitle></PubmedArticle>
+</Set>
+`
+
+	out := extractText(t, xml, "-pattern", "PubmedArticle", "-def", "-", "-element", "PMID", "ArticleTitle", "AbstractText", "-csv")
+
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v\n%s", err, out)
+	}
+
+	want := [][]string{
+		{"1", `Cats, "dogs", and mice`, "line one\nline two"},
+		{"2", "Plain title", "-"},
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d\n%s", len(rows), len(want), out)
+	}
+	for i, row := range rows {
+		if strings.Join(row, "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d: got %q, want %q", i, row, want[i])
+		}
+	}
+}
+
+func TestCSVPrefixAndSuffix(t *testing.T) {
+
+	xml := "<Set><Rec><A>x,y</A></Rec></Set>\n"
+
+	out := extractText(t, xml, "-pattern", "Rec", "-pfx", "[", "-sfx", "]", "-element", "A", "-csv")
+
+	if out != "\"[x,y]\"\n" {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestCSVRejectsWrap(t *testing.T) {
+
+	_, err := ParseArgumentsErr([]string{"-csv", "-pattern", "Rec", "-wrp", "A", "-element", "A"}, "Rec")
+	if err == nil {
+		t.Error("expected -csv with -wrp to fail")
+	}
+}
+
+const pubmedSubtree = `<Set><PubmedArticle><MedlineCitation><PMID Version="1">123</PMID><Article>` +
+	`<ArticleTitle>A "quoted" \\ title: with colon</ArticleTitle>` +
+	`<AuthorList><Author><LastName>Smith</LastName></Author><Author><LastName>Jones</LastName></Author></AuthorList>` +
+	`<Count>12</Count><Score>1.5</Score><Flag>true</Flag></Article></MedlineCitation></PubmedArticle></Set>
+`
+
+func TestJSONPackageRepeatedKeys(t *testing.T) {
+
+	xml := `<Set>
+<Rec><Id>1</Id><Aff>A</Aff><Aff>B</Aff><Aff>A</Aff></Rec>
+<Rec><Id>2</Id><Aff>C</Aff></Rec>
+<Rec><Id>3</Id></Rec>
+</Set>
+`
+
+	out := extractText(t, xml, "-pattern", "Rec", "-jsonpkg", "-lbl", "x", "-element", "Id", "-block", "Aff", "-element", "Aff")
+
+	want := []string{
+		`{"x":1,"Aff":["A","B","A"]}`,
+		`{"x":2,"Aff":"C"}`,
+		`{"x":3}`,
+	}
+
+	// xtract adds the braces and commas around each record
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", out)
+	}
+	for i, line := range lines {
+		obj := "{" + line + "}"
+		if obj != want[i] {
+			t.Errorf("record %d: got %s, want %s", i+1, obj, want[i])
+		}
+		// a parser keeps every repeated value
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(obj), &rec); err != nil {
+			t.Errorf("record %d is not valid JSON: %v", i+1, err)
+		}
+	}
+}
+
+func TestJSONObject(t *testing.T) {
+
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{"", ""},
+		{"\x1EId\x1F7", `"Id":7`},
+		{"\x1EId\x1F7\x1EName\x1Fx\x1Fy", `"Id":7,"Name":["x","y"]`},
+		{"\x1EA\x1F1\x1EB\x1F2\x1EA\x1F3\x1F4", `"A":[1,3,4],"B":2`},
+		{"\x1EA \"q\"\x1F007", `"A \"q\"":"007"`},
+	}
+
+	for _, tt := range tests {
+		if got := jsonObject(tt.fields); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestJSONTreeRoundTrip(t *testing.T) {
+
+	out := extractText(t, pubmedSubtree, "-pattern", "PubmedArticle", "-element", "%")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+
+	want := map[string]interface{}{
+		"MedlineCitation": map[string]interface{}{
+			"PMID": 123.0,
+			"Article": map[string]interface{}{
+				"ArticleTitle": `A "quoted" \\ title: with colon`,
+				"AuthorList": map[string]interface{}{
+					"Author": []interface{}{
+						map[string]interface{}{"LastName": "Smith"},
+						map[string]interface{}{"LastName": "Jones"},
+					},
+				},
+				"Count": 12.0,
+				"Score": 1.5,
+				"Flag":  true,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// marshaling and unmarshaling again must give the same structure
+	raw, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again map[string]interface{}
+	if err := json.Unmarshal(raw, &again); err != nil || !reflect.DeepEqual(again, got) {
+		t.Errorf("round trip changed structure: %v", err)
+	}
+}
+
+func TestYAMLTreeDocuments(t *testing.T) {
+
+	xml := `<Set>
+<DocumentSummary><Id>1</Id><Title>Key: value</Title><Item>a</Item><Item>-b</Item></DocumentSummary>
+<DocumentSummary><Id>2</Id><Title>Plain</Title></DocumentSummary>
+</Set>
+`
+
+	out := extractText(t, xml, "-pattern", "DocumentSummary", "-element", "=")
+
+	want := `---
+Id: 1
+Title: "Key: value"
+Item:
+  - a
+  - "-b"
+---
+Id: 2
+Title: Plain
+`
+
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+
+	tests := []struct {
+		xml  string
+		args []string
+		want string
+	}{
+		// integer pipelines are unchanged
+		{"<N>3</N><N>4</N>", []string{"-sum", "N"}, "7\n"},
+		{"<N>7</N><N>2</N>", []string{"-div", "N"}, "3\n"},
+		// mixed integer and floating-point input
+		{"<N>3</N><N>1.25</N>", []string{"-sum", "N"}, "4.25\n"},
+		{"<N>2</N><N>0.5</N>", []string{"-mul", "N"}, "1\n"},
+		// large values keep full precision
+		{"<N>12345678901.5</N><N>1</N>", []string{"-sum", "N"}, "12345678902.5\n"},
+		// small values do not switch to exponent notation
+		{"<N>1.5e-3</N>", []string{"-max", "N"}, "0.0015\n"},
+		{"<N>1.5e-3</N><N>1</N>", []string{"-sub", "N"}, "-0.9985\n"},
+		// modulus skips fractional values instead of failing
+		{"<N>7</N><N>2.5</N><N>3</N>", []string{"-mod", "N"}, "1\n"},
+		{"<N>7</N><N>2.5</N>", []string{"-mod", "N"}, ""},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		out := extractText(t, "<Set><Rec>"+tt.xml+"</Rec></Set>\n", args...)
+		if out != tt.want {
+			t.Errorf("%s %v: got %q, want %q", tt.xml, tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestSortValues(t *testing.T) {
+
+	xml := `<Set><Rec>
+<Q db="x">taxon:9606</Q><Q db="y">GeneID:7157</Q><Q db="z">HGNC:11998</Q><Q db="w">geneid:7157</Q>
+<N>10</N><N>abc</N><N>-2.5</N><N>1e1</N><N>3</N><N>xyz</N>
+<S>only</S>
+</Rec></Set>
+`
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// case-insensitive ordering keeps equal keys in original order
+		{[]string{"-sep", "|", "-sort-values", "Q"}, "GeneID:7157|geneid:7157|HGNC:11998|taxon:9606\n"},
+		// attribute addressing
+		{[]string{"-sep", "|", "-sort-values", "Q@db"}, "w|x|y|z\n"},
+		// parent addressing
+		{[]string{"-sep", "|", "-sort-values", "Rec/Q@db"}, "w|x|y|z\n"},
+		// ranges trim values before sorting
+		{[]string{"-sep", "|", "-sort-values", "Q[1:4]"}, "Gene|gene|HGNC|taxo\n"},
+		// numeric ordering is stable, with unparseable values at the end in original order
+		{[]string{"-sep", "|", "-sort-numeric", "N"}, "-2.5|3|10|1e1|abc|xyz\n"},
+		// single values pass through unchanged
+		{[]string{"-sort-values", "S"}, "only\n"},
+		{[]string{"-sort-numeric", "S"}, "only\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		out := extractText(t, xml, args...)
+		if out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestNumericConditionals(t *testing.T) {
+
+	tests := []struct {
+		xml  string
+		args []string
+		want bool
+	}{
+		{"<V>3.14</V>", []string{"-if", "V", "-gt", "3"}, true},
+		{"<V>2.9</V>", []string{"-if", "V", "-gt", "3"}, false},
+		{"<V>1e-5</V>", []string{"-if", "V", "-lt", "0.001"}, true},
+		{"<V>1e-2</V>", []string{"-if", "V", "-lt", "0.001"}, false},
+		{"<V>-2.5</V>", []string{"-if", "V", "-lt", "-2"}, true},
+		{"<V>-7</V>", []string{"-if", "V", "-ge", "-7"}, true},
+		{"<V>-7</V>", []string{"-if", "V", "-ne", "-7"}, false},
+		{"<V>2.50</V>", []string{"-if", "V", "-eq", "2.5"}, true},
+		{"<V>.5</V>", []string{"-if", "V", "-le", ".5"}, true},
+		// integers keep exact comparison
+		{"<V>9007199254740993</V>", []string{"-if", "V", "-gt", "9007199254740992"}, true},
+		// element as second argument with floating-point content
+		{"<V>1e-10</V><W>0.05</W>", []string{"-if", "V", "-lt", "W"}, true},
+		{"<V>0.5</V><W>0.05</W>", []string{"-if", "V", "-lt", "W"}, false},
+		// non-numeric values never match
+		{"<V>abc</V>", []string{"-if", "V", "-gt", "0"}, false},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		args = append(args, "-element", "V")
+		out := extractText(t, "<Set><Rec>"+tt.xml+"</Rec></Set>\n", args...)
+		if (out != "") != tt.want {
+			t.Errorf("%s %v: got %q, want match %v", tt.xml, tt.args, out, tt.want)
+		}
+	}
+}
+
+const crisprSet = `<PubmedArticleSet>
+<PubmedArticle><PMID>1</PMID><ArticleTitle>Genome editing with CRISPR-Cas9 in mice</ArticleTitle></PubmedArticle>
+<PubmedArticle><PMID>2</PMID><ArticleTitle>A CRISPR Cas9 screen</ArticleTitle></PubmedArticle>
+<PubmedArticle><PMID>3</PMID><ArticleTitle>CRISPR arrays in archaea</ArticleTitle></PubmedArticle>
+<PubmedArticle><PMID>4</PMID><ArticleTitle>crispr-cas9 delivery</ArticleTitle></PubmedArticle>
+</PubmedArticleSet>
+`
+
+func TestRegexConditional(t *testing.T) {
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"CRISPR[- ]Cas9", "1\n2\n"},
+		{"(?i)crispr[- ]cas9", "1\n2\n4\n"},
+		{"^CRISPR", "3\n"},
+		{"[a-z]{4}$", "1\n2\n3\n4\n"},
+		{"in (mice|archaea)$", "1\n3\n"},
+	}
+
+	for _, tt := range tests {
+		out := extractText(t, crisprSet, "-pattern", "PubmedArticle", "-if", "ArticleTitle", "-regex", tt.expr, "-element", "PMID")
+		if out != tt.want {
+			t.Errorf("-regex %q: got %q, want %q", tt.expr, out, tt.want)
+		}
+	}
+
+	_, err := ParseArgumentsErr([]string{"-pattern", "PubmedArticle", "-if", "ArticleTitle", "-regex", "CRISPR[", "-element", "PMID"}, "PubmedArticle")
+	if err == nil || !strings.Contains(err.Error(), "CRISPR[") {
+		t.Errorf("expected parse error naming bad expression, got %v", err)
+	}
+}
+
+// findRegex returns the compiled expressions attached to -regex conditions
+func findRegex(blk *Block) []*regexp.Regexp {
+
+	var res []*regexp.Regexp
+
+	for _, op := range blk.Conditions {
+		for _, stp := range op.Stages {
+			if stp.Type == REGEX {
+				res = append(res, stp.Regx)
+			}
+		}
+	}
+	for _, sub := range blk.Subtasks {
+		res = append(res, findRegex(sub)...)
+	}
+
+	return res
+}
+
+func BenchmarkRegexConditional(b *testing.B) {
+
+	cmds, err := ParseArgumentsErr([]string{"-pattern", "PubmedArticle", "-if", "ArticleTitle", "-regex", "CRISPR[- ]Cas9", "-element", "PMID"}, "PubmedArticle")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// expression is compiled once while parsing, and the same object serves every record
+	rgx := findRegex(cmds)
+	if len(rgx) != 1 || rgx[0] == nil {
+		b.Fatalf("expected one compiled expression, found %v", rgx)
+	}
+	compiled := rgx[0]
+
+	rec := "<PubmedArticle><PMID>1</PMID><ArticleTitle>Genome editing with CRISPR-Cas9 in mice</ArticleTitle></PubmedArticle>"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ProcessExtract(rec, "", i+1, "", "", nil, nil, nil, cmds) != "1\n" {
+			b.Fatal("record did not match")
+		}
+	}
+	b.StopTimer()
+
+	if findRegex(cmds)[0] != compiled {
+		b.Error("expression was recompiled during processing")
+	}
+}
+
+func TestMultipleConditionClauses(t *testing.T) {
+
+	xml := `<Set>
+<Rec><ID>1</ID><A>x</A><B>abc</B><C>c</C></Rec>
+<Rec><ID>2</ID><A>x</A><B>xyz</B><C>c</C></Rec>
+<Rec><ID>3</ID><A>z</A><B>abc</B><C>c</C></Rec>
+<Rec><ID>4</ID><A>x</A><B>abc</B></Rec>
+</Set>
+`
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// all clauses must pass
+		{[]string{"-if", "A", "-equals", "x", "-unless", "B", "-contains", "y", "-if", "C", "-element", "ID"}, "1\n"},
+		// -else fires when any clause fails
+		{[]string{"-if", "A", "-equals", "x", "-unless", "B", "-contains", "y", "-if", "C", "-lbl", "yes", "-else", "-element", "ID"}, "yes\n2\n3\n4\n"},
+		// each clause keeps its own -or chain
+		{[]string{"-if", "A", "-equals", "z", "-or", "B", "-equals", "xyz", "-if", "C", "-element", "ID"}, "2\n3\n"},
+		{[]string{"-unless", "A", "-equals", "z", "-unless", "C", "-element", "ID"}, "4\n"},
+		// deprecated -match and -avoid still work
+		{[]string{"-match", "A:x", "-element", "ID"}, "1\n2\n4\n"},
+		{[]string{"-avoid", "A:x", "-element", "ID"}, "3\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		out := extractText(t, xml, args...)
+		if out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestColumnHeader(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-element", "MedlineCitation/PMID", "Title"}, "PMID\tTitle"},
+		{[]string{"-tab", "|", "-element", "PMID", "Title"}, "PMID|Title"},
+		{[]string{"-element", "PMID,Title", "Year"}, "PMID\tTitle\tYear"},
+		{[]string{"-sep", ";", "-element", "PMID,Title", "Year"}, "PMID;Title\tYear"},
+		{[]string{"-lbl", "Source", "-element", "PMID", "Author@id"}, "Source\tPMID\tid"},
+		{[]string{"-tag", "Entry", "-att", "type", "x", "-cls", "-element", "PMID", "-end", "Entry"}, "Entry"},
+		{[]string{"-element", "Year", "-tag", "Entry", "-cls", "-element", "PMID", "-end", "Entry"}, "Year\tEntry"},
+		{[]string{"-element", "PMID", "Title", "-csv"}, "PMID,Title"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec", "-def", "-"}, tt.args...)
+		cmds, err := ParseArgumentsErr(args, "Rec")
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := ColumnHeader(cmds); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	// header separators match those of the data row
+	args := []string{"-pattern", "Rec", "-tab", "|", "-sep", ";", "-element", "PMID,Title", "Year"}
+	cmds, err := ParseArgumentsErr(args, "Rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := extractText(t, "<Set><Rec><PMID>1</PMID><Title>T</Title><Year>2000</Year></Rec></Set>\n", args...)
+	if hdr := ColumnHeader(cmds); strings.Count(hdr, "|") != strings.Count(row, "|") || strings.Count(hdr, ";") != strings.Count(row, ";") {
+		t.Errorf("header %q does not line up with row %q", hdr, row)
+	}
+}
+
+func TestFirstAndLastN(t *testing.T) {
+
+	xml := `<Set><Rec><K>2</K>
+<Author><LastName>Ames</LastName></Author><Author><LastName>Baker</LastName></Author>
+<Author><LastName>Cole</LastName></Author><Author><LastName>Dunn</LastName></Author>
+</Rec></Set>
+`
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-sep", "|", "-first-n", "3", "Author/LastName"}, "Ames|Baker|Cole\n"},
+		{[]string{"-sep", "|", "-last-n", "2", "Author/LastName"}, "Cole|Dunn\n"},
+		// n=1 is the same as -first and -last
+		{[]string{"-first-n", "1", "LastName"}, "Ames\n"},
+		{[]string{"-first", "LastName"}, "Ames\n"},
+		{[]string{"-last-n", "1", "LastName"}, "Dunn\n"},
+		{[]string{"-last", "LastName"}, "Dunn\n"},
+		// more than available prints all values
+		{[]string{"-sep", "|", "-first-n", "10", "LastName"}, "Ames|Baker|Cole|Dunn\n"},
+		{[]string{"-sep", "|", "-last-n", "10", "LastName"}, "Ames|Baker|Cole|Dunn\n"},
+		// count can come from a variable
+		{[]string{"-COUNT", "K", "-sep", "|", "-last-n", "&COUNT", "LastName"}, "Cole|Dunn\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		out := extractText(t, xml, args...)
+		if out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestJoinINSDFeatures(t *testing.T) {
+
+	ftr := func(key, tag, loc, quals string) string {
+		str := "<INSDFeature><INSDFeature_key>" + key + "</INSDFeature_key>"
+		if loc != "" {
+			str += "<INSDFeature_intervals><INSDInterval><INSDInterval_from>" + loc +
+				"</INSDInterval_from><INSDInterval_to>60</INSDInterval_to></INSDInterval></INSDFeature_intervals>"
+		}
+		str += "<INSDFeature_quals><INSDQualifier><INSDQualifier_name>locus_tag</INSDQualifier_name>" +
+			"<INSDQualifier_value>" + tag + "</INSDQualifier_value></INSDQualifier>" + quals + "</INSDFeature_quals></INSDFeature>"
+		return str
+	}
+	qual := func(name, val string) string {
+		return "<INSDQualifier><INSDQualifier_name>" + name + "</INSDQualifier_name><INSDQualifier_value>" + val + "</INSDQualifier_value></INSDQualifier>"
+	}
+	seq := func(accn, ftrs string) string {
+		return "<INSDSeq><INSDSeq_locus>" + accn + "</INSDSeq_locus><INSDSeq_accession-version>" + accn +
+			"</INSDSeq_accession-version><INSDSeq_feature-table>" + ftrs + "</INSDSeq_feature-table></INSDSeq>"
+	}
+
+	xml := "<INSDSet>" +
+		seq("NM_1.1",
+			ftr("mRNA", "T1", "1", qual("gene", "ABC")+qual("transcript_id", "NM_1.1"))+
+				ftr("CDS", "T1", "10", qual("gene", "ABC")+qual("product", "abc protein"))+
+				ftr("CDS", "T2", "", qual("gene", "XYZ")+qual("product", "xyz protein"))) +
+		seq("NM_2.1",
+			ftr("mRNA", "T3", "1", qual("transcript_id", "NM_2.1"))) +
+		"</INSDSet>\n"
+
+	args := ProcessINSD([]string{"-joined", "CDS,mRNA", "gene", "product", "transcript_id"}, true, false, false)
+	if len(args) < 2 || args[0] != "-join-features" {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+	features := strings.Split(args[1], ",")
+
+	var buf strings.Builder
+	err := ExtractStream(context.Background(), strings.NewReader(xml), args[2:], func(str string) error {
+		// rows for each record are joined separately, as by CreateFeatureJoiner
+		buf.WriteString(JoinINSDFeatures(str, features))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one row per record group, with CDS columns then mRNA columns, and dashes for a missing feature
+	want := "NM_1.1\tABC\tabc protein\t-\tABC\t-\tNM_1.1\n" +
+		"NM_1.1\tXYZ\txyz protein\t-\t-\t-\t-\n" +
+		"NM_2.1\t-\t-\t-\t-\t-\tNM_2.1\n"
+
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestOrfsExtraction(t *testing.T) {
+
+	seq := "CC" + "ATG" + strings.Repeat("GCT", 10) + "TAA" + "CC"
+	xml := "<INSDSet><INSDSeq><INSDSeq_sequence>" + seq + "</INSDSeq_sequence></INSDSeq></INSDSet>\n"
+
+	if out := extractText(t, xml, "-pattern", "INSDSeq", "-sep", "|", "-orfs:min=30,atg", "INSDSeq_sequence"); out != "3..38 + 3 36\n" {
+		t.Errorf("got %q", out)
+	}
+	if out := extractText(t, xml, "-pattern", "INSDSeq", "-orfs:min=30,atg,prot", "INSDSeq_sequence"); out != "MAAAAAAAAAA\n" {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestTaxonomyRanks(t *testing.T) {
+
+	xml := `<TaxaSet>
+<Taxon><TaxId>9606</TaxId><ScientificName>Homo sapiens</ScientificName><Rank>species</Rank>
+<LineageEx>
+<Taxon><TaxId>40674</TaxId><ScientificName>Mammalia</ScientificName><Rank>class</Rank></Taxon>
+<Taxon><TaxId>9604</TaxId><ScientificName>Hominidae</ScientificName><Rank>family</Rank></Taxon>
+<Taxon><TaxId>9605</TaxId><ScientificName>Homo</ScientificName><Rank>genus</Rank></Taxon>
+</LineageEx></Taxon>
+<Taxon><TaxId>1</TaxId><ScientificName>root</ScientificName><Rank>no rank</Rank></Taxon>
+<Taxon><TaxId>9605</TaxId><ScientificName>Homo</ScientificName><Rank>genus</Rank>
+<LineageEx>
+<Taxon><TaxId>9604</TaxId><ScientificName>Hominidae</ScientificName><Rank>family</Rank></Taxon>
+</LineageEx></Taxon>
+</TaxaSet>
+`
+
+	args := ProcessTaxonomy([]string{"class", "family", "genus", "species"}, true)
+
+	got := extractText(t, xml, args...)
+	want := "9606\tHomo sapiens\tMammalia\tHominidae\tHomo\tHomo sapiens\n" +
+		"1\troot\t-\t-\t-\t-\n" +
+		"9605\tHomo\t-\tHominidae\tHomo\t-\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// printed command quotes variables, which a shell would otherwise treat as a background operator
+	cmd := strings.Join(ProcessTaxonomy([]string{"genus"}, false), " ")
+	if !strings.Contains(cmd, `-element "&RANK1"`) || strings.Contains(cmd, " &RANK1") {
+		t.Errorf("unquoted variable in %s", cmd)
+	}
+}
+
+func TestPositionRanges(t *testing.T) {
+
+	// author lists of one, two, and four names
+	xml := `<Set>
+<Rec><Id>1</Id><Au>A</Au></Rec>
+<Rec><Id>2</Id><Au>A</Au><Au>B</Au></Rec>
+<Rec><Id>4</Id><Au>A</Au><Au>B</Au><Au>C</Au><Au>D</Au></Rec>
+</Set>
+`
+
+	tests := []struct {
+		posn string
+		want string
+	}{
+		// skips only the first author, whatever the list length
+		{"2:-1", "1\n2\tB\n4\tB\tC\tD\n"},
+		// range past the end of a list stops at its last node
+		{"2:5", "1\n2\tB\n4\tB\tC\tD\n"},
+		{"3:", "1\n2\n4\tC\tD\n"},
+		{":3", "1\tA\n2\tA\tB\n4\tA\tB\tC\n"},
+		{"-3:-1", "1\tA\n2\tA\tB\n4\tB\tC\tD\n"},
+		{"-2", "1\n2\tA\n4\tC\n"},
+		// range or index entirely outside of a list selects nothing
+		{"5:9", "1\n2\n4\n"},
+		{"3", "1\n2\n4\tC\n"},
+		{"-5", "1\n2\n4\n"},
+		// reversed range selects nothing
+		{"3:2", "1\n2\n4\n"},
+		{"-1:-2", "1\n2\n4\n"},
+	}
+
+	for _, tt := range tests {
+		out := extractText(t, xml, "-pattern", "Rec", "-element", "Id", "-block", "Au", "-position", tt.posn, "-element", "Au")
+		if out != tt.want {
+			t.Errorf("-position %s: got %q, want %q", tt.posn, out, tt.want)
+		}
+	}
+
+	// zero is rejected before any record is read
+	for _, posn := range []string{"0", "0:2", "2:0"} {
+		err := ExtractStream(context.Background(), strings.NewReader(xml), []string{"-pattern", "Rec", "-block", "Au", "-position", posn, "-element", "Au"}, func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "cannot be 0") {
+			t.Errorf("-position %s: got error %v", posn, err)
+		}
+	}
+}
+
+func TestPositionBounds(t *testing.T) {
+
+	tests := []struct {
+		n, beg, end int
+		isRange     bool
+		lo, hi      int
+	}{
+		{4, 2, -1, true, 2, 4},
+		{1, 2, -1, true, 2, 1},
+		{3, 2, 10, true, 2, 3},
+		{3, -10, -1, true, 1, 3},
+		{3, 0, 2, true, 1, 2},
+		{3, 2, 0, true, 2, 3},
+		{3, 3, 2, true, 3, 2},
+		{3, -1, -1, false, 3, 3},
+		{3, 4, 4, false, 1, 0},
+		{3, -4, -4, false, 1, 0},
+		{0, 1, 0, true, 1, 0},
+	}
+
+	for _, tt := range tests {
+		// lo greater than hi selects nothing
+		lo, hi := PositionBounds(tt.n, tt.beg, tt.end, tt.isRange)
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("n %d, %d:%d, range %v: got %d..%d, want %d..%d", tt.n, tt.beg, tt.end, tt.isRange, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestParseArgumentErrors(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-element", "A"}, "No -pattern in command-line arguments"},
+		{[]string{"-pattern", "Rec", "-element", "A[3]"}, "Colon missing in range A[3]"},
+		{[]string{"-pattern", "Rec", "-element", "A[x:]"}, "Unrecognized range component A[x:]"},
+		{[]string{"-pattern", "Rec", "-element", "A[&:2]"}, "Unrecognized variable '&'"},
+		{[]string{"-pattern", "Rec", "-if", "A[0:2]", "-element", "A"}, "Range component A[0:] must be positive"},
+		{[]string{"-pattern", "Rec", "-if", "&lower", "-element", "A"}, "Unrecognized variable '&lower'"},
+		{[]string{"-pattern", "Rec", "-if", "A", "-lt", "#", "-element", "A"}, "Element missing after '#'"},
+		{[]string{"-pattern", "Rec", "-if", "A", "-regex", "(", "-element", "A"}, "Invalid regular expression '('"},
+		{[]string{"-pattern", "Rec", "-reg", "(", "-replace", "A"}, "Invalid -reg pattern '(': error parsing regexp: missing closing ): `(`"},
+		{[]string{"-pattern", "Rec", "-if", "D", "-age:weeks", "-lt", "3", "-element", "A"}, "Unit in '-age:weeks' must be days or years"},
+		{[]string{"-pattern", "Rec", "-gc:abc", "Seq"}, "Window size in 'abc' must be a positive integer"},
+		{[]string{"-pattern", "Rec", "-gc:100,0", "Seq"}, "Step size in '100,0' must be a positive integer"},
+		{[]string{"-pattern", "Rec", "-orfs:min=x", "Seq"}, "Value in 'min=x' must be a positive integer"},
+		{[]string{"-pattern", "Rec", "-orfs:bogus", "Seq"}, "Unrecognized -orfs setting 'bogus'"},
+		{[]string{"-pattern", "Rec", "-fasta:0", "Seq"}, "Line width in '-fasta:0' must be a positive integer"},
+		{[]string{"-pattern", "Rec", "-block", "B", "-first-n", "0", "A"}, "Count '0' must be a positive integer"},
+		{[]string{"-pattern", "Rec", "-element", "A", "-else", "-lbl", "x"}, "Misplaced -else command"},
+		{[]string{"-pattern", "Rec", "-lbl", "x"}, "No -element statement in argument list"},
+		{[]string{"-pattern", "Rec", "-fmt", "%z", "-element", "A"}, "Unsupported -fmt specification '%z'"},
+		{[]string{"-pattern", "Rec", "-fmt", "%x", "-element", "A"}, "Unsupported -fmt specification '%x'"},
+		{[]string{"-pattern", "Rec", "-fmt", "abc", "-element", "A"}, "No numeric directive in -fmt specification 'abc'"},
+		{[]string{"-pattern", "Rec", "-fmt", "%d-%d", "-element", "A"}, "Multiple directives in -fmt specification '%d-%d'"},
+		{[]string{"-pattern", "Rec", "-block", "A", "-position", "x:y", "-element", "A"}, "Unrecognized position 'x:y'"},
+		{[]string{"-pattern", "Rec", "-block", "A", "-position", "2:x", "-element", "A"}, "Unrecognized position '2:x'"},
+		{[]string{"-pattern", "Rec", "-block", "A", "-position", "middle", "-element", "A"}, "Unrecognized position 'middle'"},
+		{[]string{"-pattern", "Rec", "-block", "A", "-position", "0", "-element", "A"}, "Position '0' cannot be 0, count from 1 at the start or from -1 at the end"},
+		{[]string{"-pattern", "Rec", "-block", "A", "-position", "0:3", "-element", "A"}, "Position '0:3' cannot be 0, count from 1 at the start or from -1 at the end"},
+		{[]string{"-pattern", "Rec", "-position", "-1:0", "-element", "A"}, "Position '-1:0' cannot be 0, count from 1 at the start or from -1 at the end"},
+	}
+
+	for _, tt := range tests {
+		blk, err := ParseArgumentsErr(tt.args, "Rec")
+		if err == nil {
+			t.Errorf("%v: expected error %q", tt.args, tt.want)
+			continue
+		}
+		if blk != nil {
+			t.Errorf("%v: expected nil block with error", tt.args)
+		}
+		if err.Error() != tt.want {
+			t.Errorf("%v: got error %q, want %q", tt.args, err.Error(), tt.want)
+		}
+	}
+
+	// valid options still parse
+	for _, args := range [][]string{
+		{"-pattern", "Rec", "-gc:100,50", "Seq"},
+		{"-pattern", "Rec", "-orfs:min=30,code=11,atg", "Seq"},
+		{"-pattern", "Rec", "-if", "D", "-age:years", "-lt", "3", "-element", "A"},
+		{"-pattern", "Rec", "-block", "A", "-position", "2:-1", "-element", "A"},
+		{"-pattern", "Rec", "-block", "A", "-position", ":3", "-element", "A"},
+		{"-pattern", "Rec", "-block", "A", "-position", "outer", "-element", "A"},
+	} {
+		if _, err := ParseArgumentsErr(args, "Rec"); err != nil {
+			t.Errorf("%v: unexpected error %v", args, err)
+		}
+	}
+}
+
+// classifyText runs -classify on each record through the consumer pipeline with a phrase table
+func classifyText(t *testing.T, xml string, terms map[string]string) string {
+
+	t.Helper()
+
+	if NumServe() < 1 {
+		SetTunings(0, 0, 0, 0, 0, 0, 0, false)
+	}
+
+	transform := make(map[string]string)
+	for ky, vl := range terms {
+		transform[ky] = vl
+	}
+
+	cmds := ParseArguments([]string{"-pattern", "Rec", "-element", "Id", "-classify", "Text"}, "Rec")
+
+	xmlq := CreateXMLProducer("Rec", "", false, CreateXMLStreamer(strings.NewReader(xml)))
+	tblq := CreateXMLConsumers(cmds, "", "", "", transform, true, nil, xmlq)
+	unsq := CreateXMLUnshuffler(tblq)
+
+	var buf strings.Builder
+	for ext := range unsq {
+		buf.WriteString(ext.Text)
+	}
+
+	return buf.String()
+}
+
+func TestGroupByCount(t *testing.T) {
+
+	if NumServe() < 1 {
+		SetTunings(0, 0, 0, 0, 0, 0, 0, false)
+	}
+
+	xml := `<Set>
+<Rec><J>Nature</J><Y>2020</Y></Rec>
+<Rec><J>Cell</J><Y>2021</Y></Rec>
+<Rec><J>Nature</J><Y>2020</Y></Rec>
+<Rec><J>Cell</J><Y>2020</Y></Rec>
+<Rec><J>Nature</J><Y>2021</Y></Rec>
+<Rec><J>Nature</J><Y>2020</Y></Rec>
+<Rec><J>Science</J></Rec>
+</Set>
+`
+
+	want := []string{"Nature\t2020\t3", "Cell\t2020\t1", "Cell\t2021\t1", "Nature\t2021\t1", "Science\t1"}
+
+	// trailing -count is optional
+	for _, args := range [][]string{
+		{"-pattern", "Rec", "-group-by", "J,Y", "-count"},
+		{"-pattern", "Rec", "-group-by", "J,Y"},
+	} {
+		cmds, err := ParseArgumentsErr(args, "Rec")
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+
+		histogram := make(map[string]int)
+
+		xmlq := CreateXMLProducer("Rec", "", false, CreateXMLStreamer(strings.NewReader(xml)))
+		tblq := CreateXMLConsumers(cmds, "", "", "", nil, false, histogram, xmlq)
+		for range CreateXMLUnshuffler(tblq) {
+		}
+
+		// decreasing count, ties in alphabetical order
+		var got []string
+		for _, key := range sortedByCount(histogram, 0) {
+			got = append(got, key+"\t"+strconv.Itoa(histogram[key]))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %q, want %q", args, got, want)
+		}
+	}
+
+	// -count is not a command by itself
+	if _, err := ParseArgumentsErr([]string{"-pattern", "Rec", "-element", "J", "-count"}, "Rec"); err == nil {
+		t.Errorf("expected error for -count without -group-by")
+	}
+}
+
+func TestClassifyOverlappingPhrases(t *testing.T) {
+
+	terms := map[string]string{
+		"lung cancer":        "Disease:lung cancer",
+		"cancer":             "Disease:cancer",
+		"Small Cell Lung":    "Histology:small cell",
+		"non-small cell":     "Histology:non-small cell",
+		"breast":             "Organ:breast",
+		"breast cancer gene": "Gene:BRCA",
+	}
+
+	xml := "<Set>" +
+		"<Rec><Id>1</Id><Text>Risk of lung cancer in smokers.</Text></Rec>" +
+		"<Rec><Id>2</Id><Text>Cancer screening.</Text></Rec>" +
+		"<Rec><Id>3</Id><Text>Small cell lung cancer and breast tissue.</Text></Rec>" +
+		"<Rec><Id>4</Id><Text>Mutations in a breast cancer gene.</Text></Rec>" +
+		"<Rec><Id>5</Id><Text>Cancers of the lung.</Text></Rec>" +
+		"</Set>"
+
+	got := strings.Split(strings.TrimSuffix(classifyText(t, xml, terms), "\n"), "\n")
+
+	want := []string{
+		// longer phrase wins, shorter one is not reported for the same words
+		"1\t<Disease>lung cancer</Disease>",
+		// capitalized text matches lower-case phrase
+		"2\t<Disease>cancer</Disease>",
+		// small cell lung takes lung from lung cancer, leaving cancer by itself
+		"3\t<Histology>small cell</Histology>\t<Disease>cancer</Disease>\t<Organ>breast</Organ>",
+		"4\t<Gene>BRCA</Gene>",
+		// whole words only
+		"5",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %q", got)
+	}
+	for i := range want {
+		if !sameFields(got[i], want[i]) {
+			t.Errorf("record %d: got %q, want %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+// sameFields compares tab-delimited rows, ignoring the order of fields after the first
+func sameFields(a, b string) bool {
+
+	fa := strings.Split(a, "\t")
+	fb := strings.Split(b, "\t")
+	if len(fa) != len(fb) || fa[0] != fb[0] {
+		return false
+	}
+
+	seen := make(map[string]int)
+	for _, f := range fa[1:] {
+		seen[f]++
+	}
+	for _, f := range fb[1:] {
+		seen[f]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestKeepMatchingObjects(t *testing.T) {
+
+	author := func(name, affl string) string {
+		return "<Author><Name>" + name + "</Name><Affiliation>" + affl + "</Affiliation></Author>"
+	}
+
+	xml := "<PubmedArticleSet>" +
+		"<PubmedArticle><PMID>1</PMID><AuthorList>" + author("Smith", "NIH") + author("Jones", "MIT") + "</AuthorList></PubmedArticle>" +
+		"<PubmedArticle><PMID>2</PMID><AuthorList>" + author("Brown", "MIT") + "</AuthorList></PubmedArticle>" +
+		"<PubmedArticle><PMID>3</PMID><AuthorList>" + author("Green", "NIH") + author("White", "NIH") + "</AuthorList></PubmedArticle>" +
+		"</PubmedArticleSet>\n"
+
+	kept := func(names ...string) string {
+		str := "<PubmedArticle>\n"
+		for _, name := range names {
+			str += "<Author>\n  <Name>" + name + "</Name>\n  <Affiliation>NIH</Affiliation>\n</Author>\n"
+		}
+		return str + "</PubmedArticle>"
+	}
+
+	// qualifying authors are grouped per article, and an article without any gets no wrapper
+	out := extractText(t, xml, "-pattern", "PubmedArticle", "-keep", "Author", "-if", "Affiliation", "-contains", "NIH")
+	want := kept("Smith") + "\n" + kept("Green", "White") + "\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	// a later -element is evaluated on the record, not inside the -keep block
+	out = extractText(t, xml, "-pattern", "PubmedArticle", "-keep", "Author", "-if", "Affiliation", "-contains", "NIH", "-element", "PMID")
+	want = kept("Smith") + "\n1\n" + "2\n" + kept("Green", "White") + "\n3\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+
+	xml := "<Set><Rec><Count>1234567</Count><Score>2</Score><Score>3</Score><Score>3</Score><Frac>0.123</Frac><Name>abc</Name></Rec></Set>\n"
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-fmt", "%.1f", "-avg", "Score"}, "2.7\n"},
+		{[]string{"-fmt", "%,d", "-element", "Count"}, "1,234,567\n"},
+		{[]string{"-fmt", "%.1f%%", "-element", "Frac"}, "12.3%\n"},
+		{[]string{"-fmt", "%%", "-element", "Frac"}, "12.3%\n"},
+		{[]string{"-fmt", "%.2f", "-element", "Name"}, "abc\n"},
+		{[]string{"-fmt", "%d", "-def", "-", "-element", "Missing"}, "-\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		if out := extractText(t, xml, args...); out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestFindDeprecated(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		posn []int
+		want []string
+	}{
+		// values are not commands
+		{[]string{"-pattern", "Rec", "-lbl", "-Block", "-element", "A"}, nil, nil},
+		{[]string{"-pattern", "Rec", "-if", "A", "-equals", "-match", "-element", "A"}, nil, nil},
+		{[]string{"-pattern", "Rec", "-Block", "B", "-element", "A"}, nil,
+			[]string{"Argument 3: Upper-case '-Block' exploration command is deprecated, use lower-case '-block' instead"}},
+		// positions refer to the original command line when known
+		{[]string{"-pattern", "Rec", "-Block", "B", "-element", "A"}, []int{4, 5, 6, 7, 8, 9},
+			[]string{"Argument 6: Upper-case '-Block' exploration command is deprecated, use lower-case '-block' instead"}},
+		{[]string{"-pattern", "Rec", "-match", "A:1", "-wrp", "Outer,Inner", "-element", "A"}, []int{3, 4, 7, 8, 9, 10, 11, 12},
+			[]string{"Argument 7: '-match A:1' is deprecated, use -if A -equals 1 instead",
+				"Argument 9: '-wrp Outer,Inner' is deprecated, use -enc Outer -wrp Inner instead"}},
+	}
+
+	for _, tt := range tests {
+		got := findDeprecated(tt.args, tt.posn)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestArithmeticVariables(t *testing.T) {
+
+	xml := "<INSDSeq><INSDFeature>" +
+		"<INSDInterval><INSDInterval_from>101</INSDInterval_from><INSDInterval_to>250</INSDInterval_to></INSDInterval>" +
+		"<INSDInterval><INSDInterval_from>400</INSDInterval_from><INSDInterval_to>412</INSDInterval_to></INSDInterval>" +
+		"</INSDFeature></INSDSeq>\n"
+
+	// interval lengths match the -sub difference plus one
+	sub := extractText(t, xml, "-pattern", "INSDSeq", "-block", "INSDInterval", "-def", "-", "-sub", "INSDInterval_to,INSDInterval_from")
+	if sub != "149\t12\n" {
+		t.Fatalf("-sub gives %q", sub)
+	}
+	dif := extractText(t, xml, "-pattern", "INSDSeq", "-block", "INSDInterval",
+		"-FR", "INSDInterval_from", "-TO", "INSDInterval_to", "-DIF", "(&TO - &FR)", "-def", "-", "-element", "&DIF")
+	if dif != sub {
+		t.Errorf("difference gives %q, -sub gives %q", dif, sub)
+	}
+	out := extractText(t, xml, "-pattern", "INSDSeq", "-block", "INSDInterval",
+		"-FR", "INSDInterval_from", "-TO", "INSDInterval_to", "-LEN", "(&TO - &FR + 1)", "-def", "-", "-element", "&LEN")
+	if out != "150\t13\n" {
+		t.Errorf("lengths give %q", out)
+	}
+
+	// precedence, integer division, and parenthesized literals
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-X", "(2 + 3 * 4)", "-element", "&X"}, "14\n"},
+		// a missing variable leaves the result unset
+		{[]string{"-TO", "INSDInterval_to", "-X", "(&TO - &FR + 1)", "-def", "-", "-element", "&X"}, "-\n"},
+		{[]string{"-X", "((2 + 3) * -4)", "-element", "&X"}, "-20\n"},
+		{[]string{"-X", "(7 / 2)", "-element", "&X"}, "3\n"},
+		{[]string{"-X", "(, )", "-element", "&X"}, ", \n"},
+		{[]string{"-X", "(2024-01-01)", "-element", "&X"}, "2024-01-01\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "INSDSeq"}, tt.args...)
+		if out := extractText(t, xml, args...); out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"(&T % 2)", "(7 % 2)", "(7 / 0)", "(&T -)"} {
+		if _, err := ParseArgumentsErr([]string{"-pattern", "Rec", "-X", expr, "-element", "&X"}, "Rec"); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
+
+func TestComparisonOperands(t *testing.T) {
+
+	xml := "<Set>" +
+		"<Rec><Id>1</Id><T>hi</T><A>abc</A><B>xyz</B><N>5</N>" +
+		"<Author><Name>X</Name><AffiliationInfo>U1</AffiliationInfo></Author>" +
+		"<Author><Name>Y</Name><AffiliationInfo>U2</AffiliationInfo></Author></Rec>" +
+		"<Rec><Id>2</Id><T>ho</T><A>abcd</A><B>xy</B><N>7</N>" +
+		"<Author><Name>X</Name><AffiliationInfo>U1</AffiliationInfo><AffiliationInfo>U3</AffiliationInfo></Author></Rec>" +
+		"</Set>\n"
+
+	tests := []struct {
+		cond []string
+		want string
+	}{
+		// counts of two different elements
+		{[]string{"#Author", "-eq", "#AffiliationInfo"}, "1\n"},
+		{[]string{"#Author", "-ne", "#AffiliationInfo"}, "2\n"},
+		{[]string{"#AffiliationInfo", "-gt", "#Author"}, "2\n"},
+		// lengths of two different elements
+		{[]string{"%A", "-eq", "%B"}, "1\n"},
+		{[]string{"%A", "-gt", "%B"}, "2\n"},
+		{[]string{"%A", "-is-equal-to", "%B"}, "1\n"},
+		// element against element, variable, and literal
+		{[]string{"N", "-gt", "%A"}, "1\n2\n"},
+		{[]string{"N", "-eq", "(5)"}, "1\n"},
+		{[]string{"N", "-ge", "\\-1"}, "1\n2\n"},
+		{[]string{"T", "-is-equal-to", "hi"}, "1\n"},
+		{[]string{"T", "-differs-from", "hi"}, "2\n"},
+		{[]string{"T", "-is-equal-to", "(ho)"}, "2\n"},
+		{[]string{"A", "-differs-from", "B"}, "1\n2\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec", "-if"}, tt.cond...)
+		args = append(args, "-element", "Id")
+		if out := extractText(t, xml, args...); out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.cond, out, tt.want)
+		}
+	}
+
+	// variables on the right side
+	out := extractText(t, xml, "-pattern", "Rec", "-LIM", "(6)", "-block", "*", "-if", "N", "-gt", "&LIM", "-element", "Id")
+	if out != "2\n" {
+		t.Errorf("variable operand gives %q", out)
+	}
+	out = extractText(t, xml, "-pattern", "Rec", "-CNT", "#Author", "-block", "*", "-if", "#AffiliationInfo", "-eq", "&CNT", "-element", "Id")
+	if out != "1\n" {
+		t.Errorf("count variable operand gives %q", out)
+	}
+}
+
+func TestAuthorKeyExtraction(t *testing.T) {
+
+	xml := `<Set>
+<Rec><PMID>1</PMID><Author>Smith J</Author><Author>J. A. Smith</Author><Author>Smith,J.A.</Author><Author>Jones B</Author></Rec>
+<Rec><PMID>2</PMID><Author>Hans Müller</Author><Author>Müller H</Author></Rec>
+</Set>
+`
+
+	out := extractText(t, xml, "-pattern", "Rec", "-element", "PMID", "-authkey", "Author")
+	want := "1\tsmith j\tjones b\n2\tmuller h\n"
+
+	if out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestReplaceRules(t *testing.T) {
+
+	xml := "<Set>\n<Rec><Acc>NM_000546.6</Acc><Name>abc</Name></Rec>\n</Set>\n"
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"two rules with backreferences",
+			[]string{"-reg", "^([A-Z]+)_", "-exp", "$1:", "-reg", "[.](?P<ver>[0-9]+)$", "-exp", " v${ver}", "-replace", "Acc"},
+			"NM:000546 v6\n"},
+		{"rules apply in sequence",
+			[]string{"-reg", "b", "-exp", "X", "-reg", "X", "-exp", "YY", "-replace", "Name"},
+			"aYYc\n"},
+		{"exp before reg",
+			[]string{"-exp", "X", "-reg", "a", "-replace", "Name"},
+			"Xbc\n"},
+		{"reg without exp does not reuse earlier exp",
+			[]string{"-reg", "b", "-exp", "X", "-replace", "Name", "-reg", "c", "-replace", "Name"},
+			"aXc\tab\n"},
+		{"exp alone reuses current reg",
+			[]string{"-reg", "b", "-exp", "X", "-replace", "Name", "-exp", "Y", "-replace", "Name"},
+			"aXc\taYc\n"},
+		{"rules kept for repeated replace",
+			[]string{"-reg", "b", "-exp", "X", "-replace", "Name", "-replace", "Name"},
+			"aXc\taXc\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := extractText(t, xml, append([]string{"-pattern", "Rec"}, tt.args...)...)
+			if out != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkReplaceRules(b *testing.B) {
+
+	rlock.Lock()
+	replx = nil
+	rlock.Unlock()
+
+	cmds, err := ParseArgumentsErr([]string{"-pattern", "Rec", "-reg", "^([A-Z]+)_", "-exp", "$1:", "-reg", "[.]([0-9]+)$", "-exp", " v$1", "-replace", "Acc"}, "Rec")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// distinct values must not add cache entries
+		rec := fmt.Sprintf("<Rec><Acc>NM_%06d.%d</Acc></Rec>", i, i%10)
+		if ProcessExtract(rec, "", i+1, "", "", nil, nil, nil, cmds) != fmt.Sprintf("NM:%06d v%d\n", i, i%10) {
+			b.Fatal("unexpected replacement")
+		}
+	}
+	b.StopTimer()
+
+	// each pattern compiles once, the cache only grows on a miss
+	rlock.Lock()
+	defer rlock.Unlock()
+	if len(replx) != 2 {
+		b.Errorf("expected 2 compiled patterns, found %d", len(replx))
+	}
+}
+
+// findReplace returns the compiled patterns attached to -reg commands
+func findReplace(blk *Block) []*regexp.Regexp {
+
+	var res []*regexp.Regexp
+
+	for _, op := range blk.Commands {
+		if op.Type == REG {
+			res = append(res, op.Regx)
+		}
+	}
+	for _, sub := range blk.Subtasks {
+		res = append(res, findReplace(sub)...)
+	}
+
+	return res
+}
+
+func TestReplaceCache(t *testing.T) {
+
+	rlock.Lock()
+	replx = nil
+	rlock.Unlock()
+
+	// three -replace clauses with two distinct patterns, applied to overlapping values
+	cmds, err := ParseArgumentsErr([]string{"-pattern", "Rec",
+		"-reg", "b", "-exp", "X", "-replace", "A",
+		"-reg", "c", "-exp", "Y", "-replace", "B",
+		"-reg", "b", "-exp", "Z", "-replace", "B"}, "Rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rgx := findReplace(cmds)
+	if len(rgx) != 3 || rgx[0] == nil || rgx[1] == nil || rgx[0] == rgx[1] || rgx[0] != rgx[2] {
+		t.Fatalf("expected patterns compiled at parse time and shared when identical, found %v", rgx)
+	}
+
+	for i := 0; i < 100; i++ {
+		val := fmt.Sprintf("abc%d", i)
+		rec := fmt.Sprintf("<Rec><A>%s</A><B>%s</B></Rec>", val, val)
+		want := fmt.Sprintf("aXc%d\tabY%d\taZc%d\n", i, i, i)
+		if got := ProcessExtract(rec, "", i+1, "", "", nil, nil, nil, cmds); got != want {
+			t.Fatalf("record %d: got %q, want %q", i+1, got, want)
+		}
+	}
+
+	// cache size follows the number of distinct patterns, not the number of distinct values
+	rlock.Lock()
+	defer rlock.Unlock()
+	if len(replx) != 2 {
+		t.Errorf("expected 2 cached patterns, found %d", len(replx))
+	}
+}
+
+func TestReplaceCacheBound(t *testing.T) {
+
+	rlock.Lock()
+	replx = nil
+	rlock.Unlock()
+
+	// patterns generated on the fly must not grow the cache without limit
+	for i := 0; i <= 2*maxReplaceCache; i++ {
+		reg := fmt.Sprintf("^p%d$", i)
+		re, err := replaceRegexp(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if re.String() != reg {
+			t.Fatalf("pattern %q returned %q", reg, re.String())
+		}
+	}
+
+	rlock.Lock()
+	size := len(replx)
+	rlock.Unlock()
+
+	if size == 0 || size > maxReplaceCache {
+		t.Errorf("expected at most %d cached patterns, found %d", maxReplaceCache, size)
+	}
+
+	// a repeated pattern is served from the cache
+	first, _ := replaceRegexp("^p0$")
+	second, _ := replaceRegexp("^p0$")
+	if first != second {
+		t.Error("repeated pattern was compiled again")
+	}
+}
+
+func TestStatistics(t *testing.T) {
+
+	// values returns a record with one <N> element per number
+	values := func(nums ...string) string {
+		var buf strings.Builder
+		buf.WriteString("<Set><Rec>")
+		for _, num := range nums {
+			buf.WriteString("<N>" + num + "</N>")
+		}
+		buf.WriteString("</Rec></Set>\n")
+		return buf.String()
+	}
+
+	classic := values("2", "4", "4", "4", "5", "5", "7", "9")
+	negative := values("-3", "-1", "2", "6")
+	fraction := values("-1.5", "2.5")
+	// large offset checks that the one-pass Welford update does not lose precision
+	offset := values("1000000004", "1000000007", "1000000013", "1000000016")
+
+	tests := []struct {
+		xml  string
+		args []string
+		want string
+	}{
+		{classic, []string{"-avg", "N", "-dev", "N", "-devp", "N", "-var", "N", "-varp", "N"}, "5\t2\t2\t4\t4\n"},
+		{negative, []string{"-avg", "N", "-dev", "N", "-devp", "N", "-var", "N", "-varp", "N"}, "1\t3\t3\t15\t11\n"},
+		{fraction, []string{"-avg", "N", "-dev", "N", "-devp", "N", "-var", "N", "-varp", "N"}, "0.50\t2.83\t2.00\t8.00\t4.00\n"},
+		{offset, []string{"-avg", "N", "-var", "N", "-varp", "N"}, "1000000010\t30\t22\n"},
+		// integer input is truncated when no -fmt is given
+		{values("4", "5"), []string{"-avg", "N"}, "4\n"},
+		{values("1", "2", "4", "10"), []string{"-avg", "N", "-dev", "N"}, "4\t4\n"},
+		{negative, []string{"-avg", "N"}, "1\n"},
+		{values("-4", "-5"), []string{"-avg", "N"}, "-4\n"},
+		// a decimal value switches to two decimal places
+		{values("1", "2", "4", "10.0"), []string{"-avg", "N", "-dev", "N"}, "4.25\t4.03\n"},
+		// -fmt replaces the default precision
+		{classic, []string{"-fmt", "%.3f", "-avg", "N", "-dev", "N", "-var", "N"}, "5.000\t2.138\t4.571\n"},
+		{negative, []string{"-fmt", "%.1f", "-avg", "N", "-devp", "N"}, "1.0\t3.4\n"},
+		// sample statistics need two values, population statistics need one
+		{values("-7"), []string{"-def", "-", "-dev", "N", "-var", "N", "-devp", "N", "-varp", "N"}, "-\t-\t0\t0\n"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-pattern", "Rec"}, tt.args...)
+		if out := extractText(t, tt.xml, args...); out != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestIntegerStatisticsUnchanged(t *testing.T) {
+
+	// expected lines were produced by the integer-only accumulators that predate floating-point support
+	tests := []struct {
+		nums string
+		want string
+	}{
+		{"2 4 4 4 5 5 7 9", "40\t2\t9\t5\t2\t5\t-\t-\t-\t-\t2,6,10,14,19,24,31,40\t3,5,5,5,6,6,8,10\t1,3,3,3,4,4,6,8\n"},
+		{"-3 -1 2 6", "4\t-3\t6\t1\t3\t2\t-\t-\t-\t-\t-3,-4,-2,4\t-2,0,3,7\t-4,-2,1,5\n"},
+		{"1 2 4 10", "17\t1\t10\t4\t4\t4\t-\t-\t-\t-\t1,3,7,17\t2,3,5,11\t0,1,3,9\n"},
+		{"-4 -5", "-9\t-5\t-4\t-4\t0\t-4\t1\t20\t0\t-4\t-4,-9\t-3,-4\t-5,-6\n"},
+		{"7 x 3", "10\t3\t7\t5\t2\t7\t4\t21\t2\t1\t7,10\t8,4\t6,2\n"},
+		{"17 5", "22\t5\t17\t11\t8\t17\t12\t85\t3\t2\t17,22\t18,6\t16,4\n"},
+		{"-17 5", "-12\t-17\t5\t-6\t15\t5\t-22\t-85\t-3\t-2\t-17,-12\t-16,6\t-18,4\n"},
+		{"7", "7\t7\t7\t7\t-\t7\t-\t-\t-\t-\t7\t8\t6\n"},
+	}
+
+	args := []string{"-pattern", "Rec", "-def", "-"}
+	for _, op := range []string{"-sum", "-min", "-max", "-avg", "-dev", "-med", "-sub", "-mul", "-div", "-mod"} {
+		args = append(args, op, "N")
+	}
+	args = append(args, "-sep", ",", "-acc", "N", "-inc", "N", "-dec", "N")
+
+	for _, tt := range tests {
+		xml := "<Set><Rec><N>" + strings.Join(strings.Fields(tt.nums), "</N><N>") + "</N></Rec></Set>\n"
+		if out := extractText(t, xml, args...); out != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.nums, out, tt.want)
+		}
+	}
+}
+
+func TestDateAge(t *testing.T) {
+
+	// frozen clock, as with xtract -today
+	if !SetAgeReference("2024/06/30") {
+		t.Fatal("reference date not recognized")
+	}
+	defer func() { ageToday = "" }()
+
+	xml := `<Set>
+<Rec><PMID>1</PMID><PubDate><Year>2024</Year><Month>Jun</Month><Day>01</Day></PubDate><Revised>2024/06/15</Revised></Rec>
+<Rec><PMID>2</PMID><PubDate><Year>2024</Year><Month>Mar</Month><Day>15</Day></PubDate></Rec>
+<Rec><PMID>3</PMID><PubDate><MedlineDate>2024 Apr-May</MedlineDate></PubDate></Rec>
+<Rec><PMID>4</PMID><PubDate><MedlineDate>Spring</MedlineDate></PubDate></Rec>
+<Rec><PMID>5</PMID><PubDate><Year>2020</Year></PubDate></Rec>
+</Set>
+`
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"numeric conditional", []string{"-if", "PubDate", "-age:days", "-le", "90", "-element", "PMID"},
+			"1\n3\n"},
+		{"days", []string{"-element", "PMID", "-def", "-", "-age", "PubDate"},
+			"1\t29\n2\t107\n3\t90\n4\t-\n5\t1642\n"},
+		{"years", []string{"-element", "PMID", "-def", "-", "-age:years", "PubDate"},
+			"1\t0.1\n2\t0.3\n3\t0.2\n4\t-\n5\t4.5\n"},
+		{"literal reference", []string{"-element", "PMID", "-def", "-", "-asof", "2025/06/01", "-age:years", "PubDate"},
+			"1\t1.0\n2\t1.2\n3\t1.2\n4\t-\n5\t5.4\n"},
+		{"element reference", []string{"-element", "PMID", "-def", "-", "-asof", "Revised", "-age", "PubDate"},
+			"1\t14\n2\t-\n3\t-\n4\t-\n5\t-\n"},
+		{"variable reference", []string{"-element", "PMID", "-VAR", "Revised", "-def", "-", "-asof", "&VAR", "-age", "PubDate"},
+			"1\t14\n2\t-\n3\t-\n4\t-\n5\t-\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := extractText(t, xml, append([]string{"-pattern", "Rec"}, tt.args...)...)
+			if out != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+		})
+	}
+
+	if SetAgeReference("someday") {
+		t.Error("unrecognized reference date was accepted")
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+
+	xml := `<Set>
+<Rec><PMID>1</PMID><PubDate><Year>2021</Year><Month>Mar</Month><Day>5</Day></PubDate></Rec>
+<Rec><PMID>2</PMID><PubDate><Year>2021</Year><Month>3</Month></PubDate></Rec>
+<Rec><PMID>3</PMID><PubDate><Year>2021</Year></PubDate></Rec>
+<Rec><PMID>4</PMID><PubDate><MedlineDate>2021 Mar-Apr</MedlineDate></PubDate></Rec>
+<Rec><PMID>5</PMID><PubDate><MedlineDate>2020 Dec-2021 Jan</MedlineDate></PubDate></Rec>
+<Rec><PMID>6</PMID><PubDate><MedlineDate>2020-2021</MedlineDate></PubDate></Rec>
+</Set>
+`
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", []string{"-date", "*"},
+			"1\t2021/03/05\n2\t2021/03\n3\t2021\n4\t2021/03\n5\t2020/12\n6\t2020\n"},
+		{"iso", []string{"-datefmt", "YYYY-MM-DD", "-date", "*"},
+			"1\t2021-03-05\n2\t2021-03\n3\t2021\n4\t2021-03\n5\t2020-12\n6\t2020\n"},
+		{"compact", []string{"-datefmt", "YYYYMMDD", "-date", "*"},
+			"1\t20210305\n2\t202103\n3\t2021\n4\t202103\n5\t202012\n6\t2020\n"},
+		{"year and month", []string{"-datefmt", "YYYYMM", "-date", "*"},
+			"1\t202103\n2\t202103\n3\t2021\n4\t202103\n5\t202012\n6\t2020\n"},
+		// trailing literal only follows a complete date
+		{"trailing literal", []string{"-datefmt", "YYYY-MM-DD;", "-date", "*"},
+			"1\t2021-03-05;\n2\t2021-03\n3\t2021\n4\t2021-03\n5\t2020-12\n6\t2020\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := extractText(t, xml, append([]string{"-pattern", "Rec", "-element", "PMID", "-unit", "PubDate"}, tt.args...)...)
+			if out != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+		})
+	}
+}